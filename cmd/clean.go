@@ -1,24 +1,51 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/dustin/go-humanize"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/docker"
 	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+	"github.com/yehia2amer/BuildBloatBuster/internal/notify"
 	"github.com/yehia2amer/BuildBloatBuster/internal/report"
 	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
-	"github.com/yehia2amer/BuildBloatBuster/internal/size"
+	"github.com/yehia2amer/BuildBloatBuster/internal/stats"
+	"github.com/yehia2amer/BuildBloatBuster/internal/tui"
+	"github.com/yehia2amer/BuildBloatBuster/internal/webhook"
 )
 
 var cleanCmd = &cobra.Command{
 	Use:   "clean [paths...]",
 	Short: "Clean up deletable folders",
-	Long:  `Scans for and deletes specified folders, with a confirmation prompt.`,
+	Long: `Scans for and deletes specified folders, with a confirmation prompt.
+
+A path that is itself a deletable folder, such as one printed by
+scan --format plain, is cleaned as it is, after the same safety checks and
+size calculation as folders found by scanning.
+
+With --stdin, or the path -, the directories to clean are read from stdin
+instead, one per line, or separated by NUL bytes with --null:
+
+  fd -t d -H '^.next$' ~/code | BuildBloatBuster clean --stdin
+  find ~/code -name target -type d -print0 | BuildBloatBuster clean --null -
+
+Each is checked and sized like a path given on the command line; those that
+don't exist or fail a safety check are listed and skipped.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := runClean(cmd, args); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -28,43 +55,220 @@ var cleanCmd = &cobra.Command{
 }
 
 func runClean(cmd *cobra.Command, paths []string) error {
-	if err := checkScanPaths(Cfg.ScanPaths); err != nil {
+	fromStdin, _ := cmd.Flags().GetBool("stdin")
+	if slices.Contains(paths, "-") {
+		if len(paths) > 1 {
+			return fmt.Errorf("the path - reads the directories to clean from stdin and cannot be combined with other paths")
+		}
+		fromStdin, paths = true, nil
+	}
+	null, _ := cmd.Flags().GetBool("null")
+	if null && !fromStdin {
+		return fmt.Errorf("--null only applies to paths read from stdin, with --stdin or the path -")
+	}
+	global, err := checkGlobalFlag(cmd, paths)
+	if err != nil {
 		return err
 	}
+	from, _ := cmd.Flags().GetString("from")
+	if from != "" && (global || len(paths) > 0) {
+		return fmt.Errorf("--from cleans the directories in a results file and cannot be combined with paths or --global")
+	}
+	if fromStdin && (from != "" || global || len(paths) > 0) {
+		return fmt.Errorf("--stdin cleans the directories read from stdin and cannot be combined with paths, --from or --global")
+	}
+
+	// The config flags come first so the safety check below sees a
+	// --quarantine-dir given on the command line
+	if err := applyConfigFlags(cmd); err != nil {
+		return err
+	}
+	// Override scan paths before the safety check so paths given on the
+	// command line are checked too
+	applyScanPathArgs(paths)
+	allowHome, _ := cmd.Flags().GetBool("allow-home")
+	if !global && from == "" && !fromStdin {
+		if err := prepareScanPaths(allowHome); err != nil {
+			return err
+		}
+	}
 	// This function is a modified version of runScan to allow for interaction.
 	// 1. Scan for candidates
-	format, _ := cmd.Flags().GetString("format")
-	Cfg.Output.Format = format
-	candidates, err := findCandidates(paths)
+	applyWebhookFlags(cmd)
+	if err := applyFormatFlag(cmd); err != nil {
+		return err
+	}
+	notAccessedSince, err := notAccessedSinceFlag(cmd)
+	if err != nil {
+		return err
+	}
+	freeTarget, err := freeTargetFlag(cmd)
+	if err != nil {
+		return err
+	}
+	if err := applyMaxDeleteFlag(cmd); err != nil {
+		return err
+	}
+	pruneDocker, _ := cmd.Flags().GetBool("docker-prune")
+	if pruneDocker && freeTarget > 0 {
+		return fmt.Errorf("--docker-prune can't be combined with --free, as Docker's storage may be on another filesystem")
+	}
+	// Global caches are shared by every project, so they are always
+	// quarantined and can be restored if something still needed them
+	if global && Cfg.Delete.Mode != "quarantine" {
+		fmt.Fprintln(os.Stderr, "Note: global caches are always quarantined, ignoring delete.mode "+Cfg.Delete.Mode)
+		Cfg.Delete.Mode = "quarantine"
+		CfgSources.Set("delete.mode", "flag --global")
+	}
+	var candidates []scan.Candidate
+	// Results loaded with --from were read by an earlier scan
+	readErrors = nil
+	switch {
+	case from != "":
+		candidates, err = loadResults(from)
+	case fromStdin:
+		candidates, err = stdinCandidates(cmd, stdin, null)
+	default:
+		candidates, err = findCandidates(cmd, paths, global)
+	}
 	if err != nil {
 		return err
 	}
+	if candidates, err = applyIDFlag(cmd, candidates); err != nil {
+		return err
+	}
+	candidates = skipInformational(candidates)
+	candidates = applyOnlyFlag(cmd, candidates)
+	if !pruneDocker {
+		candidates = skipDockerCandidates(candidates, Cfg.MachineReadable())
+	}
+	if notAccessedSince > 0 {
+		candidates = filterNotAccessedSince(candidates, notAccessedSince)
+	}
 
 	if len(candidates) == 0 {
 		fmt.Println("No directories found to clean.")
+		postReport(webhook.Report{Command: "clean", DryRun: dryRun, DeleteMode: Cfg.Delete.Mode}, nil)
 		return nil
 	}
 
-	isJSON := Cfg.Output.Format == "json"
+	isJSON := Cfg.HasOutputFormat("json")
+	machineReadable := Cfg.MachineReadable()
 
-	// 2. Report candidates to the user
-	reporter := report.NewReporter(Cfg.Output.Format, Cfg.Output.SortBy)
-	if err := reporter.Report(candidates); err != nil {
-		return fmt.Errorf("failed to generate report: %w", err)
+	// With --free, only the directories needed to reach the target are cleaned
+	var goal *freeSpaceGoal
+	if freeTarget > 0 {
+		if goal, err = newFreeSpaceGoal(candidates, freeTarget); err != nil {
+			return err
+		}
+		if goal.before >= goal.target {
+			fmt.Printf("%s is already free on the filesystem of %s, at least --free %s. Nothing to clean.\n",
+				bytesize.Format(goal.before), goal.path, bytesize.Format(goal.target))
+			return nil
+		}
+		candidates = goal.plan(candidates, machineReadable)
+		if len(candidates) == 0 {
+			fmt.Printf("No directories found to clean on the filesystem of %s.\n", goal.path)
+			return nil
+		}
+	}
+
+	yes := assumeYes(cmd)
+
+	// 2. Let the user pick candidates interactively, or report them all
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	interactive = interactive && !machineReadable
+	if interactive {
+		selected, ok, err := tui.SelectCandidates(candidates)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Operation cancelled.")
+			return nil
+		}
+		if len(selected) == 0 {
+			fmt.Println("No directories selected.")
+			return nil
+		}
+		candidates = selected
+		fmt.Printf("Selected %d directories (%s).\n", len(candidates), bytesize.Format(totalCandidateSize(candidates)))
+	} else {
+		sizeUnit, _ := Cfg.FixedSizeUnit()
+		reporter := report.NewReporter(Cfg.OutputFormats(), Cfg.Output.SortBy, sizeUnit)
+		chart, _ := cmd.Flags().GetBool("chart")
+		reporter.SetChart(chart)
+		noTruncate, _ := cmd.Flags().GetBool("no-truncate")
+		reporter.SetNoTruncate(noTruncate)
+		// JSON output can't prompt, so it records why nothing will be deleted
+		if refusal := deleteCapRefusal(candidates); refusal != nil && isJSON && !dryRun {
+			reporter.SetRefusal(refusal)
+		}
+		reporter.SetReadErrors(readErrors)
+		if err := reporter.Report(candidates); err != nil {
+			return fmt.Errorf("failed to generate report: %w", err)
+		}
+	}
+
+	// Optionally show what's inside each candidate before anything is deleted
+	preview, _ := cmd.Flags().GetBool("preview")
+	if preview && !machineReadable {
+		printPreviews(candidates)
 	}
 
 	// 3. Handle dry-run or prompt for confirmation
+	refusal := deleteCapRefusal(candidates)
 	if dryRun {
-		if !isJSON {
+		if refusal != nil {
+			fmt.Fprintf(os.Stderr, "Note: %s; a real run asks you to type a confirmation, and refuses with --yes or JSON output.\n", refusal.Reason)
+		}
+		if !machineReadable {
+			if Cfg.Delete.Mode == "quarantine" {
+				printQuarantineDestinations(candidates)
+			}
 			fmt.Println("\nDry run enabled. No files will be deleted.")
-			fmt.Println("Run with --dry-run=false to enable deletion.")
+			if yes && cmd.Flags().Changed("yes") {
+				fmt.Println("--yes only skips the confirmation prompt. Run with --apply (-D) to delete.")
+			} else {
+				fmt.Println("Run with --apply (-D) to delete.")
+			}
 		}
+		postReport(webhook.Report{Command: "clean", DryRun: true, DeleteMode: Cfg.Delete.Mode}, candidates)
 		return nil
 	}
 
-	// If not a dry run, prompt for confirmation unless --yes is passed or in JSON mode
-	yes, _ := cmd.Flags().GetBool("yes")
-	if !yes && !isJSON {
+	// A selection larger than the cap is never deleted unattended, not even
+	// with --yes; at a prompt it takes typing a phrase
+	confirmedOverCap := false
+	if refusal != nil {
+		if yes || isJSON {
+			return fmt.Errorf("%s; nothing was deleted. Narrow the selection, or raise delete.maxPerRunGB or --max-delete", refusal.Reason)
+		}
+		phrase := "delete " + bytesize.Format(totalCandidateSize(candidates))
+		confirmed, err := confirmOverCap(refusal.Reason, phrase)
+		if err != nil {
+			return fmt.Errorf("confirmation failed: %w", err)
+		}
+		if !confirmed {
+			fmt.Println("Confirmation phrase not entered. Operation cancelled.")
+			return nil
+		}
+		confirmedOverCap = true
+	}
+
+	// If not a dry run, prompt for confirmation unless --yes is passed, in JSON mode,
+	// or the user already confirmed a selection interactively or with the phrase
+	confirmEach, _ := cmd.Flags().GetBool("confirm-each")
+	if confirmEach && !isJSON {
+		candidates, err = confirmEachCandidate(candidates, promptCandidate)
+		if err != nil {
+			return fmt.Errorf("confirmation failed: %w", err)
+		}
+		if len(candidates) == 0 {
+			fmt.Println("No directories confirmed. Nothing to delete.")
+			return nil
+		}
+	} else if !yes && !isJSON && !interactive && !confirmedOverCap {
 		proceed, err := confirmDeletion(candidates)
 		if err != nil {
 			return fmt.Errorf("confirmation failed: %w", err)
@@ -75,51 +279,569 @@ func runClean(cmd *cobra.Command, paths []string) error {
 		}
 	}
 
-	// 4. Perform deletion
+	// Permanent deletion can't be undone, so it is confirmed once more
+	if Cfg.Delete.Mode == "rm" && !yes {
+		if isJSON {
+			return fmt.Errorf("delete mode rm deletes permanently; pass --yes to confirm it when the output is JSON")
+		}
+		proceed, err := confirmPermanentDeletion(candidates)
+		if err != nil {
+			return fmt.Errorf("confirmation failed: %w", err)
+		}
+		if !proceed {
+			fmt.Println("Operation cancelled.")
+			return nil
+		}
+	}
+
+	// 4. Perform deletion, leaving out directories a running process still
+	// uses. Docker's build cache is no directory, docker prunes it.
+	candidates, dockerCache := splitDockerCandidates(candidates)
+	candidates = skipOpenCandidates(cmd, candidates)
+	if len(candidates) == 0 && len(dockerCache) == 0 {
+		fmt.Println("No directories left to clean.")
+		return nil
+	}
 	eraser := erase.NewEraser(Cfg)
-	if err := eraser.EraseCandidates(candidates); err != nil {
-		return fmt.Errorf("failed during deletion: %w", err)
+	if goal != nil {
+		eraser.SetDone(func() bool { return goal.done(len(eraser.Removed())) })
 	}
+	if len(candidates) > 0 {
+		// The bar is drawn on stderr with the moved directories listed above
+		// it, so both have to be the terminal
+		var progress *mpb.Progress
+		if Cfg.Delete.Mode == "quarantine" && progressEnabled() && !machineReadable && isTerminal(os.Stdout) {
+			progress = newProgress()
+			eraser.SetProgress(progress)
+		}
+		err := eraser.EraseCandidates(candidates)
+		if progress != nil {
+			progress.Wait()
+		}
+		if err != nil {
+			return fmt.Errorf("failed during deletion: %w", err)
+		}
+	}
+	if goal != nil {
+		goal.report()
+	}
+	pruned := pruneDockerCache(dockerCache, machineReadable)
 
+	// The stats are only a record, so failing to update them is not an error
+	historyPath := stats.HistoryPath(Cfg.Delete.QuarantineDir)
+	if err := recordCleanStats(stats.DefaultPath(), historyPath, Cfg.Delete.Mode, eraser.Removed()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update stats: %v\n", err)
+	}
+
+	removed := slices.Concat(eraser.Removed(), pruned)
+	postReport(webhook.Report{Command: "clean", DeleteMode: Cfg.Delete.Mode}, removed)
+
+	// A notification is a courtesy, so one that can't be shown is only logged
+	if notifyDone, _ := cmd.Flags().GetBool("notify"); notifyDone {
+		if err := notify.Send(notify.Title, notify.CleanSummary(len(removed), totalCandidateSize(removed))); err != nil {
+			slog.Debug("desktop notification failed", "error", err)
+		}
+	}
+
+	if !machineReadable {
+		reportExpired(Cfg.Delete.QuarantineDir, Cfg.Delete.RetentionDays)
+	}
 	return nil
 }
 
-// findCandidates performs the scan and size calculation, returning the final list.
-func findCandidates(paths []string) ([]scan.Candidate, error) {
-	if len(paths) > 0 {
-		Cfg.ScanPaths = paths
+// skipDockerCandidates leaves out Docker's build cache, which clean only
+// prunes with --docker-prune, and says so
+func skipDockerCandidates(candidates []scan.Candidate, quietNote bool) []scan.Candidate {
+	kept, dockerCache := splitDockerCandidates(candidates)
+	if len(dockerCache) > 0 && !quietNote {
+		fmt.Fprintf(os.Stderr, "Note: leaving Docker's build cache (%s) alone; pass --docker-prune to clean it with docker builder prune.\n",
+			bytesize.Format(totalCandidateSize(dockerCache)))
 	}
+	return kept
+}
 
-	scanner := scan.NewScanner(Cfg)
-	candidates, err := scanner.ScanPaths()
+// skipInformational leaves out the candidates that were only reported, such
+// as the version control directories of results saved by scan --report-vcs
+func skipInformational(candidates []scan.Candidate) []scan.Candidate {
+	return slices.DeleteFunc(candidates, func(candidate scan.Candidate) bool {
+		return candidate.Informational
+	})
+}
+
+// splitDockerCandidates separates the candidates standing for Docker's build
+// cache from the directories
+func splitDockerCandidates(candidates []scan.Candidate) (dirs, dockerCache []scan.Candidate) {
+	for _, candidate := range candidates {
+		if docker.IsCandidate(candidate) {
+			dockerCache = append(dockerCache, candidate)
+		} else {
+			dirs = append(dirs, candidate)
+		}
+	}
+	return dirs, dockerCache
+}
+
+// pruneDockerCache runs docker builder prune for the Docker build cache
+// candidates and returns the ones it pruned. A failure is only warned about,
+// as the directories were cleaned regardless.
+func pruneDockerCache(dockerCache []scan.Candidate, machineReadable bool) []scan.Candidate {
+	if len(dockerCache) == 0 {
+		return nil
+	}
+	if err := pruneDockerBuildCache(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to prune Docker's build cache: %v\n", err)
+		return nil
+	}
+	if !machineReadable {
+		fmt.Printf("Pruned Docker's build cache (%s).\n", bytesize.Format(totalCandidateSize(dockerCache)))
+	}
+	return dockerCache
+}
+
+// pruneDockerBuildCache prunes Docker's build cache; tests replace it
+var pruneDockerBuildCache = docker.PruneBuildCache
+
+// reportExpired points out the quarantined items kept for longer than the
+// retention period, which purge --expired deletes
+func reportExpired(quarantineDir string, retentionDays int) {
+	items, err := listQuarantinedItems(quarantineDir)
 	if err != nil {
-		return nil, fmt.Errorf("scanning failed: %w", err)
+		return
+	}
+	expired := expiredItems(items, retentionDays, time.Now())
+	if len(expired) == 0 {
+		return
 	}
+	var total int64
+	for _, item := range expired {
+		total += item.SizeBytes
+	}
+	fmt.Printf("\n%d quarantined items (%s) are older than the %d-day retention period; run 'BuildBloatBuster purge --expired' to delete them.\n",
+		len(expired), bytesize.Format(total), retentionDays)
+}
+
+// freeSpace returns the free space on the filesystem holding a path; tests
+// replace it to simulate a filling disk
+var freeSpace = erase.FreeSpace
+
+// deviceOf returns the device of the filesystem holding a path; tests replace
+// it to simulate several disks
+var deviceOf = erase.DeviceID
+
+// freeTargetFlag parses --free, the free space clean --free cleans until; 0
+// when it isn't given
+func freeTargetFlag(cmd *cobra.Command) (int64, error) {
+	value, _ := cmd.Flags().GetString("free")
+	if value == "" {
+		return 0, nil
+	}
+	target, err := bytesize.ParseWithUnit(value, bytesize.MiB)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --free: %w", err)
+	}
+	if target <= 0 {
+		return 0, fmt.Errorf("--free must be greater than zero")
+	}
+	return target, nil
+}
+
+// freeSpaceGoal is the free space clean --free cleans until, on the
+// filesystem holding path
+type freeSpaceGoal struct {
+	path   string
+	target int64
+	// device is the device of that filesystem, if known
+	device    uint64
+	hasDevice bool
+	// before is the free space before anything was cleaned
+	before int64
+	// noGain is set when removing directories didn't free any space
+	noGain bool
+}
+
+// newFreeSpaceGoal measures the free space on the filesystem holding the
+// largest candidate, where most of the space will come from
+func newFreeSpaceGoal(candidates []scan.Candidate, target int64) (*freeSpaceGoal, error) {
+	largest := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.SizeBytes > largest.SizeBytes {
+			largest = candidate
+		}
+	}
+	path := filepath.Dir(largest.Path)
+	free, ok := freeSpace(path)
+	if !ok {
+		return nil, fmt.Errorf("--free: cannot determine the free space on the filesystem of %s", path)
+	}
+	goal := &freeSpaceGoal{path: path, target: target, before: int64(free)}
+	goal.device, goal.hasDevice = deviceOf(path)
+	return goal, nil
+}
 
+// plan picks the candidates to clean to reach the goal and says how much
+// space they are expected to free. Only candidates on the measured filesystem
+// count towards it; those on other devices are left alone with a warning.
+func (g *freeSpaceGoal) plan(candidates []scan.Candidate, quietPlan bool) []scan.Candidate {
+	candidates = g.sameDevice(candidates)
+	needed := g.target - g.before
+	planned := planFreeSpace(candidates, needed)
+	plannedSize := totalCandidateSize(planned)
+	if plannedSize < needed {
+		fmt.Fprintf(os.Stderr, "Warning: cleaning every directory found frees %s, short of the %s needed for --free %s\n",
+			bytesize.Format(plannedSize), bytesize.Format(needed), bytesize.Format(g.target))
+	}
+	if !quietPlan {
+		fmt.Printf("%s is free on the filesystem of %s; --free %s needs %s more.\n",
+			bytesize.Format(g.before), g.path, bytesize.Format(g.target), bytesize.Format(needed))
+		fmt.Printf("Cleaning the %d largest directories (%s) of %d found.\n\n", len(planned), bytesize.Format(plannedSize), len(candidates))
+	}
+	return planned
+}
+
+// sameDevice returns the candidates on the filesystem of the goal, warning
+// about the others. Candidates whose device is unknown are kept.
+func (g *freeSpaceGoal) sameDevice(candidates []scan.Candidate) []scan.Candidate {
+	if !g.hasDevice {
+		return candidates
+	}
+	var kept, skipped []scan.Candidate
+	for _, candidate := range candidates {
+		if device, ok := deviceOf(candidate.Path); ok && device != g.device {
+			skipped = append(skipped, candidate)
+			continue
+		}
+		kept = append(kept, candidate)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: --free leaves out %d directories (%s) on other filesystems than %s, as removing them frees no space there\n",
+			len(skipped), bytesize.Format(totalCandidateSize(skipped)), g.path)
+		if verbose {
+			for _, candidate := range skipped {
+				fmt.Fprintf(os.Stderr, "  %s\n", candidate.Path)
+			}
+		}
+	}
+	return kept
+}
+
+// done re-checks the free space before each directory is removed, as sizes
+// can be off and other processes write too, and reports whether to stop:
+// when the target is reached, or when moving directories to the quarantine
+// or trash on the same filesystem freed nothing
+func (g *freeSpaceGoal) done(removed int) bool {
+	free, ok := freeSpace(g.path)
+	if !ok {
+		return false
+	}
+	if int64(free) >= g.target {
+		return true
+	}
+	if removed > 0 && int64(free) <= g.before && Cfg.Delete.Mode != "rm" {
+		g.noGain = true
+		return true
+	}
+	return false
+}
+
+// report prints the free space before and after cleaning
+func (g *freeSpaceGoal) report() {
+	after, ok := freeSpace(g.path)
+	if !ok {
+		return
+	}
+	fmt.Printf("\nFree space on the filesystem of %s: %s before, %s after (target %s).\n",
+		g.path, bytesize.Format(g.before), bytesize.Format(int64(after)), bytesize.Format(g.target))
+	if g.noGain {
+		fmt.Fprintf(os.Stderr, "Warning: delete mode %s moves directories within the same filesystem, which frees no space; "+
+			"stopped early. Use --delete-mode rm, or a --quarantine-dir on another volume.\n", Cfg.Delete.Mode)
+	}
+}
+
+// planFreeSpace returns the candidates to clean to free needed bytes:
+// largest first, the oldest first among equally large ones, until their
+// sizes add up to needed
+func planFreeSpace(candidates []scan.Candidate, needed int64) []scan.Candidate {
+	sorted := slices.Clone(candidates)
+	slices.SortStableFunc(sorted, func(a, b scan.Candidate) int {
+		if c := cmp.Compare(b.SizeBytes, a.SizeBytes); c != 0 {
+			return c
+		}
+		return a.NewestMTime.Compare(b.NewestMTime)
+	})
+
+	var planned []scan.Candidate
+	var total int64
+	for _, candidate := range sorted {
+		if total >= needed {
+			break
+		}
+		planned = append(planned, candidate)
+		total += candidate.SizeBytes
+	}
+	return planned
+}
+
+// recordCleanStats adds the removed candidates to the lifetime stats at path
+// and appends the run, with every removed path, to the history at historyPath
+func recordCleanStats(path, historyPath, mode string, removed []scan.Candidate) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	s, err := stats.Record(path, len(removed), totalCandidateSize(removed))
+	if err != nil {
+		return err
+	}
+
+	run := stats.Run{
+		Timestamp:  s.LastRun,
+		Mode:       mode,
+		Deleted:    s.LastRunDeleted,
+		BytesFreed: s.LastRunBytesFreed,
+	}
+	for _, candidate := range removed {
+		run.Paths = append(run.Paths, stats.PathTotal{Path: candidate.Path, BytesFreed: candidate.SizeBytes, Cleans: 1})
+	}
+	return stats.AppendRun(historyPath, run)
+}
+
+// loadResults reads the candidates of a results file written by scan --save or
+// scan --format json. Directories that no longer exist are skipped, and a
+// protected path or the home directory fails the whole file.
+func loadResults(path string) ([]scan.Candidate, error) {
+	snapshot, err := report.LoadSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []scan.Candidate
+	for _, candidate := range snapshot.Candidates {
+		if _, err := os.Lstat(candidate.Path); err != nil {
+			fmt.Fprintf(os.Stderr, "Note: skipping %s from %s, it no longer exists\n", candidate.Path, path)
+			continue
+		}
+		absPath, err := filepath.Abs(candidate.Path)
+		if err != nil || config.IsWithinProtectedPath(absPath) || config.IsHomeDir(absPath) {
+			return nil, fmt.Errorf("for your safety, %s from %s will not be cleaned", candidate.Path, path)
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// stdin is where clean --stdin reads paths from; tests replace it
+var stdin io.Reader = os.Stdin
+
+// readPathList reads the paths in r, one per line, or separated by NUL bytes
+// with null, as find -print0 and scan --format plain0 write them. Empty
+// entries are ignored.
+func readPathList(r io.Reader, null bool) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sep := "\n"
+	if null {
+		sep = "\x00"
+	}
+	var paths []string
+	for _, entry := range strings.Split(string(data), sep) {
+		if !null {
+			entry = strings.TrimSuffix(entry, "\r")
+		}
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		paths = append(paths, entry)
+	}
+	return paths, nil
+}
+
+// stdinCandidates reads the directories to clean from r and sizes them.
+// Paths that fail the safety checks are listed and skipped, so one bad line
+// doesn't abort the whole batch.
+func stdinCandidates(cmd *cobra.Command, r io.Reader, null bool) ([]scan.Candidate, error) {
+	paths, err := readPathList(r, null)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paths from stdin: %w", err)
+	}
+
+	scanner := scan.NewScanner(Cfg)
+	seen := make(map[string]bool)
+	var candidates []scan.Candidate
+	for _, path := range paths {
+		absPath, err := checkListedPath(scanner, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s from stdin: %v\n", path, err)
+			continue
+		}
+		if seen[absPath] {
+			continue
+		}
+		seen[absPath] = true
+		candidates = append(candidates, scan.Candidate{Path: absPath, Reason: "listed on stdin"})
+	}
 	if len(candidates) == 0 {
 		return nil, nil
 	}
+	return sizeCandidates(cmd, candidates)
+}
+
+// checkListedPath returns the absolute path of a directory given to clean
+// by path, or why it may not be cleaned
+func checkListedPath(scanner *scan.Scanner, path string) (string, error) {
+	absPath, err := filepath.Abs(config.ExpandHome(path))
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(absPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("it does not exist")
+	case err != nil:
+		return "", err
+	case !info.IsDir():
+		return "", fmt.Errorf("it is not a directory")
+	case config.IsWithinProtectedPath(absPath) || config.IsHomeDir(absPath):
+		return "", fmt.Errorf("it is a protected path")
+	case !scanner.IsSafeToDelete(scan.Candidate{Path: absPath}):
+		return "", fmt.Errorf("it is version control, excluded, or a folder of a project that isn't in includeNames")
+	}
+	return absPath, nil
+}
+
+// applyIDFlag keeps the candidates named by --id, if it was given
+func applyIDFlag(cmd *cobra.Command, candidates []scan.Candidate) ([]scan.Candidate, error) {
+	ids, _ := cmd.Flags().GetStringSlice("id")
+	if len(ids) == 0 {
+		return candidates, nil
+	}
+	return scan.SelectByID(candidates, ids)
+}
 
-	calculator := size.NewCalculator(Cfg.Concurrency)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+// findCandidates performs the scan and size calculation, returning the final list.
+func findCandidates(cmd *cobra.Command, paths []string, global bool) ([]scan.Candidate, error) {
+	applyScanPathArgs(paths)
 
-	candidates, err = calculator.CalculateSizes(ctx, candidates)
+	candidates, err := collectCandidates(cmd, global)
 	if err != nil {
-		return nil, fmt.Errorf("size calculation failed: %w", err)
+		return nil, err
 	}
 
-	return size.FilterByMinSize(candidates, Cfg.MinSizeMB), nil
+	return filterReclaimable(cmd, candidates), nil
 }
 
-func confirmDeletion(candidates []scan.Candidate) (bool, error) {
+// printQuarantineDestinations shows, in a dry run, where each candidate would
+// be moved in the quarantine, named as a real run started now would name it
+func printQuarantineDestinations(candidates []scan.Candidate) {
+	dirs, _ := splitDockerCandidates(candidates)
+	if len(dirs) == 0 {
+		return
+	}
+	eraser := erase.NewEraser(Cfg)
+	now := time.Now()
+	fmt.Printf("\nWould move %d directories to quarantine (%s):\n", len(dirs), Cfg.Delete.QuarantineDir)
+	for _, candidate := range dirs {
+		destPath, _ := eraser.QuarantineDestination(candidate, now)
+		fmt.Printf(" - %s -> %s\n", candidate.Path, destPath)
+	}
+}
+
+// previewLimit is the number of entries shown per candidate with --preview
+const previewLimit = 10
+
+// printPreviews lists the largest immediate children of each candidate
+func printPreviews(candidates []scan.Candidate) {
+	for _, candidate := range candidates {
+		fmt.Printf("\n%s\n", candidate.Path)
+		entries, err := scan.PreviewDirectory(candidate.Path, previewLimit)
+		if err != nil {
+			fmt.Printf("  (could not read directory: %v)\n", err)
+			continue
+		}
+		if len(entries) == 0 {
+			fmt.Println("  (empty)")
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name
+			if entry.IsDir {
+				name += string(filepath.Separator)
+			}
+			fmt.Printf("  %10s  %s\n", bytesize.Format(entry.SizeBytes), name)
+		}
+	}
+}
+
+// totalCandidateSize sums up the size of all candidates
+func totalCandidateSize(candidates []scan.Candidate) int64 {
 	var totalSize int64
 	for _, c := range candidates {
 		totalSize += c.SizeBytes
 	}
-	totalSizeStr := humanize.Bytes(uint64(totalSize))
+	return totalSize
+}
+
+func confirmDeletion(candidates []scan.Candidate) (bool, error) {
+	totalSizeStr := bytesize.Format(totalCandidateSize(candidates))
+	return confirm(fmt.Sprintf("Delete %d directories and free %s of space?", len(candidates), totalSizeStr))
+}
+
+// applyMaxDeleteFlag sets delete.maxPerRunGB from --max-delete, a size such
+// as 50GB; a plain number is GB and 0 removes the cap
+func applyMaxDeleteFlag(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("max-delete") {
+		return nil
+	}
+	value, _ := cmd.Flags().GetString("max-delete")
+	maxBytes, err := bytesize.ParseWithUnit(value, bytesize.GB)
+	if err != nil {
+		return fmt.Errorf("invalid --max-delete: %w", err)
+	}
+	Cfg.Delete.MaxPerRunGB = float64(maxBytes) / float64(bytesize.GB)
+	CfgSources.Set("delete.maxPerRunGB", "flag --max-delete")
+	return nil
+}
+
+// deleteCapRefusal returns why candidates may not be deleted without an
+// explicit confirmation: their total size exceeds delete.maxPerRunGB. It
+// returns nil when they are within the cap or there is none.
+func deleteCapRefusal(candidates []scan.Candidate) *report.Refusal {
+	maxBytes := Cfg.MaxDeleteBytes()
+	total := totalCandidateSize(candidates)
+	if maxBytes <= 0 || total <= maxBytes {
+		return nil
+	}
+	return &report.Refusal{
+		Code: "max-delete",
+		Reason: fmt.Sprintf("the %d directories selected use %s, more than the %s a run may delete",
+			len(candidates), bytesize.Format(total), bytesize.Format(maxBytes)),
+	}
+}
+
+// confirmOverCap asks the user to type phrase to delete a selection larger
+// than delete.maxPerRunGB. It is a variable so tests can stub out the prompt.
+var confirmOverCap = func(reason, phrase string) (bool, error) {
+	prompt := promptui.Prompt{Label: fmt.Sprintf("Warning: %s. Type %q to continue", reason, phrase)}
+	answer, err := prompt.Run()
+	if err == promptui.ErrInterrupt || err == promptui.ErrAbort {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(answer) == phrase, nil
+}
+
+// confirmPermanentDeletion asks again before candidates are deleted with
+// delete mode rm. It is a variable so tests can stub out the prompt.
+var confirmPermanentDeletion = func(candidates []scan.Candidate) (bool, error) {
+	return confirm(fmt.Sprintf("Delete mode is rm: the %d directories are deleted permanently and can't be restored. Continue?", len(candidates)))
+}
+
+// confirm asks a yes/no question that defaults to no
+func confirm(label string) (bool, error) {
 	prompt := promptui.Prompt{
-		Label:     fmt.Sprintf("Delete %d directories and free %s of space?", len(candidates), totalSizeStr),
+		Label:     label,
 		IsConfirm: true,
 		Default:   "n",
 	}
@@ -136,14 +858,105 @@ func confirmDeletion(candidates []scan.Candidate) (bool, error) {
 	return true, nil // User confirmed
 }
 
+// Answers for per-item confirmation
+const (
+	answerYes  = "yes"
+	answerNo   = "no"
+	answerQuit = "quit"
+)
+
+// promptCandidate asks whether a single candidate should be deleted.
+// It is a variable so tests can stub out the interactive prompt.
+var promptCandidate = func(candidate scan.Candidate) (string, error) {
+	answers := []string{answerYes, answerNo, answerQuit}
+	prompt := promptui.Select{
+		Label: fmt.Sprintf("Delete %s (%s)?", candidate.Path, bytesize.Format(candidate.SizeBytes)),
+		Items: answers,
+	}
+
+	idx, _, err := prompt.Run()
+	if err != nil {
+		if err == promptui.ErrInterrupt || err == promptui.ErrAbort {
+			return answerQuit, nil
+		}
+		return "", err
+	}
+	return answers[idx], nil
+}
+
+// confirmEachCandidate prompts for every candidate and returns the confirmed ones.
+// Answering "quit" stops prompting but keeps the items confirmed so far.
+func confirmEachCandidate(candidates []scan.Candidate, ask func(scan.Candidate) (string, error)) ([]scan.Candidate, error) {
+	var confirmed []scan.Candidate
+	for _, candidate := range candidates {
+		answer, err := ask(candidate)
+		if err != nil {
+			return nil, err
+		}
+		switch answer {
+		case answerYes:
+			confirmed = append(confirmed, candidate)
+		case answerQuit:
+			return confirmed, nil
+		}
+	}
+	return confirmed, nil
+}
+
 func init() {
 	rootCmd.AddCommand(cleanCmd)
 
 	// Add flags from scan command to clean command
-	cleanCmd.Flags().IntP("min-size", "s", 0, "minimum size in MB (overrides config)")
+	cleanCmd.Flags().StringP("min-size", "s", "", "minimum size, e.g. 500MB or 2GiB; a plain number is MiB (overrides config)")
+	cleanCmd.Flags().Int64("min-files", 0, "minimum number of files inside, e.g. 100000; applies together with --min-size")
 	cleanCmd.Flags().IntP("max-depth", "d", 0, "maximum directory depth (overrides config)")
+	cleanCmd.Flags().Int("max-results", 0, "stop scanning after this many directories were found, 0 = unlimited (overrides config)")
 	cleanCmd.Flags().StringSliceP("include", "i", nil, "additional patterns to include")
 	cleanCmd.Flags().StringSliceP("exclude", "e", nil, "additional patterns to exclude")
-	cleanCmd.Flags().BoolP("yes", "y", false, "skip confirmation prompt and proceed with deletion")
-	cleanCmd.Flags().String("format", "table", "output format (table, json, csv)")
+	cleanCmd.Flags().StringSlice("exclude-glob", nil, "exclude directories whose name matches these patterns, e.g. '*.egg-info'")
+	cleanCmd.Flags().StringSlice("include-from", nil, "add the patterns in these files, one per line, to the include names")
+	cleanCmd.Flags().StringSlice("exclude-from", nil, "add the patterns in these files, one per line, to the exclusions")
+	cleanCmd.Flags().StringSlice("id", nil, "only clean the directories with these IDs from the report, e.g. a1b2c3d4,deadbeef")
+	cleanCmd.Flags().Bool("stdin", false, "clean the directories read from stdin, one per line, instead of scanning; the path - does the same")
+	cleanCmd.Flags().Bool("null", false, "with --stdin, the paths are separated by NUL bytes, as find -print0 and --format plain0 write them")
+	cleanCmd.Flags().String("from", "", "clean the directories in this results file (from scan --save or --format json) instead of scanning")
+	cleanCmd.Flags().StringSlice("only", nil, "only report directories that matched these include patterns, e.g. node_modules,target")
+	cleanCmd.Flags().String("not-accessed-since", "", "only clean directories whose files were not read or written within this age, e.g. 30d")
+	cleanCmd.Flags().StringSlice("profile", nil, "built-in profiles to enable, e.g. node,python (overrides config)")
+	cleanCmd.Flags().StringSlice("lang", nil, "scan only for these ecosystems' directories, e.g. node,rust, instead of the include list (see profiles list)")
+	cleanCmd.MarkFlagsMutuallyExclusive("lang", "profile")
+	cleanCmd.Flags().StringSlice("presets", nil, "built-in presets of directories outside any project to report too, e.g. mobile (overrides config)")
+	cleanCmd.Flags().BoolP("yes", "y", false, "skip the confirmation prompt; deleting also needs --apply (default from behavior.requireConfirmation)")
+	cleanCmd.Flags().Bool("docker-prune", false, "with the docker preset, clean Docker's build cache with docker builder prune, which can't be undone")
+	cleanCmd.Flags().String("max-delete", "", "refuse, or ask for a typed confirmation, when the selection is larger than this, e.g. 50GB; a plain number is GB (overrides config)")
+	cleanCmd.Flags().String("free", "", "only clean the largest directories needed until this much space is free, e.g. 20GB; a plain number is MiB")
+	cleanCmd.Flags().Bool("notify", false, "show a desktop notification with the space freed when the clean finishes")
+	cleanCmd.Flags().Bool("preview", false, "list the largest entries inside each directory before confirming")
+	cleanCmd.Flags().Bool("confirm-each", false, "confirm each directory individually before deleting it")
+	cleanCmd.Flags().Bool("interactive", false, "choose individual directories to clean in an interactive list")
+	cleanCmd.Flags().String("format", "table", "output format (table, json, csv, tsv, plain, plain0), or several separated by commas, e.g. json,csv")
+	cleanCmd.Flags().String("sort", "", "sort results by size, path or age (overrides config)")
+	cleanCmd.Flags().String("size-unit", "", "show size columns as plain numbers in this unit, e.g. MB or GiB (overrides config)")
+	cleanCmd.Flags().Bool("chart", false, "draw a bar next to every directory in the table, scaled to the largest")
+	cleanCmd.Flags().String("report-url", "", "POST the JSON summary of what was cleaned, with the hostname and a timestamp, to this URL when the clean completes (overrides config)")
+	cleanCmd.Flags().StringArray("report-url-header", nil, "header to send with --report-url, e.g. 'Authorization: Bearer $TOKEN'; repeatable")
+	cleanCmd.Flags().Bool("report-url-insecure", false, "don't verify the TLS certificate of --report-url, for self-signed certificates (overrides config)")
+	cleanCmd.Flags().Bool("no-truncate", false, "show paths and reasons in full instead of fitting the table to the terminal")
+	cleanCmd.Flags().Bool("allow-home", false, "allow scanning your entire home directory")
+	cleanCmd.Flags().Bool("include-network-fs", false, "descend into network filesystems (NFS, SMB, ...) below the scan paths")
+	cleanCmd.Flags().Int("concurrency", 0, "number of size calculation workers (default: tuned to the storage type)")
+	cleanCmd.Flags().Bool("no-cache", false, "recompute every size instead of reusing cached sizes")
+	cleanCmd.Flags().Bool("strict", false, "fail without cleaning anything if any directory can't be read while scanning or sizing")
+	cleanCmd.Flags().Bool("show-errors", false, "list the paths that couldn't be read while scanning or sizing, instead of only counting them")
+	cleanCmd.Flags().Bool("no-open-file-check", false, "don't skip directories that running processes have files open in, which saves time on long lists")
+	cleanCmd.Flags().Bool("force-recent", false, "also clean directories modified within skipIfModifiedWithin, which a build may still be using")
+	cleanCmd.Flags().String("delete-mode", "", "quarantine, trash or rm (permanent, asks again unless --yes) (overrides config)")
+	cleanCmd.Flags().String("quarantine-dir", "", "directory to move quarantined directories to (overrides config)")
+	cleanCmd.Flags().Int("retention-days", 0, "days to keep quarantined items before they are reported as expired (overrides config)")
+	cleanCmd.Flags().String("audit-log", "", "append a JSON line for every removed directory to this file (overrides config)")
+	cleanCmd.Flags().Bool("global", false, "clean well-known global caches (gradle, maven, pip, npm, ...) instead of paths; always quarantined")
+	cleanCmd.Flags().Duration("timeout", 0, "overall time limit for scanning and size calculation, e.g. 10m (overrides config)")
+	registerScanCompletions(cleanCmd)
+	cleanCmd.RegisterFlagCompletionFunc("delete-mode", cobra.FixedCompletions(config.ValidDeleteModes, cobra.ShellCompDirectiveNoFileComp))
+	cleanCmd.MarkFlagDirname("quarantine-dir")
 }
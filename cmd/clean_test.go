@@ -0,0 +1,564 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+	"github.com/yehia2amer/BuildBloatBuster/internal/stats"
+)
+
+func TestConfirmEachCandidate(t *testing.T) {
+	tmpDir := t.TempDir()
+	quarantineDir := filepath.Join(tmpDir, "quarantine")
+
+	var candidates []scan.Candidate
+	for _, name := range []string{"node_modules", "target", ".venv", "dist"} {
+		path := filepath.Join(tmpDir, "project", name)
+		require.NoError(t, os.MkdirAll(path, 0755))
+		candidates = append(candidates, scan.Candidate{Path: path, SizeBytes: 1024})
+	}
+
+	// yes, no, yes, then quit before the last item
+	answers := map[string]string{
+		candidates[0].Path: answerYes,
+		candidates[1].Path: answerNo,
+		candidates[2].Path: answerYes,
+		candidates[3].Path: answerQuit,
+	}
+	stub := func(c scan.Candidate) (string, error) {
+		return answers[c.Path], nil
+	}
+
+	confirmed, err := confirmEachCandidate(candidates, stub)
+	require.NoError(t, err)
+	require.Equal(t, []scan.Candidate{candidates[0], candidates[2]}, confirmed)
+
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Delete.QuarantineDir = quarantineDir
+	require.NoError(t, erase.NewEraser(cfg).EraseCandidates(confirmed))
+
+	for i, c := range candidates {
+		_, err := os.Stat(c.Path)
+		if i == 0 || i == 2 {
+			assert.True(t, os.IsNotExist(err), "confirmed item %s should be quarantined", c.Path)
+		} else {
+			assert.NoError(t, err, "unconfirmed item %s should be left alone", c.Path)
+		}
+	}
+}
+
+func TestRecordCleanStats_Accumulates(t *testing.T) {
+	tmpDir := t.TempDir()
+	statsPath := filepath.Join(tmpDir, "stats.json")
+	historyPath := filepath.Join(tmpDir, "history.jsonl")
+
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Delete.QuarantineDir = filepath.Join(tmpDir, "quarantine")
+
+	clean := func(names ...string) {
+		var candidates []scan.Candidate
+		for _, name := range names {
+			path := filepath.Join(tmpDir, "project", name)
+			require.NoError(t, os.MkdirAll(path, 0755))
+			candidates = append(candidates, scan.Candidate{Path: path, SizeBytes: 1000})
+		}
+		// A candidate that no longer exists can't be removed and must not be counted
+		candidates = append(candidates, scan.Candidate{Path: filepath.Join(tmpDir, "gone"), SizeBytes: 5000})
+
+		eraser := erase.NewEraser(cfg)
+		require.NoError(t, eraser.EraseCandidates(candidates))
+		require.NoError(t, recordCleanStats(statsPath, historyPath, cfg.Delete.Mode, eraser.Removed()))
+	}
+
+	clean("node_modules", "target")
+	clean(".venv")
+
+	s, err := stats.Load(statsPath)
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Runs)
+	assert.Equal(t, 3, s.TotalDeleted)
+	assert.Equal(t, int64(3000), s.TotalBytesFreed)
+	assert.Equal(t, 1, s.LastRunDeleted)
+
+	runs, err := stats.LoadHistory(historyPath)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "quarantine", runs[0].Mode)
+	assert.Equal(t, 2, runs[0].Deleted)
+	assert.Equal(t, int64(2000), runs[0].BytesFreed)
+	assert.Len(t, runs[0].Paths, 2)
+	assert.Equal(t, filepath.Join(tmpDir, "project", ".venv"), runs[1].Paths[0].Path)
+}
+
+func TestClean_DeleteModeRm(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	oldCfg, oldSources, oldDryRun, oldConfirm := Cfg, CfgSources, dryRun, confirmPermanentDeletion
+	setFlags := map[string]string{"delete-mode": "rm", "yes": "true", "min-size": "0", "no-cache": "true"}
+	t.Cleanup(func() {
+		Cfg, CfgSources, dryRun, confirmPermanentDeletion = oldCfg, oldSources, oldDryRun, oldConfirm
+		for name := range setFlags {
+			flag := cleanCmd.Flags().Lookup(name)
+			require.NoError(t, flag.Value.Set(flag.DefValue))
+			flag.Changed = false
+		}
+	})
+
+	Cfg = config.GetDefaults()
+	Cfg.ExcludePaths = nil
+	Cfg.Delete.AuditLog = ""
+	// The project is created just now, which would make it look in use
+	Cfg.SkipIfModifiedWithin = "0"
+	CfgSources = config.Provenance{}
+	dryRun = false
+	for name, value := range setFlags {
+		require.NoError(t, cleanCmd.Flags().Set(name, value))
+	}
+
+	project := filepath.Join(t.TempDir(), "app")
+	target := filepath.Join(project, "node_modules")
+	require.NoError(t, os.MkdirAll(target, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(target, "index.js"), []byte("module.exports = 1\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "package.json"), []byte("{}"), 0644))
+
+	// --yes stands in for the extra confirmation rm asks for
+	confirmPermanentDeletion = func([]scan.Candidate) (bool, error) {
+		t.Fatal("rm asked for confirmation despite --yes")
+		return false, nil
+	}
+	require.NoError(t, runClean(cleanCmd, []string{project}))
+
+	assert.Equal(t, "rm", Cfg.Delete.Mode)
+	assert.Equal(t, "flag --delete-mode", CfgSources.Source("delete.mode"))
+	assert.NoDirExists(t, target, "node_modules should be deleted")
+	assert.FileExists(t, filepath.Join(project, "package.json"))
+	assert.NoDirExists(t, Cfg.Delete.QuarantineDir, "nothing may be quarantined")
+}
+
+func TestClean_RejectsScanPathInQuarantineDirFlag(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	oldCfg, oldSources := Cfg, CfgSources
+	t.Cleanup(func() {
+		Cfg, CfgSources = oldCfg, oldSources
+		flag := cleanCmd.Flags().Lookup("quarantine-dir")
+		require.NoError(t, flag.Value.Set(flag.DefValue))
+		flag.Changed = false
+	})
+
+	Cfg = config.GetDefaults()
+	Cfg.ExcludePaths = nil
+	CfgSources = config.Provenance{}
+
+	quarantineDir := filepath.Join(t.TempDir(), "q")
+	project := filepath.Join(quarantineDir, "proj")
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "node_modules"), 0755))
+	require.NoError(t, cleanCmd.Flags().Set("quarantine-dir", quarantineDir))
+
+	// The quarantine directory is only known from the flag, not the config
+	err := runClean(cleanCmd, []string{project})
+	assert.ErrorContains(t, err, "inside the quarantine directory")
+	assert.DirExists(t, filepath.Join(project, "node_modules"))
+}
+
+func TestFindCandidates_SkipsRecentlyModified(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	oldCfg, oldSources := Cfg, CfgSources
+	t.Cleanup(func() {
+		Cfg, CfgSources = oldCfg, oldSources
+		flag := cleanCmd.Flags().Lookup("force-recent")
+		require.NoError(t, flag.Value.Set(flag.DefValue))
+		flag.Changed = false
+	})
+	Cfg = config.GetDefaults()
+	Cfg.ExcludePaths = nil
+	Cfg.MinSize = "0"
+	CfgSources = config.Provenance{}
+
+	// A candidate that is old throughout, except for one file deep inside
+	// that a running build just wrote
+	project := filepath.Join(t.TempDir(), "app")
+	target := filepath.Join(project, "node_modules")
+	require.NoError(t, os.MkdirAll(filepath.Join(target, ".cache", "build"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "package.json"), []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(target, "index.js"), []byte("module.exports = 1\n"), 0644))
+	old := time.Now().Add(-48 * time.Hour)
+	for _, path := range []string{filepath.Join(target, "index.js"), filepath.Join(target, ".cache", "build"), filepath.Join(target, ".cache"), target} {
+		require.NoError(t, os.Chtimes(path, old, old))
+	}
+	fresh := filepath.Join(target, ".cache", "build", "chunk.js")
+	require.NoError(t, os.WriteFile(fresh, []byte("x"), 0644))
+	// Writing the file touched its directory, so backdate that again
+	require.NoError(t, os.Chtimes(filepath.Join(target, ".cache", "build"), old, old))
+
+	candidates, err := findCandidates(cleanCmd, []string{project}, false)
+	require.NoError(t, err)
+	assert.Empty(t, candidates, "a candidate with a freshly written file may be in use")
+
+	// Outside the window the candidate is cleaned as usual
+	Cfg.SkipIfModifiedWithin = "1m"
+	require.NoError(t, os.Chtimes(fresh, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+	candidates, err = findCandidates(cleanCmd, []string{project}, false)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, target, candidates[0].Path)
+
+	// --force-recent includes it regardless
+	Cfg.SkipIfModifiedWithin = "2h"
+	candidates, err = findCandidates(cleanCmd, []string{project}, false)
+	require.NoError(t, err)
+	assert.Empty(t, candidates)
+	require.NoError(t, cleanCmd.Flags().Set("force-recent", "true"))
+	candidates, err = findCandidates(cleanCmd, []string{project}, false)
+	require.NoError(t, err)
+	assert.Len(t, candidates, 1)
+}
+
+func TestFindCandidates_Strict(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any directory")
+	}
+	t.Setenv("HOME", t.TempDir())
+	oldCfg, oldSources := Cfg, CfgSources
+	t.Cleanup(func() {
+		Cfg, CfgSources = oldCfg, oldSources
+		flag := cleanCmd.Flags().Lookup("strict")
+		require.NoError(t, flag.Value.Set(flag.DefValue))
+		flag.Changed = false
+	})
+	Cfg = config.GetDefaults()
+	Cfg.ExcludePaths = nil
+	Cfg.MinSize = "0"
+	Cfg.SkipIfModifiedWithin = "0"
+	CfgSources = config.Provenance{}
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "app", "node_modules"), 0755))
+	locked := filepath.Join(root, "locked")
+	require.NoError(t, os.Mkdir(locked, 0755))
+	require.NoError(t, os.Chmod(locked, 0))
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	// By default the unreadable directory is skipped
+	candidates, err := findCandidates(cleanCmd, []string{root}, false)
+	require.NoError(t, err)
+	assert.Len(t, candidates, 1)
+	require.Len(t, readErrors, 1)
+	assert.Equal(t, locked, readErrors[0].Path)
+
+	require.NoError(t, cleanCmd.Flags().Set("strict", "true"))
+	_, err = findCandidates(cleanCmd, []string{root}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--strict: 1 paths could not be read")
+	assert.Contains(t, err.Error(), locked)
+}
+
+func TestReportVCS_NotCleaned(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	oldCfg, oldSources := Cfg, CfgSources
+	t.Cleanup(func() {
+		Cfg, CfgSources = oldCfg, oldSources
+		flag := scanCmd.Flags().Lookup("report-vcs")
+		require.NoError(t, flag.Value.Set(flag.DefValue))
+		flag.Changed = false
+	})
+	// The default minSize and skipIfModifiedWithin apply only to what can be cleaned
+	Cfg = config.GetDefaults()
+	Cfg.ExcludePaths = nil
+	CfgSources = config.Provenance{}
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "app", "node_modules"), 0755))
+	gitDir := filepath.Join(root, "app", ".git")
+	require.NoError(t, os.MkdirAll(filepath.Join(gitDir, "objects"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "objects", "pack"), make([]byte, 4096), 0644))
+	paths := func(candidates []scan.Candidate) []string {
+		var paths []string
+		for _, candidate := range candidates {
+			paths = append(paths, candidate.Path)
+		}
+		return paths
+	}
+
+	// scan --report-vcs reports the .git directory with its size
+	require.NoError(t, scanCmd.Flags().Set("report-vcs", "true"))
+	applyScanPathArgs([]string{root})
+	reported, err := collectCandidates(scanCmd, false)
+	require.NoError(t, err)
+	require.Contains(t, paths(reported), gitDir)
+	for _, candidate := range reported {
+		if candidate.Path == gitDir {
+			assert.Equal(t, scan.ReasonVCS, candidate.Reason)
+			assert.Equal(t, int64(4096), candidate.SizeBytes)
+		}
+	}
+
+	// The small, just modified .git survives the filters the node_modules next
+	// to it doesn't
+	assert.Equal(t, []string{gitDir}, paths(filterReclaimable(scanCmd, reported)))
+
+	// clean never selects it: not when scanning, nor from saved results
+	Cfg.MinSize = "0"
+	Cfg.SkipIfModifiedWithin = "0"
+	candidates, err := findCandidates(cleanCmd, []string{root}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "app", "node_modules")}, paths(candidates))
+	assert.NotContains(t, paths(skipInformational(reported)), gitDir)
+}
+
+func TestPlanFreeSpace(t *testing.T) {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	candidates := []scan.Candidate{
+		{Path: "/p/small", SizeBytes: 1 << 20, NewestMTime: old},
+		{Path: "/p/big-recent", SizeBytes: 8 << 20, NewestMTime: recent},
+		{Path: "/p/big-old", SizeBytes: 8 << 20, NewestMTime: old},
+		{Path: "/p/medium", SizeBytes: 4 << 20, NewestMTime: old},
+	}
+	paths := func(candidates []scan.Candidate) []string {
+		var paths []string
+		for _, candidate := range candidates {
+			paths = append(paths, candidate.Path)
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name   string
+		needed int64
+		want   []string
+	}{
+		{"one is enough, oldest of the largest", 5 << 20, []string{"/p/big-old"}},
+		{"largest first", 17 << 20, []string{"/p/big-old", "/p/big-recent", "/p/medium"}},
+		{"not enough", 100 << 20, []string{"/p/big-old", "/p/big-recent", "/p/medium", "/p/small"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, paths(planFreeSpace(candidates, tt.needed)))
+		})
+	}
+}
+
+func TestFreeSpaceGoal_PlanSameDevice(t *testing.T) {
+	oldFreeSpace, oldDeviceOf := freeSpace, deviceOf
+	t.Cleanup(func() { freeSpace, deviceOf = oldFreeSpace, oldDeviceOf })
+
+	// /disk1 and /disk2 are separate filesystems
+	freeSpace = func(path string) (uint64, bool) { return 1 << 30, true }
+	deviceOf = func(path string) (uint64, bool) {
+		switch {
+		case strings.HasPrefix(path, "/disk1/"):
+			return 1, true
+		case strings.HasPrefix(path, "/disk2/"):
+			return 2, true
+		}
+		return 0, false
+	}
+	candidates := []scan.Candidate{
+		{Path: "/disk1/app/node_modules", SizeBytes: 8 << 30},
+		{Path: "/disk2/big/target", SizeBytes: 4 << 30},
+		{Path: "/disk2/huge/target", SizeBytes: 2 << 30},
+		{Path: "/disk1/lib/target", SizeBytes: 1 << 30},
+	}
+	goal, err := newFreeSpaceGoal(candidates, 20<<30)
+	require.NoError(t, err)
+	assert.Equal(t, "/disk1/app", goal.path)
+
+	// Freeing space on disk2 wouldn't bring disk1 closer to the target
+	var planned []string
+	for _, candidate := range goal.plan(candidates, true) {
+		planned = append(planned, candidate.Path)
+	}
+	assert.Equal(t, []string{"/disk1/app/node_modules", "/disk1/lib/target"}, planned)
+
+	// Without a device to compare with, nothing is left out
+	deviceOf = func(path string) (uint64, bool) { return 0, false }
+	goal, err = newFreeSpaceGoal(candidates, 20<<30)
+	require.NoError(t, err)
+	assert.Len(t, goal.plan(candidates, true), 4)
+}
+
+func TestFreeSpaceGoal_Done(t *testing.T) {
+	oldCfg, originalFreeSpace := Cfg, freeSpace
+	t.Cleanup(func() { Cfg, freeSpace = oldCfg, originalFreeSpace })
+	Cfg = config.GetDefaults()
+
+	var free uint64 = 5 << 30
+	freeSpace = func(path string) (uint64, bool) { return free, true }
+	goal, err := newFreeSpaceGoal([]scan.Candidate{{Path: "/p/app/node_modules", SizeBytes: 1 << 30}}, 20<<30)
+	require.NoError(t, err)
+	assert.Equal(t, "/p/app", goal.path)
+	assert.Equal(t, int64(5<<30), goal.before)
+
+	// Removing goes on until the target is reached
+	assert.False(t, goal.done(0))
+	free = 12 << 30
+	assert.False(t, goal.done(1))
+	free = 20 << 30
+	assert.True(t, goal.done(2))
+
+	// Quarantining on the same filesystem frees nothing, so it stops early
+	free = 5 << 30
+	Cfg.Delete.Mode = "quarantine"
+	assert.True(t, goal.done(1))
+	assert.True(t, goal.noGain)
+
+	Cfg.Delete.Mode = "rm"
+	goal.noGain = false
+	assert.False(t, goal.done(1))
+}
+
+func TestClean_MaxDelete(t *testing.T) {
+	tests := []struct {
+		name string
+		yes  bool
+		// typed is whether the confirmation phrase is entered at the prompt
+		typed        bool
+		wantPrompted bool
+		wantErr      string
+		wantDeleted  bool
+	}{
+		{"refused with --yes", true, false, false, "more than the 1 B a run may delete", false},
+		{"phrase typed", false, true, true, "", true},
+		{"phrase not typed", false, false, true, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			oldCfg, oldSources, oldDryRun, oldConfirm := Cfg, CfgSources, dryRun, confirmOverCap
+			setFlags := map[string]string{"max-delete": "1B", "yes": "false", "min-size": "0", "no-cache": "true"}
+			if tt.yes {
+				setFlags["yes"] = "true"
+			}
+			t.Cleanup(func() {
+				Cfg, CfgSources, dryRun, confirmOverCap = oldCfg, oldSources, oldDryRun, oldConfirm
+				for name := range setFlags {
+					flag := cleanCmd.Flags().Lookup(name)
+					require.NoError(t, flag.Value.Set(flag.DefValue))
+					flag.Changed = false
+				}
+			})
+
+			Cfg = config.GetDefaults()
+			Cfg.ExcludePaths = nil
+			Cfg.Delete.AuditLog = ""
+			Cfg.SkipIfModifiedWithin = "0"
+			CfgSources = config.Provenance{}
+			dryRun = false
+			for name, value := range setFlags {
+				require.NoError(t, cleanCmd.Flags().Set(name, value))
+			}
+
+			project := filepath.Join(t.TempDir(), "app")
+			target := filepath.Join(project, "node_modules")
+			require.NoError(t, os.MkdirAll(target, 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(target, "index.js"), []byte("module.exports = 1\n"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(project, "package.json"), []byte("{}"), 0644))
+
+			prompted := false
+			confirmOverCap = func(reason, phrase string) (bool, error) {
+				prompted = true
+				assert.Contains(t, reason, "more than the 1 B a run may delete")
+				assert.True(t, strings.HasPrefix(phrase, "delete "), phrase)
+				return tt.typed, nil
+			}
+
+			err := runClean(cleanCmd, []string{project})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantPrompted, prompted)
+			assert.Equal(t, "flag --max-delete", CfgSources.Source("delete.maxPerRunGB"))
+			if tt.wantDeleted {
+				assert.NoDirExists(t, target)
+			} else {
+				assert.DirExists(t, target)
+			}
+		})
+	}
+}
+
+func TestReadPathList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		null  bool
+		want  []string
+	}{
+		{"lines", "/a/node_modules\n/b/target\n", false, []string{"/a/node_modules", "/b/target"}},
+		{"CRLF and blank lines", "/a/node_modules\r\n\r\n/b/target", false, []string{"/a/node_modules", "/b/target"}},
+		{"NUL separated", "/a/with\nnewline\x00/b/target\x00", true, []string{"/a/with\nnewline", "/b/target"}},
+		{"empty", "", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := readPathList(strings.NewReader(tt.input), tt.null)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, paths)
+		})
+	}
+}
+
+func TestClean_Stdin(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	oldCfg, oldSources, oldDryRun, oldStdin := Cfg, CfgSources, dryRun, stdin
+	t.Cleanup(func() { Cfg, CfgSources, dryRun, stdin = oldCfg, oldSources, oldDryRun, oldStdin })
+	Cfg = config.GetDefaults()
+	Cfg.ExcludePaths = nil
+	CfgSources = config.Provenance{}
+	dryRun = true
+
+	root := t.TempDir()
+	cache := filepath.Join(root, "tools", "cache")
+	project := filepath.Join(root, "app")
+	target := filepath.Join(project, "node_modules")
+	src := filepath.Join(project, "src")
+	for _, dir := range []string{cache, target, src, filepath.Join(project, ".git")} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(cache, "blob"), []byte("12345"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "package.json"), []byte("{}"), 0644))
+
+	// Only the directories that pass the safety checks are kept
+	input := strings.Join([]string{
+		cache,
+		target,
+		target,
+		src,
+		filepath.Join(project, ".git"),
+		filepath.Join(project, "package.json"),
+		filepath.Join(root, "missing"),
+		"/",
+	}, "\n")
+	candidates, err := stdinCandidates(cleanCmd, strings.NewReader(input), false)
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+	assert.Equal(t, cache, candidates[0].Path)
+	assert.Equal(t, int64(5), candidates[0].SizeBytes)
+	assert.NotEmpty(t, candidates[0].ID)
+	assert.Equal(t, target, candidates[1].Path)
+
+	// - reads stdin too, and can't be mixed with other paths
+	stdin = strings.NewReader(cache + "\x00")
+	require.NoError(t, cleanCmd.Flags().Set("null", "true"))
+	t.Cleanup(func() {
+		flag := cleanCmd.Flags().Lookup("null")
+		require.NoError(t, flag.Value.Set(flag.DefValue))
+		flag.Changed = false
+	})
+	require.NoError(t, runClean(cleanCmd, []string{"-"}))
+	assert.DirExists(t, cache)
+	assert.ErrorContains(t, runClean(cleanCmd, []string{"-", root}), "cannot be combined with other paths")
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+)
+
+// knownPatternNames returns every directory name that the defaults or a
+// built-in profile know about, sorted and without duplicates
+func knownPatternNames() []string {
+	defaults := config.GetDefaults()
+	names := slices.Concat(defaults.IncludeNames, defaults.ExcludeNames)
+	for _, profile := range config.Profiles() {
+		names = append(names, profile.IncludeNames...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// completeList completes the last element of a comma-separated flag value
+// such as --include node_modules,tar from values, leaving out the elements
+// already typed
+func completeList(values []string, toComplete string) []string {
+	typed := strings.Split(toComplete, ",")
+	prefix := strings.Join(typed[:len(typed)-1], ",")
+	if prefix != "" {
+		prefix += ","
+	}
+	current := typed[len(typed)-1]
+
+	var completions []string
+	for _, value := range values {
+		if strings.HasPrefix(value, current) && !slices.Contains(typed[:len(typed)-1], value) {
+			completions = append(completions, prefix+value)
+		}
+	}
+	return completions
+}
+
+// listCompletion completes a comma-separated flag value from the values values returns
+func listCompletion(values func() []string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeList(values(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completionConfig returns the config to complete from. Cobra parses the
+// flags of the completed command line only after the root command loaded the
+// config, so a --config on that line is loaded here.
+func completionConfig() config.Config {
+	if cfgFile == "" {
+		return Cfg
+	}
+	cfg, _, err := config.LoadConfigWithProvenance(cfgFile)
+	if err != nil {
+		return Cfg
+	}
+	return cfg
+}
+
+// completeQuarantinedPaths completes the original paths of the items in the
+// quarantine, leaving out those already given. Only the metadata files are
+// read, and a missing quarantine directory simply has nothing to complete.
+func completeQuarantinedPaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	items, err := listQuarantinedItems(completionConfig().Delete.QuarantineDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var paths []string
+	for _, item := range items {
+		if strings.HasPrefix(item.OriginalPath, toComplete) && !slices.Contains(args, item.OriginalPath) {
+			paths = append(paths, item.OriginalPath)
+		}
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeScanPaths completes positional scan paths with directories only
+func completeScanPaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+// registerScanCompletions adds the completions shared by scan and clean
+func registerScanCompletions(cmd *cobra.Command) {
+	cmd.ValidArgsFunction = completeScanPaths
+	cmd.RegisterFlagCompletionFunc("include", listCompletion(knownPatternNames))
+	cmd.RegisterFlagCompletionFunc("exclude", listCompletion(knownPatternNames))
+	cmd.RegisterFlagCompletionFunc("only", listCompletion(knownPatternNames))
+	cmd.RegisterFlagCompletionFunc("profile", listCompletion(config.ProfileNames))
+	cmd.RegisterFlagCompletionFunc("lang", listCompletion(config.ProfileNames))
+	cmd.RegisterFlagCompletionFunc("presets", listCompletion(config.PresetNames))
+	cmd.RegisterFlagCompletionFunc("format", listCompletion(func() []string { return config.ValidOutputFormats }))
+	cmd.RegisterFlagCompletionFunc("sort", cobra.FixedCompletions(config.ValidSortOrders, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("size-unit", cobra.FixedCompletions(bytesize.UnitNames, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+)
+
+// complete runs cobra's hidden completion command for args, as a shell would,
+// and returns the completions without the trailing directive line
+func complete(t *testing.T, args ...string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(io.Discard)
+	rootCmd.SetArgs(append([]string{cobra.ShellCompRequestCmd}, args...))
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		cfgFile = ""
+	})
+
+	require.NoError(t, rootCmd.Execute())
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.True(t, strings.HasPrefix(lines[len(lines)-1], ":"), "missing directive in %q", out.String())
+	return lines[:len(lines)-1]
+}
+
+func TestCompletion_Flags(t *testing.T) {
+	for _, command := range []string{"scan", "clean"} {
+		t.Run(command, func(t *testing.T) {
+			assert.Equal(t, []string{"table", "json", "csv", "tsv", "plain", "plain0"}, complete(t, command, "--format", ""))
+			assert.Equal(t, []string{"size", "path", "age"}, complete(t, command, "--sort", ""))
+			assert.Contains(t, complete(t, command, "--profile", ""), "python")
+
+			includes := complete(t, command, "--include", "node_")
+			assert.Equal(t, []string{"node_modules"}, includes)
+			// Names already in the list are not offered again
+			includes = complete(t, command, "--include", "node_modules,")
+			assert.NotContains(t, includes, "node_modules,node_modules")
+			assert.Contains(t, includes, "node_modules,target")
+			assert.Contains(t, complete(t, command, "--exclude", ""), "vendor")
+		})
+	}
+}
+
+func TestCompletion_QuarantinedPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	quarantineDir := filepath.Join(tmpDir, "quarantine")
+	require.NoError(t, os.MkdirAll(quarantineDir, 0755))
+	for _, name := range []string{"node_modules", "target"} {
+		writeTestMetadata(t, erase.MetadataPath(filepath.Join(quarantineDir, name)), erase.Metadata{
+			OriginalPath:   filepath.Join("/projects/app", name),
+			QuarantinePath: filepath.Join(quarantineDir, name),
+			Timestamp:      time.Now(),
+		})
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("delete:\n  quarantineDir: "+quarantineDir+"\n"), 0644))
+
+	for _, command := range []string{"restore", "purge"} {
+		t.Run(command, func(t *testing.T) {
+			assert.Equal(t, []string{"/projects/app/node_modules", "/projects/app/target"},
+				complete(t, "--config", configPath, command, ""))
+			assert.Equal(t, []string{"/projects/app/target"},
+				complete(t, "--config", configPath, command, "/projects/app/t"))
+			// Items already given are not offered again
+			assert.Equal(t, []string{"/projects/app/target"},
+				complete(t, "--config", configPath, command, "/projects/app/node_modules", ""))
+		})
+	}
+
+	t.Run("missing quarantine", func(t *testing.T) {
+		missing := filepath.Join(tmpDir, "missing.yaml")
+		require.NoError(t, os.WriteFile(missing, []byte("delete:\n  quarantineDir: "+filepath.Join(tmpDir, "gone")+"\n"), 0644))
+		assert.Empty(t, complete(t, "--config", missing, "restore", ""))
+	})
+}
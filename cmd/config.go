@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file",
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a commented config file with the default settings",
+	Long: `Writes a .BuildBloatBuster.yaml to the current directory, populated with the
+default settings and comments explaining every key.
+
+Use --global to write the user-wide config file instead: $XDG_CONFIG_HOME
+(or ~/.config) on Linux, ~/Library/Application Support on macOS and %APPDATA%
+on Windows. An existing file is never overwritten unless --force is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		global, _ := cmd.Flags().GetBool("global")
+		force, _ := cmd.Flags().GetBool("force")
+
+		path := config.LocalConfigName
+		if global {
+			var err error
+			path, err = config.GlobalConfigPath()
+			if err != nil {
+				return fmt.Errorf("could not determine the global config location: %w", err)
+			}
+		}
+
+		if err := writeDefaultConfig(path, force); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote default configuration to %s\n", path)
+		return nil
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration",
+	Long: `Prints the fully merged configuration the other commands will use: the
+defaults, overridden by the config file, overridden by flags.
+
+With --verbose, every setting is annotated with where it came from.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfigFlags(cmd); err != nil {
+			return err
+		}
+		format, _ := cmd.Flags().GetString("format")
+		out, err := renderEffectiveConfig(Cfg, CfgSources, format, verbose)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(out))
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for mistakes",
+	Long: `Loads the config file (--config, or the first one found in the standard
+locations) and reports every invalid setting together with a suggested fix, plus warnings for unknown keys
+and other likely mistakes.
+
+Exits with a non-zero status if the configuration is invalid, so it can be used in CI.`,
+	// The file is loaded by the command itself so every problem is listed
+	// instead of the root command exiting on the first load error
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	SilenceUsage:     true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfgFile != "" {
+			return runConfigValidate(cfgFile)
+		}
+		path := config.FindConfigFile()
+		if path == "" {
+			fmt.Println("No config file found; the defaults will be used.")
+			return nil
+		}
+		return runConfigValidate(path)
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON Schema for the config file",
+	Long: `Prints a JSON Schema describing every setting of the config file with its
+type, default and allowed values. Point your editor at it for autocompletion
+and validation, e.g. with the YAML language server:
+
+  BuildBloatBuster config schema > BuildBloatBuster.schema.json
+  # yaml-language-server: $schema=./BuildBloatBuster.schema.json
+
+Defaults that depend on the home directory, such as delete.quarantineDir, are
+those of the current user.`,
+	// The schema doesn't depend on the config file, which may not even load
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+// runConfigValidate reports the warnings and problems found in the config file at path.
+func runConfigValidate(path string) error {
+	keyWarnings, err := config.KeyWarnings(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	cfg, _, err := config.LoadConfigWithProvenance(path)
+	var invalid *config.ValidationError
+	if err != nil && !errors.As(err, &invalid) {
+		return fmt.Errorf("could not load %s: %w", path, err)
+	}
+
+	for _, warning := range append(keyWarnings, cfg.Warnings()...) {
+		fmt.Printf("[%s] %s\n", checkWarn, warning)
+	}
+	if invalid != nil {
+		for _, problem := range invalid.Problems {
+			fmt.Printf("[%s] %s\n", checkFail, problem)
+		}
+		return fmt.Errorf("%s is invalid: %d problem(s) found", path, len(invalid.Problems))
+	}
+
+	fmt.Printf("%s is valid.\n", path)
+	return nil
+}
+
+// renderEffectiveConfig renders cfg as YAML or JSON. When annotate is set, each
+// setting is listed under its dotted key together with its source.
+func renderEffectiveConfig(cfg config.Config, sources config.Provenance, format string, annotate bool) ([]byte, error) {
+	switch format {
+	case "yaml":
+		if !annotate {
+			return yaml.Parser().Marshal(cfg.Map())
+		}
+		flat := cfg.Flatten()
+		var b strings.Builder
+		for _, key := range cfg.Keys() {
+			value, err := json.Marshal(flat[key])
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, "%s: %s  # %s\n", key, value, sources.Source(key))
+		}
+		return []byte(b.String()), nil
+
+	case "json":
+		var v any = cfg.Map()
+		if annotate {
+			annotated := make(map[string]string)
+			for _, key := range cfg.Keys() {
+				annotated[key] = sources.Source(key)
+			}
+			v = map[string]any{"config": cfg.Map(), "sources": annotated}
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported format: %s (expected yaml or json)", format)
+	}
+}
+
+// writeDefaultConfig writes the commented default config to path, refusing to
+// replace an existing file unless force is set.
+func writeDefaultConfig(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+	}
+
+	data, err := config.RenderYAML(config.GetDefaults())
+	if err != nil {
+		return fmt.Errorf("failed to render config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create directory for %s: %w", path, err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+
+	configShowCmd.Flags().String("format", "yaml", "output format (yaml, json)")
+	configShowCmd.Flags().StringP("min-size", "s", "", "minimum size, e.g. 500MB or 2GiB; a plain number is MiB (overrides config)")
+	configShowCmd.Flags().IntP("max-depth", "d", 0, "maximum directory depth (overrides config)")
+	configShowCmd.Flags().Int("max-results", 0, "stop scanning after this many directories were found, 0 = unlimited (overrides config)")
+	configShowCmd.Flags().StringSliceP("include", "i", nil, "additional patterns to include")
+	configShowCmd.Flags().StringSliceP("exclude", "e", nil, "additional patterns to exclude")
+	configShowCmd.Flags().StringSlice("exclude-glob", nil, "exclude directories whose name matches these patterns, e.g. '*.egg-info'")
+	configShowCmd.Flags().StringSlice("include-from", nil, "add the patterns in these files, one per line, to the include names")
+	configShowCmd.Flags().StringSlice("exclude-from", nil, "add the patterns in these files, one per line, to the exclusions")
+	configShowCmd.Flags().StringSlice("profile", nil, "built-in profiles to enable, e.g. node,python (overrides config)")
+	configShowCmd.Flags().StringSlice("lang", nil, "scan only for these ecosystems' directories, e.g. node,rust, instead of the include list (see profiles list)")
+	configShowCmd.MarkFlagsMutuallyExclusive("lang", "profile")
+	configShowCmd.Flags().StringSlice("presets", nil, "built-in presets of directories outside any project to report too, e.g. mobile (overrides config)")
+	configShowCmd.Flags().Int("concurrency", 0, "number of size calculation workers (overrides config)")
+
+	configInitCmd.Flags().Bool("global", false, "write the user-wide config file instead of ./.BuildBloatBuster.yaml")
+	configInitCmd.Flags().Bool("force", false, "overwrite an existing config file")
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+)
+
+func TestWriteDefaultConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", ".BuildBloatBuster.yaml")
+
+	require.NoError(t, writeDefaultConfig(path, false))
+	cfg, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, config.GetDefaults(), cfg)
+
+	// Refuses to overwrite without --force
+	require.NoError(t, os.WriteFile(path, []byte("minSizeMB: 1\n"), 0644))
+	assert.Error(t, writeDefaultConfig(path, false))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "minSizeMB: 1\n", string(data))
+
+	require.NoError(t, writeDefaultConfig(path, true))
+	cfg, err = config.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "10MB", cfg.MinSize)
+}
+
+func TestRenderEffectiveConfig(t *testing.T) {
+	cfg := config.GetDefaults()
+	cfg.MaxDepth = 3
+	sources := config.Provenance{"maxDepth": "/tmp/.BuildBloatBuster.yaml"}
+
+	t.Run("json round-trips", func(t *testing.T) {
+		out, err := renderEffectiveConfig(cfg, sources, "json", false)
+		require.NoError(t, err)
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(out, &m))
+		assert.Equal(t, float64(3), m["maxDepth"])
+		assert.Equal(t, "quarantine", m["delete"].(map[string]any)["mode"])
+	})
+
+	t.Run("annotated yaml shows sources", func(t *testing.T) {
+		out, err := renderEffectiveConfig(cfg, sources, "yaml", true)
+		require.NoError(t, err)
+		assert.Contains(t, string(out), "maxDepth: 3  # /tmp/.BuildBloatBuster.yaml\n")
+		assert.Contains(t, string(out), "delete.mode: \"quarantine\"  # default\n")
+	})
+
+	t.Run("annotated json includes sources", func(t *testing.T) {
+		out, err := renderEffectiveConfig(cfg, sources, "json", true)
+		require.NoError(t, err)
+		var m struct {
+			Sources map[string]string `json:"sources"`
+		}
+		require.NoError(t, json.Unmarshal(out, &m))
+		assert.Equal(t, "/tmp/.BuildBloatBuster.yaml", m.Sources["maxDepth"])
+		assert.Equal(t, "default", m.Sources["output.format"])
+	})
+
+	t.Run("rejects unknown format", func(t *testing.T) {
+		_, err := renderEffectiveConfig(cfg, sources, "toml", false)
+		assert.Error(t, err)
+	})
+}
+
+func TestRunConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.yaml")
+	require.NoError(t, os.WriteFile(valid, []byte("minSizeMB: 1\n"), 0644))
+	assert.NoError(t, runConfigValidate(valid))
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("minSizeMB: -1\noutput:\n  sortBy: name\n"), 0644))
+	err := runConfigValidate(invalid)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 problem(s)")
+
+	assert.Error(t, runConfigValidate(filepath.Join(dir, "missing.yaml")))
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/report"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old.json> <new.json>",
+	Short: "Compare two saved scans",
+	Long: `Compares two scan snapshots and reports which directories were added,
+removed, grew or shrank, with the size change of each, largest first, and the
+overall change in reclaimable space. --min-delta leaves out smaller changes.
+
+Snapshots are written by "scan --save <file>", or by redirecting the output
+of "scan --format json" to a file.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		minDeltaFlag, _ := cmd.Flags().GetString("min-delta")
+		minDelta, err := bytesize.ParseWithUnit(minDeltaFlag, bytesize.MiB)
+		if err != nil {
+			return fmt.Errorf("invalid --min-delta %q: %v", minDeltaFlag, errors.Unwrap(err))
+		}
+		return runDiff(args[0], args[1], format, minDelta)
+	},
+}
+
+func runDiff(oldPath, newPath, format string, minDelta int64) error {
+	oldSnapshot, err := report.LoadSnapshot(oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	newSnapshot, err := report.LoadSnapshot(newPath)
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	return report.ReportDiff(report.DiffSnapshots(oldSnapshot, newSnapshot, minDelta), format)
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().String("format", "table", "output format (table, json)")
+	diffCmd.Flags().String("min-delta", "0", "only list changes at least this large, e.g. 100MB; a plain number is MiB")
+	diffCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"table", "json"}, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,416 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+	"github.com/yehia2amer/BuildBloatBuster/internal/size"
+)
+
+// Doctor check statuses
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+// checkResult is the outcome of a single doctor check. Hint suggests how to
+// fix a warning or failure.
+type checkResult struct {
+	Name   string
+	Status string
+	Detail string
+	Hint   string
+}
+
+// minQuarantineFree is the free space below which the quarantine partition is
+// reported as too small; quarantining across filesystems copies the data there
+const minQuarantineFree = 1 << 30
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check configuration and environment for problems",
+	Long: `Validates the effective configuration and the environment it runs in:
+- the config file parses, is valid and has no unknown or deprecated keys
+- scan paths exist, are readable and aren't protected or excluded
+- the quarantine directory is writable, has free space and isn't scanned itself
+- the delete mode is valid
+- no directory name is both included and excluded
+- the concurrency suits the storage of the scan paths
+- progress output can be drawn in this terminal
+
+Every warning and failure comes with a hint on how to fix it.
+Exits with a non-zero status if any check fails.`,
+	// The config file is loaded by the command itself so that an invalid
+	// file is reported as a failed check instead of aborting
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := cfgFile
+		if path == "" {
+			path = config.FindConfigFile()
+		}
+		return runDoctor(path)
+	},
+}
+
+func runDoctor(configPath string) error {
+	cfg, results := checkConfigFile(configPath)
+	bytesize.DisplayUnits = bytesize.Units(cfg.Output.Units)
+	results = append(results, runDoctorChecks(cfg)...)
+	results = append(results, checkProgressOutput(isTerminal(os.Stderr), os.Getenv("TERM")))
+
+	failures, warnings := 0, 0
+	for _, r := range results {
+		fmt.Printf("[%s] %s", r.Status, r.Name)
+		if r.Detail != "" {
+			fmt.Printf(": %s", r.Detail)
+		}
+		fmt.Println()
+		if r.Hint != "" && r.Status != checkPass {
+			fmt.Printf("       hint: %s\n", r.Hint)
+		}
+		switch r.Status {
+		case checkFail:
+			failures++
+		case checkWarn:
+			warnings++
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("doctor found %d problem(s)", failures)
+	}
+	if warnings > 0 {
+		fmt.Printf("\nAll checks passed with %d warning(s).\n", warnings)
+		return nil
+	}
+	fmt.Println("\nAll checks passed.")
+	return nil
+}
+
+// checkConfigFile loads the config file at path, or the defaults if path is
+// empty, and reports unknown keys and invalid settings. The config is returned
+// even when invalid so the remaining checks can still run against it.
+func checkConfigFile(path string) (config.Config, []checkResult) {
+	if path == "" {
+		return config.GetDefaults(), []checkResult{{Name: "config file", Status: checkPass, Detail: "none found, using defaults"}}
+	}
+	name := "config file " + path
+
+	keyWarnings, err := config.KeyWarnings(path)
+	if err != nil {
+		return config.GetDefaults(), []checkResult{{
+			Name:   name,
+			Status: checkFail,
+			Detail: fmt.Sprintf("could not read: %v", err),
+			Hint:   "fix the YAML syntax, or move the file away to fall back to the defaults",
+		}}
+	}
+
+	var results []checkResult
+	for _, warning := range keyWarnings {
+		results = append(results, checkResult{
+			Name:   name,
+			Status: checkWarn,
+			Detail: warning,
+			Hint:   "unknown keys are ignored; compare the spelling with `config show`",
+		})
+	}
+
+	cfg, _, err := config.LoadConfigWithProvenance(path)
+	var invalid *config.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		for _, problem := range invalid.Problems {
+			results = append(results, checkResult{
+				Name:   name,
+				Status: checkFail,
+				Detail: problem.Error(),
+				Hint:   "fix the setting; `config validate` lists every problem in the file",
+			})
+		}
+	case err != nil:
+		return config.GetDefaults(), append(results, checkResult{Name: name, Status: checkFail, Detail: err.Error()})
+	}
+
+	if len(results) == 0 {
+		results = append(results, checkResult{Name: name, Status: checkPass})
+	}
+	return cfg, results
+}
+
+// runDoctorChecks runs all checks against cfg and returns their results in order.
+func runDoctorChecks(cfg config.Config) []checkResult {
+	var results []checkResult
+
+	for _, scanPath := range cfg.ScanPaths {
+		results = append(results, checkScanPathReadable(scanPath))
+		results = append(results, checkScanPathOverlap(scanPath, cfg.ExcludePaths, cfg.Delete.QuarantineDir)...)
+	}
+	results = append(results, checkQuarantineWritable(cfg.Delete.QuarantineDir))
+	if cfg.Delete.QuarantineDir != "" {
+		results = append(results, checkQuarantineSpace(cfg.Delete.QuarantineDir, erase.FreeSpace))
+	}
+
+	if config.IsValidDeleteMode(cfg.Delete.Mode) {
+		results = append(results, checkResult{Name: "delete mode", Status: checkPass, Detail: cfg.Delete.Mode})
+	} else {
+		results = append(results, checkResult{
+			Name:   "delete mode",
+			Status: checkFail,
+			Detail: fmt.Sprintf("unsupported mode %q (expected one of %s)", cfg.Delete.Mode, strings.Join(config.ValidDeleteModes, ", ")),
+			Hint:   "set delete.mode in the config file",
+		})
+	}
+
+	results = append(results, checkIncludeExcludeOverlap(cfg.IncludeNames, cfg.ExcludeNames)...)
+	results = append(results, checkConcurrency(cfg.Concurrency, cfg.ScanPaths, size.DetectStorage)...)
+
+	return results
+}
+
+// checkScanPathReadable verifies that a scan path exists, is a directory and can be listed.
+func checkScanPathReadable(scanPath string) checkResult {
+	name := fmt.Sprintf("scan path %s", scanPath)
+
+	info, err := os.Stat(scanPath)
+	if err != nil {
+		return checkResult{Name: name, Status: checkFail, Detail: err.Error(), Hint: "create the directory or remove it from scanPaths"}
+	}
+	if !info.IsDir() {
+		return checkResult{Name: name, Status: checkFail, Detail: "not a directory", Hint: "scan paths must be directories"}
+	}
+	if _, err := os.ReadDir(scanPath); err != nil {
+		return checkResult{Name: name, Status: checkFail, Detail: fmt.Sprintf("not readable: %v", err), Hint: "fix the directory's permissions"}
+	}
+	return checkResult{Name: name, Status: checkPass}
+}
+
+// checkQuarantineWritable verifies that files can be created in the quarantine
+// directory, or in its nearest existing ancestor if it hasn't been created yet.
+func checkQuarantineWritable(quarantineDir string) checkResult {
+	name := fmt.Sprintf("quarantine dir %s", quarantineDir)
+	if quarantineDir == "" {
+		return checkResult{Name: "quarantine dir", Status: checkFail, Detail: "not configured", Hint: "set delete.quarantineDir in the config file"}
+	}
+
+	dir := quarantineDir
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return checkResult{Name: name, Status: checkFail, Detail: fmt.Sprintf("%s is not a directory", dir), Hint: "move the file away or point delete.quarantineDir elsewhere"}
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return checkResult{Name: name, Status: checkFail, Detail: "no existing parent directory", Hint: "point delete.quarantineDir to an existing disk"}
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".BuildBloatBuster-doctor-*")
+	if err != nil {
+		return checkResult{Name: name, Status: checkFail, Detail: fmt.Sprintf("not writable: %v", err), Hint: "fix the directory's permissions or point delete.quarantineDir elsewhere"}
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return checkResult{Name: name, Status: checkPass}
+}
+
+// checkIncludeExcludeOverlap warns about names that appear in both lists;
+// the exclude entry wins, so the include entry has no effect.
+func checkIncludeExcludeOverlap(includeNames, excludeNames []string) []checkResult {
+	excluded := make(map[string]struct{}, len(excludeNames))
+	for _, name := range excludeNames {
+		excluded[name] = struct{}{}
+	}
+
+	var results []checkResult
+	seen := make(map[string]struct{})
+	for _, name := range includeNames {
+		if _, ok := excluded[name]; !ok {
+			continue
+		}
+		if _, dup := seen[name]; dup {
+			continue
+		}
+		seen[name] = struct{}{}
+		results = append(results, checkResult{
+			Name:   "include/exclude names",
+			Status: checkWarn,
+			Detail: fmt.Sprintf("%q is both included and excluded; it will never be selected", name),
+			Hint:   "remove it from includeNames or excludeNames",
+		})
+	}
+
+	if len(results) == 0 {
+		results = append(results, checkResult{Name: "include/exclude names", Status: checkPass})
+	}
+	return results
+}
+
+// checkScanPathOverlap reports a scan path that is protected, the whole home
+// directory or excluded, and a quarantine directory inside it that the scan
+// would walk into
+func checkScanPathOverlap(scanPath string, excludePaths []string, quarantineDir string) []checkResult {
+	name := fmt.Sprintf("scan path %s", scanPath)
+
+	if err := checkScanPaths([]string{scanPath}, true); err != nil {
+		return []checkResult{{Name: name, Status: checkFail, Detail: err.Error(), Hint: "scan your projects directory instead"}}
+	}
+
+	var results []checkResult
+	if config.IsHomeDir(scanPath) {
+		results = append(results, checkResult{
+			Name:   name,
+			Status: checkWarn,
+			Detail: "is your entire home directory",
+			Hint:   "scans refuse it without --allow-home; list your projects directories instead",
+		})
+	}
+	if excludePath := containingPath(scanPath, excludePaths); excludePath != "" {
+		results = append(results, checkResult{
+			Name:   name,
+			Status: checkWarn,
+			Detail: fmt.Sprintf("lies in excludePaths entry %s, so nothing below it is scanned", excludePath),
+			Hint:   "remove it from scanPaths or the entry from excludePaths",
+		})
+	}
+	if quarantineDir != "" && isWithinDir(quarantineDir, scanPath) && containingPath(quarantineDir, excludePaths) == "" {
+		results = append(results, checkResult{
+			Name:   name,
+			Status: checkWarn,
+			Detail: fmt.Sprintf("contains the quarantine dir %s, so quarantined directories would be found again", quarantineDir),
+			Hint:   "add the quarantine dir to excludePaths",
+		})
+	}
+
+	if len(results) == 0 {
+		results = append(results, checkResult{Name: name + " overlap", Status: checkPass})
+	}
+	return results
+}
+
+// checkQuarantineSpace warns when the filesystem the quarantine directory is
+// on, found through its nearest existing ancestor, is nearly full. Quarantining
+// from another filesystem copies the data, so a tiny partition fills up fast.
+func checkQuarantineSpace(quarantineDir string, freeSpace func(string) (uint64, bool)) checkResult {
+	name := "quarantine free space"
+
+	dir := quarantineDir
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return checkResult{Name: name, Status: checkWarn, Detail: "no existing parent directory"}
+		}
+		dir = parent
+	}
+
+	free, ok := freeSpace(dir)
+	if !ok {
+		return checkResult{Name: name, Status: checkPass, Detail: "unknown on this platform"}
+	}
+	if free < minQuarantineFree {
+		return checkResult{
+			Name:   name,
+			Status: checkWarn,
+			Detail: fmt.Sprintf("only %s free on the filesystem of %s", bytesize.Format(int64(free)), dir),
+			Hint:   "point delete.quarantineDir to a larger disk, run `purge` or set delete.mode to rm",
+		}
+	}
+	return checkResult{Name: name, Status: checkPass, Detail: bytesize.Format(int64(free)) + " free"}
+}
+
+// checkConcurrency warns when an explicit concurrency is higher than suits the
+// storage of a scan path; spinning disks and network shares slow down when
+// walked by many workers at once. detect reports the storage of a path.
+func checkConcurrency(concurrency int, scanPaths []string, detect func(string) size.StorageKind) []checkResult {
+	if concurrency <= 0 {
+		return []checkResult{{Name: "concurrency", Status: checkPass, Detail: "auto, tuned per scan path"}}
+	}
+
+	var results []checkResult
+	for _, scanPath := range scanPaths {
+		kind := detect(scanPath)
+		if kind != size.StorageRotational && kind != size.StorageNetwork {
+			continue
+		}
+		if recommended := size.WorkersFor(kind); concurrency > recommended {
+			results = append(results, checkResult{
+				Name:   "concurrency",
+				Status: checkWarn,
+				Detail: fmt.Sprintf("%d workers on %s storage at %s, where %d perform better", concurrency, kind, scanPath, recommended),
+				Hint:   "set concurrency to 0 to tune the workers per scan path",
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		results = append(results, checkResult{Name: "concurrency", Status: checkPass, Detail: fmt.Sprint(concurrency)})
+	}
+	return results
+}
+
+// checkProgressOutput reports whether live progress will be drawn: it needs a
+// terminal on stderr that understands cursor movement
+func checkProgressOutput(isTerminal bool, term string) checkResult {
+	name := "progress output"
+	switch {
+	case !isTerminal:
+		return checkResult{
+			Name:   name,
+			Status: checkWarn,
+			Detail: "stderr is not a terminal, so progress is not shown",
+			Hint:   "expected when piping or in CI; run in a terminal to see progress",
+		}
+	case term == "dumb":
+		return checkResult{
+			Name:   name,
+			Status: checkWarn,
+			Detail: "TERM=dumb may not support redrawing the progress line",
+			Hint:   "use --no-progress to turn progress off, or a terminal with cursor support",
+		}
+	}
+	return checkResult{Name: name, Status: checkPass}
+}
+
+// containingPath returns the first of dirs that path is or lies under, or ""
+func containingPath(path string, dirs []string) string {
+	for _, dir := range dirs {
+		if isWithinDir(path, dir) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// isWithinDir reports whether path is dir or lies below it
+func isWithinDir(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/size"
+)
+
+// statusesByName collects the check statuses, keyed by check name.
+func statusesByName(results []checkResult) map[string][]string {
+	statuses := make(map[string][]string)
+	for _, r := range results {
+		statuses[r.Name] = append(statuses[r.Name], r.Status)
+	}
+	return statuses
+}
+
+// doctorDefaults returns the default config without the default excludePaths,
+// which cover the temporary directories tests scan
+func doctorDefaults() config.Config {
+	cfg := config.GetDefaults()
+	cfg.ExcludePaths = nil
+	return cfg
+}
+
+func TestDoctor(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("healthy config passes", func(t *testing.T) {
+		cfg := doctorDefaults()
+		cfg.ScanPaths = []string{tmpDir}
+		cfg.Delete.QuarantineDir = filepath.Join(t.TempDir(), "not", "created", "yet")
+
+		for _, r := range runDoctorChecks(cfg) {
+			assert.Equal(t, checkPass, r.Status, "%s: %s", r.Name, r.Detail)
+		}
+		_, err := os.Stat(cfg.Delete.QuarantineDir)
+		assert.True(t, os.IsNotExist(err), "doctor should not create the quarantine dir")
+	})
+
+	t.Run("missing scan path fails", func(t *testing.T) {
+		cfg := doctorDefaults()
+		missing := filepath.Join(tmpDir, "missing")
+		cfg.ScanPaths = []string{missing}
+		cfg.Delete.QuarantineDir = tmpDir
+
+		statuses := statusesByName(runDoctorChecks(cfg))
+		assert.Equal(t, []string{checkFail}, statuses["scan path "+missing])
+	})
+
+	t.Run("invalid delete mode fails", func(t *testing.T) {
+		cfg := doctorDefaults()
+		cfg.ScanPaths = []string{tmpDir}
+		cfg.Delete.QuarantineDir = tmpDir
+		cfg.Delete.Mode = "shred"
+
+		statuses := statusesByName(runDoctorChecks(cfg))
+		assert.Equal(t, []string{checkFail}, statuses["delete mode"])
+	})
+
+	t.Run("quarantine dir that is a file fails", func(t *testing.T) {
+		file := filepath.Join(tmpDir, "file")
+		require.NoError(t, os.WriteFile(file, nil, 0644))
+
+		cfg := doctorDefaults()
+		cfg.ScanPaths = []string{tmpDir}
+		cfg.Delete.QuarantineDir = file
+
+		statuses := statusesByName(runDoctorChecks(cfg))
+		assert.Equal(t, []string{checkFail}, statuses["quarantine dir "+file])
+	})
+
+	t.Run("include and exclude overlap warns", func(t *testing.T) {
+		cfg := doctorDefaults()
+		cfg.ScanPaths = []string{tmpDir}
+		cfg.Delete.QuarantineDir = tmpDir
+		cfg.ExcludeNames = append(cfg.ExcludeNames, "vendor")
+
+		statuses := statusesByName(runDoctorChecks(cfg))
+		assert.Equal(t, []string{checkWarn}, statuses["include/exclude names"])
+	})
+}
+
+func TestDoctor_ScanPathOverlap(t *testing.T) {
+	tmpDir := t.TempDir()
+	projects := filepath.Join(tmpDir, "projects")
+	quarantine := filepath.Join(projects, ".quarantine")
+
+	tests := []struct {
+		name          string
+		scanPath      string
+		excludePaths  []string
+		quarantineDir string
+		want          []string
+	}{
+		{"separate", projects, nil, filepath.Join(tmpDir, "quarantine"), []string{checkPass}},
+		{"protected", "/etc", nil, quarantine, []string{checkFail}},
+		{"excluded", projects, []string{tmpDir}, filepath.Join(tmpDir, "quarantine"), []string{checkWarn}},
+		{"quarantine inside", projects, nil, quarantine, []string{checkWarn}},
+		{"quarantine inside but excluded", projects, []string{quarantine}, quarantine, []string{checkPass}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := checkScanPathOverlap(tt.scanPath, tt.excludePaths, tt.quarantineDir)
+			var statuses []string
+			for _, r := range results {
+				statuses = append(statuses, r.Status)
+				if r.Status != checkPass {
+					assert.NotEmpty(t, r.Hint, "%s: %s", r.Name, r.Detail)
+				}
+			}
+			assert.Equal(t, tt.want, statuses)
+		})
+	}
+}
+
+func TestDoctor_QuarantineSpace(t *testing.T) {
+	quarantineDir := filepath.Join(t.TempDir(), "quarantine")
+	var queried string
+	freeSpace := func(free uint64, ok bool) func(string) (uint64, bool) {
+		return func(path string) (uint64, bool) {
+			queried = path
+			return free, ok
+		}
+	}
+
+	r := checkQuarantineSpace(quarantineDir, freeSpace(100<<20, true))
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Detail, "100 MiB")
+	assert.Equal(t, filepath.Dir(quarantineDir), queried, "should query the nearest existing ancestor")
+
+	assert.Equal(t, checkPass, checkQuarantineSpace(quarantineDir, freeSpace(50<<30, true)).Status)
+	assert.Equal(t, checkPass, checkQuarantineSpace(quarantineDir, freeSpace(0, false)).Status)
+}
+
+func TestDoctor_Concurrency(t *testing.T) {
+	storage := map[string]size.StorageKind{"/ssd": size.StorageSSD, "/hdd": size.StorageRotational}
+	detect := func(path string) size.StorageKind { return storage[path] }
+	scanPaths := []string{"/ssd", "/hdd"}
+
+	assert.Equal(t, []string{checkPass}, statusesByName(checkConcurrency(0, scanPaths, detect))["concurrency"])
+	assert.Equal(t, []string{checkPass}, statusesByName(checkConcurrency(2, scanPaths, detect))["concurrency"])
+
+	results := checkConcurrency(32, scanPaths, detect)
+	require.Len(t, results, 1)
+	assert.Equal(t, checkWarn, results[0].Status)
+	assert.Contains(t, results[0].Detail, "/hdd")
+}
+
+func TestDoctor_ProgressOutput(t *testing.T) {
+	assert.Equal(t, checkPass, checkProgressOutput(true, "xterm-256color").Status)
+	assert.Equal(t, checkWarn, checkProgressOutput(true, "dumb").Status)
+	assert.Equal(t, checkWarn, checkProgressOutput(false, "xterm-256color").Status)
+}
+
+func TestDoctor_ConfigFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("no file uses defaults", func(t *testing.T) {
+		cfg, results := checkConfigFile("")
+		assert.Equal(t, config.GetDefaults().Delete.Mode, cfg.Delete.Mode)
+		assert.Equal(t, []string{checkPass}, statusesByName(results)["config file"])
+	})
+
+	t.Run("unknown key warns", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "typo.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("maxdepth: 3\n"), 0644))
+
+		_, results := checkConfigFile(path)
+		require.Len(t, results, 1)
+		assert.Equal(t, checkWarn, results[0].Status)
+		assert.Contains(t, results[0].Detail, "maxDepth")
+	})
+
+	t.Run("invalid setting fails but still loads", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "invalid.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("delete:\n  mode: shred\nmaxDepth: 4\n"), 0644))
+
+		cfg, results := checkConfigFile(path)
+		assert.Contains(t, statusesByName(results)["config file "+path], checkFail)
+		assert.Equal(t, 4, cfg.MaxDepth)
+	})
+
+	t.Run("unparsable file fails", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "broken.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("scanPaths: [\n"), 0644))
+
+		_, results := checkConfigFile(path)
+		assert.Equal(t, []string{checkFail}, statusesByName(results)["config file "+path])
+	})
+}
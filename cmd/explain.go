@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+	"github.com/yehia2amer/BuildBloatBuster/internal/size"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <path>",
+	Short: "Explain why a directory is or isn't selected by scan",
+	Long: `Traces the decision the scan makes about a directory. Starting at the
+nearest scan path above it (or --root), every directory on the way down is
+checked against the rules in the order the scan applies them: maxDepth,
+protected paths, excludePaths, network filesystems, version control
+directories, excludeNames and finally includeNames and the profile rules.
+The trace stops at the first directory the scan doesn't walk into. If the
+directory itself is selected, its size is checked against minSize.
+
+The flags that change what scan selects can be given to explain too, to
+see what a scan with them would do.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeScanPaths,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfigFlags(cmd); err != nil {
+			return err
+		}
+		if err := Cfg.Validate(); err != nil {
+			return err
+		}
+		root, _ := cmd.Flags().GetString("root")
+		return runExplain(args[0], root)
+	},
+}
+
+func runExplain(path, root string) error {
+	trace, err := scan.NewScanner(Cfg).Explain(path, root)
+	if len(trace) == 0 {
+		return err
+	}
+	for i, evaluation := range trace {
+		printEvaluation(evaluation, i == 0)
+	}
+	if err != nil {
+		return err
+	}
+
+	target, _ := filepath.Abs(path)
+	last := trace[len(trace)-1]
+	decisive := last.Decisive()
+	switch {
+	case last.Path != target && last.Outcome == scan.OutcomeSelect:
+		fmt.Printf("\nNot selected on its own: it is inside %s, which is selected and deleted as a whole.\n", last.Path)
+	case last.Path != target:
+		fmt.Printf("\nNot selected: the scan never reaches it, because it skips %s (%s).\n", last.Path, decisive.Detail)
+	case last.Outcome == scan.OutcomeSkip:
+		fmt.Printf("\nNot selected: skipped by %s.\n", decisive.Detail)
+	case last.Outcome == scan.OutcomeDescend:
+		fmt.Println("\nNot selected: no include pattern or detector matches it, so the scan walks into it.")
+	default:
+		return explainMinSize(target)
+	}
+	return nil
+}
+
+// printEvaluation prints the rules checked for one directory of the trace
+func printEvaluation(evaluation scan.Evaluation, isRoot bool) {
+	label := evaluation.Path
+	if isRoot {
+		label += " (scan path)"
+	}
+	fmt.Printf("%s: %s\n", label, evaluation.Outcome)
+	for _, check := range evaluation.Checks {
+		status := "pass"
+		switch {
+		case check.Applies && evaluation.Outcome == scan.OutcomeSelect:
+			status = "match"
+		case check.Applies:
+			status = "skip"
+		}
+		detail := check.Detail
+		if detail == "" {
+			detail = "no match"
+		}
+		fmt.Printf("  [%s] %s: %s\n", status, check.Rule, detail)
+	}
+}
+
+// explainMinSize finishes the trace of a selected directory with the minSize
+// filter, which is applied once its size is known
+func explainMinSize(path string) error {
+	minSize, _ := Cfg.MinSizeBytes()
+	dirSize, err := size.CalculateDirectorySize(path)
+	if err != nil {
+		return fmt.Errorf("failed to calculate the size of %s: %w", path, err)
+	}
+	if dirSize < minSize {
+		fmt.Printf("  [skip] minSize: %s is below minSize %s\n", bytesize.Format(dirSize), bytesize.Format(minSize))
+		fmt.Println("\nNot reported: it is selected, but smaller than minSize.")
+		return nil
+	}
+	fmt.Printf("  [pass] minSize: %s is at least minSize %s\n", bytesize.Format(dirSize), bytesize.Format(minSize))
+	fmt.Println("\nSelected: scan reports it and clean deletes it.")
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+	explainCmd.Flags().String("root", "", "scan path to start from (default: the nearest configured scan path above the directory)")
+	explainCmd.Flags().StringP("min-size", "s", "", "minimum size, e.g. 500MB or 2GiB; a plain number is MiB (overrides config)")
+	explainCmd.Flags().IntP("max-depth", "d", 0, "maximum directory depth (overrides config)")
+	explainCmd.Flags().StringSliceP("include", "i", nil, "additional patterns to include")
+	explainCmd.Flags().StringSliceP("exclude", "e", nil, "additional patterns to exclude")
+	explainCmd.Flags().StringSlice("exclude-glob", nil, "exclude directories whose name matches these patterns, e.g. '*.egg-info'")
+	explainCmd.Flags().StringSlice("include-from", nil, "add the patterns in these files, one per line, to the include names")
+	explainCmd.Flags().StringSlice("exclude-from", nil, "add the patterns in these files, one per line, to the exclusions")
+	explainCmd.Flags().StringSlice("profile", nil, "built-in profiles to enable, e.g. node,python (overrides config)")
+	explainCmd.Flags().StringSlice("lang", nil, "scan only for these ecosystems' directories, e.g. node,rust, instead of the include list (see profiles list)")
+	explainCmd.MarkFlagsMutuallyExclusive("lang", "profile")
+	explainCmd.Flags().Bool("include-network-fs", false, "descend into network filesystems (NFS, SMB, ...) below the scan paths")
+	explainCmd.MarkFlagDirname("root")
+	explainCmd.RegisterFlagCompletionFunc("include", listCompletion(knownPatternNames))
+	explainCmd.RegisterFlagCompletionFunc("exclude", listCompletion(knownPatternNames))
+	explainCmd.RegisterFlagCompletionFunc("profile", listCompletion(config.ProfileNames))
+	explainCmd.RegisterFlagCompletionFunc("lang", listCompletion(config.ProfileNames))
+}
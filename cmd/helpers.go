@@ -1,14 +1,83 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/spf13/cobra"
+	"github.com/vbauerster/mpb/v8"
 	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/docker"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+	"github.com/yehia2amer/BuildBloatBuster/internal/report"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+	"github.com/yehia2amer/BuildBloatBuster/internal/size"
 )
 
-func checkScanPaths(scanPaths []string) error {
-	protectedPaths := config.GetProtectedPaths()
+// prepareScanPaths resolves the scan paths with resolveScanPaths and checks
+// that they are safe to scan
+func prepareScanPaths(allowHome bool) error {
+	scanPaths, err := resolveScanPaths(Cfg.ScanPaths)
+	if err != nil {
+		return err
+	}
+	Cfg.ScanPaths = scanPaths
+	return checkScanPaths(Cfg.ScanPaths, allowHome)
+}
+
+// resolveScanPaths expands a leading ~ in the scan paths and checks that they
+// exist. A path that doesn't exist or can't be accessed is skipped with a
+// warning; only when none is left does the run fail. A file stands for the
+// directory it is in.
+func resolveScanPaths(scanPaths []string) ([]string, error) {
+	var resolved []string
+	var errs []error
+	for _, scanPath := range scanPaths {
+		expanded := config.ExpandHome(scanPath)
+		info, err := os.Stat(expanded)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			errs = append(errs, fmt.Errorf("scan path %s does not exist", scanPath))
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("cannot access scan path %s: %w", scanPath, err))
+			continue
+		case !info.IsDir():
+			dir := filepath.Dir(expanded)
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "Note: %s is a file, scanning the directory it is in, %s\n", scanPath, dir)
+			}
+			expanded = dir
+		}
+		if !slices.Contains(resolved, expanded) {
+			resolved = append(resolved, expanded)
+		}
+	}
+	if len(resolved) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v, skipping it\n", err)
+	}
+	return resolved, nil
+}
+
+// checkScanPaths rejects scan paths that are, or lie inside, a protected system path
+// or the quarantine directory. The home directory root is only allowed when
+// allowHome is set.
+func checkScanPaths(scanPaths []string, allowHome bool) error {
+	var quarantineDir string
+	if Cfg.Delete.QuarantineDir != "" {
+		quarantineDir, _ = filepath.Abs(Cfg.Delete.QuarantineDir)
+	}
 	for _, scanPath := range scanPaths {
 		absScanPath, err := filepath.Abs(scanPath)
 		if err != nil {
@@ -16,15 +85,537 @@ func checkScanPaths(scanPaths []string) error {
 			return fmt.Errorf("could not verify safety of path %s: %w", scanPath, err)
 		}
 
-		for _, protected := range protectedPaths {
-			absProtected, err := filepath.Abs(protected)
+		// Check the path both as given and with symlinks resolved (e.g. /etc -> /private/etc)
+		checkPaths := []string{absScanPath}
+		if resolved, err := filepath.EvalSymlinks(absScanPath); err == nil && resolved != absScanPath {
+			checkPaths = append(checkPaths, resolved)
+		}
+
+		for _, p := range checkPaths {
+			if config.IsWithinProtectedPath(p) {
+				return fmt.Errorf("for your safety, scanning protected path '%s' is not allowed", scanPath)
+			}
+			if !allowHome && config.IsHomeDir(p) {
+				return fmt.Errorf("scanning your entire home directory '%s' requires --allow-home", scanPath)
+			}
+			if quarantineDir != "" && (p == quarantineDir || strings.HasPrefix(p, strings.TrimSuffix(quarantineDir, string(filepath.Separator))+string(filepath.Separator))) {
+				return fmt.Errorf("'%s' is inside the quarantine directory %s, whose items are never scanned; use restore or purge for them", scanPath, quarantineDir)
+			}
+		}
+	}
+	return nil
+}
+
+// sizeContext returns a context bounded by the overall scan and size timeout:
+// the --timeout flag if set, otherwise size.timeoutSeconds from the config.
+func sizeContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(Cfg.Size.TimeoutSeconds) * time.Second
+	if flagTimeout, err := cmd.Flags().GetDuration("timeout"); err == nil && flagTimeout > 0 {
+		timeout = flagTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// newSizeCalculator creates a size calculator from the current config. Unless
+// concurrency was set explicitly, the worker count is tuned per scan root
+// based on the storage it lives on. Sizes are cached on disk unless --no-cache
+// is given, or --not-accessed-since needs the access times only a walk finds.
+func newSizeCalculator(cmd *cobra.Command) *size.Calculator {
+	calculator := size.NewCalculator(Cfg.Concurrency)
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	notAccessedSince, _ := cmd.Flags().GetString("not-accessed-since")
+	// Cached entries miss writes below a candidate's top level, which could
+	// hide a running build from clean
+	protectRecent := cmd.Name() == "clean" && inUseWindow(cmd) > 0
+	if !noCache && notAccessedSince == "" && !protectRecent {
+		calculator.SetCache(size.LoadCache(size.DefaultCachePath()))
+	}
+	if Cfg.Concurrency <= 0 {
+		roots := make(map[string]int, len(Cfg.ScanPaths))
+		for _, scanPath := range Cfg.ScanPaths {
+			kind := size.DetectStorage(scanPath)
+			roots[scanPath] = size.WorkersFor(kind)
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Using %d size workers for %s (%s storage)\n", roots[scanPath], scanPath, kind)
+			}
+		}
+		calculator.SetRootConcurrency(roots)
+	} else if verbose {
+		fmt.Fprintf(os.Stderr, "Using %d size workers\n", Cfg.Concurrency)
+	}
+	calculator.SetCandidateTimeout(time.Duration(Cfg.Size.CandidateTimeoutSeconds) * time.Second)
+	calculator.SetFollowSymlinks(Cfg.FollowSymlinks)
+	calculator.SetReadRetries(Cfg.ReadRetries)
+	return calculator
+}
+
+// checkGlobalFlag reports whether --global was given, rejecting it together
+// with path arguments.
+func checkGlobalFlag(cmd *cobra.Command, paths []string) (bool, error) {
+	global, _ := cmd.Flags().GetBool("global")
+	if global && len(paths) > 0 {
+		return false, fmt.Errorf("--global scans the well-known cache locations and cannot be combined with paths")
+	}
+	return global, nil
+}
+
+// collectCandidates finds and sizes the candidates: the global caches with
+// --global, otherwise the directories found under the scan paths.
+func collectCandidates(cmd *cobra.Command, global bool) ([]scan.Candidate, error) {
+	if global {
+		return sizeCandidates(cmd, scan.FindGlobalCaches())
+	}
+
+	calculator := newSizeCalculator(cmd)
+	ctx, cancel := sizeContext(cmd)
+	defer cancel()
+
+	scanner := scan.NewScanner(Cfg)
+	traceDecisions(scanner)
+	// Only scan has --report-vcs
+	reportVCS, _ := cmd.Flags().GetBool("report-vcs")
+	scanner.SetReportVCS(reportVCS)
+	candidates, err := scanAndSize(ctx, scanner, calculator, progressEnabled())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStrict(cmd, slices.Concat(scanner.Unreadable(), calculator.Unreadable())); err != nil {
+		return nil, err
+	}
+	readErrors = scan.SortReadErrors(slices.Concat(scanner.ReadErrors(), calculator.ReadErrors()))
+	reportReadErrors(cmd)
+	if slices.Contains(Cfg.Presets, config.PresetDocker) {
+		candidates = append(candidates, dockerCandidates(ctx)...)
+	}
+	reportSkippedNetworkFS(scanner)
+	reportCapped(scanner)
+	reportScanStats(scanner)
+	scan.AssignIDs(candidates)
+	return candidates, nil
+}
+
+// sizeCandidates sizes candidates that were listed rather than found by
+// scanning, such as the global caches, and assigns their IDs
+func sizeCandidates(cmd *cobra.Command, candidates []scan.Candidate) ([]scan.Candidate, error) {
+	calculator := newSizeCalculator(cmd)
+	ctx, cancel := sizeContext(cmd)
+	defer cancel()
+
+	if progressEnabled() {
+		p := newProgress(mpb.WithRefreshRate(180 * time.Millisecond))
+		calculator.SetProgress(p)
+		defer p.Wait()
+	}
+	candidates, err := calculator.CalculateSizes(ctx, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("size calculation failed: %w", err)
+	}
+	if err := checkStrict(cmd, calculator.Unreadable()); err != nil {
+		return nil, err
+	}
+	readErrors = calculator.ReadErrors()
+	reportReadErrors(cmd)
+	reportWarnings(candidates)
+	scan.AssignIDs(candidates)
+	return candidates, nil
+}
+
+// dockerCandidates returns the candidate for Docker's build cache, if any of
+// it is reclaimable. Without a docker CLI there is nothing to report; a CLI
+// that fails, e.g. because the daemon isn't running, is warned about.
+func dockerCandidates(ctx context.Context) []scan.Candidate {
+	candidate, ok, err := docker.BuildCacheCandidate(ctx)
+	switch {
+	case errors.Is(err, docker.ErrNotInstalled):
+		slog.Debug("docker preset skipped", "error", err)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Warning: docker preset: %v\n", err)
+	case ok:
+		return []scan.Candidate{candidate}
+	}
+	return nil
+}
+
+// checkStrict fails the run with --strict when directories couldn't be read
+// while scanning or sizing; without it, they are skipped
+func checkStrict(cmd *cobra.Command, unreadable []string) error {
+	if strict, _ := cmd.Flags().GetBool("strict"); !strict || len(unreadable) == 0 {
+		return nil
+	}
+	slices.Sort(unreadable)
+	unreadable = slices.Compact(unreadable)
+	return fmt.Errorf("--strict: %d paths could not be read:\n  %s", len(unreadable), strings.Join(unreadable, "\n  "))
+}
+
+// openFiles finds the processes holding files open in directories; tests
+// replace it to simulate them
+var openFiles = erase.OpenFiles
+
+// skipOpenCandidates leaves out, with a warning naming the processes, the
+// candidates that a running process has files open in, such as the
+// node_modules of a dev server. --no-open-file-check turns the check off.
+func skipOpenCandidates(cmd *cobra.Command, candidates []scan.Candidate) []scan.Candidate {
+	if noCheck, _ := cmd.Flags().GetBool("no-open-file-check"); noCheck || len(candidates) == 0 {
+		return candidates
+	}
+	paths := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		paths[i] = candidate.Path
+	}
+	holders, err := openFiles(paths)
+	if err != nil {
+		if !erase.IsOpenFilesUnsupported(err) {
+			fmt.Fprintf(os.Stderr, "Warning: could not check for open files: %v\n", err)
+		}
+		return candidates
+	}
+
+	var kept []scan.Candidate
+	for _, candidate := range candidates {
+		processes := holders[filepath.Clean(candidate.Path)]
+		if len(processes) == 0 {
+			kept = append(kept, candidate)
+			continue
+		}
+		names := make([]string, len(processes))
+		for i, process := range processes {
+			names[i] = process.String()
+		}
+		fmt.Fprintf(os.Stderr, "Skipping %s: files are open in %s (use --no-open-file-check to clean it anyway)\n",
+			candidate.Path, strings.Join(names, ", "))
+	}
+	return kept
+}
+
+// traceDecisions prints, with --verbose, which rule selected or skipped each
+// directory so conflicting include and exclude rules can be debugged. It goes
+// to stderr to keep JSON output on stdout intact.
+func traceDecisions(scanner *scan.Scanner) {
+	if !verbose {
+		return
+	}
+	scanner.OnDecision(func(d scan.Decision) {
+		action := "skip"
+		if d.Selected {
+			action = "match"
+		}
+		fmt.Fprintf(os.Stderr, "%-5s %s (%s)\n", action, d.Path, d.Rule)
+	})
+}
+
+// reportSkippedNetworkFS tells the user which network filesystems the scan left out
+func reportSkippedNetworkFS(scanner *scan.Scanner) {
+	if quiet {
+		return
+	}
+	for _, path := range scanner.SkippedNetworkFS() {
+		fmt.Fprintf(os.Stderr, "Skipped network filesystem %s (use --include-network-fs to scan it)\n", path)
+	}
+}
+
+// reportWarnings prints the warnings of candidates that may need manual
+// follow-up after cleaning, such as simulators Xcode still lists
+func reportWarnings(candidates []scan.Candidate) {
+	if quiet {
+		return
+	}
+	for _, candidate := range candidates {
+		if candidate.Warning != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", candidate.Path, candidate.Warning)
+		}
+	}
+}
+
+// readErrors are the paths the last scan and sizing couldn't read, which
+// JSON output and the webhook report list as "errors"
+var readErrors []scan.ReadError
+
+// reportReadErrors tells the user how many paths couldn't be read, so the
+// sizes aren't mistaken for complete, and lists them with --show-errors.
+// JSON output lists them anyway.
+func reportReadErrors(cmd *cobra.Command) {
+	if quiet || Cfg.MachineReadable() || len(readErrors) == 0 {
+		return
+	}
+	if show, _ := cmd.Flags().GetBool("show-errors"); !show {
+		fmt.Fprintf(os.Stderr, "Warning: %d paths could not be read and are left out (use --show-errors to list them)\n", len(readErrors))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %d paths could not be read and are left out:\n", len(readErrors))
+	for _, readErr := range readErrors {
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", readErr.Path, readErr.Error)
+	}
+}
+
+// reportCapped tells the user when the scan stopped at --max-results, so
+// the results are not mistaken for everything there is
+func reportCapped(scanner *scan.Scanner) {
+	if quiet || !scanner.Capped() {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Results capped: stopped scanning after %d directories (raise --max-results or set it to 0 to see all)\n", Cfg.MaxResults)
+}
+
+// reportScanStats prints, with --verbose, how many directories the scan
+// visited and what became of them
+func reportScanStats(scanner *scan.Scanner) {
+	if !verbose {
+		return
+	}
+	stats := scanner.Stats()
+	fmt.Fprintf(os.Stderr, "Scanned %d directories: %d walked into, %d candidates, %d skipped (maxDepth %d, excluded %d, version control %d, network filesystems %d)\n",
+		stats.DirsVisited, stats.DirsDescended, stats.CandidatesFound, stats.Skipped(),
+		stats.SkippedDepth, stats.SkippedExcluded, stats.SkippedVCS, stats.SkippedNetworkFS)
+}
+
+// assumeYes reports whether to proceed without asking for confirmation:
+// --yes if it was given, otherwise unless behavior.requireConfirmation is set
+func assumeYes(cmd *cobra.Command) bool {
+	if cmd.Flags().Changed("yes") {
+		yes, _ := cmd.Flags().GetBool("yes")
+		return yes
+	}
+	return !Cfg.Behavior.RequireConfirmation
+}
+
+// applyConfigFlags applies the config-overriding flags that were given on the
+// command line and records them as the source of those settings. It fails
+// when a pattern file of --include-from or --exclude-from can't be read.
+func applyConfigFlags(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if flags.Changed("min-size") {
+		Cfg.MinSize, _ = flags.GetString("min-size")
+		CfgSources.Set("minSize", "flag --min-size")
+	}
+	if flags.Changed("max-depth") {
+		Cfg.MaxDepth, _ = flags.GetInt("max-depth")
+		CfgSources.Set("maxDepth", "flag --max-depth")
+	}
+	if flags.Changed("max-results") {
+		Cfg.MaxResults, _ = flags.GetInt("max-results")
+		CfgSources.Set("maxResults", "flag --max-results")
+	}
+	if flags.Changed("lang") {
+		// Only the languages' own names are selected, not the default list;
+		// --include still adds to them
+		Cfg.Profiles, _ = flags.GetStringSlice("lang")
+		Cfg.IncludeNames = nil
+		CfgSources.Set("profiles", "flag --lang")
+		CfgSources.Set("includeNames", "flag --lang")
+	}
+	if flags.Changed("include") {
+		include, _ := flags.GetStringSlice("include")
+		includeNames(include)
+		CfgSources.Set("includeNames", CfgSources.Source("includeNames")+" + flag --include")
+	}
+	if flags.Changed("include-from") {
+		files, _ := flags.GetStringSlice("include-from")
+		for _, file := range files {
+			patterns, err := config.ReadPatternFile(file)
 			if err != nil {
-				continue
+				return fmt.Errorf("--include-from: %w", err)
 			}
-			if absScanPath == absProtected {
-				return fmt.Errorf("for your safety, scanning protected path '%s' is not allowed", scanPath)
+			includeNames(patterns)
+			Cfg.IncludeFrom = append(Cfg.IncludeFrom, absPath(file))
+		}
+		CfgSources.Set("includeNames", CfgSources.Source("includeNames")+" + flag --include-from")
+		CfgSources.Set("includeFrom", "flag --include-from")
+	}
+	if flags.Changed("exclude") {
+		exclude, _ := flags.GetStringSlice("exclude")
+		Cfg.ExcludeNames = append(Cfg.ExcludeNames, exclude...)
+		CfgSources.Set("excludeNames", CfgSources.Source("excludeNames")+" + flag --exclude")
+	}
+	if flags.Changed("exclude-from") {
+		files, _ := flags.GetStringSlice("exclude-from")
+		for _, file := range files {
+			if err := Cfg.AddExcludeFrom(file); err != nil {
+				return fmt.Errorf("--exclude-from: %w", err)
 			}
+			Cfg.ExcludeFrom = append(Cfg.ExcludeFrom, absPath(file))
 		}
+		CfgSources.Set("excludeNames", CfgSources.Source("excludeNames")+" + flag --exclude-from")
+		CfgSources.Set("excludeFrom", "flag --exclude-from")
+	}
+	if flags.Changed("exclude-glob") {
+		globs, _ := flags.GetStringSlice("exclude-glob")
+		Cfg.ExcludeGlobs = append(Cfg.ExcludeGlobs, globs...)
+		CfgSources.Set("excludeGlobs", CfgSources.Source("excludeGlobs")+" + flag --exclude-glob")
+	}
+	if flags.Changed("profile") {
+		Cfg.Profiles, _ = flags.GetStringSlice("profile")
+		CfgSources.Set("profiles", "flag --profile")
+	}
+	if flags.Changed("presets") {
+		Cfg.Presets, _ = flags.GetStringSlice("presets")
+		CfgSources.Set("presets", "flag --presets")
+	}
+	if flags.Changed("include-network-fs") {
+		Cfg.IncludeNetworkFS, _ = flags.GetBool("include-network-fs")
+		CfgSources.Set("includeNetworkFS", "flag --include-network-fs")
+	}
+	if flags.Changed("concurrency") {
+		Cfg.Concurrency, _ = flags.GetInt("concurrency")
+		CfgSources.Set("concurrency", "flag --concurrency")
+	}
+	if flags.Changed("delete-mode") {
+		Cfg.Delete.Mode, _ = flags.GetString("delete-mode")
+		CfgSources.Set("delete.mode", "flag --delete-mode")
+	}
+	if flags.Changed("quarantine-dir") {
+		Cfg.Delete.QuarantineDir, _ = flags.GetString("quarantine-dir")
+		CfgSources.Set("delete.quarantineDir", "flag --quarantine-dir")
+	}
+	if flags.Changed("retention-days") {
+		Cfg.Delete.RetentionDays, _ = flags.GetInt("retention-days")
+		CfgSources.Set("delete.retentionDays", "flag --retention-days")
+	}
+	if flags.Changed("audit-log") {
+		Cfg.Delete.AuditLog, _ = flags.GetString("audit-log")
+		CfgSources.Set("delete.auditLog", "flag --audit-log")
 	}
 	return nil
 }
+
+// includeNames adds names to the include names. Flags beat the config file,
+// so an included name is no longer excluded by the configuration.
+func includeNames(names []string) {
+	Cfg.IncludeNames = append(Cfg.IncludeNames, names...)
+	Cfg.ExcludeNames = slices.DeleteFunc(slices.Clone(Cfg.ExcludeNames), func(name string) bool {
+		return slices.Contains(names, name)
+	})
+}
+
+// absPath returns path made absolute, or path itself if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// applyFormatFlag overrides the configured output format, sort order and size
+// unit with --format, --sort and --size-unit, if given.
+func applyFormatFlag(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("format") {
+		Cfg.Output.Format, _ = cmd.Flags().GetString("format")
+		CfgSources.Set("output.format", "flag --format")
+	}
+	if cmd.Flags().Changed("sort") {
+		Cfg.Output.SortBy, _ = cmd.Flags().GetString("sort")
+		CfgSources.Set("output.sortBy", "flag --sort")
+	}
+	if cmd.Flags().Changed("size-unit") {
+		Cfg.Output.SizeUnit, _ = cmd.Flags().GetString("size-unit")
+		CfgSources.Set("output.sizeUnit", "flag --size-unit")
+	}
+	return Cfg.Validate()
+}
+
+// applyOnlyFlag keeps the candidates that matched one of the include names
+// given with --only, if any. Names no include pattern knows are warned about,
+// since they can never match.
+func applyOnlyFlag(cmd *cobra.Command, candidates []scan.Candidate) []scan.Candidate {
+	only, _ := cmd.Flags().GetStringSlice("only")
+	if len(only) == 0 {
+		return candidates
+	}
+	known := append(knownPatternNames(), Cfg.IncludeNames...)
+	for _, name := range only {
+		if !slices.Contains(known, name) {
+			fmt.Fprintf(os.Stderr, "Warning: --only %s is not an include pattern, so nothing matches it\n", name)
+		}
+	}
+	return report.FilterByMatchedName(candidates, only)
+}
+
+// notAccessedSinceFlag parses --not-accessed-since, an age such as 30d. It is
+// zero if the flag wasn't given.
+func notAccessedSinceFlag(cmd *cobra.Command) (time.Duration, error) {
+	value, _ := cmd.Flags().GetString("not-accessed-since")
+	if value == "" {
+		return 0, nil
+	}
+	age, err := parseAge(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --not-accessed-since %q: %w", value, err)
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("--not-accessed-since must be greater than zero")
+	}
+	return age, nil
+}
+
+// filterNotAccessedSince keeps the candidates not used within age. Candidates
+// without access times, e.g. on noatime mounts, are judged by their
+// modification times instead.
+func filterNotAccessedSince(candidates []scan.Candidate, age time.Duration) []scan.Candidate {
+	for _, candidate := range candidates {
+		if candidate.NewestATime.IsZero() {
+			slog.Debug("no access times recorded, using modification times", "path", candidate.Path)
+		}
+	}
+	return size.FilterNotUsedSince(candidates, time.Now().Add(-age))
+}
+
+// inUseWindow returns how recently a candidate may have been modified before
+// it is skipped as possibly in use by a running build; 0 with --force-recent
+func inUseWindow(cmd *cobra.Command) time.Duration {
+	if force, _ := cmd.Flags().GetBool("force-recent"); force {
+		return 0
+	}
+	window, _ := Cfg.InUseWindow()
+	return window
+}
+
+// filterReclaimable drops the candidates below the minimum size or file count
+// and those that look in use. Informational candidates, such as the .git
+// directories of scan --report-vcs, are never cleaned and are kept as found.
+func filterReclaimable(cmd *cobra.Command, candidates []scan.Candidate) []scan.Candidate {
+	var reclaimable, informational []scan.Candidate
+	for _, candidate := range candidates {
+		if candidate.Informational {
+			informational = append(informational, candidate)
+		} else {
+			reclaimable = append(reclaimable, candidate)
+		}
+	}
+	minSize, _ := Cfg.MinSizeBytes()
+	minFiles, _ := cmd.Flags().GetInt64("min-files")
+	reclaimable = size.FilterByMinFileCount(size.FilterByMinSize(reclaimable, minSize), minFiles)
+	return append(filterRecentlyModified(cmd, reclaimable), informational...)
+}
+
+// filterRecentlyModified drops the candidates modified within the in-use
+// window. Every skip is listed with --verbose, like the rule decisions of
+// the scan, and their number is always mentioned.
+func filterRecentlyModified(cmd *cobra.Command, candidates []scan.Candidate) []scan.Candidate {
+	window := inUseWindow(cmd)
+	if window <= 0 {
+		return candidates
+	}
+	now := time.Now()
+	older, recent := size.SplitRecentlyModified(candidates, now.Add(-window))
+	if verbose {
+		for _, candidate := range recent {
+			fmt.Fprintf(os.Stderr, "%-5s %s (skipIfModifiedWithin %s: modified %s ago)\n",
+				"skip", candidate.Path, Cfg.SkipIfModifiedWithin, now.Sub(candidate.NewestMTime).Round(time.Second))
+		}
+	}
+	if len(recent) > 0 && !quiet {
+		fmt.Fprintf(os.Stderr, "Skipped %d directories modified within %s, a build may still be using them (use --force-recent to include them)\n",
+			len(recent), Cfg.SkipIfModifiedWithin)
+	}
+	return older
+}
+
+// applyScanPathArgs makes positional path arguments the scan paths, if any were given.
+func applyScanPathArgs(paths []string) {
+	if len(paths) > 0 {
+		Cfg.ScanPaths = paths
+		CfgSources.Set("scanPaths", "arguments")
+	}
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestCheckScanPaths(t *testing.T) {
+	fakeHome := t.TempDir()
+	t.Setenv("HOME", fakeHome)
+	t.Setenv("USERPROFILE", fakeHome)
+
+	t.Run("rejects protected path", func(t *testing.T) {
+		assert.Error(t, checkScanPaths([]string{"/usr"}, false))
+	})
+
+	t.Run("rejects path inside protected path", func(t *testing.T) {
+		assert.Error(t, checkScanPaths([]string{"/usr/local"}, false))
+	})
+
+	t.Run("home root requires allow-home", func(t *testing.T) {
+		assert.Error(t, checkScanPaths([]string{fakeHome}, false))
+		assert.NoError(t, checkScanPaths([]string{fakeHome}, true))
+	})
+
+	t.Run("allows project inside home", func(t *testing.T) {
+		assert.NoError(t, checkScanPaths([]string{filepath.Join(fakeHome, "projects")}, false))
+	})
+
+	t.Run("rejects the quarantine directory", func(t *testing.T) {
+		oldCfg := Cfg
+		t.Cleanup(func() { Cfg = oldCfg })
+		workspace := filepath.Join(fakeHome, "work")
+		Cfg = config.GetDefaults()
+		Cfg.Delete.QuarantineDir = filepath.Join(workspace, ".bbb-trash")
+
+		assert.ErrorContains(t, checkScanPaths([]string{Cfg.Delete.QuarantineDir}, false), "inside the quarantine directory")
+		assert.ErrorContains(t, checkScanPaths([]string{filepath.Join(Cfg.Delete.QuarantineDir, "20240101-120000-node_modules")}, false), "inside the quarantine directory")
+		// A workspace holding the quarantine is scanned, without the quarantine
+		assert.NoError(t, checkScanPaths([]string{workspace}, false))
+	})
+}
+
+func TestResolveScanPaths(t *testing.T) {
+	fakeHome := t.TempDir()
+	t.Setenv("HOME", fakeHome)
+	t.Setenv("USERPROFILE", fakeHome)
+	work := filepath.Join(fakeHome, "work")
+	require.NoError(t, os.MkdirAll(work, 0755))
+	file := filepath.Join(work, "package.json")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	missing := filepath.Join(fakeHome, "missing")
+
+	t.Run("expands ~", func(t *testing.T) {
+		resolved, err := resolveScanPaths([]string{"~/work"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{work}, resolved)
+	})
+
+	t.Run("a file stands for its directory", func(t *testing.T) {
+		resolved, err := resolveScanPaths([]string{work, file})
+		require.NoError(t, err)
+		assert.Equal(t, []string{work}, resolved)
+	})
+
+	t.Run("skips missing paths", func(t *testing.T) {
+		resolved, err := resolveScanPaths([]string{missing, work})
+		require.NoError(t, err)
+		assert.Equal(t, []string{work}, resolved)
+	})
+
+	t.Run("fails when no path exists", func(t *testing.T) {
+		_, err := resolveScanPaths([]string{missing, "~/gone"})
+		assert.ErrorContains(t, err, "scan path "+missing+" does not exist")
+		assert.ErrorContains(t, err, "scan path ~/gone does not exist")
+	})
+}
+
+func TestApplyConfigFlags_IncludeBeatsConfigExclude(t *testing.T) {
+	oldCfg, oldSources := Cfg, CfgSources
+	defer func() { Cfg, CfgSources = oldCfg, oldSources }()
+
+	Cfg = config.GetDefaults()
+	Cfg.ExcludeNames = []string{"src", "vendor"}
+	CfgSources = config.Provenance{}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().StringSliceP("include", "i", nil, "")
+	require.NoError(t, cmd.Flags().Set("include", "vendor"))
+	require.NoError(t, applyConfigFlags(cmd))
+
+	assert.Contains(t, Cfg.IncludeNames, "vendor")
+	assert.Equal(t, []string{"src"}, Cfg.ExcludeNames)
+}
+
+func TestApplyConfigFlags_PatternFiles(t *testing.T) {
+	oldCfg, oldSources := Cfg, CfgSources
+	defer func() { Cfg, CfgSources = oldCfg, oldSources }()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.WriteFile("caches.txt", []byte("# team caches\n.turbo\n\nvendor\n"), 0644))
+	require.NoError(t, os.WriteFile("keep.txt", []byte("fixtures\ntmp-*\n"), 0644))
+
+	Cfg = config.GetDefaults()
+	Cfg.ExcludeNames = []string{"src", "vendor"}
+	CfgSources = config.Provenance{}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().StringSlice("include-from", nil, "")
+	cmd.Flags().StringSlice("exclude-from", nil, "")
+	require.NoError(t, cmd.Flags().Set("include-from", "caches.txt"))
+	require.NoError(t, cmd.Flags().Set("exclude-from", "keep.txt"))
+	require.NoError(t, applyConfigFlags(cmd))
+
+	// Relative paths are read from the working directory, and included
+	// names lift configured exclusions like --include does
+	assert.Subset(t, Cfg.IncludeNames, []string{".turbo", "vendor"})
+	assert.Equal(t, []string{"src", "fixtures"}, Cfg.ExcludeNames)
+	assert.Equal(t, []string{"tmp-*"}, Cfg.ExcludeGlobs)
+	assert.Equal(t, []string{filepath.Join(dir, "caches.txt")}, Cfg.IncludeFrom)
+	assert.Equal(t, "flag --exclude-from", CfgSources.Source("excludeFrom"))
+
+	require.NoError(t, cmd.Flags().Set("include-from", "missing.txt"))
+	assert.ErrorContains(t, applyConfigFlags(cmd), "--include-from")
+}
+
+func TestApplyConfigFlags_Lang(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"web/node_modules", "web/.turbo", "cli/target", "tool/.venv", "tool/pkg/__pycache__", "tool/.pytest_cache", "web/dist", "ios/Pods"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	for _, file := range []string{"web/package.json", "cli/Cargo.toml", "tool/pyproject.toml", "ios/Podfile"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, file), nil, 0644))
+	}
+
+	tests := []struct {
+		lang    string
+		include string
+		want    []string
+	}{
+		{"node,rust", "", []string{"cli/target", "web/.turbo", "web/dist", "web/node_modules"}},
+		{"python", "", []string{"tool/.pytest_cache", "tool/.venv", "tool/pkg/__pycache__"}},
+		{"rust", "dist", []string{"cli/target", "web/dist"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			oldCfg, oldSources := Cfg, CfgSources
+			defer func() { Cfg, CfgSources = oldCfg, oldSources }()
+			Cfg = config.GetDefaults()
+			Cfg.ScanPaths = []string{root}
+			Cfg.ExcludePaths = []string{}
+			CfgSources = config.Provenance{}
+
+			cmd := &cobra.Command{}
+			cmd.Flags().StringSlice("lang", nil, "")
+			cmd.Flags().StringSliceP("include", "i", nil, "")
+			require.NoError(t, cmd.Flags().Set("lang", tt.lang))
+			if tt.include != "" {
+				require.NoError(t, cmd.Flags().Set("include", tt.include))
+			}
+			require.NoError(t, applyConfigFlags(cmd))
+			require.NoError(t, Cfg.Validate())
+			assert.Equal(t, "flag --lang", CfgSources.Source("profiles"))
+
+			candidates, err := scan.NewScanner(Cfg).ScanPaths()
+			require.NoError(t, err)
+			var found []string
+			for _, candidate := range candidates {
+				rel, err := filepath.Rel(root, candidate.Path)
+				require.NoError(t, err)
+				found = append(found, filepath.ToSlash(rel))
+			}
+			slices.Sort(found)
+			assert.Equal(t, tt.want, found)
+		})
+	}
+}
+
+func TestNewSizeCalculator_ConcurrencyFlag(t *testing.T) {
+	oldCfg, oldSources := Cfg, CfgSources
+	defer func() { Cfg, CfgSources = oldCfg, oldSources }()
+
+	Cfg = config.GetDefaults()
+	Cfg.Concurrency = 8
+	CfgSources = config.Provenance{}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Int("concurrency", 0, "")
+	cmd.Flags().Bool("no-cache", true, "")
+	require.NoError(t, cmd.Flags().Set("concurrency", "3"))
+	require.NoError(t, applyConfigFlags(cmd))
+
+	assert.Equal(t, 3, newSizeCalculator(cmd).Concurrency())
+	assert.Equal(t, "flag --concurrency", CfgSources.Source("concurrency"))
+}
+
+func TestSkipOpenCandidates(t *testing.T) {
+	originalOpenFiles := openFiles
+	t.Cleanup(func() {
+		openFiles = originalOpenFiles
+		flag := cleanCmd.Flags().Lookup("no-open-file-check")
+		require.NoError(t, flag.Value.Set(flag.DefValue))
+		flag.Changed = false
+	})
+	openFiles = func(dirs []string) (map[string][]erase.Process, error) {
+		return map[string][]erase.Process{"/p/web/node_modules": {{PID: 42, Name: "node"}}}, nil
+	}
+	candidates := []scan.Candidate{{Path: "/p/web/node_modules"}, {Path: "/p/api/target"}}
+
+	kept := skipOpenCandidates(cleanCmd, candidates)
+	assert.Equal(t, []scan.Candidate{{Path: "/p/api/target"}}, kept)
+
+	require.NoError(t, cleanCmd.Flags().Set("no-open-file-check", "true"))
+	assert.Equal(t, candidates, skipOpenCandidates(cleanCmd, candidates))
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/size"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <path>",
+	Short: "Show what takes up the space in a directory",
+	Long: `Walks a directory and shows its total size, file count and newest
+modification time, along with its largest immediate subdirectories and its
+largest files at any depth, so a candidate can be looked into before it is
+deleted.
+
+Protected paths are refused. Files and directories that can't be read are
+left out of the totals and counted as unreadable.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeScanPaths,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		top, _ := cmd.Flags().GetInt("top")
+		return runInspect(cmd, args[0], format, top)
+	},
+}
+
+func runInspect(cmd *cobra.Command, path, format string, top int) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unsupported format: %s (use table or json)", format)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	if config.IsWithinProtectedPath(absPath) {
+		return fmt.Errorf("refusing to inspect protected path: %s", absPath)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", absPath)
+	}
+
+	calculator := size.NewCalculator(Cfg.Concurrency)
+	calculator.SetFollowSymlinks(Cfg.FollowSymlinks)
+	calculator.SetReadRetries(Cfg.ReadRetries)
+	ctx, cancel := sizeContext(cmd)
+	defer cancel()
+	inspection, err := calculator.Inspect(ctx, absPath, top)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", absPath, err)
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(inspection)
+	}
+	printInspection(inspection)
+	return nil
+}
+
+// printInspection prints the totals of an inspection and its largest
+// subdirectories and files as tables
+func printInspection(in size.Inspection) {
+	fmt.Printf("%s: %s in %d files", in.Path, bytesize.Format(in.SizeBytes), in.FileCount)
+	if !in.NewestModTime.IsZero() {
+		fmt.Printf(", newest modified %s", in.NewestModTime.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Println()
+	if in.Unreadable > 0 {
+		fmt.Printf("%d files or directories could not be read and are not counted.\n", in.Unreadable)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(in.Subdirectories) > 0 {
+		fmt.Fprintln(w, "\nLargest subdirectories:")
+		fmt.Fprintln(w, "SIZE\tFILES\tNEWEST MODIFIED\tPATH")
+		for _, dir := range in.Subdirectories {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", bytesize.Format(dir.SizeBytes), dir.FileCount,
+				formatInspectTime(dir.ModTime), dir.Path+string(filepath.Separator))
+		}
+		w.Flush()
+	}
+	if len(in.Files) > 0 {
+		fmt.Fprintln(w, "\nLargest files:")
+		fmt.Fprintln(w, "SIZE\tMODIFIED\tPATH")
+		for _, file := range in.Files {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", bytesize.Format(file.SizeBytes), formatInspectTime(file.ModTime), file.Path)
+		}
+		w.Flush()
+	}
+}
+
+// formatInspectTime formats a modification time, which empty directories lack
+func formatInspectTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().String("format", "table", "output format (table, json)")
+	inspectCmd.Flags().Int("top", 10, "number of largest subdirectories and files to show")
+	inspectCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"table", "json"}, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the audit log of removed and restored directories",
+	Long: `Shows the audit log: every quarantine, rm, restore and purge, with the user,
+hostname and run that did it, oldest first.
+
+Filter it by date with --since and --until (YYYY-MM-DD or RFC 3339), by path
+with --path, which matches the path and everything below it, and by --action.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var filter auditFilter
+		var err error
+		flags := cmd.Flags()
+		since, _ := flags.GetString("since")
+		if filter.since, err = parseLogTime("since", since, false); err != nil {
+			return err
+		}
+		until, _ := flags.GetString("until")
+		if filter.until, err = parseLogTime("until", until, true); err != nil {
+			return err
+		}
+		filter.action, _ = flags.GetString("action")
+		if filter.path, _ = flags.GetString("path"); filter.path != "" {
+			if filter.path, err = filepath.Abs(filter.path); err != nil {
+				return err
+			}
+		}
+		format, _ := flags.GetString("format")
+		return runLog(Cfg.Delete.AuditLog, filter, format)
+	},
+}
+
+// auditFilter selects audit log entries; zero fields match everything
+type auditFilter struct {
+	since, until time.Time
+	// path matches entries for this path or anything below it
+	path   string
+	action string
+}
+
+func (f auditFilter) matches(entry erase.AuditEntry) bool {
+	if !f.since.IsZero() && entry.Timestamp.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !entry.Timestamp.Before(f.until) {
+		return false
+	}
+	if f.action != "" && entry.Action != f.action {
+		return false
+	}
+	if f.path != "" {
+		rel, err := filepath.Rel(f.path, entry.OriginalPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLogTime parses the value of --since or --until. A date alone means the
+// start of that day in local time, or with endOfDay the start of the next day,
+// so that --until includes the given day.
+func parseLogTime(name, value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --%s %q: use YYYY-MM-DD or RFC 3339, e.g. 2024-05-01T15:04:05Z", name, value)
+	}
+	return t, nil
+}
+
+func runLog(path string, filter auditFilter, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unsupported format: %s (use table or json)", format)
+	}
+	if path == "" {
+		return errors.New("the audit log is disabled; set delete.auditLog in the config or pass --audit-log to clean")
+	}
+
+	entries, err := erase.LoadAuditLog(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read audit log: %w", err)
+	}
+
+	matched := []erase.AuditEntry{}
+	for _, entry := range entries {
+		if filter.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(matched)
+	}
+
+	if len(matched) == 0 {
+		fmt.Println("No audit log entries found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTION\tSIZE\tBY\tRUN\tPATH")
+	for _, entry := range matched {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s@%s\t%s\t%s\n",
+			entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.Action,
+			bytesize.Format(entry.SizeBytes), entry.User, entry.Hostname, entry.RunID, entry.OriginalPath)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+	logCmd.Flags().String("since", "", "only show entries at or after this date (YYYY-MM-DD or RFC 3339)")
+	logCmd.Flags().String("until", "", "only show entries up to this date, inclusive (YYYY-MM-DD or RFC 3339)")
+	logCmd.Flags().String("path", "", "only show entries for this path or below it")
+	logCmd.Flags().String("action", "", "only show entries of this action (quarantine, rm, trash, restore, purge)")
+	logCmd.Flags().String("format", "table", "output format (table, json)")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+)
+
+func TestAuditFilter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 5, d, 0, 0, 0, 0, time.Local) }
+	entry := erase.AuditEntry{
+		Timestamp:    day(10).Add(15 * time.Hour),
+		Action:       erase.ActionQuarantine,
+		OriginalPath: "/home/me/projects/app/node_modules",
+	}
+
+	tests := []struct {
+		name   string
+		filter auditFilter
+		want   bool
+	}{
+		{"no filter", auditFilter{}, true},
+		{"since before", auditFilter{since: day(10)}, true},
+		{"since after", auditFilter{since: day(11)}, false},
+		{"until after", auditFilter{until: day(11)}, true},
+		{"until before", auditFilter{until: day(10)}, false},
+		{"action", auditFilter{action: erase.ActionQuarantine}, true},
+		{"other action", auditFilter{action: erase.ActionRestore}, false},
+		{"parent path", auditFilter{path: "/home/me/projects"}, true},
+		{"same path", auditFilter{path: "/home/me/projects/app/node_modules"}, true},
+		{"sibling with common prefix", auditFilter{path: "/home/me/projects/ap"}, false},
+		{"unrelated path", auditFilter{path: "/srv"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.matches(entry))
+		})
+	}
+}
+
+func TestParseLogTime(t *testing.T) {
+	since, err := parseLogTime("since", "2026-05-10", false)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 10, 0, 0, 0, 0, time.Local), since)
+
+	// A date alone includes the whole day in --until
+	until, err := parseLogTime("until", "2026-05-10", true)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 11, 0, 0, 0, 0, time.Local), until)
+
+	exact, err := parseLogTime("until", "2026-05-10T12:00:00Z", true)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC), exact)
+
+	_, err = parseLogTime("since", "last week", false)
+	assert.ErrorContains(t, err, `invalid --since "last week"`)
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// logFile is the file --log-file appends JSON logs to
+var logFile string
+
+// logLevel returns the level --verbose and --quiet select: warnings by
+// default, debug detail with --verbose and only errors with --quiet
+func logLevel() slog.Level {
+	switch {
+	case verbose:
+		return slog.LevelDebug
+	case quiet:
+		return slog.LevelError
+	}
+	return slog.LevelWarn
+}
+
+// setupLogging makes slog's default logger, which the scanner, calculator and
+// eraser log to, print to stderr and, with --log-file, also append JSON lines
+// to that file
+func setupLogging() error {
+	level := logLevel()
+	handler := slog.Handler(newConsoleHandler(os.Stderr, level))
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open log file: %w", err)
+		}
+		// The file stays open until the process exits
+		handler = fanoutHandler{handler, slog.NewJSONHandler(file, &slog.HandlerOptions{Level: level})}
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// consoleHandler writes log records for people: the message after a prefix
+// such as "Warning: ", followed by the attributes as key=value pairs
+type consoleHandler struct {
+	mu    *sync.Mutex
+	out   io.Writer
+	level slog.Leveler
+	// attrs are the attributes added with WithAttrs, already formatted
+	attrs  string
+	prefix string
+}
+
+func newConsoleHandler(out io.Writer, level slog.Leveler) *consoleHandler {
+	return &consoleHandler{mu: &sync.Mutex{}, out: out, level: level}
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, record slog.Record) error {
+	var b strings.Builder
+	switch {
+	case record.Level >= slog.LevelError:
+		b.WriteString("Error: ")
+	case record.Level >= slog.LevelWarn:
+		b.WriteString("Warning: ")
+	case record.Level < slog.LevelInfo:
+		b.WriteString("Debug: ")
+	}
+	b.WriteString(record.Message)
+	b.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		h.writeAttr(&b, h.prefix, attr)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, attr := range attrs {
+		h.writeAttr(&b, h.prefix, attr)
+	}
+	clone := *h
+	clone.attrs += b.String()
+	return &clone
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// writeAttr appends attr as " key=value", flattening groups into dotted keys
+func (h *consoleHandler) writeAttr(b *strings.Builder, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			h.writeAttr(b, prefix, member)
+		}
+		return
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, attr.Key, logfmtValue(attr.Value.String()))
+}
+
+// logfmtValue quotes value if it is empty or contains spaces, quotes or
+// equals signs, so key=value pairs can be split again
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \"=\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// fanoutHandler passes every record to each of its handlers
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(newConsoleHandler(&out, slog.LevelWarn))
+
+	logger.Info("not shown")
+	logger.Warn("failed to delete", "path", "/p/my app/node_modules", "error", errors.New("permission denied"))
+	logger.With("run", 7).WithGroup("item").Error("failed to write metadata", "path", "/p/target")
+
+	assert.Equal(t,
+		"Warning: failed to delete path=\"/p/my app/node_modules\" error=\"permission denied\"\n"+
+			"Error: failed to write metadata run=7 item.path=/p/target\n",
+		out.String())
+}
+
+func TestLogLevel(t *testing.T) {
+	t.Cleanup(func() { verbose, quiet = false, false })
+
+	verbose, quiet = false, false
+	assert.Equal(t, slog.LevelWarn, logLevel())
+	verbose = true
+	assert.Equal(t, slog.LevelDebug, logLevel())
+	verbose, quiet = false, true
+	assert.Equal(t, slog.LevelError, logLevel())
+}
+
+func TestSetupLogging_File(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+		logFile = ""
+	})
+
+	logFile = filepath.Join(t.TempDir(), "bbb.log")
+	require.NoError(t, setupLogging())
+	slog.Warn("failed to calculate size", "path", "/p/node_modules")
+	slog.Debug("not logged without --verbose")
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	require.Len(t, lines, 1)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "failed to calculate size", entry["msg"])
+	assert.Equal(t, "/p/node_modules", entry["path"])
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+)
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Inspect the built-in ecosystem profiles",
+}
+
+var profilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the built-in profiles and what they match",
+	Long: `Lists every built-in profile with the directory names it adds and, for
+generic names such as build or target, the project files one of which must sit
+next to the directory for it to be selected.
+
+Enable profiles with --profile node,python or "profiles: [node, python]" in
+the config file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		printProfiles(os.Stdout, config.Profiles())
+	},
+}
+
+// printProfiles writes each profile and the names it matches to w
+func printProfiles(w io.Writer, profiles []config.Profile) {
+	for i, profile := range profiles {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s: %s\n", profile.Name, profile.Description)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, name := range profile.IncludeNames {
+			if markers, ok := profile.Rules[name]; ok {
+				fmt.Fprintf(tw, "  %s\tonly next to %s\n", name, strings.Join(markers, ", "))
+			} else {
+				fmt.Fprintf(tw, "  %s\n", name)
+			}
+		}
+		tw.Flush()
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(profilesCmd)
+	profilesCmd.AddCommand(profilesListCmd)
+}
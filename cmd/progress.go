@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+	"github.com/yehia2amer/BuildBloatBuster/internal/size"
+)
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressEnabled reports whether live progress should be rendered. Progress
+// goes to stderr, so it is drawn whenever stderr is an interactive terminal,
+// whatever the output format, unless --quiet or --no-progress turned it off.
+func progressEnabled() bool {
+	return !quiet && !noProgress && isTerminal(os.Stderr)
+}
+
+// newProgress returns a progress container that draws on stderr, keeping the
+// report on stdout free of control sequences
+func newProgress(options ...mpb.ContainerOption) *mpb.Progress {
+	return mpb.New(append([]mpb.ContainerOption{mpb.WithOutput(os.Stderr)}, options...)...)
+}
+
+// scanProgress renders live scan progress as an mpb spinner.
+type scanProgress struct {
+	p      *mpb.Progress
+	bar    *mpb.Bar
+	latest atomic.Pointer[scan.ScanProgress]
+}
+
+// startScanProgress starts rendering scan progress. The scanner's callback only
+// stores the latest snapshot; mpb's refresh rate throttles the actual redraws.
+func startScanProgress() *scanProgress {
+	sp := &scanProgress{}
+	sp.latest.Store(&scan.ScanProgress{})
+
+	sp.p = newProgress(mpb.WithWidth(1), mpb.WithRefreshRate(250*time.Millisecond))
+	sp.bar = sp.p.New(0,
+		mpb.SpinnerStyle(),
+		mpb.PrependDecorators(decor.Name("Scanning ")),
+		mpb.AppendDecorators(
+			decor.Any(func(decor.Statistics) string {
+				snap := sp.latest.Load()
+				return fmt.Sprintf("%d directories, %d candidates | %s",
+					snap.DirsVisited, snap.CandidatesFound, tailString(snap.CurrentPath, 50))
+			}),
+		),
+	)
+	return sp
+}
+
+// update records the latest progress snapshot.
+func (sp *scanProgress) update(progress scan.ScanProgress) {
+	sp.latest.Store(&progress)
+}
+
+// stop removes the spinner line once the scan is done; the size bar below it
+// keeps running.
+func (sp *scanProgress) stop() {
+	sp.bar.Abort(true)
+}
+
+// scanAndSize streams candidates from the scanner into the calculator, so
+// directories are sized while the scan is still running. When enabled, live
+// scan progress is rendered above the size bar.
+func scanAndSize(ctx context.Context, scanner *scan.Scanner, calculator *size.Calculator, showProgress bool) ([]scan.Candidate, error) {
+	var sp *scanProgress
+	if showProgress {
+		sp = startScanProgress()
+		scanner.OnProgress(sp.update)
+		calculator.SetProgress(sp.p)
+		defer sp.p.Wait()
+	}
+
+	scanCtx, cancelScan := context.WithCancel(ctx)
+	defer cancelScan()
+	found := make(chan scan.Candidate, 64)
+	scanErr := make(chan error, 1)
+	go func() {
+		err := scanner.Stream(scanCtx, found)
+		if sp != nil {
+			sp.stop()
+		}
+		scanErr <- err
+	}()
+
+	candidates, err := calculator.CalculateSizesStream(ctx, found)
+	if err != nil {
+		// Unblock the scan, which may be waiting to hand over a candidate
+		cancelScan()
+		<-scanErr
+		return nil, fmt.Errorf("size calculation failed: %w", err)
+	}
+	if err := <-scanErr; err != nil {
+		return nil, fmt.Errorf("scanning failed: %w", err)
+	}
+	return candidates, nil
+}
+
+// tailString keeps the last maxLen runes of s, prefixed with "..." when shortened.
+func tailString(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	return "..." + string(runes[len(runes)-(maxLen-3):])
+}
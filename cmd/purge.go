@@ -7,22 +7,68 @@ import (
 
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
 )
 
 var purgeCmd = &cobra.Command{
-	Use:   "purge",
+	Use:   "purge [original-paths...]",
 	Short: "Permanently delete items from quarantine",
 	Long: `Permanently deletes items from the quarantine directory.
-Use the --days flag to only purge items older than a certain number of days.
+Use the --days flag to only purge items older than a certain number of days,
+--expired to purge the items kept longer than delete.retentionDays (or
+--retention-days), or give the original paths of the items to purge.
 WARNING: This action is irreversible.`,
+	ValidArgsFunction: completeQuarantinedPaths,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		days, _ := cmd.Flags().GetInt("days")
-		return runPurge(days)
+		days, err := purgeDays(cmd)
+		if err != nil {
+			return err
+		}
+		return runPurge(days, args)
 	},
 }
 
-func runPurge(days int) error {
+// purgeDays returns the age in days above which items are purged: --days, or
+// the retention period with --expired
+func purgeDays(cmd *cobra.Command) (int, error) {
+	if err := applyConfigFlags(cmd); err != nil {
+		return 0, err
+	}
+	if err := Cfg.Validate(); err != nil {
+		return 0, err
+	}
+	expired, _ := cmd.Flags().GetBool("expired")
+	if !expired {
+		days, _ := cmd.Flags().GetInt("days")
+		return days, nil
+	}
+	if cmd.Flags().Changed("days") {
+		return 0, fmt.Errorf("--expired purges the items older than the retention period and cannot be combined with --days")
+	}
+	return Cfg.Delete.RetentionDays, nil
+}
+
+// expiredItems returns the items that were quarantined more than
+// retentionDays before now
+func expiredItems(items []erase.Metadata, retentionDays int, now time.Time) []erase.Metadata {
+	cutoff := now.AddDate(0, 0, -retentionDays)
+	var expired []erase.Metadata
+	for _, item := range items {
+		if item.Timestamp.Before(cutoff) {
+			expired = append(expired, item)
+		}
+	}
+	return expired
+}
+
+func runPurge(days int, paths []string) error {
 	quarantineDir := Cfg.Delete.QuarantineDir
+	lock, err := erase.LockQuarantine(quarantineDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
 	items, err := listQuarantinedItems(quarantineDir)
 	if err != nil {
 		return fmt.Errorf("could not list quarantined items: %w", err)
@@ -32,19 +78,15 @@ func runPurge(days int) error {
 		fmt.Println("Quarantine is empty. Nothing to purge.")
 		return nil
 	}
-
-	var toPurge []string
-	var toPurgeMeta []string
-	var cutoff time.Time
-	if days > 0 {
-		cutoff = time.Now().AddDate(0, 0, -days)
+	if len(paths) > 0 {
+		if items, err = itemsFromPaths(items, paths); err != nil {
+			return err
+		}
 	}
 
-	for _, item := range items {
-		if days == 0 || item.Timestamp.Before(cutoff) {
-			toPurge = append(toPurge, item.QuarantinePath)
-			toPurgeMeta = append(toPurgeMeta, item.QuarantinePath+".meta.json")
-		}
+	toPurge := items
+	if days > 0 {
+		toPurge = expiredItems(items, days, time.Now())
 	}
 
 	if len(toPurge) == 0 {
@@ -68,14 +110,11 @@ func runPurge(days int) error {
 
 	// Perform purge
 	fmt.Println("Purging items...")
-	for i, path := range toPurge {
-		fmt.Printf(" - Deleting %s\n", path)
-		if err := os.RemoveAll(path); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to delete directory %s: %v\n", path, err)
-		}
-		// Also delete metadata file
-		if err := os.Remove(toPurgeMeta[i]); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to delete metadata file %s: %v\n", toPurgeMeta[i], err)
+	eraser := erase.NewEraser(Cfg)
+	for _, item := range toPurge {
+		fmt.Printf(" - Deleting %s\n", item.QuarantinePath)
+		if err := eraser.Purge(item); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
 	}
 
@@ -86,4 +125,8 @@ func runPurge(days int) error {
 func init() {
 	rootCmd.AddCommand(purgeCmd)
 	purgeCmd.Flags().Int("days", 0, "only purge items older than this many days (default: all items)")
+	purgeCmd.Flags().Bool("expired", false, "only purge items older than the retention period")
+	purgeCmd.Flags().Int("retention-days", 0, "days to keep quarantined items for --expired (overrides config)")
+	purgeCmd.RegisterFlagCompletionFunc("days", cobra.NoFileCompletions)
+	purgeCmd.RegisterFlagCompletionFunc("retention-days", cobra.NoFileCompletions)
 }
@@ -45,7 +45,7 @@ func createNewItem(t *testing.T, quarantineDir, name string, timestamp time.Time
 		Timestamp:      timestamp,
 		SizeBytes:      1234,
 	}
-	writeTestMetadata(t, itemPath+".meta.json", meta)
+	writeTestMetadata(t, erase.MetadataPath(itemPath), meta)
 }
 
 func createOldItem(t *testing.T, quarantineDir, name string, timestamp time.Time) {
@@ -59,13 +59,14 @@ func createOldItem(t *testing.T, quarantineDir, name string, timestamp time.Time
 		Timestamp:      timestamp,
 		SizeBytes:      5678,
 	}
-	writeTestMetadata(t, itemPath+".meta.json", meta)
+	writeTestMetadata(t, erase.MetadataPath(itemPath), meta)
 }
 
 func writeTestMetadata(t *testing.T, path string, meta erase.Metadata) {
 	t.Helper()
 	data, err := json.Marshal(meta)
 	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
 	err = os.WriteFile(path, data, 0644)
 	require.NoError(t, err)
 }
@@ -92,7 +93,7 @@ func TestPurge(t *testing.T) {
 	for _, item := range items {
 		if item.Timestamp.Before(cutoff) {
 			toPurge = append(toPurge, item.QuarantinePath)
-			toPurgeMeta = append(toPurgeMeta, item.QuarantinePath+".meta.json")
+			toPurgeMeta = append(toPurgeMeta, erase.MetadataPath(item.QuarantinePath))
 		}
 	}
 
@@ -111,3 +112,49 @@ func TestPurge(t *testing.T) {
 	assert.Len(t, remainingItems, 1)
 	assert.Equal(t, filepath.Join(quarantineDir, "new-item"), remainingItems[0].QuarantinePath)
 }
+
+func TestPurgeDays_RetentionDaysFlag(t *testing.T) {
+	quarantineDir, cleanup := setupPurgeTest(t)
+	defer cleanup()
+
+	oldCfg, oldSources := Cfg, CfgSources
+	t.Cleanup(func() {
+		Cfg, CfgSources = oldCfg, oldSources
+		for _, name := range []string{"expired", "retention-days", "days"} {
+			flag := purgeCmd.Flags().Lookup(name)
+			require.NoError(t, flag.Value.Set(flag.DefValue))
+			flag.Changed = false
+		}
+	})
+
+	Cfg = config.GetDefaults()
+	Cfg.Delete.QuarantineDir = quarantineDir
+	CfgSources = config.Provenance{}
+	require.NoError(t, purgeCmd.Flags().Set("expired", "true"))
+
+	// The configured 14 days keep the 10 day old item
+	days, err := purgeDays(purgeCmd)
+	require.NoError(t, err)
+	assert.Equal(t, 14, days)
+
+	require.NoError(t, purgeCmd.Flags().Set("retention-days", "5"))
+	days, err = purgeDays(purgeCmd)
+	require.NoError(t, err)
+	assert.Equal(t, 5, days)
+	assert.Equal(t, "flag --retention-days", CfgSources.Source("delete.retentionDays"))
+
+	items, err := listQuarantinedItems(quarantineDir)
+	require.NoError(t, err)
+	expired := expiredItems(items, days, time.Now())
+	require.Len(t, expired, 1)
+	assert.Equal(t, filepath.Join(quarantineDir, "old-item"), expired[0].QuarantinePath)
+
+	require.NoError(t, purgeCmd.Flags().Set("retention-days", "-1"))
+	_, err = purgeDays(purgeCmd)
+	assert.ErrorContains(t, err, "delete.retentionDays")
+
+	require.NoError(t, purgeCmd.Flags().Set("retention-days", "5"))
+	require.NoError(t, purgeCmd.Flags().Set("days", "3"))
+	_, err = purgeDays(purgeCmd)
+	assert.ErrorContains(t, err, "cannot be combined with --days")
+}
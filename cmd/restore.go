@@ -5,36 +5,46 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
-	"github.com/dustin/go-humanize"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
 	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+	"github.com/yehia2amer/BuildBloatBuster/internal/tui"
 )
 
 var restoreCmd = &cobra.Command{
-	Use:   "restore",
-	Short: "Restore a directory from quarantine",
-	Long: `Restores a previously quarantined directory to its original location.
-You can run this command without arguments to see a list of restorable items.`,
+	Use:   "restore [original-paths...]",
+	Short: "Restore directories from quarantine",
+	Long: `Restores previously quarantined directories to their original location.
+You can run this command without arguments to see a list of restorable items.
+With --multi, several items can be checked in the list and restored in one pass.
+Given the original paths of quarantined items, those are restored without asking.`,
+	ValidArgsFunction: completeQuarantinedPaths,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runRestore()
+		multi, _ := cmd.Flags().GetBool("multi")
+		var picker restorePicker = singlePicker{}
+		if multi {
+			picker = multiPicker{}
+		}
+		if len(args) > 0 {
+			picker = pathPicker{paths: args}
+		}
+		return runRestore(picker)
 	},
 }
 
-func runRestore() error {
-	quarantineDir := Cfg.Delete.QuarantineDir
-	items, err := listQuarantinedItems(quarantineDir)
-	if err != nil {
-		return fmt.Errorf("could not list quarantined items: %w", err)
-	}
+// restorePicker chooses which quarantined items to restore. ok is false if
+// the user cancelled.
+type restorePicker interface {
+	Pick(items []erase.Metadata) (selected []erase.Metadata, ok bool, err error)
+}
 
-	if len(items) == 0 {
-		fmt.Println("Quarantine is empty. Nothing to restore.")
-		return nil
-	}
+// singlePicker picks one item from a promptui list
+type singlePicker struct{}
 
+func (singlePicker) Pick(items []erase.Metadata) ([]erase.Metadata, bool, error) {
 	// Create a list of choices for the prompt
 	type promptItem struct {
 		erase.Metadata
@@ -45,7 +55,7 @@ func runRestore() error {
 	for i, item := range items {
 		promptItems[i] = promptItem{
 			Metadata:  item,
-			HumanSize: humanize.Bytes(uint64(item.SizeBytes)),
+			HumanSize: bytesize.Format(item.SizeBytes),
 		}
 	}
 
@@ -58,7 +68,8 @@ func runRestore() error {
 --------- Item Details ----------
 Original Path: {{ .OriginalPath }}
 Quarantined At: {{ .Timestamp }}
-Size: {{ .HumanSize }}`,
+Size: {{ .HumanSize }}
+Compressed: {{ .Compressed }}`,
 	}
 
 	prompt := promptui.Select{
@@ -70,60 +81,154 @@ Size: {{ .HumanSize }}`,
 
 	idx, _, err := prompt.Run()
 	if err != nil {
-		if err == promptui.ErrAbort {
-			fmt.Println("Restore operation cancelled.")
-			return nil
+		if err == promptui.ErrAbort || err == promptui.ErrInterrupt {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("prompt failed: %w", err)
+	}
+	return []erase.Metadata{items[idx]}, true, nil
+}
+
+// multiPicker lets the user check any number of items in an interactive list
+type multiPicker struct{}
+
+func (multiPicker) Pick(items []erase.Metadata) ([]erase.Metadata, bool, error) {
+	candidates := make([]scan.Candidate, len(items))
+	for i, item := range items {
+		candidates[i] = scan.Candidate{Path: item.OriginalPath, SizeBytes: item.SizeBytes}
+	}
+
+	indexes, ok, err := tui.SelectToRestore(candidates)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	selected := make([]erase.Metadata, len(indexes))
+	for i, idx := range indexes {
+		selected[i] = items[idx]
+	}
+	return selected, true, nil
+}
+
+// pathPicker picks the items that were quarantined from the given paths
+type pathPicker struct {
+	paths []string
+}
+
+func (p pathPicker) Pick(items []erase.Metadata) ([]erase.Metadata, bool, error) {
+	selected, err := itemsFromPaths(items, p.paths)
+	return selected, err == nil, err
+}
+
+// itemsFromPaths returns the items quarantined from each of paths, in the
+// order of paths. A path quarantined more than once matches every item.
+func itemsFromPaths(items []erase.Metadata, paths []string) ([]erase.Metadata, error) {
+	var selected []erase.Metadata
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("prompt failed: %w", err)
+		found := false
+		for _, item := range items {
+			if item.OriginalPath == absPath {
+				selected = append(selected, item)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("nothing in quarantine was originally at %s", absPath)
+		}
+	}
+	return selected, nil
+}
+
+func runRestore(picker restorePicker) error {
+	quarantineDir := Cfg.Delete.QuarantineDir
+	lock, err := erase.LockQuarantine(quarantineDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	items, err := listQuarantinedItems(quarantineDir)
+	if err != nil {
+		return fmt.Errorf("could not list quarantined items: %w", err)
+	}
+
+	if len(items) == 0 {
+		fmt.Println("Quarantine is empty. Nothing to restore.")
+		return nil
+	}
+
+	selected, ok, err := picker.Pick(items)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Restore operation cancelled.")
+		return nil
+	}
+	if len(selected) == 0 {
+		fmt.Println("Nothing selected. Nothing to restore.")
+		return nil
 	}
 
-	selectedItem := items[idx]
+	return restoreItems(selected)
+}
 
-	// Perform the restore
-	fmt.Printf("Restoring '%s' to '%s'...\n", selectedItem.QuarantinePath, selectedItem.OriginalPath)
-	if err := os.Rename(selectedItem.QuarantinePath, selectedItem.OriginalPath); err != nil {
-		return fmt.Errorf("failed to move directory: %w", err)
+// restoreItems restores each item on its own, so a conflict with one doesn't
+// stop the others, and ends with a summary of what happened to every item
+func restoreItems(items []erase.Metadata) error {
+	eraser := erase.NewEraser(Cfg)
+	failures := make([]error, len(items))
+	failed := 0
+	for i, item := range items {
+		fmt.Printf("Restoring '%s' to '%s'...\n", item.QuarantinePath, item.OriginalPath)
+		if failures[i] = eraser.Restore(item); failures[i] != nil {
+			failed++
+		}
 	}
 
-	// Clean up the metadata file
-	metaPath := selectedItem.QuarantinePath + ".meta.json"
-	if err := os.Remove(metaPath); err != nil {
-		// Log a warning but don't fail the whole operation
-		fmt.Fprintf(os.Stderr, "Warning: failed to remove metadata file %s: %v\n", metaPath, err)
+	if len(items) > 1 || failed > 0 {
+		fmt.Println("\nSummary:")
+		for i, item := range items {
+			if failures[i] != nil {
+				fmt.Printf(" - Failed   %s: %v\n", item.OriginalPath, failures[i])
+			} else {
+				fmt.Printf(" - Restored %s\n", item.OriginalPath)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("restore incomplete: restored %d of %d items", len(items)-failed, len(items))
 	}
 
 	fmt.Println("Restore complete.")
 	return nil
 }
 
-// listQuarantinedItems scans the quarantine directory for metadata files.
+// listQuarantinedItems reads the metadata files of the quarantine directory,
+// in either layout. It changes nothing, so it is safe without the lock.
 func listQuarantinedItems(quarantineDir string) ([]erase.Metadata, error) {
 	var items []erase.Metadata
 
-	files, err := os.ReadDir(quarantineDir)
+	metaPaths, err := erase.MetadataFiles(quarantineDir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil // Quarantine directory doesn't exist yet
-		}
 		return nil, err
 	}
+	for _, metaPath := range metaPaths {
+		data, err := os.ReadFile(metaPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not read metadata file %s: %v\n", metaPath, err)
+			continue
+		}
 
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".meta.json") {
-			metaPath := filepath.Join(quarantineDir, file.Name())
-			data, err := os.ReadFile(metaPath)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: could not read metadata file %s: %v\n", metaPath, err)
-				continue
-			}
-
-			var meta erase.Metadata
-			if err := json.Unmarshal(data, &meta); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: could not parse metadata file %s: %v\n", metaPath, err)
-				continue
-			}
-			items = append(items, meta)
+		var meta erase.Metadata
+		if err := json.Unmarshal(data, &meta); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not parse metadata file %s: %v\n", metaPath, err)
+			continue
 		}
+		items = append(items, meta)
 	}
 
 	return items, nil
@@ -131,4 +236,5 @@ func listQuarantinedItems(quarantineDir string) ([]erase.Metadata, error) {
 
 func init() {
 	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().Bool("multi", false, "choose several items to restore in an interactive list")
 }
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+)
+
+// fakePicker selects the items whose original path ends in one of names
+type fakePicker struct {
+	names     []string
+	cancelled bool
+	offered   []erase.Metadata
+}
+
+func (p *fakePicker) Pick(items []erase.Metadata) ([]erase.Metadata, bool, error) {
+	p.offered = items
+	if p.cancelled {
+		return nil, false, nil
+	}
+	var selected []erase.Metadata
+	for _, item := range items {
+		for _, name := range p.names {
+			if filepath.Base(item.OriginalPath) == name {
+				selected = append(selected, item)
+			}
+		}
+	}
+	return selected, true, nil
+}
+
+// setupRestoreTest quarantines the named directories, which would be restored
+// below the returned projects directory
+func setupRestoreTest(t *testing.T, names ...string) (projects string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	quarantineDir := filepath.Join(tmpDir, "quarantine")
+	projects = filepath.Join(tmpDir, "projects")
+	require.NoError(t, os.MkdirAll(quarantineDir, 0755))
+	require.NoError(t, os.MkdirAll(projects, 0755))
+
+	for _, name := range names {
+		itemPath := filepath.Join(quarantineDir, name)
+		require.NoError(t, os.Mkdir(itemPath, 0755))
+		writeTestMetadata(t, erase.MetadataPath(itemPath), erase.Metadata{
+			OriginalPath:   filepath.Join(projects, name),
+			QuarantinePath: itemPath,
+			Timestamp:      time.Now(),
+			SizeBytes:      1234,
+		})
+	}
+
+	Cfg = config.GetDefaults()
+	Cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	Cfg.Delete.QuarantineDir = quarantineDir
+	return projects
+}
+
+func TestRunRestore_MultipleItems(t *testing.T) {
+	projects := setupRestoreTest(t, "a", "b", "c")
+	// b can't be restored because something already took its place
+	require.NoError(t, os.Mkdir(filepath.Join(projects, "b"), 0755))
+
+	picker := &fakePicker{names: []string{"a", "b", "c"}}
+	err := runRestore(picker)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "restored 2 of 3 items")
+	assert.Len(t, picker.offered, 3)
+
+	// The conflict didn't stop the other items
+	assert.DirExists(t, filepath.Join(projects, "a"))
+	assert.DirExists(t, filepath.Join(projects, "c"))
+	remaining, err := listQuarantinedItems(Cfg.Delete.QuarantineDir)
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, filepath.Join(projects, "b"), remaining[0].OriginalPath)
+}
+
+func TestRunRestore_Selection(t *testing.T) {
+	projects := setupRestoreTest(t, "a", "b")
+
+	require.NoError(t, runRestore(&fakePicker{names: []string{"b"}}))
+	assert.NoDirExists(t, filepath.Join(projects, "a"))
+	assert.DirExists(t, filepath.Join(projects, "b"))
+
+	entries, err := erase.LoadAuditLog(Cfg.Delete.AuditLog)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, erase.ActionRestore, entries[0].Action)
+	assert.Equal(t, filepath.Join(projects, "b"), entries[0].OriginalPath)
+
+	// Cancelling restores nothing
+	require.NoError(t, runRestore(&fakePicker{cancelled: true}))
+	assert.NoDirExists(t, filepath.Join(projects, "a"))
+}
+
+func TestRunRestore_Paths(t *testing.T) {
+	projects := setupRestoreTest(t, "node_modules", "target")
+
+	require.NoError(t, runRestore(pathPicker{paths: []string{filepath.Join(projects, "target")}}))
+	assert.DirExists(t, filepath.Join(projects, "target"))
+	assert.NoDirExists(t, filepath.Join(projects, "node_modules"))
+
+	err := runRestore(pathPicker{paths: []string{filepath.Join(projects, "dist")}})
+	assert.ErrorContains(t, err, "nothing in quarantine was originally at")
+	assert.NoDirExists(t, filepath.Join(projects, "node_modules"), "nothing should be restored when a path is unknown")
+}
+
+func TestListQuarantinedItems_AdversarialNames(t *testing.T) {
+	quarantineDir := filepath.Join(t.TempDir(), "quarantine")
+	require.NoError(t, os.MkdirAll(quarantineDir, 0755))
+
+	// Items whose names look like metadata: a directory, and a link to a file
+	// holding valid metadata
+	dirItem := filepath.Join(quarantineDir, "20240101-120000-cache.meta.json")
+	require.NoError(t, os.Mkdir(dirItem, 0755))
+	writeTestMetadata(t, erase.MetadataPath(dirItem), erase.Metadata{OriginalPath: "/projects/app/cache.meta.json", QuarantinePath: dirItem})
+	decoy := filepath.Join(t.TempDir(), "decoy.json")
+	writeTestMetadata(t, decoy, erase.Metadata{OriginalPath: "/decoy", QuarantinePath: "/decoy"})
+	linkItem := filepath.Join(quarantineDir, "20240101-120000-link.meta.json")
+	require.NoError(t, os.Symlink(decoy, linkItem))
+	writeTestMetadata(t, erase.MetadataPath(linkItem), erase.Metadata{OriginalPath: "/projects/app/link.meta.json", QuarantinePath: linkItem})
+
+	// Metadata written next to its item by an older version is read in place
+	legacyItem := filepath.Join(quarantineDir, "20240101-120000-node_modules")
+	require.NoError(t, os.Mkdir(legacyItem, 0755))
+	writeTestMetadata(t, legacyItem+".meta.json", erase.Metadata{OriginalPath: "/projects/app/node_modules", QuarantinePath: legacyItem})
+
+	items, err := listQuarantinedItems(quarantineDir)
+	require.NoError(t, err)
+	var originals []string
+	for _, item := range items {
+		originals = append(originals, item.OriginalPath)
+	}
+	assert.ElementsMatch(t, []string{"/projects/app/cache.meta.json", "/projects/app/link.meta.json", "/projects/app/node_modules"}, originals)
+	assert.FileExists(t, legacyItem+".meta.json", "listing changes nothing")
+	assert.DirExists(t, dirItem)
+
+	// Nothing is an orphan, and restoring an item takes its metadata with it
+	orphans, err := findQuarantineOrphans(quarantineDir)
+	require.NoError(t, err)
+	assert.Zero(t, orphans.count())
+
+	// Whoever locks the quarantine moves the legacy metadata into place
+	lock, err := erase.LockQuarantine(quarantineDir)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+	assert.NoFileExists(t, legacyItem+".meta.json")
+	assert.FileExists(t, erase.MetadataPath(legacyItem))
+	items, err = listQuarantinedItems(quarantineDir)
+	require.NoError(t, err)
+	assert.Len(t, items, 3)
+	require.NoError(t, erase.Restore(erase.Metadata{OriginalPath: filepath.Join(t.TempDir(), "cache.meta.json"), QuarantinePath: dirItem}))
+	assert.NoFileExists(t, erase.MetadataPath(dirItem))
+}
@@ -6,18 +6,26 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
 	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/report"
 )
 
 var cfgFile string
 var Cfg config.Config
+
+// CfgSources records where each setting in Cfg came from
+var CfgSources = config.Provenance{}
 var version string
 
 // Global flags
 var (
 	dryRun     bool
+	apply      bool
 	jsonOutput bool
 	verbose    bool
+	quiet      bool
+	noProgress bool
 )
 
 var rootCmd = &cobra.Command{
@@ -27,48 +35,142 @@ var rootCmd = &cobra.Command{
 like node_modules, target, build, .cache and other common build artifacts.
 
 It operates with safety as the primary concern:
-- Dry-run mode by default
+- Dry-run mode by default; --apply deletes
 - Quarantine deletion (move to trash) instead of permanent deletion
 - Smart filtering to avoid deleting important directories
 - Interactive confirmation prompts`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		// Load configuration
 		if cfgFile != "" {
+			warnConfigKeys(cfgFile)
 			var err error
-			Cfg, err = config.LoadConfig(cfgFile)
+			Cfg, CfgSources, err = config.LoadConfigWithProvenance(cfgFile)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", cfgFile, err)
 				os.Exit(1)
 			}
 			if verbose {
-				fmt.Printf("Using config file: %s\n", cfgFile)
+				fmt.Fprintf(os.Stderr, "Using config file: %s\n", cfgFile)
+			}
+		} else if path := config.FindConfigFile(); path != "" {
+			// Use the first config file found in the standard locations
+			warnConfigKeys(path)
+			var err error
+			Cfg, CfgSources, err = config.LoadConfigWithProvenance(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading config file %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Using config file: %s\n", path)
 			}
 		} else {
-			// Try to load from default locations
-			Cfg = config.LoadConfigWithDefaults(".BuildBloatBuster.yaml")
+			Cfg, CfgSources = config.GetDefaults(), config.Provenance{}
 			if verbose {
-				fmt.Println("Using configuration with defaults")
+				fmt.Fprintln(os.Stderr, "Using configuration with defaults")
 			}
 		}
+		for _, w := range Cfg.PathWarnings() {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+
+		if err := applyUnitsFlag(cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := applyDryRunFlags(cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !progressEnabled() {
+			report.ProgressOutput = nil
+		}
+		if err := setupLogging(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 
 	},
 }
 
+// applyDryRunFlags decides whether this run deletes: --apply turns dry-run
+// off, --dry-run sets it, and without either behavior.dryRunByDefault does.
+// Asking for --apply and a dry run at once is a contradiction and an error,
+// rather than one of the two silently winning.
+func applyDryRunFlags(cmd *cobra.Command) error {
+	dryRunSet := cmd.Flags().Changed("dry-run")
+	switch {
+	case apply && dryRunSet && dryRun:
+		return fmt.Errorf("--apply deletes and --dry-run only shows what would be deleted; pass one of them")
+	case apply:
+		dryRun = false
+	case !dryRunSet:
+		dryRun = Cfg.Behavior.DryRunByDefault
+	}
+	return nil
+}
+
+// applyUnitsFlag overrides output.units with --units, if given, and makes it
+// the style every command shows sizes in
+func applyUnitsFlag(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("units") {
+		Cfg.Output.Units, _ = cmd.Flags().GetString("units")
+		CfgSources.Set("output.units", "flag --units")
+		if err := Cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	bytesize.DisplayUnits = bytesize.Units(Cfg.Output.Units)
+	return nil
+}
+
+// warnConfigKeys prints a warning for every unrecognised or deprecated key in
+// the config file. Parse errors are left for the loader to report.
+func warnConfigKeys(path string) {
+	warnings, err := config.KeyWarnings(path)
+	if err != nil {
+		return
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", path, w)
+	}
+}
+
 func Execute() {
 	startTime := time.Now()
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Printf("\nTotal time taken: %v\n", time.Since(startTime))
+	// Output meant for another program must not be followed by anything else
+	if !isCompletionCmd(cmd) && !Cfg.MachineReadable() {
+		fmt.Printf("\nTotal time taken: %v\n", time.Since(startTime))
+	}
+}
+
+// isCompletionCmd reports whether cmd prints shell completions or a completion
+// script, whose output must not be followed by anything else
+func isCompletionCmd(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd, "completion":
+		return true
+	}
+	return cmd.HasParent() && cmd.Parent().Name() == "completion"
 }
 
 func init() {
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./.BuildBloatBuster.yaml)")
-	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", true, "show what would be deleted without actually deleting")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ./.BuildBloatBuster.yaml, then the user config directory)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", true, "show what would be deleted without actually deleting (the default; see --apply)")
+	rootCmd.PersistentFlags().BoolVarP(&apply, "apply", "D", false, "actually delete; without it nothing is deleted, even with --yes")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "output results in JSON format")
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output, including debug logs")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress progress output and all logs but errors")
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "never draw progress bars, even on a terminal")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "also append logs to this file as JSON lines")
+	rootCmd.PersistentFlags().String("units", "iec", "show sizes in iec (MiB, powers of 1024), si (MB, powers of 1000) or bytes units (overrides config)")
+	rootCmd.RegisterFlagCompletionFunc("units", cobra.FixedCompletions(config.ValidUnits, cobra.ShellCompDirectiveNoFileComp))
 	rootCmd.Version = version
 }
 
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+)
+
+func TestApplyDryRunFlags(t *testing.T) {
+	tests := []struct {
+		name            string
+		dryRunByDefault bool
+		args            []string
+		wantDryRun      bool
+		wantErr         bool
+	}{
+		{"dry run by default", true, nil, true, false},
+		{"--yes alone does not delete", true, []string{"--yes"}, true, false},
+		{"--apply", true, []string{"--apply"}, false, false},
+		{"-D", true, []string{"-D", "--yes"}, false, false},
+		{"--dry-run=false", true, []string{"--dry-run=false"}, false, false},
+		{"--apply --dry-run=false", true, []string{"--apply", "--dry-run=false"}, false, false},
+		{"--apply --dry-run", true, []string{"--apply", "--dry-run"}, true, true},
+		{"config deletes by default", false, nil, false, false},
+		{"--dry-run beats config", false, []string{"--dry-run"}, true, false},
+		{"--apply with config", false, []string{"--apply"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldCfg, oldDryRun, oldApply := Cfg, dryRun, apply
+			t.Cleanup(func() { Cfg, dryRun, apply = oldCfg, oldDryRun, oldApply })
+			Cfg = config.GetDefaults()
+			Cfg.Behavior.DryRunByDefault = tt.dryRunByDefault
+
+			cmd := &cobra.Command{}
+			cmd.Flags().BoolVar(&dryRun, "dry-run", true, "")
+			cmd.Flags().BoolVarP(&apply, "apply", "D", false, "")
+			cmd.Flags().BoolP("yes", "y", false, "")
+			require.NoError(t, cmd.ParseFlags(tt.args))
+
+			err := applyDryRunFlags(cmd)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "pass one of them")
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantDryRun, dryRun)
+		})
+	}
+}
+
+func TestAssumeYes(t *testing.T) {
+	tests := []struct {
+		name                string
+		requireConfirmation bool
+		args                []string
+		want                bool
+	}{
+		{"asks by default", true, nil, false},
+		{"--yes", true, []string{"--yes"}, true},
+		{"config skips the prompt", false, nil, true},
+		{"--yes=false beats config", false, []string{"--yes=false"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldCfg := Cfg
+			t.Cleanup(func() { Cfg = oldCfg })
+			Cfg = config.GetDefaults()
+			Cfg.Behavior.RequireConfirmation = tt.requireConfirmation
+
+			cmd := &cobra.Command{}
+			cmd.Flags().BoolP("yes", "y", false, "")
+			require.NoError(t, cmd.ParseFlags(tt.args))
+			assert.Equal(t, tt.want, assumeYes(cmd))
+		})
+	}
+}
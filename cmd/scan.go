@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"time"
@@ -9,7 +8,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/yehia2amer/BuildBloatBuster/internal/report"
 	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
-	"github.com/yehia2amer/BuildBloatBuster/internal/size"
+	"github.com/yehia2amer/BuildBloatBuster/internal/webhook"
 )
 
 var scanCmd = &cobra.Command{
@@ -35,94 +34,187 @@ like source code, version control folders, and system directories.`,
 }
 
 func runScan(cmd *cobra.Command, paths []string) error {
+	global, err := checkGlobalFlag(cmd, paths)
+	if err != nil {
+		return err
+	}
+
 	// Override scan paths if provided via command line
-	if len(paths) > 0 {
-		Cfg.ScanPaths = paths
+	applyScanPathArgs(paths)
+
+	allowHome, _ := cmd.Flags().GetBool("allow-home")
+	if !global {
+		if err := prepareScanPaths(allowHome); err != nil {
+			return err
+		}
 	}
 
-	if err := checkScanPaths(Cfg.ScanPaths); err != nil {
+	if err := applyConfigFlags(cmd); err != nil {
 		return err
 	}
-
-	format, _ := cmd.Flags().GetString("format")
-	Cfg.Output.Format = format
-	isJSON := Cfg.Output.Format == "json"
-
-	if verbose && !isJSON {
-		fmt.Printf("Scanning paths: %v\n", Cfg.ScanPaths)
-		fmt.Printf("Include patterns: %v\n", Cfg.IncludeNames)
-		fmt.Printf("Min size: %d MB\n", Cfg.MinSizeMB)
+	applyWebhookFlags(cmd)
+	if err := applyFormatFlag(cmd); err != nil {
+		return err
+	}
+	notAccessedSince, err := notAccessedSinceFlag(cmd)
+	if err != nil {
+		return err
+	}
+	machineReadable := Cfg.MachineReadable()
+
+	if verbose && !machineReadable {
+		if global {
+			fmt.Println("Scanning global caches")
+		} else {
+			fmt.Printf("Scanning paths: %v\n", Cfg.ScanPaths)
+			fmt.Printf("Include patterns: %v\n", Cfg.IncludeNames)
+		}
+		fmt.Printf("Min size: %s\n", Cfg.MinSize)
 		fmt.Printf("Max depth: %d\n", Cfg.MaxDepth)
-		fmt.Printf("Concurrency: %d\n", Cfg.Concurrency)
+		if Cfg.MaxResults > 0 {
+			fmt.Printf("Max results: %d\n", Cfg.MaxResults)
+		}
+		if Cfg.Concurrency > 0 {
+			fmt.Printf("Concurrency: %d\n", Cfg.Concurrency)
+		} else {
+			fmt.Println("Concurrency: auto")
+		}
 		fmt.Println()
 	}
 
-	// Create scanner
-	scanner := scan.NewScanner(Cfg)
-
-	// Start scanning
-	if verbose && !isJSON {
-		fmt.Println("Scanning directories...")
+	// Directories are sized as the scan finds them
+	if verbose && !machineReadable {
+		fmt.Println("Scanning directories and calculating sizes...")
 	}
 
 	startTime := time.Now()
-	candidates, err := scanner.ScanPaths()
+	candidates, err := collectCandidates(cmd, global)
 	if err != nil {
-		return fmt.Errorf("scanning failed: %w", err)
+		return err
 	}
 
-	if verbose && !isJSON {
-		fmt.Printf("Found %d candidates in %v\n", len(candidates), time.Since(startTime))
+	if verbose && !machineReadable {
+		fmt.Printf("Found and sized %d candidates in %v\n", len(candidates), time.Since(startTime))
 	}
 
 	if len(candidates) == 0 {
-		if !isJSON {
+		if !machineReadable {
 			fmt.Println("No directories found matching the criteria.")
 		}
-		return nil
+		return finishScan(cmd, candidates)
 	}
 
-	// Calculate sizes concurrently
-	if verbose && !isJSON {
-		fmt.Println("Calculating sizes...")
+	// Filter by minimum size and file count
+	minFiles, _ := cmd.Flags().GetInt64("min-files")
+	candidates = filterReclaimable(cmd, candidates)
+
+	if len(candidates) == 0 {
+		if !machineReadable {
+			if minFiles > 0 {
+				fmt.Printf("No directories found larger than %s with at least %d files.\n", Cfg.MinSize, minFiles)
+			} else {
+				fmt.Printf("No directories found larger than %s.\n", Cfg.MinSize)
+			}
+		}
+		return finishScan(cmd, candidates)
 	}
 
-	calculator := size.NewCalculator(Cfg.Concurrency)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+	candidates = applyOnlyFlag(cmd, candidates)
+	if len(candidates) == 0 {
+		if !machineReadable {
+			fmt.Println("No directories found matching --only.")
+		}
+		return finishScan(cmd, candidates)
+	}
 
-	startTime = time.Now()
-	candidates, err = calculator.CalculateSizes(ctx, candidates)
-	if err != nil {
-		return fmt.Errorf("size calculation failed: %w", err)
+	if notAccessedSince > 0 {
+		candidates = filterNotAccessedSince(candidates, notAccessedSince)
+		if len(candidates) == 0 {
+			if !machineReadable {
+				fmt.Println("No directories found unused for --not-accessed-since.")
+			}
+			return finishScan(cmd, candidates)
+		}
 	}
 
-	if verbose && !isJSON {
-		fmt.Printf("Size calculation completed in %v\n", time.Since(startTime))
+	// Generate report
+	sizeUnit, _ := Cfg.FixedSizeUnit()
+	reporter := report.NewReporter(Cfg.OutputFormats(), Cfg.Output.SortBy, sizeUnit)
+	chart, _ := cmd.Flags().GetBool("chart")
+	reporter.SetChart(chart)
+	noTruncate, _ := cmd.Flags().GetBool("no-truncate")
+	reporter.SetNoTruncate(noTruncate)
+	reporter.SetReadErrors(readErrors)
+	if err := reporter.Report(candidates); err != nil {
+		return err
 	}
+	return finishScan(cmd, candidates)
+}
 
-	// Filter by minimum size
-	candidates = size.FilterByMinSize(candidates, Cfg.MinSizeMB)
+// finishScan saves the scan result with --save and posts it to the webhook
+func finishScan(cmd *cobra.Command, candidates []scan.Candidate) error {
+	if err := saveScan(cmd, candidates); err != nil {
+		return err
+	}
+	postReport(webhook.Report{Command: "scan"}, candidates)
+	return nil
+}
 
-	if len(candidates) == 0 {
-		if !isJSON {
-			fmt.Printf("No directories found larger than %d MB.\n", Cfg.MinSizeMB)
-		}
+// saveScan writes the scan result to the snapshot file given with --save, if any
+func saveScan(cmd *cobra.Command, candidates []scan.Candidate) error {
+	path, _ := cmd.Flags().GetString("save")
+	if path == "" {
 		return nil
 	}
-
-	// Generate report
-	reporter := report.NewReporter(Cfg.Output.Format, Cfg.Output.SortBy)
-	return reporter.Report(candidates)
+	if candidates == nil {
+		candidates = []scan.Candidate{}
+	}
+	if err := report.SaveSnapshot(path, candidates); err != nil {
+		return err
+	}
+	if !Cfg.MachineReadable() {
+		fmt.Printf("Snapshot saved to %s\n", path)
+	}
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(scanCmd)
 
 	// Add scan-specific flags
-	scanCmd.Flags().IntP("min-size", "s", 0, "minimum size in MB (overrides config)")
+	scanCmd.Flags().StringP("min-size", "s", "", "minimum size, e.g. 500MB or 2GiB; a plain number is MiB (overrides config)")
+	scanCmd.Flags().Int64("min-files", 0, "minimum number of files inside, e.g. 100000; applies together with --min-size")
 	scanCmd.Flags().IntP("max-depth", "d", 0, "maximum directory depth (overrides config)")
+	scanCmd.Flags().Int("max-results", 0, "stop scanning after this many directories were found, 0 = unlimited (overrides config)")
 	scanCmd.Flags().StringSliceP("include", "i", nil, "additional patterns to include")
 	scanCmd.Flags().StringSliceP("exclude", "e", nil, "additional patterns to exclude")
-	scanCmd.Flags().String("format", "table", "output format (table, json, csv)")
+	scanCmd.Flags().StringSlice("exclude-glob", nil, "exclude directories whose name matches these patterns, e.g. '*.egg-info'")
+	scanCmd.Flags().StringSlice("include-from", nil, "add the patterns in these files, one per line, to the include names")
+	scanCmd.Flags().StringSlice("exclude-from", nil, "add the patterns in these files, one per line, to the exclusions")
+	scanCmd.Flags().StringSlice("only", nil, "only report directories that matched these include patterns, e.g. node_modules,target")
+	scanCmd.Flags().String("not-accessed-since", "", "only report directories whose files were not read or written within this age, e.g. 30d")
+	scanCmd.Flags().StringSlice("profile", nil, "built-in profiles to enable, e.g. node,python (overrides config)")
+	scanCmd.Flags().StringSlice("lang", nil, "scan only for these ecosystems' directories, e.g. node,rust, instead of the include list (see profiles list)")
+	scanCmd.MarkFlagsMutuallyExclusive("lang", "profile")
+	scanCmd.Flags().StringSlice("presets", nil, "built-in presets of directories outside any project to report too, e.g. mobile (overrides config)")
+	scanCmd.Flags().String("format", "table", "output format (table, json, csv, tsv, plain, plain0), or several separated by commas, e.g. json,csv")
+	scanCmd.Flags().String("sort", "", "sort results by size, path or age (overrides config)")
+	scanCmd.Flags().String("size-unit", "", "show size columns as plain numbers in this unit, e.g. MB or GiB (overrides config)")
+	scanCmd.Flags().Bool("chart", false, "draw a bar next to every directory in the table, scaled to the largest")
+	scanCmd.Flags().String("report-url", "", "POST the JSON summary, with the hostname and a timestamp, to this URL when the scan completes (overrides config)")
+	scanCmd.Flags().StringArray("report-url-header", nil, "header to send with --report-url, e.g. 'Authorization: Bearer $TOKEN'; repeatable")
+	scanCmd.Flags().Bool("report-url-insecure", false, "don't verify the TLS certificate of --report-url, for self-signed certificates (overrides config)")
+	scanCmd.Flags().Bool("no-truncate", false, "show paths and reasons in full instead of fitting the table to the terminal")
+	scanCmd.Flags().Bool("allow-home", false, "allow scanning your entire home directory")
+	scanCmd.Flags().Bool("include-network-fs", false, "descend into network filesystems (NFS, SMB, ...) below the scan paths")
+	scanCmd.Flags().Int("concurrency", 0, "number of size calculation workers (default: tuned to the storage type)")
+	scanCmd.Flags().Bool("no-cache", false, "recompute every size instead of reusing cached sizes")
+	scanCmd.Flags().Bool("strict", false, "fail if any directory can't be read while scanning or sizing, instead of skipping it")
+	scanCmd.Flags().Bool("report-vcs", false, "also report the size of version control directories such as .git, which are never deleted")
+	scanCmd.Flags().Bool("show-errors", false, "list the paths that couldn't be read while scanning or sizing, instead of only counting them")
+	scanCmd.Flags().Bool("force-recent", false, "include directories modified within skipIfModifiedWithin, which a build may still be using")
+	scanCmd.Flags().String("save", "", "also write the result to a JSON snapshot file for use with diff")
+	scanCmd.Flags().Bool("global", false, "scan well-known global caches (gradle, maven, pip, npm, ...) instead of paths")
+	scanCmd.Flags().Duration("timeout", 0, "overall time limit for scanning and size calculation, e.g. 10m (overrides config)")
+	registerScanCompletions(scanCmd)
 }
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/stats"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how much space clean has reclaimed",
+	Long: `Shows how much space the most recent clean run reclaimed.
+
+Use --all-time to show the running totals across every clean run instead,
+broken down by month and with the paths that reclaimed the most space.
+--format json prints all of it as JSON for scripts.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		allTime, _ := cmd.Flags().GetBool("all-time")
+		format, _ := cmd.Flags().GetString("format")
+		top, _ := cmd.Flags().GetInt("top")
+		return runStats(stats.DefaultPath(), stats.HistoryPath(Cfg.Delete.QuarantineDir), allTime, format, top)
+	},
+}
+
+// statsReport is the JSON form of the stats: the running totals together with
+// the breakdowns computed from the history
+type statsReport struct {
+	stats.Stats
+	Months   []stats.MonthTotal `json:"months"`
+	TopPaths []stats.PathTotal  `json:"topPaths"`
+}
+
+func runStats(path, historyPath string, allTime bool, format string, top int) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unsupported format: %s (use table or json)", format)
+	}
+
+	s, err := stats.Load(path)
+	if err != nil {
+		return err
+	}
+	runs, err := stats.LoadHistory(historyPath)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		report := statsReport{Stats: s, Months: stats.ByMonth(runs), TopPaths: stats.TopPaths(runs, top)}
+		// Keep the lists arrays for scripts, even before the first run
+		if report.Months == nil {
+			report.Months = []stats.MonthTotal{}
+		}
+		if report.TopPaths == nil {
+			report.TopPaths = []stats.PathTotal{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	if s.Runs == 0 {
+		fmt.Println("No clean runs recorded yet.")
+		return nil
+	}
+
+	if allTime {
+		fmt.Printf("Reclaimed %s by deleting %d directories in %d runs since %s.\n",
+			bytesize.Format(s.TotalBytesFreed), s.TotalDeleted, s.Runs, s.FirstRun.Format("2006-01-02"))
+		printStatsHistory(runs, top)
+		return nil
+	}
+
+	fmt.Printf("Last run (%s) reclaimed %s by deleting %d directories.\n",
+		s.LastRun.Format("2006-01-02 15:04:05"), bytesize.Format(s.LastRunBytesFreed), s.LastRunDeleted)
+	return nil
+}
+
+// printStatsHistory prints the per-month breakdown and the top reclaimed paths.
+// Runs from before the history was kept are only part of the totals.
+func printStatsHistory(runs []stats.Run, top int) {
+	if len(runs) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nBy month:")
+	fmt.Fprintln(w, "MONTH\tRUNS\tDIRECTORIES\tRECLAIMED")
+	for _, month := range stats.ByMonth(runs) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", month.Month, month.Runs, month.Deleted, bytesize.Format(month.BytesFreed))
+	}
+	w.Flush()
+
+	paths := stats.TopPaths(runs, top)
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nTop reclaimed paths:")
+	fmt.Fprintln(w, "RECLAIMED\tCLEANS\tPATH")
+	for _, p := range paths {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", bytesize.Format(p.BytesFreed), p.Cleans, p.Path)
+	}
+	w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().Bool("all-time", false, "show totals across all clean runs, by month and with the top reclaimed paths")
+	statsCmd.Flags().String("format", "table", "output format (table, json)")
+	statsCmd.Flags().Int("top", 10, "number of top reclaimed paths to show")
+}
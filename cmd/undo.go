@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Restore everything quarantined by the last clean",
+	Long: `Restores every directory moved to quarantine by the most recent clean run
+back to its original location in one step.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUndo()
+	},
+}
+
+func runUndo() error {
+	quarantineDir := Cfg.Delete.QuarantineDir
+	lock, err := erase.LockQuarantine(quarantineDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	session, err := erase.LoadLastSession(quarantineDir)
+	if err != nil {
+		return fmt.Errorf("could not read the last run: %w", err)
+	}
+	if session == nil || len(session.Items) == 0 {
+		fmt.Println("Nothing to undo.")
+		return nil
+	}
+
+	fmt.Printf("Undoing clean from %s (%d items)...\n", session.Timestamp.Format("2006-01-02 15:04:05"), len(session.Items))
+	restored, skipped, err := erase.NewEraser(Cfg).UndoLastSession()
+	for _, item := range restored {
+		fmt.Printf(" - Restored %s\n", item.OriginalPath)
+	}
+	for _, item := range skipped {
+		fmt.Printf(" - Skipped  %s, restored or purged since\n", item.OriginalPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Some items could not be restored:\n%v\n", err)
+		return fmt.Errorf("undo incomplete: restored %d of %d items", len(restored), len(session.Items)-len(skipped))
+	}
+
+	fmt.Println("Undo complete.")
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Find orphaned items and metadata in the quarantine",
+	Long: `Checks that every item in the quarantine directory has a metadata file and
+every metadata file still has its item. Items without metadata are left behind
+by a run that crashed mid-quarantine and can't be restored; metadata without an
+item remains after an item was deleted by hand.
+
+With --fix, orphaned metadata files are removed and you are asked whether to
+purge the orphaned items.
+
+With --checksum, items quarantined while delete.checksum was enabled are
+hashed again and compared with the checksum in their metadata, which detects
+corrupted or modified items. This reads all of their data.
+
+Exits with a non-zero status if orphans are found and not fixed, or if any
+checksum doesn't match.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fix, _ := cmd.Flags().GetBool("fix")
+		yes := assumeYes(cmd)
+		checksum, _ := cmd.Flags().GetBool("checksum")
+		confirm := confirmPurgeOrphans
+		if yes {
+			confirm = func(int) (bool, error) { return true, nil }
+		}
+		return runVerify(Cfg.Delete.QuarantineDir, fix, checksum, confirm)
+	},
+}
+
+// quarantineOrphans are the inconsistencies verify looks for
+type quarantineOrphans struct {
+	// metadata are the metadata of items that no longer exist
+	metadata []erase.Metadata
+	// items are paths in the quarantine that no metadata refers to
+	items []string
+}
+
+func (o quarantineOrphans) count() int {
+	return len(o.metadata) + len(o.items)
+}
+
+// findQuarantineOrphans compares the metadata in quarantineDir with its
+// entries. Items are matched to their metadata by name, so a quarantine
+// directory that was moved as a whole still verifies.
+func findQuarantineOrphans(quarantineDir string) (quarantineOrphans, error) {
+	var orphans quarantineOrphans
+	items, err := listQuarantinedItems(quarantineDir)
+	if err != nil {
+		return orphans, err
+	}
+	entries, err := os.ReadDir(quarantineDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return orphans, nil
+		}
+		return orphans, err
+	}
+
+	referenced := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		name := filepath.Base(item.QuarantinePath)
+		referenced[name] = struct{}{}
+		if _, err := os.Lstat(filepath.Join(quarantineDir, name)); os.IsNotExist(err) {
+			orphans.metadata = append(orphans.metadata, item)
+		}
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == erase.MetadataDirName || name == erase.SessionLogName || name == erase.QuarantineLockName || erase.IsLegacyMetadata(entry) {
+			continue
+		}
+		if _, ok := referenced[name]; !ok {
+			orphans.items = append(orphans.items, filepath.Join(quarantineDir, name))
+		}
+	}
+	return orphans, nil
+}
+
+// findChecksumMismatches recomputes the checksum of every item in
+// quarantineDir that has one and returns those that no longer match, along
+// with how many items were checked. Items that are gone are left to
+// findQuarantineOrphans.
+func findChecksumMismatches(quarantineDir string) ([]erase.Metadata, int, error) {
+	items, err := listQuarantinedItems(quarantineDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var mismatched []erase.Metadata
+	checked := 0
+	for _, item := range items {
+		if item.Checksum == "" {
+			continue
+		}
+		path := filepath.Join(quarantineDir, filepath.Base(item.QuarantinePath))
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+		checked++
+		sum, err := erase.Checksum(path)
+		if err != nil {
+			// An item that can't be read is as broken as one that changed
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if sum != item.Checksum {
+			mismatched = append(mismatched, item)
+		}
+	}
+	return mismatched, checked, nil
+}
+
+func runVerify(quarantineDir string, fix, checksum bool, confirm func(n int) (bool, error)) error {
+	// A clean that is still quarantining has moved an item before writing its
+	// metadata, so fixing waits for it rather than purging that item
+	if fix {
+		lock, err := erase.LockQuarantine(quarantineDir)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	orphans, err := findQuarantineOrphans(quarantineDir)
+	if err != nil {
+		return fmt.Errorf("could not verify quarantine: %w", err)
+	}
+
+	var mismatched []erase.Metadata
+	if checksum {
+		var checked int
+		mismatched, checked, err = findChecksumMismatches(quarantineDir)
+		if err != nil {
+			return fmt.Errorf("could not verify checksums: %w", err)
+		}
+		fmt.Printf("Verified the checksums of %d item(s).\n", checked)
+		if len(mismatched) > 0 {
+			fmt.Println("Items that changed since they were quarantined:")
+			for _, meta := range mismatched {
+				fmt.Printf(" - %s (was %s)\n", filepath.Base(meta.QuarantinePath), meta.OriginalPath)
+			}
+		}
+	}
+
+	if orphans.count() == 0 && len(mismatched) == 0 {
+		fmt.Printf("Quarantine %s is consistent.\n", quarantineDir)
+		return nil
+	}
+	if orphans.count() > 0 {
+		if err := reportOrphans(quarantineDir, orphans, fix, confirm); err != nil {
+			return err
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("%d quarantined item(s) failed checksum verification", len(mismatched))
+	}
+	return nil
+}
+
+// reportOrphans lists the orphans and, with fix, removes orphaned metadata and
+// purges orphaned items once confirm agrees
+func reportOrphans(quarantineDir string, orphans quarantineOrphans, fix bool, confirm func(n int) (bool, error)) error {
+
+	if len(orphans.metadata) > 0 {
+		fmt.Println("Metadata without a quarantined item:")
+		for _, meta := range orphans.metadata {
+			fmt.Printf(" - %s (was %s)\n", filepath.Join(erase.MetadataDirName, filepath.Base(meta.QuarantinePath)+".json"), meta.OriginalPath)
+		}
+	}
+	if len(orphans.items) > 0 {
+		fmt.Println("Quarantined items without metadata, which can't be restored:")
+		for _, path := range orphans.items {
+			fmt.Printf(" - %s\n", filepath.Base(path))
+		}
+	}
+
+	if !fix {
+		return fmt.Errorf("found %d orphan(s); run verify --fix to clean them up", orphans.count())
+	}
+
+	for _, meta := range orphans.metadata {
+		metaPath := erase.MetadataPath(filepath.Join(quarantineDir, filepath.Base(meta.QuarantinePath)))
+		if err := os.Remove(metaPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", metaPath, err)
+			continue
+		}
+		fmt.Printf("Removed orphaned metadata %s\n", filepath.Base(metaPath))
+	}
+
+	if len(orphans.items) == 0 {
+		return nil
+	}
+	proceed, err := confirm(len(orphans.items))
+	if err != nil {
+		return fmt.Errorf("confirmation failed: %w", err)
+	}
+	if !proceed {
+		fmt.Println("Orphaned items kept.")
+		return nil
+	}
+	eraser := erase.NewEraser(Cfg)
+	for _, path := range orphans.items {
+		if err := eraser.Purge(erase.Metadata{QuarantinePath: path}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		fmt.Printf("Purged orphaned item %s\n", filepath.Base(path))
+	}
+	return nil
+}
+
+// confirmPurgeOrphans asks whether the orphaned items should be purged
+func confirmPurgeOrphans(n int) (bool, error) {
+	prompt := promptui.Prompt{
+		Label:     fmt.Sprintf("Permanently delete %d orphaned items from quarantine? This cannot be undone.", n),
+		IsConfirm: true,
+		Default:   "n",
+	}
+	if _, err := prompt.Run(); err != nil {
+		if err == promptui.ErrAbort {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().Bool("fix", false, "remove orphaned metadata and offer to purge orphaned items")
+	verifyCmd.Flags().BoolP("yes", "y", false, "with --fix, purge orphaned items without asking")
+	verifyCmd.Flags().Bool("checksum", false, "recompute the checksums recorded with delete.checksum and report mismatches")
+}
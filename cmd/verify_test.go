@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// setupVerifyTest seeds a quarantine with a healthy item, an item whose
+// directory was deleted by hand and a directory without metadata
+func setupVerifyTest(t *testing.T) string {
+	t.Helper()
+	quarantineDir := filepath.Join(t.TempDir(), "quarantine")
+	require.NoError(t, os.MkdirAll(quarantineDir, 0755))
+
+	createNewItem(t, quarantineDir, "healthy", time.Now())
+	createNewItem(t, quarantineDir, "deleted-by-hand", time.Now())
+	require.NoError(t, os.Remove(filepath.Join(quarantineDir, "deleted-by-hand")))
+	require.NoError(t, os.Mkdir(filepath.Join(quarantineDir, "crashed-mid-quarantine"), 0755))
+	// The undo log is neither an item nor metadata
+	require.NoError(t, os.WriteFile(filepath.Join(quarantineDir, erase.SessionLogName), []byte("{}"), 0644))
+
+	Cfg = config.GetDefaults()
+	Cfg.Delete.QuarantineDir = quarantineDir
+	Cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	return quarantineDir
+}
+
+func TestFindQuarantineOrphans(t *testing.T) {
+	quarantineDir := setupVerifyTest(t)
+
+	orphans, err := findQuarantineOrphans(quarantineDir)
+	require.NoError(t, err)
+	require.Len(t, orphans.metadata, 1)
+	assert.Equal(t, "/dummy/original/path/deleted-by-hand", orphans.metadata[0].OriginalPath)
+	assert.Equal(t, []string{filepath.Join(quarantineDir, "crashed-mid-quarantine")}, orphans.items)
+
+	// A missing quarantine has nothing to verify
+	orphans, err = findQuarantineOrphans(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	assert.Zero(t, orphans.count())
+}
+
+func TestRunVerify(t *testing.T) {
+	t.Run("reports without fixing", func(t *testing.T) {
+		quarantineDir := setupVerifyTest(t)
+		err := runVerify(quarantineDir, false, false, nil)
+		assert.ErrorContains(t, err, "found 2 orphan(s)")
+		assert.FileExists(t, erase.MetadataPath(filepath.Join(quarantineDir, "deleted-by-hand")))
+		assert.DirExists(t, filepath.Join(quarantineDir, "crashed-mid-quarantine"))
+	})
+
+	t.Run("fix keeps declined items", func(t *testing.T) {
+		quarantineDir := setupVerifyTest(t)
+		require.NoError(t, runVerify(quarantineDir, true, false, func(int) (bool, error) { return false, nil }))
+		assert.NoFileExists(t, erase.MetadataPath(filepath.Join(quarantineDir, "deleted-by-hand")))
+		assert.DirExists(t, filepath.Join(quarantineDir, "crashed-mid-quarantine"))
+	})
+
+	t.Run("fix purges confirmed items", func(t *testing.T) {
+		quarantineDir := setupVerifyTest(t)
+		asked := 0
+		require.NoError(t, runVerify(quarantineDir, true, false, func(n int) (bool, error) {
+			asked = n
+			return true, nil
+		}))
+		assert.Equal(t, 1, asked)
+		assert.NoDirExists(t, filepath.Join(quarantineDir, "crashed-mid-quarantine"))
+
+		// Only the healthy item is left, and the quarantine now verifies
+		assert.DirExists(t, filepath.Join(quarantineDir, "healthy"))
+		require.NoError(t, runVerify(quarantineDir, false, false, nil))
+	})
+}
+
+func TestRunVerify_Checksum(t *testing.T) {
+	tmpDir := t.TempDir()
+	quarantineDir := filepath.Join(tmpDir, "quarantine")
+	Cfg = config.GetDefaults()
+	Cfg.Delete.QuarantineDir = quarantineDir
+	Cfg.Delete.AuditLog = filepath.Join(tmpDir, "audit.log")
+	Cfg.Delete.Checksum = true
+
+	var candidates []scan.Candidate
+	// Distinct names, as items quarantined in the same second share a timestamp
+	for _, name := range []string{"node_modules", "target"} {
+		dir := filepath.Join(tmpDir, "app", name)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "index.js"), []byte(name), 0644))
+		candidates = append(candidates, scan.Candidate{Path: dir})
+	}
+	require.NoError(t, erase.NewEraser(Cfg).EraseCandidates(candidates))
+
+	items, err := listQuarantinedItems(quarantineDir)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	for _, item := range items {
+		assert.NotEmpty(t, item.Checksum)
+	}
+	require.NoError(t, runVerify(quarantineDir, false, true, nil))
+
+	// Tamper with one of the quarantined files
+	tampered := items[0]
+	require.NoError(t, os.WriteFile(filepath.Join(tampered.QuarantinePath, "index.js"), []byte("tampered"), 0644))
+
+	mismatched, checked, err := findChecksumMismatches(quarantineDir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, checked)
+	require.Len(t, mismatched, 1)
+	assert.Equal(t, tampered.OriginalPath, mismatched[0].OriginalPath)
+	assert.ErrorContains(t, runVerify(quarantineDir, false, true, nil), "1 quarantined item(s) failed checksum verification")
+
+	// Without --checksum only orphans are looked for
+	require.NoError(t, runVerify(quarantineDir, false, false, nil))
+}
@@ -0,0 +1,352 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/erase"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+	"github.com/yehia2amer/BuildBloatBuster/internal/stats"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [paths...]",
+	Short: "Scan periodically and optionally quarantine stale directories",
+	Long: `Runs a scan every --interval until stopped, for use as a launchd or systemd
+service: it stays in the foreground and logs to stdout. Each cycle ends with
+one line of space-separated key=value pairs that log collectors can parse:
+
+  time=2024-05-01T03:00:00Z event=cycle cycle=1 status=ok candidates=12 bytes=5368709120 stale=3 staleBytes=2147483648 cleaned=0 freedBytes=0 duration=41.2s
+
+Directories not modified within --older-than are stale. With --auto-clean
+(or --clean) they are quarantined each cycle, which like the clean command
+also needs --apply. A cycle whose stale directories use more than
+delete.maxPerRunGB cleans none of them and logs status=refused.
+Each cycle starts after a random delay of up to --jitter, so machines started
+together don't scan at the same moment, and a cycle that is due while the
+previous one is still running is skipped. SIGINT and SIGTERM stop the watch
+once the running cycle has finished.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWatch(cmd, args)
+	},
+}
+
+// watchOptions are the settings of a watch that cycles don't change
+type watchOptions struct {
+	interval  time.Duration
+	jitter    time.Duration
+	olderThan time.Duration
+	autoClean bool
+}
+
+// cycleSummary is what one watch cycle found and removed
+type cycleSummary struct {
+	candidates int
+	bytes      int64
+	stale      int
+	staleBytes int64
+	cleaned    int
+	freedBytes int64
+	// refused is why the stale directories weren't cleaned, if a safety
+	// check such as delete.maxPerRunGB stopped it
+	refused string
+}
+
+func runWatch(cmd *cobra.Command, paths []string) error {
+	opts, err := watchOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	applyScanPathArgs(paths)
+	allowHome, _ := cmd.Flags().GetBool("allow-home")
+	if err := prepareScanPaths(allowHome); err != nil {
+		return err
+	}
+	if err := applyConfigFlags(cmd); err != nil {
+		return err
+	}
+	if err := Cfg.Validate(); err != nil {
+		return err
+	}
+	// Like global caches, directories removed unattended can always be restored
+	if opts.autoClean && Cfg.Delete.Mode != "quarantine" {
+		fmt.Fprintln(os.Stderr, "Note: watch --auto-clean always quarantines, ignoring delete.mode "+Cfg.Delete.Mode)
+		Cfg.Delete.Mode = "quarantine"
+		CfgSources.Set("delete.mode", "flag --auto-clean")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w := &watcher{
+		opts:   opts,
+		out:    os.Stdout,
+		after:  time.After,
+		random: func(n time.Duration) time.Duration { return rand.N(n) },
+		cycle: func() (cycleSummary, error) {
+			return watchCycle(cmd, opts, time.Now())
+		},
+		// A second signal then stops the watch without waiting for the cycle
+		stopping: stop,
+	}
+	return w.run(ctx)
+}
+
+// watchOptionsFromFlags reads and checks the watch flags
+func watchOptionsFromFlags(cmd *cobra.Command) (watchOptions, error) {
+	var opts watchOptions
+	opts.interval, _ = cmd.Flags().GetDuration("interval")
+	if opts.interval <= 0 {
+		return opts, fmt.Errorf("--interval must be greater than 0")
+	}
+	opts.jitter = opts.interval / 10
+	if cmd.Flags().Changed("jitter") {
+		opts.jitter, _ = cmd.Flags().GetDuration("jitter")
+		if opts.jitter < 0 {
+			return opts, fmt.Errorf("--jitter must not be negative")
+		}
+	}
+
+	olderThan, _ := cmd.Flags().GetString("older-than")
+	if olderThan != "" {
+		var err error
+		if opts.olderThan, err = parseAge(olderThan); err != nil {
+			return opts, fmt.Errorf("invalid --older-than: %w", err)
+		}
+	}
+	opts.autoClean, _ = cmd.Flags().GetBool("auto-clean")
+	if clean, _ := cmd.Flags().GetBool("clean"); clean {
+		opts.autoClean = true
+	}
+	if opts.autoClean && opts.olderThan == 0 {
+		return opts, fmt.Errorf("--auto-clean needs --older-than, so only directories nobody touched recently are removed")
+	}
+	return opts, nil
+}
+
+// parseAge parses an age such as 30d, 12h or 1h30m. Days are 24 hours; the
+// other units are those of time.ParseDuration.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not an age such as 30d or 12h", s)
+	}
+	return d, nil
+}
+
+// watcher runs cycles on a schedule. The clock, randomness and cycle are
+// fields so the schedule can be tested without waiting.
+type watcher struct {
+	opts   watchOptions
+	out    io.Writer
+	after  func(time.Duration) <-chan time.Time
+	random func(time.Duration) time.Duration
+	cycle  func() (cycleSummary, error)
+	// stopping, if set, is called once the context is cancelled
+	stopping func()
+}
+
+// cycleResult is a finished cycle, sent back to the schedule loop
+type cycleResult struct {
+	summary  cycleSummary
+	err      error
+	duration time.Duration
+}
+
+// run starts a cycle after a random delay of up to the jitter, then every
+// interval plus such a delay, until ctx is cancelled. A cycle that comes due
+// while the previous one is still running is skipped. Once cancelled, run
+// waits for the running cycle to finish before it returns.
+func (w *watcher) run(ctx context.Context) error {
+	logEvent(w.out, "start", "interval", w.opts.interval, "jitter", w.opts.jitter,
+		"olderThan", w.opts.olderThan, "autoClean", w.opts.autoClean, "dryRun", dryRun)
+
+	next := w.after(w.delay(0))
+	done := make(chan cycleResult, 1)
+	running := false
+	n := 0
+	for {
+		select {
+		case <-ctx.Done():
+			if w.stopping != nil {
+				w.stopping()
+			}
+			if running {
+				logEvent(w.out, "stopping", "reason", "signal", "waitingFor", n)
+				w.logCycle(n, <-done)
+			}
+			logEvent(w.out, "stop", "reason", "signal", "cycles", n)
+			return nil
+
+		case <-next:
+			next = w.after(w.delay(w.opts.interval))
+			if running {
+				logEvent(w.out, "skip", "cycle", n+1, "reason", "previous cycle still running")
+				continue
+			}
+			n++
+			running = true
+			go func() {
+				start := time.Now()
+				summary, err := w.cycle()
+				done <- cycleResult{summary: summary, err: err, duration: time.Since(start)}
+			}()
+
+		case result := <-done:
+			running = false
+			w.logCycle(n, result)
+		}
+	}
+}
+
+// delay returns base plus a random part of the jitter
+func (w *watcher) delay(base time.Duration) time.Duration {
+	if w.opts.jitter <= 0 {
+		return base
+	}
+	return base + w.random(w.opts.jitter)
+}
+
+// logCycle writes the summary line of cycle n
+func (w *watcher) logCycle(n int, result cycleResult) {
+	duration := result.duration.Round(100 * time.Millisecond)
+	if result.err != nil {
+		logEvent(w.out, "cycle", "cycle", n, "status", "error", "error", result.err.Error(), "duration", duration)
+		return
+	}
+	s := result.summary
+	if s.refused != "" {
+		logEvent(w.out, "cycle", "cycle", n, "status", "refused", "reason", s.refused,
+			"candidates", s.candidates, "bytes", s.bytes, "stale", s.stale, "staleBytes", s.staleBytes, "duration", duration)
+		return
+	}
+	logEvent(w.out, "cycle", "cycle", n, "status", "ok",
+		"candidates", s.candidates, "bytes", s.bytes, "stale", s.stale, "staleBytes", s.staleBytes,
+		"cleaned", s.cleaned, "freedBytes", s.freedBytes, "duration", duration)
+}
+
+// logEvent writes one line of key=value pairs, starting with the time and
+// event. Values are quoted as logfmtValue does.
+func logEvent(out io.Writer, event string, keyValues ...any) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "time=%s event=%s", time.Now().UTC().Format(time.RFC3339), event)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		fmt.Fprintf(&b, " %s=%s", keyValues[i], logfmtValue(fmt.Sprint(keyValues[i+1])))
+	}
+	fmt.Fprintln(out, b.String())
+}
+
+// watchCycle scans once and, with --auto-clean, quarantines the candidates
+// that weren't modified within --older-than of now
+func watchCycle(cmd *cobra.Command, opts watchOptions, now time.Time) (cycleSummary, error) {
+	var summary cycleSummary
+	candidates, err := findCandidates(cmd, nil, false)
+	if err != nil {
+		return summary, err
+	}
+	summary.candidates = len(candidates)
+	summary.bytes = totalCandidateSize(candidates)
+
+	stale := staleCandidates(candidates, opts.olderThan, now)
+	summary.stale = len(stale)
+	summary.staleBytes = totalCandidateSize(stale)
+	for _, candidate := range stale {
+		logEvent(os.Stdout, "stale", "path", candidate.Path, "bytes", candidate.SizeBytes,
+			"modified", candidate.NewestMTime.UTC().Format(time.RFC3339))
+	}
+
+	if !opts.autoClean || dryRun || len(stale) == 0 {
+		return summary, nil
+	}
+	stale = skipOpenCandidates(cmd, stale)
+	// Nobody is there to confirm going over delete.maxPerRunGB, so the cycle
+	// cleans nothing and the next one tries again
+	if refusal := deleteCapRefusal(stale); refusal != nil {
+		summary.refused = refusal.Reason
+		return summary, nil
+	}
+	eraser := erase.NewEraser(Cfg)
+	if err := eraser.EraseCandidates(stale); err != nil {
+		return summary, fmt.Errorf("failed during deletion: %w", err)
+	}
+	removed := eraser.Removed()
+	summary.cleaned = len(removed)
+	summary.freedBytes = totalCandidateSize(removed)
+
+	historyPath := stats.HistoryPath(Cfg.Delete.QuarantineDir)
+	if err := recordCleanStats(stats.DefaultPath(), historyPath, Cfg.Delete.Mode, removed); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update stats: %v\n", err)
+	}
+	return summary, nil
+}
+
+// staleCandidates returns the candidates last modified more than olderThan
+// before now. Without olderThan nothing is stale, and neither is a candidate
+// whose modification time is unknown.
+func staleCandidates(candidates []scan.Candidate, olderThan time.Duration, now time.Time) []scan.Candidate {
+	if olderThan == 0 {
+		return nil
+	}
+	var stale []scan.Candidate
+	cutoff := now.Add(-olderThan)
+	for _, candidate := range candidates {
+		if !candidate.NewestMTime.IsZero() && candidate.NewestMTime.Before(cutoff) {
+			stale = append(stale, candidate)
+		}
+	}
+	return stale
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().Duration("interval", 24*time.Hour, "time between scans")
+	watchCmd.Flags().Duration("jitter", 0, "random delay of up to this long before each scan (default: a tenth of --interval)")
+	watchCmd.Flags().String("older-than", "", "only count directories not modified for this long as stale, e.g. 30d or 12h")
+	watchCmd.Flags().Bool("auto-clean", false, "quarantine stale directories every cycle (needs --older-than and --apply)")
+	watchCmd.Flags().Bool("clean", false, "same as --auto-clean")
+	watchCmd.Flags().StringP("min-size", "s", "", "minimum size, e.g. 500MB or 2GiB; a plain number is MiB (overrides config)")
+	watchCmd.Flags().Int64("min-files", 0, "minimum number of files inside, e.g. 100000; applies together with --min-size")
+	watchCmd.Flags().IntP("max-depth", "d", 0, "maximum directory depth (overrides config)")
+	watchCmd.Flags().Int("max-results", 0, "stop scanning after this many directories were found, 0 = unlimited (overrides config)")
+	watchCmd.Flags().StringSliceP("include", "i", nil, "additional patterns to include")
+	watchCmd.Flags().StringSliceP("exclude", "e", nil, "additional patterns to exclude")
+	watchCmd.Flags().StringSlice("exclude-glob", nil, "exclude directories whose name matches these patterns, e.g. '*.egg-info'")
+	watchCmd.Flags().StringSlice("include-from", nil, "add the patterns in these files, one per line, to the include names")
+	watchCmd.Flags().StringSlice("exclude-from", nil, "add the patterns in these files, one per line, to the exclusions")
+	watchCmd.Flags().StringSlice("profile", nil, "built-in profiles to enable, e.g. node,python (overrides config)")
+	watchCmd.Flags().StringSlice("lang", nil, "scan only for these ecosystems' directories, e.g. node,rust, instead of the include list (see profiles list)")
+	watchCmd.MarkFlagsMutuallyExclusive("lang", "profile")
+	watchCmd.Flags().StringSlice("presets", nil, "built-in presets of directories outside any project to report too, e.g. mobile (overrides config)")
+	watchCmd.Flags().Bool("allow-home", false, "allow scanning your entire home directory")
+	watchCmd.Flags().Bool("include-network-fs", false, "descend into network filesystems (NFS, SMB, ...) below the scan paths")
+	watchCmd.Flags().Int("concurrency", 0, "number of size calculation workers (default: tuned to the storage type)")
+	watchCmd.Flags().Bool("no-cache", false, "recompute every size instead of reusing cached sizes")
+	watchCmd.Flags().Bool("no-open-file-check", false, "don't skip directories that running processes have files open in, which saves time on long lists")
+	watchCmd.Flags().Bool("force-recent", false, "include directories modified within skipIfModifiedWithin, which a build may still be using")
+	watchCmd.Flags().Duration("timeout", 0, "time limit for scanning and size calculation in each cycle, e.g. 10m (overrides config)")
+	watchCmd.ValidArgsFunction = completeScanPaths
+	watchCmd.RegisterFlagCompletionFunc("include", listCompletion(knownPatternNames))
+	watchCmd.RegisterFlagCompletionFunc("exclude", listCompletion(knownPatternNames))
+	watchCmd.RegisterFlagCompletionFunc("profile", listCompletion(config.ProfileNames))
+	watchCmd.RegisterFlagCompletionFunc("lang", listCompletion(config.ProfileNames))
+	watchCmd.RegisterFlagCompletionFunc("presets", listCompletion(config.PresetNames))
+	watchCmd.RegisterFlagCompletionFunc("older-than", cobra.NoFileCompletions)
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "30d", want: 30 * 24 * time.Hour},
+		{input: "0d", want: 0},
+		{input: "12h", want: 12 * time.Hour},
+		{input: "1h30m", want: 90 * time.Minute},
+		{input: "d", wantErr: true},
+		{input: "-3d", wantErr: true},
+		{input: "1.5d", wantErr: true},
+		{input: "-1h", wantErr: true},
+		{input: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseAge(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStaleCandidates(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []scan.Candidate{
+		{Path: "/p/old", NewestMTime: now.AddDate(0, 0, -40)},
+		{Path: "/p/recent", NewestMTime: now.AddDate(0, 0, -5)},
+		{Path: "/p/unknown"},
+	}
+
+	stale := staleCandidates(candidates, 30*24*time.Hour, now)
+	require.Len(t, stale, 1)
+	assert.Equal(t, "/p/old", stale[0].Path)
+
+	assert.Empty(t, staleCandidates(candidates, 0, now), "without an age nothing is stale")
+}
+
+// fakeClock hands the timers of a watcher to the test, which fires them
+type fakeClock struct {
+	timers chan chan time.Time
+	delays []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{timers: make(chan chan time.Time, 10)}
+}
+
+func (c *fakeClock) after(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	timer := make(chan time.Time, 1)
+	c.timers <- timer
+	return timer
+}
+
+// fire fires the next timer the watcher started
+func (c *fakeClock) fire(t *testing.T) {
+	t.Helper()
+	select {
+	case timer := <-c.timers:
+		timer <- time.Now()
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher started no timer")
+	}
+}
+
+func TestWatcher_TickRunsCycle(t *testing.T) {
+	clock := newFakeClock()
+	scans := make(chan struct{}, 10)
+	var out bytes.Buffer
+	w := &watcher{
+		opts:  watchOptions{interval: time.Minute},
+		out:   &out,
+		after: clock.after,
+		cycle: func() (cycleSummary, error) {
+			scans <- struct{}{}
+			return cycleSummary{candidates: 1, bytes: 42}, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.run(ctx) }()
+
+	assert.Empty(t, scans, "nothing is scanned before the first tick")
+	clock.fire(t)
+	select {
+	case <-scans:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the tick did not start a scan")
+	}
+	// The next tick is scheduled once the cycle started
+	<-clock.timers
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Empty(t, scans, "one tick runs one scan")
+	assert.Equal(t, []time.Duration{0, time.Minute}, clock.delays)
+	assert.Regexp(t, `(?m)^time=\S+ event=cycle cycle=1 status=ok candidates=1 bytes=42 `, out.String())
+}
+
+func TestWatcher_SkipsCycleWhileRunning(t *testing.T) {
+	clock := newFakeClock()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var out bytes.Buffer
+	w := &watcher{
+		opts:   watchOptions{interval: time.Hour, jitter: 6 * time.Minute},
+		out:    &out,
+		after:  clock.after,
+		random: func(n time.Duration) time.Duration { return n / 2 },
+		cycle: func() (cycleSummary, error) {
+			started <- struct{}{}
+			<-release
+			return cycleSummary{candidates: 2, bytes: 100}, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.run(ctx) }()
+
+	clock.fire(t)
+	<-started
+	// The second cycle comes due while the first still runs
+	clock.fire(t)
+	// Once the watcher started the third timer it has handled the second
+	<-clock.timers
+	cancel()
+	close(release)
+	require.NoError(t, <-done)
+
+	log := out.String()
+	assert.Contains(t, log, `event=skip cycle=2 reason="previous cycle still running"`)
+	assert.Contains(t, log, "event=cycle cycle=1 status=ok candidates=2 bytes=100 stale=0 staleBytes=0 cleaned=0 freedBytes=0")
+	assert.Contains(t, log, "event=stop reason=signal cycles=1")
+	assert.Equal(t, []time.Duration{3 * time.Minute, time.Hour + 3*time.Minute, time.Hour + 3*time.Minute}, clock.delays[:3])
+}
+
+func TestWatcher_CycleError(t *testing.T) {
+	clock := newFakeClock()
+	cycleDone := make(chan struct{})
+	var out bytes.Buffer
+	w := &watcher{
+		opts:  watchOptions{interval: time.Hour},
+		out:   &out,
+		after: clock.after,
+		cycle: func() (cycleSummary, error) {
+			defer close(cycleDone)
+			return cycleSummary{}, errors.New("scan failed: disk gone")
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.run(ctx) }()
+
+	clock.fire(t)
+	<-cycleDone
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Contains(t, out.String(), `event=cycle cycle=1 status=error error="scan failed: disk gone"`)
+	assert.Equal(t, time.Duration(0), clock.delays[0], "no jitter without --jitter")
+}
+
+func TestWatchCycle_RefusesOverDeleteCap(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	oldCfg, oldSources, oldDryRun := Cfg, CfgSources, dryRun
+	t.Cleanup(func() { Cfg, CfgSources, dryRun = oldCfg, oldSources, oldDryRun })
+
+	Cfg = config.GetDefaults()
+	Cfg.ExcludePaths = nil
+	Cfg.MinSize = "0"
+	Cfg.Delete.AuditLog = ""
+	Cfg.Delete.QuarantineDir = filepath.Join(t.TempDir(), "quarantine")
+	// One byte is less than any directory uses
+	Cfg.Delete.MaxPerRunGB = 1e-9
+	CfgSources = config.Provenance{}
+	dryRun = false
+
+	project := filepath.Join(t.TempDir(), "app")
+	target := filepath.Join(project, "node_modules")
+	require.NoError(t, os.MkdirAll(target, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(target, "index.js"), []byte("module.exports = 1\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "package.json"), []byte("{}"), 0644))
+	old := time.Now().AddDate(0, 0, -40)
+	require.NoError(t, os.Chtimes(filepath.Join(target, "index.js"), old, old))
+	require.NoError(t, os.Chtimes(target, old, old))
+	applyScanPathArgs([]string{project})
+
+	summary, err := watchCycle(watchCmd, watchOptions{olderThan: 30 * 24 * time.Hour, autoClean: true}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary.stale)
+	assert.Zero(t, summary.cleaned)
+	assert.Contains(t, summary.refused, "a run may delete")
+	assert.DirExists(t, target)
+
+	var out bytes.Buffer
+	(&watcher{out: &out}).logCycle(1, cycleResult{summary: summary})
+	assert.Contains(t, out.String(), "event=cycle cycle=1 status=refused reason=")
+}
+
+func TestLogEvent(t *testing.T) {
+	var out bytes.Buffer
+	logEvent(&out, "stale", "path", "/p/my app/node_modules", "bytes", 42, "note", "", "expr", "a=b")
+	line := out.String()
+	assert.Regexp(t, `^time=\S+ event=stale `, line)
+	assert.Contains(t, line, `path="/p/my app/node_modules" bytes=42 note="" expr="a=b"`+"\n")
+}
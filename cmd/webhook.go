@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yehia2amer/BuildBloatBuster/internal/report"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+	"github.com/yehia2amer/BuildBloatBuster/internal/webhook"
+)
+
+// applyWebhookFlags overrides the configured webhook with --report-url,
+// --report-url-header and --report-url-insecure, if given
+func applyWebhookFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	if flags.Changed("report-url") {
+		Cfg.Output.WebhookURL, _ = flags.GetString("report-url")
+		CfgSources.Set("output.webhookURL", "flag --report-url")
+	}
+	if flags.Changed("report-url-header") {
+		headers, _ := flags.GetStringArray("report-url-header")
+		Cfg.Output.WebhookHeaders = append(Cfg.Output.WebhookHeaders, headers...)
+		CfgSources.Set("output.webhookHeaders", CfgSources.Source("output.webhookHeaders")+" + flag --report-url-header")
+	}
+	if flags.Changed("report-url-insecure") {
+		Cfg.Output.WebhookInsecure, _ = flags.GetBool("report-url-insecure")
+		CfgSources.Set("output.webhookInsecure", "flag --report-url-insecure")
+	}
+}
+
+// postReport posts the summary of candidates to output.webhookURL, if one is
+// set. The run already did its work, so a report that can't be delivered is
+// only warned about.
+func postReport(summary webhook.Report, candidates []scan.Candidate) {
+	url, header, err := Cfg.Webhook()
+	if url == "" || err != nil {
+		return
+	}
+	if candidates == nil {
+		candidates = []scan.Candidate{}
+	}
+	summary.Snapshot = report.NewSnapshot(candidates)
+	summary.Errors = readErrors
+	summary.Hostname, _ = os.Hostname()
+	summary.Timestamp = time.Now()
+
+	opts := webhook.Options{
+		URL:      url,
+		Header:   header,
+		Timeout:  time.Duration(Cfg.Output.WebhookTimeoutSeconds) * time.Second,
+		Insecure: Cfg.Output.WebhookInsecure,
+	}
+	if err := webhook.Post(context.Background(), opts, summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to post the report to output.webhookURL: %v\n", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Package bytesize parses human-readable sizes such as "500MB" or "2GiB".
+package bytesize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SI (decimal) units
+const (
+	B  int64 = 1
+	KB int64 = 1000
+	MB       = KB * 1000
+	GB       = MB * 1000
+	TB       = GB * 1000
+	PB       = TB * 1000
+)
+
+// IEC (binary) units
+const (
+	KiB int64 = 1024
+	MiB       = KiB * 1024
+	GiB       = MiB * 1024
+	TiB       = GiB * 1024
+	PiB       = TiB * 1024
+)
+
+// units maps lower-cased unit suffixes to their size in bytes. The single
+// letter forms follow du and sort -h and are binary.
+var units = map[string]int64{
+	"b":  B,
+	"kb": KB, "mb": MB, "gb": GB, "tb": TB, "pb": PB,
+	"kib": KiB, "mib": MiB, "gib": GiB, "tib": TiB, "pib": PiB,
+	"k": KiB, "m": MiB, "g": GiB, "t": TiB, "p": PiB,
+}
+
+// Parse converts a size such as "500MB", "1.5 GiB" or "2048" to bytes. Units
+// are case-insensitive; KB, MB, GB, TB and PB are powers of 1000 and KiB, MiB,
+// GiB, TiB and PiB powers of 1024. A plain number is a count of bytes.
+func Parse(s string) (int64, error) {
+	return ParseWithUnit(s, B)
+}
+
+// ParseWithUnit is like Parse but reads a plain number as a count of
+// defaultUnit instead of bytes.
+func ParseWithUnit(s string, defaultUnit int64) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return 0, invalid(s, "it is empty")
+	}
+
+	end := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end < 0 {
+		end = len(trimmed)
+	}
+	number, suffix := trimmed[:end], strings.TrimSpace(trimmed[end:])
+	if number == "" {
+		if strings.HasPrefix(trimmed, "-") {
+			return 0, invalid(s, "it must not be negative")
+		}
+		return 0, invalid(s, "it must start with a number, e.g. 500MB")
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, invalid(s, "%q is not a number", number)
+	}
+
+	unit := defaultUnit
+	if suffix != "" {
+		var ok bool
+		if unit, ok = units[strings.ToLower(suffix)]; !ok {
+			return 0, invalid(s, "unknown unit %q (use B, KB, MB, GB, TB, KiB, MiB, GiB or TiB)", suffix)
+		}
+	}
+
+	bytes := value * float64(unit)
+	if bytes >= math.MaxInt64 {
+		return 0, invalid(s, "it is too large")
+	}
+	return int64(bytes), nil
+}
+
+// invalid returns an error for the size s that wraps the reason it was rejected,
+// so callers can report the reason alongside their own context
+func invalid(s, format string, args ...any) error {
+	return fmt.Errorf("invalid size %q: %w", s, fmt.Errorf(format, args...))
+}
@@ -0,0 +1,126 @@
+package bytesize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"2048", 2048},
+		{"512B", 512},
+		{"1KB", 1000},
+		{"500MB", 500 * MB},
+		{"2GB", 2 * GB},
+		{"3TB", 3 * TB},
+		{"1KiB", 1024},
+		{"10MiB", 10 * 1024 * 1024},
+		{"2GiB", 2 * GiB},
+		{"1TiB", TiB},
+		{"1.5GB", 1500 * MB},
+		{"0.5GiB", 512 * MiB},
+		{"500 mb", 500 * MB},
+		{"  10gib ", 10 * GiB},
+		{"2G", 2 * GiB},
+		{"100k", 100 * KiB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr string
+	}{
+		{"", "it is empty"},
+		{"10 bananas", `unknown unit "bananas"`},
+		{"MB", "must start with a number"},
+		{"-5MB", "must not be negative"},
+		{"1.2.3GB", `"1.2.3" is not a number`},
+		{"10 MB extra", `unknown unit "MB extra"`},
+		{"99999999PiB", "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := Parse(tt.in)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestParseWithUnit(t *testing.T) {
+	got, err := ParseWithUnit("10", MiB)
+	require.NoError(t, err)
+	assert.Equal(t, 10*MiB, got)
+
+	// An explicit unit wins over the default
+	got, err = ParseWithUnit("10KB", MiB)
+	require.NoError(t, err)
+	assert.Equal(t, 10*KB, got)
+}
+
+func TestFormat(t *testing.T) {
+	t.Cleanup(func() { DisplayUnits = UnitsIEC })
+
+	tests := []struct {
+		bytes int64
+		si    string
+		iec   string
+		exact string
+	}{
+		{0, "0 B", "0 B", "0 B"},
+		{999, "999 B", "999 B", "999 B"},
+		{1500 * MB, "1.5 GB", "1.4 GiB", "1,500,000,000 B"},
+		{10 * MiB, "10 MB", "10 MiB", "10,485,760 B"},
+		{-2 * GB, "-2.0 GB", "-1.9 GiB", "-2,000,000,000 B"},
+	}
+	for _, tt := range tests {
+		DisplayUnits = UnitsSI
+		assert.Equal(t, tt.si, Format(tt.bytes))
+		DisplayUnits = UnitsIEC
+		assert.Equal(t, tt.iec, Format(tt.bytes))
+		DisplayUnits = UnitsBytes
+		assert.Equal(t, tt.exact, Format(tt.bytes))
+	}
+}
+
+func TestUnit(t *testing.T) {
+	tests := []struct {
+		unit  string
+		bytes int64
+		want  string
+	}{
+		{"MB", 1536 * MB, "1536.0"},
+		{"mb", 1536 * MB, "1536.0"},
+		{"MiB", 1536 * MiB, "1536.0"},
+		{"GB", 1536 * MB, "1.5"},
+		{"KB", 1234, "1.2"},
+		{"B", 1536 * MB, "1536000000"},
+		{"GiB", 0, "0.0"},
+	}
+	for _, tt := range tests {
+		unit, err := ParseUnit(tt.unit)
+		require.NoError(t, err, tt.unit)
+		assert.Equal(t, tt.want, unit.Format(tt.bytes), "%d in %s", tt.bytes, tt.unit)
+	}
+
+	unit, err := ParseUnit("")
+	require.NoError(t, err)
+	assert.True(t, unit.IsZero())
+
+	_, err = ParseUnit("M")
+	assert.ErrorContains(t, err, `unknown size unit "M"`)
+}
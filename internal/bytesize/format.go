@@ -0,0 +1,78 @@
+package bytesize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Units is a style of rendering sizes for people
+type Units string
+
+const (
+	// UnitsSI renders powers of 1000: kB, MB, GB
+	UnitsSI Units = "si"
+	// UnitsIEC renders powers of 1024: KiB, MiB, GiB, as du -h does
+	UnitsIEC Units = "iec"
+	// UnitsBytes renders exact byte counts: 1,536,000 B
+	UnitsBytes Units = "bytes"
+)
+
+// DisplayUnits is the style Format renders sizes in. The CLI sets it from
+// output.units before running a command.
+var DisplayUnits = UnitsIEC
+
+// Format renders a count of bytes in DisplayUnits, e.g. 1.4 GiB, 1.5 GB or
+// 1,536,000,000 B
+func Format(n int64) string {
+	if n < 0 {
+		return "-" + Format(-n)
+	}
+	switch DisplayUnits {
+	case UnitsSI:
+		return humanize.Bytes(uint64(n))
+	case UnitsBytes:
+		return humanize.Comma(n) + " B"
+	}
+	return humanize.IBytes(uint64(n))
+}
+
+// Unit is a fixed unit to show sizes in as plain numbers, such as MB for
+// spreadsheets. The zero Unit means sizes are humanized instead.
+type Unit struct {
+	// Name is the canonical spelling, e.g. MB or GiB
+	Name  string
+	Bytes int64
+}
+
+// UnitNames lists the units ParseUnit accepts, in their canonical spelling
+var UnitNames = []string{"B", "KB", "MB", "GB", "TB", "KiB", "MiB", "GiB", "TiB"}
+
+// ParseUnit returns the unit named s, ignoring case. An empty s is the zero Unit.
+func ParseUnit(s string) (Unit, error) {
+	if s == "" {
+		return Unit{}, nil
+	}
+	for _, name := range UnitNames {
+		if strings.EqualFold(s, name) {
+			return Unit{Name: name, Bytes: units[strings.ToLower(name)]}, nil
+		}
+	}
+	return Unit{}, fmt.Errorf("unknown size unit %q (use %s)", s, strings.Join(UnitNames, ", "))
+}
+
+// IsZero reports whether u is the zero Unit
+func (u Unit) IsZero() bool {
+	return u.Bytes == 0
+}
+
+// Format renders n as a number of u with one decimal, e.g. 1536.0 for
+// 1.536 GB in MB. Bytes are rendered as a whole number.
+func (u Unit) Format(n int64) string {
+	if u.Bytes <= 1 {
+		return strconv.FormatInt(n, 10)
+	}
+	return strconv.FormatFloat(float64(n)/float64(u.Bytes), 'f', 1, 64)
+}
@@ -1,33 +1,190 @@
 package config
 
 import (
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
 )
 
 type Config struct {
-	ScanPaths      []string `koanf:"scanPaths"`
-	IncludeNames   []string `koanf:"includeNames"`
-	ExcludeNames   []string `koanf:"excludeNames"`
-	ExcludePaths   []string `koanf:"excludePaths"`
-	MinSizeMB      int      `koanf:"minSizeMB"`
-	MaxDepth       int      `koanf:"maxDepth"`
-	FollowSymlinks bool     `koanf:"followSymlinks"`
-	Concurrency    int      `koanf:"concurrency"`
-	Delete         struct {
+	// Version is the config file format, see CurrentVersion. Older files are
+	// migrated when loaded, so a loaded config is always the current version.
+	Version      int      `koanf:"version"`
+	ScanPaths    []string `koanf:"scanPaths"`
+	IncludeNames []string `koanf:"includeNames"`
+	ExcludeNames []string `koanf:"excludeNames"`
+	// ExcludeGlobs are patterns such as "*.egg-info" that exclude every
+	// directory whose name matches, like excludeNames do for exact names
+	ExcludeGlobs []string `koanf:"excludeGlobs"`
+	// IncludeFrom and ExcludeFrom name files of patterns, one per line, that
+	// are added to includeNames and to excludeNames or excludeGlobs when the
+	// config is loaded. Relative paths are resolved against the config
+	// file's directory.
+	IncludeFrom []string `koanf:"includeFrom"`
+	ExcludeFrom []string `koanf:"excludeFrom"`
+	// Profiles enables built-in ecosystem profiles, which add include names
+	// and restrict generic ones to real projects
+	Profiles []string `koanf:"profiles"`
+	// Presets enables built-in sets of well-known directories outside any
+	// project, such as Xcode's DerivedData, which scans then report too
+	Presets []string `koanf:"presets"`
+	// Rules refine individual include names, keyed by directory name
+	Rules map[string]Rule `koanf:"rules"`
+	// ReplaceDefaults makes includeNames/excludeNames from a config file replace
+	// the built-in lists instead of being appended to them.
+	ReplaceDefaults bool     `koanf:"replaceDefaults"`
+	ExcludePaths    []string `koanf:"excludePaths"`
+	// ProjectMarkers are file names, or glob patterns such as "*.csproj",
+	// that mark a project root in addition to the built-in ones
+	ProjectMarkers []string `koanf:"projectMarkers"`
+	// MinSize is the smallest directory reported, e.g. "500MB" or "2GiB".
+	// A plain number is read as MiB, like the deprecated minSizeMB key.
+	MinSize  string `koanf:"minSize"`
+	MaxDepth int    `koanf:"maxDepth"`
+	// MaxResults stops the scan once this many candidates were found, to bound
+	// time and memory on huge trees (0 = unlimited)
+	MaxResults     int  `koanf:"maxResults"`
+	FollowSymlinks bool `koanf:"followSymlinks"`
+	// IncludeNetworkFS makes the scanner descend into network filesystems
+	// (NFS, SMB, ...) found below a scan path
+	IncludeNetworkFS bool `koanf:"includeNetworkFS"`
+	// SkipIfModifiedWithin is a duration such as "10m": candidates with a file
+	// modified more recently may belong to a running build and are skipped
+	SkipIfModifiedWithin string `koanf:"skipIfModifiedWithin"`
+	// ReadRetries is how often a directory read failing with an I/O error,
+	// as on a flaky network mount, is retried before the directory is skipped
+	ReadRetries int `koanf:"readRetries"`
+	Concurrency int `koanf:"concurrency"`
+	Delete      struct {
 		Mode          string `koanf:"mode"`
 		QuarantineDir string `koanf:"quarantineDir"`
 		RetentionDays int    `koanf:"retentionDays"`
+		// MaxPerRunGB caps the total size, in GB, a clean run may delete; a
+		// larger selection needs a typed confirmation (0 = no cap)
+		MaxPerRunGB float64 `koanf:"maxPerRunGB"`
+		// Compress stores quarantined directories as .tar.gz archives
+		Compress bool `koanf:"compress"`
+		// Checksum records a SHA-256 of every quarantined item in its metadata
+		// for verify --checksum; it reads all of the item's data
+		Checksum bool `koanf:"checksum"`
+		// AuditLog is a JSONL file that every quarantine, rm, restore and purge
+		// is appended to (empty = disabled)
+		AuditLog string `koanf:"auditLog"`
 	} `koanf:"delete"`
 	Output struct {
 		Format string `koanf:"format"`
 		SortBy string `koanf:"sortBy"`
+		// Units is how sizes are shown: "iec" (MiB, powers of 1024), "si"
+		// (MB, powers of 1000) or "bytes" (exact byte counts)
+		Units string `koanf:"units"`
+		// SizeUnit, if set, shows the size columns of table and CSV output
+		// as plain numbers in this unit, e.g. MB (empty = humanized)
+		SizeUnit string `koanf:"sizeUnit"`
+		// WebhookURL, if set, is sent the JSON summary of every scan and
+		// clean, see Webhook
+		WebhookURL string `koanf:"webhookURL"`
+		// WebhookHeaders are "Name: value" headers sent with it, e.g. for
+		// authentication
+		WebhookHeaders []string `koanf:"webhookHeaders"`
+		// WebhookTimeoutSeconds bounds each attempt to deliver it (0 = no limit)
+		WebhookTimeoutSeconds int `koanf:"webhookTimeoutSeconds"`
+		// WebhookInsecure skips verifying the TLS certificate of WebhookURL,
+		// for self-signed certificates on internal networks
+		WebhookInsecure bool `koanf:"webhookInsecure"`
 	} `koanf:"output"`
+	Size struct {
+		// TimeoutSeconds bounds scanning and size calculation, which overlap (0 = no limit).
+		TimeoutSeconds int `koanf:"timeoutSeconds"`
+		// CandidateTimeoutSeconds bounds sizing a single candidate; when it expires
+		// the candidate keeps its partial size and is flagged incomplete (0 = no limit).
+		CandidateTimeoutSeconds int `koanf:"candidateTimeoutSeconds"`
+	} `koanf:"size"`
+	// Behavior sets the per-machine defaults of flags that guard deletion;
+	// the flags, when given, always win
+	Behavior struct {
+		// DryRunByDefault makes clean and watch --auto-clean only report
+		// unless --apply is given; when false they delete unless --dry-run is
+		DryRunByDefault bool `koanf:"dryRunByDefault"`
+		// RequireConfirmation makes clean ask before deleting unless --yes is
+		// given; when false it proceeds unless --yes=false is
+		RequireConfirmation bool `koanf:"requireConfirmation"`
+	} `koanf:"behavior"`
+}
+
+// Rule adds exceptions to an include name. Exclusions always beat includes:
+// a name listed in excludeNames is skipped even if it has a rule.
+type Rule struct {
+	// ExceptSibling skips the directory when any of these files sits next to
+	// it, e.g. vendor next to go.mod holds Go source rather than build output
+	ExceptSibling []string `koanf:"exceptSibling" json:"exceptSibling" yaml:"exceptSibling"`
+}
+
+// MinSizeBytes returns the MinSize threshold in bytes
+func (c Config) MinSizeBytes() (int64, error) {
+	return bytesize.ParseWithUnit(c.MinSize, bytesize.MiB)
+}
+
+// MaxDeleteBytes returns the Delete.MaxPerRunGB cap in bytes, 0 if there is none
+func (c Config) MaxDeleteBytes() int64 {
+	return int64(math.Round(c.Delete.MaxPerRunGB * float64(bytesize.GB)))
+}
+
+// InUseWindow returns SkipIfModifiedWithin as a duration; an empty or zero
+// setting disables the check
+func (c Config) InUseWindow() (time.Duration, error) {
+	if c.SkipIfModifiedWithin == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.SkipIfModifiedWithin)
+}
+
+// OutputFormats returns the formats in Output.Format, which lists one or more
+// separated by commas, without duplicates
+func (c Config) OutputFormats() []string {
+	var formats []string
+	for _, format := range strings.Split(c.Output.Format, ",") {
+		format = strings.TrimSpace(format)
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// HasOutputFormat reports whether format is one of the OutputFormats
+func (c Config) HasOutputFormat(format string) bool {
+	return slices.Contains(c.OutputFormats(), format)
+}
+
+// MachineReadable reports whether stdout carries output for another program,
+// JSON, TSV or a plain list of paths, so notes for people must go elsewhere
+func (c Config) MachineReadable() bool {
+	return c.HasOutputFormat("json") || c.HasOutputFormat("tsv") || c.HasOutputFormat("plain") || c.HasOutputFormat("plain0")
+}
+
+// FixedSizeUnit returns the Output.SizeUnit to show size columns in; the
+// zero Unit if sizes are humanized
+func (c Config) FixedSizeUnit() (bytesize.Unit, error) {
+	return bytesize.ParseUnit(c.Output.SizeUnit)
+}
+
+// defaultAuditLog returns where the audit log is kept unless configured:
+// $XDG_STATE_HOME/BuildBloatBuster/audit.log, or ~/.local/state as in the XDG spec
+func defaultAuditLog(getenv func(string) string, homeDir string) string {
+	stateDir := getenv("XDG_STATE_HOME")
+	if stateDir == "" || !filepath.IsAbs(stateDir) {
+		stateDir = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateDir, "BuildBloatBuster", "audit.log")
 }
 
 // GetDefaults returns the default configuration
@@ -36,6 +193,7 @@ func GetDefaults() Config {
 	quarantineDir := filepath.Join(homeDir, ".cache", "BuildBloatBuster", "trash")
 
 	config := Config{
+		Version:   CurrentVersion,
 		ScanPaths: []string{"."},
 		IncludeNames: []string{
 			"node_modules",
@@ -68,18 +226,30 @@ func GetDefaults() Config {
 			"src", "lib", "source", "Sources", "include",
 		},
 		ExcludePaths:   getDefaultExcludePaths(homeDir),
-		MinSizeMB:      10,
+		MinSize:        "10MB",
 		MaxDepth:       8,
 		FollowSymlinks: false,
-		Concurrency:    runtime.NumCPU() * 2,
+		ReadRetries:    3,
+		// Long enough to cover an incremental build writing to its output
+		SkipIfModifiedWithin: "10m",
+		Concurrency:          0, // auto-tuned per scan root from its storage type
 	}
 
 	config.Delete.Mode = "quarantine"
 	config.Delete.QuarantineDir = quarantineDir
 	config.Delete.RetentionDays = 14
+	config.Delete.AuditLog = defaultAuditLog(os.Getenv, homeDir)
 
 	config.Output.Format = "table"
 	config.Output.SortBy = "size"
+	config.Output.Units = "iec"
+	config.Output.WebhookTimeoutSeconds = 10
+
+	config.Size.TimeoutSeconds = 300
+	config.Size.CandidateTimeoutSeconds = 60
+
+	config.Behavior.DryRunByDefault = true
+	config.Behavior.RequireConfirmation = true
 
 	return config
 }
@@ -96,6 +266,58 @@ func GetProtectedPaths() []string {
 	return paths
 }
 
+// IsProtectedPath reports whether path is exactly one of the protected paths.
+func IsProtectedPath(path string) bool {
+	return matchProtectedPath(path, false)
+}
+
+// IsWithinProtectedPath reports whether path is a protected path or lies inside one.
+// Filesystem roots such as "/" only match exactly, otherwise every path would be protected.
+func IsWithinProtectedPath(path string) bool {
+	return matchProtectedPath(path, true)
+}
+
+func matchProtectedPath(path string, includeDescendants bool) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	for _, protected := range GetProtectedPaths() {
+		absProtected, err := filepath.Abs(protected)
+		if err != nil {
+			continue
+		}
+		if absPath == absProtected {
+			return true
+		}
+		if !includeDescendants || filepath.Dir(absProtected) == absProtected {
+			continue
+		}
+		if strings.HasPrefix(absPath, absProtected+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHomeDir reports whether path is the root of the current user's home directory.
+func IsHomeDir(path string) bool {
+	homeDir, err := os.UserHomeDir()
+	if err != nil || homeDir == "" {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absHome, err := filepath.Abs(homeDir)
+	if err != nil {
+		return false
+	}
+	return absPath == absHome
+}
+
 // getDefaultExcludePaths returns platform-specific default exclude paths
 func getDefaultExcludePaths(homeDir string) []string {
 	paths := []string{
@@ -129,25 +351,89 @@ func getDefaultExcludePaths(homeDir string) []string {
 
 // LoadConfig loads configuration from file and merges with defaults
 func LoadConfig(path string) (Config, error) {
+	config, _, err := LoadConfigWithProvenance(path)
+	return config, err
+}
+
+// LoadConfigWithProvenance loads configuration from file, merges it with the
+// defaults and records which settings the file provided.
+func LoadConfigWithProvenance(path string) (Config, Provenance, error) {
 	// Start with defaults
 	config := GetDefaults()
+	provenance := Provenance{}
 
 	// Try to load from file
 	k := koanf.New(".")
 	if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
-		return config, err // Return defaults with error
+		return config, provenance, err // Return defaults with error
+	}
+
+	// Older file formats are upgraded first; KeyWarnings reports what changed
+	_, renamed, err := migrate(k)
+	if err != nil {
+		return config, provenance, err
 	}
 
 	// Merge file config over defaults
 	if err := k.Unmarshal("", &config); err != nil {
-		return config, err
+		return config, provenance, err
+	}
+	config.expandPaths(runtime.GOOS, os.LookupEnv)
+	config.Version = CurrentVersion
+	for _, key := range k.Keys() {
+		// Rules are keyed by directory name, so they're tracked as a whole
+		if strings.HasPrefix(key, "rules.") {
+			key = "rules"
+		}
+		if oldKey, ok := renamed[key]; ok {
+			provenance.Set(key, path+" ("+oldKey+")")
+			continue
+		}
+		provenance.Set(key, path)
+	}
+
+	// Name lists are additive unless the file explicitly replaces the defaults
+	if !config.ReplaceDefaults {
+		defaults := GetDefaults()
+		config.IncludeNames = mergeNames(defaults.IncludeNames, k.Strings("includeNames"))
+		config.ExcludeNames = mergeNames(defaults.ExcludeNames, k.Strings("excludeNames"))
+		for _, key := range []string{"includeNames", "excludeNames"} {
+			if k.Exists(key) {
+				provenance.Set(key, SourceDefault+" + "+path)
+			}
+		}
 	}
 
-	return config, nil
+	if err := config.readPatternFiles(filepath.Dir(path), provenance); err != nil {
+		return config, provenance, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, provenance, err
+	}
+
+	return config, provenance, nil
 }
 
-// LoadConfigWithDefaults loads config or returns defaults if file doesn't exist
-func LoadConfigWithDefaults(path string) Config {
-	config, _ := LoadConfig(path)
-	return config
+// mergeNames appends extra to base, skipping duplicates while preserving order
+func mergeNames(base, extra []string) []string {
+	merged := make([]string, 0, len(base)+len(extra))
+	seen := make(map[string]struct{}, len(base)+len(extra))
+	for _, name := range append(append([]string{}, base...), extra...) {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		merged = append(merged, name)
+	}
+	return merged
+}
+
+// LoadConfigWithDefaults loads config or returns defaults if file doesn't exist.
+// Any other error, such as an invalid setting, is returned.
+func LoadConfigWithDefaults(path string) (Config, Provenance, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return GetDefaults(), Provenance{}, nil
+	}
+	return LoadConfigWithProvenance(path)
 }
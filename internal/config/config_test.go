@@ -0,0 +1,555 @@
+package config
+
+import (
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestConfig writes a YAML config file into a temp dir and returns its path.
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".BuildBloatBuster.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfig_Validation(t *testing.T) {
+	t.Run("defaults are valid", func(t *testing.T) {
+		assert.NoError(t, GetDefaults().Validate())
+	})
+
+	t.Run("invalid delete mode", func(t *testing.T) {
+		path := writeTestConfig(t, "delete:\n  mode: quarentine\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid delete.mode "quarentine"`)
+		assert.Contains(t, err.Error(), "quarantine, rm")
+	})
+
+	t.Run("invalid output format", func(t *testing.T) {
+		path := writeTestConfig(t, "output:\n  format: tabel\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid output.format "tabel"`)
+		assert.Contains(t, err.Error(), "table, json, csv")
+	})
+
+	t.Run("several output formats", func(t *testing.T) {
+		path := writeTestConfig(t, "output:\n  format: json, csv\n")
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"json", "csv"}, cfg.OutputFormats())
+		assert.True(t, cfg.HasOutputFormat("csv"))
+		assert.False(t, cfg.HasOutputFormat("table"))
+	})
+
+	t.Run("two output formats on stdout", func(t *testing.T) {
+		path := writeTestConfig(t, "output:\n  format: table,csv,json\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "table and json both print to stdout")
+	})
+
+	t.Run("plain output", func(t *testing.T) {
+		path := writeTestConfig(t, "output:\n  format: plain0,csv\n")
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.True(t, cfg.MachineReadable())
+
+		path = writeTestConfig(t, "output:\n  format: table,plain,json\n")
+		_, err = LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "table and plain and json all print to stdout")
+	})
+
+	t.Run("invalid output units", func(t *testing.T) {
+		path := writeTestConfig(t, "output:\n  units: binary\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid output.units "binary"`)
+		assert.Contains(t, err.Error(), "iec, si, bytes")
+	})
+
+	t.Run("invalid output size unit", func(t *testing.T) {
+		path := writeTestConfig(t, "output:\n  sizeUnit: megabytes\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid output.sizeUnit: unknown size unit "megabytes"`)
+	})
+
+	t.Run("invalid exclude glob", func(t *testing.T) {
+		path := writeTestConfig(t, "excludeGlobs: [\"*.egg-info\", \"[tmp\", \"build/*\"]\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid excludeGlobs entry "[tmp"`)
+		assert.Contains(t, err.Error(), `invalid excludeGlobs entry "build/*"`)
+		assert.NotContains(t, err.Error(), `"*.egg-info"`)
+	})
+
+	t.Run("missing default file falls back to defaults", func(t *testing.T) {
+		cfg, _, err := LoadConfigWithDefaults(filepath.Join(t.TempDir(), "missing.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "quarantine", cfg.Delete.Mode)
+	})
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	scanDir := t.TempDir()
+	path := writeTestConfig(t, `minSizeMB: -5
+replaceDefaults: true
+includeNames: []
+scanPaths:
+  - `+scanDir+`
+delete:
+  mode: yolo
+  quarantineDir: `+scanDir+`
+`)
+	_, err := LoadConfig(path)
+	require.Error(t, err)
+
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Problems, 4)
+	assert.Contains(t, err.Error(), "4 problems found")
+	assert.Contains(t, err.Error(), `invalid minSize "-5MiB": it must not be negative`)
+	assert.Contains(t, err.Error(), "invalid includeNames")
+	assert.Contains(t, err.Error(), `invalid delete.mode "yolo"`)
+	assert.Contains(t, err.Error(), "invalid delete.quarantineDir")
+}
+
+func TestConfig_Warnings(t *testing.T) {
+	cfg := GetDefaults()
+	assert.Empty(t, cfg.Warnings())
+
+	cfg.ExcludeNames = append(cfg.ExcludeNames, "dist", "dist")
+	warnings := cfg.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], `"dist"`)
+}
+
+func TestKeyWarnings(t *testing.T) {
+	path := writeTestConfig(t, `maxdepth: 3
+minSizeMB: 1
+delete:
+  mode: rm
+  retention: 7
+plugins:
+  a: 1
+  b: 2
+`)
+	warnings, err := KeyWarnings(path)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		`key "minSizeMB" is deprecated; use "minSize" instead`,
+		`unknown key "maxdepth" (did you mean "maxDepth"?)`,
+		`unknown key "delete.retention"`,
+		`unknown key "plugins"`,
+	}, warnings)
+
+	// Unknown keys are only warnings, the file still loads
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "1MiB", cfg.MinSize)
+}
+
+func TestLoadConfig_MinSize(t *testing.T) {
+	t.Run("size string", func(t *testing.T) {
+		path := writeTestConfig(t, "minSize: 500MB\n")
+		cfg, provenance, err := LoadConfigWithProvenance(path)
+		require.NoError(t, err)
+		minSize, err := cfg.MinSizeBytes()
+		require.NoError(t, err)
+		assert.Equal(t, int64(500_000_000), minSize)
+		assert.Equal(t, path, provenance.Source("minSize"))
+	})
+
+	t.Run("deprecated minSizeMB is read as MiB", func(t *testing.T) {
+		path := writeTestConfig(t, "minSizeMB: 50\n")
+		cfg, provenance, err := LoadConfigWithProvenance(path)
+		require.NoError(t, err)
+		minSize, err := cfg.MinSizeBytes()
+		require.NoError(t, err)
+		assert.Equal(t, int64(50*1024*1024), minSize)
+		assert.Equal(t, path+" (minSizeMB)", provenance.Source("minSize"))
+	})
+
+	t.Run("minSize wins over minSizeMB", func(t *testing.T) {
+		cfg, err := LoadConfig(writeTestConfig(t, "minSizeMB: 50\nminSize: 2GiB\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "2GiB", cfg.MinSize)
+	})
+
+	t.Run("plain number is MiB", func(t *testing.T) {
+		cfg, err := LoadConfig(writeTestConfig(t, "minSize: 20\n"))
+		require.NoError(t, err)
+		minSize, err := cfg.MinSizeBytes()
+		require.NoError(t, err)
+		assert.Equal(t, int64(20*1024*1024), minSize)
+	})
+
+	t.Run("nonsense is rejected", func(t *testing.T) {
+		_, err := LoadConfig(writeTestConfig(t, "minSize: 10 bananas\n"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid minSize "10 bananas": unknown unit "bananas"`)
+	})
+}
+
+func TestLoadConfig_Version(t *testing.T) {
+	t.Run("versionless file is migrated to the current version", func(t *testing.T) {
+		path := writeTestConfig(t, "minSizeMB: 50\nmaxDepth: 3\n")
+		cfg, provenance, err := LoadConfigWithProvenance(path)
+		require.NoError(t, err)
+		assert.Equal(t, CurrentVersion, cfg.Version)
+		assert.Equal(t, "50MiB", cfg.MinSize)
+		assert.Equal(t, 3, cfg.MaxDepth)
+		assert.Equal(t, SourceDefault, provenance.Source("version"))
+
+		warnings, err := KeyWarnings(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{`key "minSizeMB" is deprecated; use "minSize" instead`}, warnings)
+	})
+
+	t.Run("current version still reads deprecated keys", func(t *testing.T) {
+		path := writeTestConfig(t, "version: 1\nminSizeMB: 50\n")
+		cfg, provenance, err := LoadConfigWithProvenance(path)
+		require.NoError(t, err)
+		assert.Equal(t, "50MiB", cfg.MinSize)
+		assert.Equal(t, path, provenance.Source("version"))
+		assert.Equal(t, path+" (minSizeMB)", provenance.Source("minSize"))
+
+		warnings, err := KeyWarnings(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{`key "minSizeMB" is deprecated; use "minSize" instead`}, warnings)
+	})
+
+	t.Run("newer version is rejected", func(t *testing.T) {
+		path := writeTestConfig(t, "version: 2\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported config version 2")
+
+		_, err = KeyWarnings(path)
+		require.Error(t, err)
+	})
+}
+
+func TestLoadConfig_Rules(t *testing.T) {
+	path := writeTestConfig(t, "rules:\n  vendor:\n    exceptSibling: [go.mod]\n")
+	cfg, provenance, err := LoadConfigWithProvenance(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Rule{"vendor": {ExceptSibling: []string{"go.mod"}}}, cfg.Rules)
+	assert.Equal(t, path, provenance.Source("rules"))
+
+	warnings, err := KeyWarnings(path)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	_, err = LoadConfig(writeTestConfig(t, "rules:\n  vendor:\n    exceptSibling: []\n"))
+	assert.ErrorContains(t, err, "invalid rules.vendor: exceptSibling is empty")
+}
+
+func TestLoadConfig_MergesNameLists(t *testing.T) {
+	defaults := GetDefaults()
+
+	t.Run("extra include keeps defaults", func(t *testing.T) {
+		path := writeTestConfig(t, "includeNames:\n  - .angular\n  - node_modules\n")
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+
+		assert.Subset(t, cfg.IncludeNames, defaults.IncludeNames)
+		assert.Contains(t, cfg.IncludeNames, ".angular")
+		assert.Len(t, cfg.IncludeNames, len(defaults.IncludeNames)+1, "duplicates should be dropped")
+		assert.Equal(t, defaults.ExcludeNames, cfg.ExcludeNames)
+	})
+
+	t.Run("replaceDefaults replaces the lists", func(t *testing.T) {
+		path := writeTestConfig(t, "replaceDefaults: true\nincludeNames:\n  - .angular\nexcludeNames:\n  - keep\n")
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{".angular"}, cfg.IncludeNames)
+		assert.Equal(t, []string{"keep"}, cfg.ExcludeNames)
+	})
+}
+
+func TestRenderYAML_RoundTrip(t *testing.T) {
+	defaults := GetDefaults()
+	data, err := RenderYAML(defaults)
+	require.NoError(t, err)
+
+	path := writeTestConfig(t, string(data))
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, defaults, cfg)
+}
+
+func TestLoadConfig_Provenance(t *testing.T) {
+	path := writeTestConfig(t, "maxDepth: 3\nincludeNames:\n  - .angular\ndelete:\n  mode: rm\n")
+	cfg, provenance, err := LoadConfigWithProvenance(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, cfg.MaxDepth)
+	assert.Equal(t, path, provenance.Source("maxDepth"))
+	assert.Equal(t, path, provenance.Source("delete.mode"))
+	assert.Equal(t, "default + "+path, provenance.Source("includeNames"))
+	assert.Equal(t, SourceDefault, provenance.Source("minSize"))
+	assert.Equal(t, SourceDefault, provenance.Source("delete.quarantineDir"))
+
+	// Every provenance key is a real config key
+	keys := cfg.Keys()
+	for key := range provenance {
+		assert.Contains(t, keys, key)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	fakeHome := t.TempDir()
+	t.Setenv("HOME", fakeHome)
+	t.Setenv("USERPROFILE", fakeHome)
+	env := map[string]string{"WORK": "/srv/work", "ProgramFiles(x86)": `C:\Program Files (x86)`}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		name string
+		path string
+		goos string
+		want string
+	}{
+		{"home", "~", "linux", fakeHome},
+		{"under home", "~/work", "linux", filepath.Join(fakeHome, "work")},
+		{"tilde inside", "/srv/~/work", "linux", "/srv/~/work"},
+		{"unknown user", "~no-such-user-bbb/work", "linux", "~no-such-user-bbb/work"},
+		{"variable", "$WORK/app", "linux", "/srv/work/app"},
+		{"braced variable", "${WORK}app", "linux", "/srv/workapp"},
+		{"unset variable kept", "$NOPE/app", "linux", "$NOPE/app"},
+		{"percent ignored", "%WORK%", "linux", "%WORK%"},
+		{"percent on windows", "%ProgramFiles(x86)%", "windows", `C:\Program Files (x86)`},
+		{"unset percent kept", "%NOPE%", "windows", "%NOPE%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expandPath(tt.path, tt.goos, lookupEnv))
+		})
+	}
+
+	t.Run("named user", func(t *testing.T) {
+		current, err := user.Current()
+		if err != nil || current.HomeDir == "" {
+			t.Skip("no current user")
+		}
+		assert.Equal(t, filepath.Join(current.HomeDir, "work"), ExpandHome("~"+current.Username+"/work"))
+	})
+}
+
+func TestConfig_Webhook(t *testing.T) {
+	lookupEnv := func(name string) (string, bool) {
+		if name == "TOKEN" {
+			return "s3cret", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		headers    []string
+		wantURL    string
+		wantHeader http.Header
+		wantErr    string
+	}{
+		{"disabled", "", []string{"X-Ignored: 1"}, "", nil, ""},
+		{"expanded", "https://dash.internal/hook?t=$TOKEN", []string{"Authorization: Bearer ${TOKEN}", "X-Fleet: ci"},
+			"https://dash.internal/hook?t=s3cret", http.Header{"Authorization": {"Bearer s3cret"}, "X-Fleet": {"ci"}}, ""},
+		{"not http", "ftp://dash.internal", nil, "", nil, "must be an http:// or https:// URL"},
+		{"no host", "https://", nil, "", nil, "must be an http:// or https:// URL"},
+		{"header without colon", "https://dash.internal", []string{"Bearer s3cret"}, "", nil, `"Name: value"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := GetDefaults()
+			cfg.Output.WebhookURL = tt.url
+			cfg.Output.WebhookHeaders = tt.headers
+			url, header, err := cfg.webhook("linux", lookupEnv)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.ErrorContains(t, cfg.Validate(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantURL, url)
+			assert.Equal(t, tt.wantHeader, header)
+		})
+	}
+
+	// An unset variable is kept as written, and pointed out
+	cfg := GetDefaults()
+	cfg.Output.WebhookURL = "https://dash.internal"
+	cfg.Output.WebhookHeaders = []string{"Authorization: Bearer $UNSET_BBB_TOKEN"}
+	assert.Contains(t, cfg.PathWarnings(), `output.webhookHeaders "Authorization: Bearer $UNSET_BBB_TOKEN" refers to the environment variable UNSET_BBB_TOKEN, which is not set`)
+}
+
+func TestLoadConfig_PatternFiles(t *testing.T) {
+	path := writeTestConfig(t, "includeFrom: [caches.txt]\nexcludeFrom: [keep.txt]\n")
+	dir := filepath.Dir(path)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "caches.txt"), []byte("# shared by the team\n.turbo\n  .docusaurus  \n\nnode_modules\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("fixtures\n*.egg-info\n"), 0644))
+
+	// Relative paths are resolved against the config file's directory
+	cfg, provenance, err := LoadConfigWithProvenance(path)
+	require.NoError(t, err)
+	defaults := GetDefaults()
+	assert.Equal(t, append(slices.Clone(defaults.IncludeNames), ".docusaurus"), cfg.IncludeNames)
+	assert.Equal(t, append(slices.Clone(defaults.ExcludeNames), "fixtures"), cfg.ExcludeNames)
+	assert.Equal(t, []string{"*.egg-info"}, cfg.ExcludeGlobs)
+	assert.Equal(t, []string{filepath.Join(dir, "caches.txt")}, cfg.IncludeFrom)
+	assert.Equal(t, "default + "+filepath.Join(dir, "caches.txt"), provenance.Source("includeNames"))
+
+	_, err = LoadConfig(writeTestConfig(t, "excludeFrom: [missing.txt]\n"))
+	assert.ErrorContains(t, err, "excludeFrom: could not read pattern file")
+}
+
+func TestLoadConfig_ExpandsPaths(t *testing.T) {
+	fakeHome := t.TempDir()
+	t.Setenv("HOME", fakeHome)
+	t.Setenv("USERPROFILE", fakeHome)
+	t.Setenv("BBB_TEST_WORK", filepath.Join(fakeHome, "work"))
+
+	path := writeTestConfig(t, `scanPaths:
+  - ~/code
+  - $BBB_TEST_WORK
+  - $BBB_TEST_UNSET/app
+excludePaths:
+  - ${BBB_TEST_WORK}/vendor
+delete:
+  quarantineDir: $HOME/.bbb-trash
+`)
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join(fakeHome, "code"), filepath.Join(fakeHome, "work"), "$BBB_TEST_UNSET/app"}, cfg.ScanPaths)
+	assert.Equal(t, []string{filepath.Join(fakeHome, "work") + "/vendor"}, cfg.ExcludePaths)
+	assert.Equal(t, fakeHome+"/.bbb-trash", cfg.Delete.QuarantineDir)
+	assert.Equal(t, []string{
+		`scanPaths "$BBB_TEST_UNSET/app" refers to the environment variable BBB_TEST_UNSET, which is not set`,
+	}, cfg.Warnings())
+}
+
+func TestSearchPaths(t *testing.T) {
+	home := filepath.FromSlash("/home/me")
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+	global := func(dir string) string {
+		return filepath.Join(filepath.FromSlash(dir), "BuildBloatBuster", "config.yaml")
+	}
+
+	t.Run("linux falls back to ~/.config", func(t *testing.T) {
+		assert.Equal(t, []string{LocalConfigName, global("/home/me/.config")},
+			searchPaths("linux", env(nil), home))
+	})
+
+	t.Run("XDG_CONFIG_HOME is honoured", func(t *testing.T) {
+		xdg, err := filepath.Abs(filepath.FromSlash("/xdg"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{LocalConfigName, filepath.Join(xdg, "BuildBloatBuster", "config.yaml")},
+			searchPaths("linux", env(map[string]string{"XDG_CONFIG_HOME": xdg}), home))
+	})
+
+	t.Run("relative XDG_CONFIG_HOME is ignored", func(t *testing.T) {
+		assert.Equal(t, []string{LocalConfigName, global("/home/me/.config")},
+			searchPaths("linux", env(map[string]string{"XDG_CONFIG_HOME": "relative"}), home))
+	})
+
+	t.Run("macOS adds Application Support", func(t *testing.T) {
+		assert.Equal(t, []string{
+			LocalConfigName,
+			global("/home/me/.config"),
+			global("/home/me/Library/Application Support"),
+		}, searchPaths("darwin", env(nil), home))
+	})
+
+	t.Run("windows adds APPDATA", func(t *testing.T) {
+		appData := filepath.FromSlash("/appdata")
+		assert.Equal(t, []string{
+			LocalConfigName,
+			global("/home/me/.config"),
+			global("/appdata"),
+		}, searchPaths("windows", env(map[string]string{"APPDATA": appData}), home))
+	})
+}
+
+func TestFindConfigFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// Keep the real user config directories out of the search
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("APPDATA", home)
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	assert.Empty(t, FindConfigFile())
+
+	globalPath := filepath.Join(xdg, "BuildBloatBuster", "config.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(globalPath), 0755))
+	require.NoError(t, os.WriteFile(globalPath, []byte("minSizeMB: 1\n"), 0644))
+	assert.Equal(t, globalPath, FindConfigFile())
+
+	// The file in the current directory takes precedence
+	require.NoError(t, os.WriteFile(LocalConfigName, []byte("minSizeMB: 2\n"), 0644))
+	assert.Equal(t, LocalConfigName, FindConfigFile())
+}
+
+func TestResolveProfiles(t *testing.T) {
+	t.Run("union of includes and conservative rules", func(t *testing.T) {
+		profile, err := ResolveProfiles([]string{"rust", "jvm", "python"})
+		require.NoError(t, err)
+
+		assert.Contains(t, profile.IncludeNames, "target")
+		assert.Contains(t, profile.IncludeNames, ".gradle")
+		assert.Contains(t, profile.IncludeNames, "__pycache__")
+		// target is restricted by both rust and jvm, so either's marker is accepted
+		assert.Equal(t, []string{"Cargo.toml", "build.sbt", "pom.xml"}, profile.Rules["target"])
+		assert.NotContains(t, profile.Rules, "__pycache__")
+	})
+
+	t.Run("a restricted name stays restricted", func(t *testing.T) {
+		profile, err := ResolveProfiles([]string{"ios", "node"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"package.json"}, profile.Rules["build"])
+	})
+
+	t.Run("all covers every profile", func(t *testing.T) {
+		all, err := ResolveProfiles([]string{ProfileAll})
+		require.NoError(t, err)
+		for _, p := range builtinProfiles {
+			assert.Subset(t, all.IncludeNames, p.IncludeNames, p.Name)
+		}
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := ResolveProfiles([]string{"cobol"})
+		assert.Error(t, err)
+
+		path := writeTestConfig(t, "profiles: [node, cobol]\n")
+		_, err = LoadConfig(path)
+		assert.ErrorContains(t, err, `invalid profiles entry "cobol"`)
+	})
+
+	t.Run("profiles make an empty include list valid", func(t *testing.T) {
+		path := writeTestConfig(t, "replaceDefaults: true\nincludeNames: []\nprofiles: [rust]\n")
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"rust"}, cfg.Profiles)
+	})
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var (
+	// envRef matches $VAR and ${VAR}
+	envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+	// windowsEnvRef matches %VAR%, including names such as ProgramFiles(x86)
+	windowsEnvRef = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%`)
+)
+
+// ExpandPath expands a path from the config file the way a shell would: a
+// leading ~ or ~user becomes that user's home directory, and $VAR and ${VAR}
+// (and %VAR% on Windows) become the environment variable's value. A variable
+// that isn't set is kept as written, so PathWarnings can point it out rather
+// than the path silently changing.
+func ExpandPath(path string) string {
+	return expandPath(path, runtime.GOOS, os.LookupEnv)
+}
+
+// expandPath is ExpandPath for the platform goos and the environment lookupEnv
+func expandPath(path, goos string, lookupEnv func(string) (string, bool)) string {
+	return ExpandHome(expandEnv(path, goos, lookupEnv))
+}
+
+// expandEnv replaces the references to environment variables that are set
+// in s with their values
+func expandEnv(s, goos string, lookupEnv func(string) (string, bool)) string {
+	for _, re := range envRefs(goos) {
+		s = re.ReplaceAllStringFunc(s, func(ref string) string {
+			if value, ok := lookupEnv(envRefName(re, ref)); ok {
+				return value
+			}
+			return ref
+		})
+	}
+	return s
+}
+
+// envRefs returns the patterns of environment variable references on goos
+func envRefs(goos string) []*regexp.Regexp {
+	if goos == "windows" {
+		return []*regexp.Regexp{envRef, windowsEnvRef}
+	}
+	return []*regexp.Regexp{envRef}
+}
+
+// envRefName returns the variable name in ref, a match of re
+func envRefName(re *regexp.Regexp, ref string) string {
+	for _, name := range re.FindStringSubmatch(ref)[1:] {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// undefinedEnvRefs returns the environment variables path refers to that
+// aren't set
+func undefinedEnvRefs(path, goos string, lookupEnv func(string) (string, bool)) []string {
+	var names []string
+	for _, re := range envRefs(goos) {
+		for _, ref := range re.FindAllString(path, -1) {
+			name := envRefName(re, ref)
+			if _, ok := lookupEnv(name); !ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// ExpandHome replaces a leading ~ or ~user in path with the home directory
+// of the current or the named user. Shells expand it on the command line,
+// but not in a config file. A user that doesn't exist is left as written.
+func ExpandHome(path string) string {
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+	name, rest := path[1:], ""
+	if i := strings.IndexAny(name, `/`+string(filepath.Separator)); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+
+	var homeDir string
+	if name == "" {
+		homeDir, _ = os.UserHomeDir()
+	} else if u, err := user.Lookup(name); err == nil {
+		homeDir = u.HomeDir
+	}
+	if homeDir == "" {
+		return path
+	}
+	return filepath.Join(homeDir, rest)
+}
+
+// expandPaths expands the path settings with expandPath
+func (c *Config) expandPaths(goos string, lookupEnv func(string) (string, bool)) {
+	for i, path := range c.ScanPaths {
+		c.ScanPaths[i] = expandPath(path, goos, lookupEnv)
+	}
+	for i, path := range c.ExcludePaths {
+		c.ExcludePaths[i] = expandPath(path, goos, lookupEnv)
+	}
+	c.Delete.QuarantineDir = expandPath(c.Delete.QuarantineDir, goos, lookupEnv)
+}
+
+// PathWarnings returns a warning for every path or webhook setting that
+// refers to an environment variable that isn't set
+func (c Config) PathWarnings() []string {
+	return c.pathWarnings(runtime.GOOS, os.LookupEnv)
+}
+
+// pathWarnings is PathWarnings for the platform goos and the environment
+// lookupEnv
+func (c Config) pathWarnings(goos string, lookupEnv func(string) (string, bool)) []string {
+	var warnings []string
+	check := func(key, path string) {
+		for _, name := range undefinedEnvRefs(path, goos, lookupEnv) {
+			warnings = append(warnings, fmt.Sprintf("%s %q refers to the environment variable %s, which is not set", key, path, name))
+		}
+	}
+	for _, path := range c.ScanPaths {
+		check("scanPaths", path)
+	}
+	for _, path := range c.ExcludePaths {
+		check("excludePaths", path)
+	}
+	check("delete.quarantineDir", c.Delete.QuarantineDir)
+	check("output.webhookURL", c.Output.WebhookURL)
+	for _, header := range c.Output.WebhookHeaders {
+		check("output.webhookHeaders", header)
+	}
+	return warnings
+}
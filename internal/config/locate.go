@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+)
+
+// LocalConfigName is the name of the config file looked up in the current directory.
+const LocalConfigName = ".BuildBloatBuster.yaml"
+
+// globalConfigName is the name of the config file in the user-wide config directories
+const globalConfigName = "config.yaml"
+
+// GlobalConfigPath returns the platform's location for the user-wide config
+// file: $XDG_CONFIG_HOME (or ~/.config) on Linux, ~/Library/Application Support
+// on macOS and %APPDATA% on Windows.
+func GlobalConfigPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "BuildBloatBuster", globalConfigName), nil
+}
+
+// SearchPaths returns the config file locations in the order they are tried:
+// the current directory, the XDG config directory and then the platform's
+// native config directory.
+func SearchPaths() []string {
+	homeDir, _ := os.UserHomeDir()
+	return searchPaths(runtime.GOOS, os.Getenv, homeDir)
+}
+
+func searchPaths(goos string, getenv func(string) string, homeDir string) []string {
+	paths := []string{LocalConfigName}
+	add := func(dir string) {
+		path := filepath.Join(dir, "BuildBloatBuster", globalConfigName)
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	// XDG_CONFIG_HOME must be absolute to be honoured, as in the spec
+	if xdg := getenv("XDG_CONFIG_HOME"); xdg != "" && filepath.IsAbs(xdg) {
+		add(xdg)
+	} else if homeDir != "" {
+		add(filepath.Join(homeDir, ".config"))
+	}
+
+	switch goos {
+	case "darwin":
+		if homeDir != "" {
+			add(filepath.Join(homeDir, "Library", "Application Support"))
+		}
+	case "windows":
+		if appData := getenv("APPDATA"); appData != "" {
+			add(appData)
+		}
+	}
+	return paths
+}
+
+// FindConfigFile returns the first existing config file from SearchPaths,
+// or "" if there is none.
+func FindConfigFile() string {
+	for _, path := range SearchPaths() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/knadh/koanf/v2"
+)
+
+// CurrentVersion is the config file format this build reads and writes.
+// Files without a version key are version 0.
+const CurrentVersion = 1
+
+// migrations upgrade a config file one version at a time: migrations[n]
+// turns version n into version n+1.
+var migrations = []func(*upgrade){
+	migrateV0,
+}
+
+// aliases rename the deprecated keys that are still read, whatever version a
+// file declares. Files written for the current version may still use them.
+var aliases = []func(*upgrade){
+	renameMinSizeMB,
+}
+
+// upgrade holds the raw keys of a config file while migrations rewrite them
+type upgrade struct {
+	k        *koanf.Koanf
+	warnings []string
+	// renamed maps keys set by a migration to the old key they were read from
+	renamed map[string]string
+}
+
+// rename moves the value of oldKey to newKey, converted by convert, and warns
+// that oldKey is deprecated. A file that already sets newKey keeps its value.
+func (u *upgrade) rename(oldKey, newKey string, convert func(*koanf.Koanf, string) any) {
+	if !u.k.Exists(oldKey) {
+		return
+	}
+	u.warnings = append(u.warnings, fmt.Sprintf("key %q is deprecated; use %q instead", oldKey, newKey))
+	if !u.k.Exists(newKey) {
+		_ = u.k.Set(newKey, convert(u.k, oldKey))
+		u.renamed[newKey] = oldKey
+	}
+	u.k.Delete(oldKey)
+}
+
+// migrateV0 upgrades a file without a version to version 1, which only added
+// the version key; the minSizeMB key it deprecated is one of the aliases
+func migrateV0(u *upgrade) {}
+
+// renameMinSizeMB reads the minSizeMB key, which minSize replaced
+func renameMinSizeMB(u *upgrade) {
+	u.rename("minSizeMB", "minSize", func(k *koanf.Koanf, key string) any {
+		return fmt.Sprintf("%dMiB", k.Int64(key))
+	})
+}
+
+// migrate upgrades the config file loaded into k to CurrentVersion in place.
+// It returns a warning for every outdated setting it rewrote, and which keys
+// were renamed, mapping the new key to the old one.
+func migrate(k *koanf.Koanf) (warnings []string, renamed map[string]string, err error) {
+	version := k.Int("version")
+	if version < 0 || version > CurrentVersion {
+		return nil, nil, fmt.Errorf("unsupported config version %d: this build of BuildBloatBuster reads versions up to %d; upgrade it or lower the version",
+			version, CurrentVersion)
+	}
+
+	u := &upgrade{k: k, renamed: make(map[string]string)}
+	for _, step := range migrations[version:] {
+		step(u)
+	}
+	for _, alias := range aliases {
+		alias(u)
+	}
+	return u.warnings, u.renamed, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadPatternFile reads the patterns in the file at path, one per line.
+// Blank lines and lines starting with # are ignored, and surrounding spaces
+// are trimmed.
+func ReadPatternFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read pattern file: %w", err)
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read pattern file %s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// AddIncludeFrom adds the patterns of the file at path to the include names
+func (c *Config) AddIncludeFrom(path string) error {
+	patterns, err := ReadPatternFile(path)
+	if err != nil {
+		return err
+	}
+	c.IncludeNames = mergeNames(c.IncludeNames, patterns)
+	return nil
+}
+
+// AddExcludeFrom adds the patterns of the file at path to the exclusions:
+// those with a wildcard (*, ? or [) to the exclude globs, the others to the
+// exclude names
+func (c *Config) AddExcludeFrom(path string) error {
+	patterns, err := ReadPatternFile(path)
+	if err != nil {
+		return err
+	}
+	var names, globs []string
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "*?[") {
+			globs = append(globs, pattern)
+		} else {
+			names = append(names, pattern)
+		}
+	}
+	c.ExcludeNames = mergeNames(c.ExcludeNames, names)
+	c.ExcludeGlobs = mergeNames(c.ExcludeGlobs, globs)
+	return nil
+}
+
+// readPatternFiles adds the patterns of the includeFrom and excludeFrom files
+// of a config file in dir, resolving relative paths against dir
+func (c *Config) readPatternFiles(dir string, provenance Provenance) error {
+	resolve := func(path string) string {
+		if path = ExpandPath(path); !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		return path
+	}
+	for i, path := range c.IncludeFrom {
+		c.IncludeFrom[i] = resolve(path)
+		if err := c.AddIncludeFrom(c.IncludeFrom[i]); err != nil {
+			return fmt.Errorf("includeFrom: %w", err)
+		}
+		provenance.Set("includeNames", provenance.Source("includeNames")+" + "+c.IncludeFrom[i])
+	}
+	for i, path := range c.ExcludeFrom {
+		c.ExcludeFrom[i] = resolve(path)
+		if err := c.AddExcludeFrom(c.ExcludeFrom[i]); err != nil {
+			return fmt.Errorf("excludeFrom: %w", err)
+		}
+		provenance.Set("excludeNames", provenance.Source("excludeNames")+" + "+c.ExcludeFrom[i])
+	}
+	return nil
+}
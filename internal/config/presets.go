@@ -0,0 +1,82 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+)
+
+// Preset is a curated set of well-known directories outside any project,
+// such as the caches of IDEs and mobile toolchains. Their parents hold no
+// project files, so no include name could select them; a scan with the
+// preset enabled reports them next to what it finds under the scan paths.
+type Preset struct {
+	Name        string
+	Description string
+	// dirs returns the preset's directories for a platform and home directory
+	dirs func(goos, homeDir string) []PresetDir
+}
+
+// PresetDir is a directory a preset reports, with the label its reason names
+type PresetDir struct {
+	Label string
+	Path  string
+}
+
+// PresetDocker reports Docker's build cache, which is kept in Docker's own
+// storage, so the preset has no directories; the docker CLI measures it
+const PresetDocker = "docker"
+
+// builtinPresets are the presets selectable with --presets or presets:
+var builtinPresets = []Preset{
+	{
+		Name:        "mobile",
+		Description: "Xcode DerivedData and the Gradle and Android build caches",
+		dirs: func(goos, homeDir string) []PresetDir {
+			dirs := []PresetDir{
+				{"gradle caches", filepath.Join(homeDir, ".gradle", "caches")},
+				{"android build cache", filepath.Join(homeDir, ".android", "build-cache")},
+			}
+			if goos == "darwin" {
+				dirs = append(dirs, PresetDir{"xcode DerivedData", filepath.Join(homeDir, "Library", "Developer", "Xcode", "DerivedData")})
+			}
+			return dirs
+		},
+	},
+	{
+		Name:        PresetDocker,
+		Description: "Docker's reclaimable build cache, measured with docker system df",
+		dirs:        func(goos, homeDir string) []PresetDir { return nil },
+	},
+}
+
+// Presets returns the built-in presets
+func Presets() []Preset {
+	return slices.Clone(builtinPresets)
+}
+
+// PresetNames returns the names of the built-in presets
+func PresetNames() []string {
+	names := make([]string, 0, len(builtinPresets))
+	for _, p := range builtinPresets {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Dirs returns the directories the preset reports on the platform goos for
+// the user with homeDir, whether they exist or not
+func (p Preset) Dirs(goos, homeDir string) []PresetDir {
+	if homeDir == "" {
+		return nil
+	}
+	return p.dirs(goos, homeDir)
+}
+
+// LookupPreset returns the built-in preset called name
+func LookupPreset(name string) (Preset, bool) {
+	i := slices.IndexFunc(builtinPresets, func(p Preset) bool { return p.Name == name })
+	if i < 0 {
+		return Preset{}, false
+	}
+	return builtinPresets[i], true
+}
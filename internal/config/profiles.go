@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Profile is a curated set of build output directory names for an ecosystem.
+// Rules restrict some of those names to directories that sit next to one of
+// the listed marker files, e.g. target only next to Cargo.toml, so generic
+// names like build or dist don't match unrelated folders.
+type Profile struct {
+	Name         string
+	Description  string
+	IncludeNames []string
+	// Rules maps a directory name to the marker files, any one of which must
+	// exist in the same parent directory for it to be selected
+	Rules map[string][]string
+}
+
+// ProfileAll selects every built-in profile
+const ProfileAll = "all"
+
+var jsMarkers = []string{"package.json"}
+var pythonMarkers = []string{"pyproject.toml", "setup.py", "setup.cfg"}
+var gradleMarkers = []string{"build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts"}
+
+// builtinProfiles are the profiles selectable with --profile or profiles:
+var builtinProfiles = []Profile{
+	{
+		Name:         "node",
+		Description:  "Node.js packages and framework caches",
+		IncludeNames: []string{"node_modules", ".next", ".nuxt", ".svelte-kit", ".turbo", ".parcel-cache", "dist", "build", "out"},
+		Rules: map[string][]string{
+			"dist":  jsMarkers,
+			"build": jsMarkers,
+			"out":   jsMarkers,
+		},
+	},
+	{
+		Name:         "python",
+		Description:  "Python virtualenvs, bytecode and tool caches",
+		IncludeNames: []string{".venv", "venv", "__pycache__", ".pytest_cache", ".mypy_cache", ".ruff_cache", ".tox", ".nox", ".hypothesis", "htmlcov", "build", "dist"},
+		Rules: map[string][]string{
+			"build": pythonMarkers,
+			"dist":  pythonMarkers,
+		},
+	},
+	{
+		Name:         "rust",
+		Description:  "Cargo build output",
+		IncludeNames: []string{"target"},
+		Rules: map[string][]string{
+			"target": {"Cargo.toml"},
+		},
+	},
+	{
+		Name:         "jvm",
+		Description:  "Maven, Gradle and sbt build output",
+		IncludeNames: []string{"target", "build", ".gradle"},
+		Rules: map[string][]string{
+			"target":  {"pom.xml", "build.sbt"},
+			"build":   gradleMarkers,
+			".gradle": gradleMarkers,
+		},
+	},
+	{
+		Name:         "ios",
+		Description:  "CocoaPods, Carthage and Swift Package Manager output",
+		IncludeNames: []string{"Pods", "Carthage", ".build", "DerivedData"},
+		Rules: map[string][]string{
+			"Pods":     {"Podfile"},
+			"Carthage": {"Cartfile"},
+			".build":   {"Package.swift"},
+		},
+	},
+	{
+		Name:         "frontend",
+		Description:  "Frontend bundler, framework and test output",
+		IncludeNames: []string{"node_modules", ".next", ".nuxt", ".svelte-kit", ".angular", ".turbo", ".parcel-cache", ".vite", "storybook-static", "coverage", "dist", "build", "out"},
+		Rules: map[string][]string{
+			"storybook-static": jsMarkers,
+			"coverage":         jsMarkers,
+			"dist":             jsMarkers,
+			"build":            jsMarkers,
+			"out":              jsMarkers,
+		},
+	},
+}
+
+// Profiles returns the built-in profiles, including the combined "all" profile
+func Profiles() []Profile {
+	profiles := slices.Clone(builtinProfiles)
+	all, _ := ResolveProfiles([]string{ProfileAll})
+	all.Name = ProfileAll
+	all.Description = "Every profile above"
+	return append(profiles, all)
+}
+
+// ProfileNames returns the names of the built-in profiles, including "all"
+func ProfileNames() []string {
+	return append(builtinProfileNames(), ProfileAll)
+}
+
+func builtinProfileNames() []string {
+	names := make([]string, 0, len(builtinProfiles))
+	for _, p := range builtinProfiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// ResolveProfiles combines the named profiles into one. Include names are the
+// union of all profiles. Rules are combined conservatively: a name that any
+// selected profile restricts stays restricted, even if another profile lists
+// it unconditionally, and the marker files of every restricting profile are
+// accepted.
+func ResolveProfiles(names []string) (Profile, error) {
+	combined := Profile{Name: strings.Join(names, ","), Rules: map[string][]string{}}
+	if slices.Contains(names, ProfileAll) {
+		names = builtinProfileNames()
+	}
+	for _, name := range names {
+		i := slices.IndexFunc(builtinProfiles, func(p Profile) bool { return p.Name == name })
+		if i < 0 {
+			return Profile{}, fmt.Errorf("unknown profile %q", name)
+		}
+		profile := builtinProfiles[i]
+
+		combined.IncludeNames = mergeNames(combined.IncludeNames, profile.IncludeNames)
+		for dirName, markers := range profile.Rules {
+			combined.Rules[dirName] = mergeNames(combined.Rules[dirName], markers)
+		}
+	}
+	for _, markers := range combined.Rules {
+		sort.Strings(markers)
+	}
+	return combined, nil
+}
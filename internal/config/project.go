@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+)
+
+// ProjectOverrides are the filters a project config file adds for the scan
+// roots at or below its directory. They are merged over the effective config,
+// never replacing it.
+type ProjectOverrides struct {
+	// Path is the project config file the overrides were loaded from
+	Path         string
+	IncludeNames []string
+	ExcludeNames []string
+	ExcludePaths []string
+}
+
+// FindProjectConfig returns the nearest LocalConfigName in dir or one of its
+// parent directories, or "" if there is none.
+func FindProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, LocalConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectOverrides reads includeNames, excludeNames and excludePaths from
+// the project config file at path; other settings in it are ignored. Relative
+// excludePaths are resolved against the file's directory.
+func LoadProjectOverrides(path string) (ProjectOverrides, error) {
+	overrides := ProjectOverrides{Path: path}
+
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
+		return overrides, err
+	}
+
+	overrides.IncludeNames = k.Strings("includeNames")
+	overrides.ExcludeNames = k.Strings("excludeNames")
+	dir := filepath.Dir(path)
+	for _, excludePath := range k.Strings("excludePaths") {
+		if !filepath.IsAbs(excludePath) {
+			excludePath = filepath.Join(dir, excludePath)
+		}
+		overrides.ExcludePaths = append(overrides.ExcludePaths, excludePath)
+	}
+	return overrides, nil
+}
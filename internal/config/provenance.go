@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/knadh/koanf/maps"
+)
+
+// SourceDefault is the source of settings that were never overridden
+const SourceDefault = "default"
+
+// Provenance records where each effective setting came from, keyed by its
+// dotted config key (e.g. "delete.mode"). Values are a config file path or a
+// flag such as "flag --min-size"; keys that are absent are defaults.
+type Provenance map[string]string
+
+// Source returns where the setting for key came from
+func (p Provenance) Source(key string) string {
+	if source, ok := p[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// Set records source as the origin of key
+func (p Provenance) Set(key, source string) {
+	p[key] = source
+}
+
+// Flatten returns the config as a map of dotted koanf keys to values
+func (c Config) Flatten() map[string]any {
+	flat := make(map[string]any)
+	flattenStruct(reflect.ValueOf(c), "", flat)
+	return flat
+}
+
+// Map returns the config as a nested map keyed like the config file
+func (c Config) Map() map[string]any {
+	return maps.Unflatten(c.Flatten(), ".")
+}
+
+// Keys returns the dotted keys of all settings, sorted
+func (c Config) Keys() []string {
+	flat := c.Flatten()
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flattenStruct walks the koanf-tagged fields of v, descending into nested structs
+func flattenStruct(v reflect.Value, prefix string, out map[string]any) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("koanf")
+		if tag == "" || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			flattenStruct(v.Field(i), key, out)
+			continue
+		}
+		out[key] = v.Field(i).Interface()
+	}
+}
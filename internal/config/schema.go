@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// SchemaURI is the JSON Schema dialect Schema describes the config file in
+const SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaDescriptions documents every setting in the schema, keyed by dotted
+// key. Settings inside each rule are keyed as rules.*.<key>. A test checks
+// that every setting has a description.
+var schemaDescriptions = map[string]string{
+	"version":                      "Config file format; older files are upgraded when loaded.",
+	"scanPaths":                    "Paths to scan when none are given on the command line.",
+	"includeNames":                 "Directory names that mark a folder as deletable build output.",
+	"excludeNames":                 "Directory names that are never selected or descended into.",
+	"excludeGlobs":                 "Patterns such as \"*.egg-info\"; directories whose name matches one are never selected or descended into.",
+	"includeFrom":                  "Files of directory names, one per line, added to includeNames. Relative paths are resolved against the config file's directory.",
+	"excludeFrom":                  "Files of directory names or patterns, one per line, added to excludeNames or excludeGlobs. Relative paths are resolved against the config file's directory.",
+	"profiles":                     "Built-in ecosystem profiles to enable; they add include names and only select generic names such as build next to a matching project file.",
+	"presets":                      "Built-in sets of well-known directories outside any project, such as Xcode DerivedData, that scans report too.",
+	"rules":                        "Exceptions for individual include names, keyed by directory name.",
+	"rules.*.exceptSibling":        "Skip the directory when any of these files sits next to it.",
+	"replaceDefaults":              "Make includeNames/excludeNames replace the built-in lists instead of being added to them.",
+	"excludePaths":                 "Absolute paths that are never scanned.",
+	"projectMarkers":               "File names or patterns such as \"*.csproj\" that mark a project root, in addition to the built-in ones such as package.json.",
+	"minSize":                      "Only report directories at least this large, e.g. \"500MB\" or \"2GiB\"; a plain number is MiB and 0 reports every directory.",
+	"minSizeMB":                    "Deprecated: use minSize.",
+	"maxDepth":                     "How many levels below a scan path the scanner descends.",
+	"maxResults":                   "Stop scanning once this many directories were found (0 = unlimited).",
+	"followSymlinks":               "Follow symbolic links and junctions; each target is counted once.",
+	"includeNetworkFS":             "Descend into network filesystems (NFS, SMB, ...) mounted below a scan path.",
+	"skipIfModifiedWithin":         "Skip directories with a file modified within this duration, e.g. \"10m\", as a build may still be writing to them (\"0\" = never skip).",
+	"readRetries":                  "How often a directory read failing with an I/O error is retried, with backoff, before the directory is skipped.",
+	"concurrency":                  "Number of size calculation workers (0 = tuned to the storage type).",
+	"delete":                       "How directories are removed.",
+	"delete.mode":                  "\"quarantine\" moves directories to quarantineDir, \"trash\" to the desktop trash and \"rm\" deletes them permanently.",
+	"delete.quarantineDir":         "Where quarantined directories are moved to.",
+	"delete.retentionDays":         "Days to keep quarantined items before they are eligible for purging.",
+	"delete.maxPerRunGB":           "Largest total size in GB a clean or watch --auto-clean cycle may delete; a larger selection needs a typed confirmation and is refused with --yes, JSON output or watch (0 = no cap).",
+	"delete.compress":              "Store quarantined directories as .tar.gz archives.",
+	"delete.checksum":              "Record a SHA-256 of every quarantined item for verify --checksum.",
+	"delete.auditLog":              "JSONL file every quarantine, rm, restore and purge is appended to (empty = disabled).",
+	"output":                       "How results are shown.",
+	"output.format":                "One or more of table, json, csv, tsv, plain and plain0, separated by commas; only one of them may print to stdout.",
+	"output.sortBy":                "Order of the results.",
+	"output.units":                 "\"iec\" shows sizes in powers of 1024, \"si\" in powers of 1000 and \"bytes\" as exact byte counts.",
+	"output.sizeUnit":              "Show the size columns of table and CSV output as plain numbers in this unit, e.g. MB (empty = humanized).",
+	"output.webhookURL":            "POST the JSON summary of every scan and clean, with the hostname and a timestamp, to this URL (empty = disabled).",
+	"output.webhookHeaders":        "Headers sent to webhookURL as \"Name: value\"; $VAR and ${VAR} are expanded when sending.",
+	"output.webhookTimeoutSeconds": "Time limit for each attempt to deliver to webhookURL, in seconds (0 = no limit).",
+	"output.webhookInsecure":       "Don't verify the TLS certificate of webhookURL, for self-signed certificates on internal networks.",
+	"size":                         "Limits on size calculation.",
+	"size.timeoutSeconds":          "Time limit for scanning and sizing, in seconds (0 = no limit).",
+	"size.candidateTimeoutSeconds": "Time limit per directory, in seconds; slower directories keep a partial size (0 = no limit).",
+	"behavior":                     "Per-machine defaults of the flags that guard deletion; the flags always win.",
+	"behavior.dryRunByDefault":     "Only show what would be deleted unless --apply is given; when false, delete unless --dry-run is given.",
+	"behavior.requireConfirmation": "Ask before deleting in clean and verify --fix unless --yes is given; when false, proceed unless --yes=false is given.",
+}
+
+// Schema returns a JSON Schema for the config file, generated from the Config
+// struct: every key with its type, description and default, and the allowed
+// values of settings that take one of a fixed set.
+func Schema() map[string]any {
+	schema := structSchema(reflect.ValueOf(GetDefaults()), "")
+	schema["$schema"] = SchemaURI
+	schema["title"] = "BuildBloatBuster configuration"
+
+	// The deprecated spelling of minSize is still read
+	properties := schema["properties"].(map[string]any)
+	properties["minSizeMB"] = map[string]any{
+		"type":        "integer",
+		"minimum":     0,
+		"deprecated":  true,
+		"description": schemaDescriptions["minSizeMB"],
+	}
+	return schema
+}
+
+// structSchema describes the koanf-tagged fields of v as an object schema.
+// Unknown keys are rejected so editors flag typos such as "maxdepth".
+func structSchema(v reflect.Value, prefix string) map[string]any {
+	properties := make(map[string]any)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("koanf")
+		if tag == "" || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+		properties[tag] = fieldSchema(v.Field(i), key)
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// fieldSchema describes the setting key holding v, with v as its default
+func fieldSchema(v reflect.Value, key string) map[string]any {
+	var schema map[string]any
+	switch v.Kind() {
+	case reflect.Struct:
+		schema = structSchema(v, key)
+	case reflect.Map:
+		// Map values such as rules have no default to take the fields from
+		schema = map[string]any{
+			"type":                 "object",
+			"additionalProperties": fieldSchema(reflect.New(v.Type().Elem()).Elem(), key+".*"),
+		}
+	case reflect.Slice:
+		schema = map[string]any{
+			"type":  "array",
+			"items": fieldSchema(reflect.New(v.Type().Elem()).Elem(), key+".*"),
+		}
+	case reflect.String:
+		schema = map[string]any{"type": "string"}
+	case reflect.Bool:
+		schema = map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		// None of the numeric settings may be negative
+		schema = map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float64:
+		schema = map[string]any{"type": "number", "minimum": 0}
+	default:
+		panic(fmt.Sprintf("config schema: unsupported type %s for %s", v.Type(), key))
+	}
+
+	if description, ok := schemaDescriptions[key]; ok {
+		schema["description"] = description
+	}
+	if hasDefault(v, key) {
+		schema["default"] = v.Interface()
+	}
+
+	switch key {
+	case "version":
+		schema["maximum"] = CurrentVersion
+	case "profiles":
+		schema["items"] = map[string]any{"enum": ProfileNames()}
+	case "minSize":
+		schema["type"] = []string{"string", "integer"}
+	case "delete.mode":
+		schema["enum"] = ValidDeleteModes
+	case "output.format":
+		// A single format is offered for completion; lists still validate
+		schema["anyOf"] = []map[string]any{
+			{"enum": ValidOutputFormats},
+			{"pattern": outputFormatPattern()},
+		}
+	case "output.sortBy":
+		schema["enum"] = ValidSortOrders
+	case "output.units":
+		schema["enum"] = ValidUnits
+	}
+	return schema
+}
+
+// hasDefault reports whether v is a default worth showing for key: settings
+// inside lists and maps have none, and an unset list is not an empty one
+func hasDefault(v reflect.Value, key string) bool {
+	switch {
+	case strings.Contains(key, "*"), v.Kind() == reflect.Struct:
+		return false
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Map:
+		return !v.IsNil()
+	}
+	return true
+}
+
+// outputFormatPattern matches a comma-separated list of ValidOutputFormats
+func outputFormatPattern() string {
+	quoted := make([]string, len(ValidOutputFormats))
+	for i, format := range ValidOutputFormats {
+		quoted[i] = regexp.QuoteMeta(format)
+	}
+	format := "(" + strings.Join(quoted, "|") + ")"
+	return fmt.Sprintf(`^\s*%s\s*(,\s*%s\s*)*$`, format, format)
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// asJSON round-trips v through encoding/json, so schemas and configs are
+// compared as the generic values an editor would see
+func asJSON(t *testing.T, v any) any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	var out any
+	require.NoError(t, json.Unmarshal(data, &out))
+	return out
+}
+
+// parseYAMLConfig parses a config file into generic values
+func parseYAMLConfig(t *testing.T, content string) any {
+	t.Helper()
+	raw, err := yaml.Parser().Unmarshal([]byte(content))
+	require.NoError(t, err)
+	return asJSON(t, raw)
+}
+
+// schemaErrors validates value against the subset of JSON Schema that Schema
+// generates and returns a message for every violation
+func schemaErrors(schema, value any, path string) []string {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		if schema == false {
+			return []string{path + ": not allowed"}
+		}
+		return nil
+	}
+
+	var errs []string
+	if typ, ok := s["type"]; ok {
+		var types []any
+		if list, ok := typ.([]any); ok {
+			types = list
+		} else {
+			types = []any{typ}
+		}
+		if !slices.ContainsFunc(types, func(t any) bool { return hasJSONType(value, t.(string)) }) {
+			return []string{fmt.Sprintf("%s: %v is not of type %v", path, value, typ)}
+		}
+	}
+	if enum, ok := s["enum"].([]any); ok && !slices.Contains(enum, value) {
+		errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		if str, ok := value.(string); ok && !regexp.MustCompile(pattern).MatchString(str) {
+			errs = append(errs, fmt.Sprintf("%s: %q does not match %s", path, str, pattern))
+		}
+	}
+	if minimum, ok := s["minimum"].(float64); ok {
+		if n, ok := value.(float64); ok && n < minimum {
+			errs = append(errs, fmt.Sprintf("%s: %v is less than %v", path, n, minimum))
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		if !slices.ContainsFunc(anyOf, func(sub any) bool { return len(schemaErrors(sub, value, path)) == 0 }) {
+			errs = append(errs, fmt.Sprintf("%s: %v matches none of the allowed forms", path, value))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := s["properties"].(map[string]any)
+		for key, item := range v {
+			sub, known := properties[key]
+			if !known {
+				sub = s["additionalProperties"]
+			}
+			errs = append(errs, schemaErrors(sub, item, path+"."+key)...)
+		}
+	case []any:
+		for i, item := range v {
+			errs = append(errs, schemaErrors(s["items"], item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return errs
+}
+
+func hasJSONType(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	}
+	return false
+}
+
+func TestSchema_AcceptsValidConfigs(t *testing.T) {
+	schema := asJSON(t, Schema())
+
+	rendered, err := RenderYAML(GetDefaults())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"rendered defaults", string(rendered)},
+		{"hand written", `
+scanPaths: ["~/code"]
+profiles: ["node", "rust"]
+rules:
+  vendor:
+    exceptSibling: ["go.mod"]
+minSize: 500
+maxDepth: 4
+delete:
+  mode: rm
+output:
+  format: "json, csv"
+  sortBy: age
+  units: bytes
+`},
+		{"deprecated minSizeMB", "minSizeMB: 100\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Empty(t, schemaErrors(schema, parseYAMLConfig(t, tt.content), "$"))
+		})
+	}
+}
+
+func TestSchema_RejectsInvalidConfigs(t *testing.T) {
+	schema := asJSON(t, Schema())
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown key", "maxdepth: 3\n", "$.maxdepth"},
+		{"unknown delete mode", "delete:\n  mode: shred\n", "$.delete.mode"},
+		{"unknown output format", "output:\n  format: xml\n", "$.output.format"},
+		{"unknown profile", "profiles: [cobol]\n", "$.profiles[0]"},
+		{"negative depth", "maxDepth: -1\n", "$.maxDepth"},
+		{"wrong type", "followSymlinks: sometimes\n", "$.followSymlinks"},
+		{"unknown rule key", "rules:\n  vendor:\n    exceptChild: [x]\n", "$.rules.vendor.exceptChild"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := schemaErrors(schema, parseYAMLConfig(t, tt.content), "$")
+			require.Len(t, errs, 1)
+			assert.True(t, strings.HasPrefix(errs[0], tt.wantErr+":"), errs[0])
+		})
+	}
+}
+
+func TestSchema_CoversEverySetting(t *testing.T) {
+	schema := Schema()
+	assert.Equal(t, SchemaURI, schema["$schema"])
+
+	// Every setting is in the schema with a description, so a new field
+	// can't be added without documenting it here
+	for _, key := range GetDefaults().Keys() {
+		node := schema
+		for _, part := range strings.Split(key, ".") {
+			properties, _ := node["properties"].(map[string]any)
+			require.Contains(t, properties, part, "schema lacks %s", key)
+			node = properties[part].(map[string]any)
+		}
+		assert.NotEmpty(t, node["description"], "%s has no description", key)
+	}
+
+	mode := schema["properties"].(map[string]any)["delete"].(map[string]any)["properties"].(map[string]any)["mode"].(map[string]any)
+	assert.Equal(t, ValidDeleteModes, mode["enum"])
+	assert.Equal(t, "quarantine", mode["default"])
+}
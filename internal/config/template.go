@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+)
+
+// configTemplate renders a fully commented config file. Strings are emitted as
+// JSON, which is valid YAML and takes care of quoting.
+var configTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"q": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}).Parse(`# BuildBloatBuster configuration
+# Generated by "BuildBloatBuster config init". Every key is optional; anything
+# left out falls back to the built-in default.
+
+# Config file format. Files from older releases are upgraded when loaded.
+version: {{ .Version }}
+
+# Paths to scan when none are given on the command line. Here and in
+# excludePaths and delete.quarantineDir, a leading ~ and $VAR or ${VAR} are
+# expanded.
+scanPaths:
+{{- range .ScanPaths }}
+  - {{ q . }}
+{{- end }}
+
+# Directory names that mark a folder as deletable build output.
+includeNames:
+{{- range .IncludeNames }}
+  - {{ q . }}
+{{- end }}
+
+# Built-in ecosystem profiles to enable: node, python, rust, jvm, ios,
+# frontend or all. They add their directory names to includeNames and only
+# select generic names such as build or target next to a matching project
+# file. Run "BuildBloatBuster profiles list" to see what each one matches.
+{{ if .Profiles }}profiles: {{ q .Profiles }}{{ else }}# profiles: ["node", "python"]{{ end }}
+
+# Built-in presets of well-known directories outside any project that scans
+# report too: mobile adds Xcode DerivedData and the Gradle and Android build
+# caches, docker Docker's reclaimable build cache. Unlike excludePaths, they
+# are picked from a curated list.
+{{ if .Presets }}presets: {{ q .Presets }}{{ else }}# presets: ["mobile"]{{ end }}
+
+# Directory names that are never selected or descended into.
+excludeNames:
+{{- range .ExcludeNames }}
+  - {{ q . }}
+{{- end }}
+
+# Patterns such as "*.egg-info" or "tmp-*"; directories whose name matches one
+# are never selected or descended into, like excludeNames.
+{{ if .ExcludeGlobs }}excludeGlobs: {{ q .ExcludeGlobs }}{{ else }}# excludeGlobs: ["*.egg-info", "tmp-*"]{{ end }}
+
+# Files of patterns, one per line, added to includeNames and to excludeNames
+# (or excludeGlobs, for patterns with * ? or [) when the config is loaded.
+# Blank lines and lines starting with # are ignored. Relative paths are
+# resolved against the directory of this file.
+{{ if .IncludeFrom }}includeFrom: {{ q .IncludeFrom }}{{ else }}# includeFrom: ["team-caches.txt"]{{ end }}
+{{ if .ExcludeFrom }}excludeFrom: {{ q .ExcludeFrom }}{{ else }}# excludeFrom: ["team-keep.txt"]{{ end }}
+
+# Exceptions for individual include names. A directory is skipped when any of
+# its exceptSibling files sits next to it. excludeNames always take precedence.
+{{ if .Rules }}rules:
+{{- range $name, $rule := .Rules }}
+  {{ q $name }}:
+    exceptSibling: {{ q $rule.ExceptSibling }}
+{{- end }}{{ else }}# rules:
+#   vendor:
+#     exceptSibling: ["go.mod"]{{ end }}
+
+# When true, includeNames/excludeNames above replace the built-in lists.
+# When false, they are added to the built-in lists instead.
+replaceDefaults: {{ .ReplaceDefaults }}
+
+# Absolute paths that are never scanned.
+excludePaths:
+{{- range .ExcludePaths }}
+  - {{ q . }}
+{{- end }}
+
+# File names, or patterns such as "*.csproj", that mark a project root in
+# addition to the built-in ones (package.json, go.mod, Cargo.toml, ...).
+{{ if .ProjectMarkers }}projectMarkers: {{ q .ProjectMarkers }}{{ else }}# projectMarkers: ["deno.json", "mix.exs", "*.csproj"]{{ end }}
+
+# Only report directories at least this large, e.g. "500MB" or "2GiB".
+# KB/MB/GB/TB are powers of 1000, KiB/MiB/GiB/TiB powers of 1024, and a
+# plain number is read as MiB. 0 reports every directory.
+minSize: {{ q .MinSize }}
+
+# How many levels below a scan path the scanner descends.
+maxDepth: {{ .MaxDepth }}
+
+# Stop scanning once this many directories were found, to bound time and
+# memory on huge trees. 0 means unlimited.
+maxResults: {{ .MaxResults }}
+
+# Whether to follow symbolic links and junctions. Sizes then include what the
+# links point to, each target counted once. It's safer to keep this false.
+followSymlinks: {{ .FollowSymlinks }}
+
+# Whether to descend into network filesystems (NFS, SMB, ...) mounted below a
+# scan path. They are skipped by default because walking them is slow.
+includeNetworkFS: {{ .IncludeNetworkFS }}
+
+# Directories holding a file modified within this duration, such as "10m" or
+# "1h", may belong to a build that is still running and are skipped. Use
+# --force-recent to include them anyway ("0" = never skip).
+skipIfModifiedWithin: {{ q .SkipIfModifiedWithin }}
+
+# How many times a directory that fails to read with an I/O error, as happens
+# on flaky network mounts, is read again before it is skipped. The wait
+# between attempts doubles each time (0 = never retry).
+readRetries: {{ .ReadRetries }}
+
+# Number of size calculation workers. 0 tunes it per scan path from the
+# storage type (fewer workers for spinning disks and network shares). It also
+# caps how many scan paths are walked at once (0 = one per CPU).
+concurrency: {{ .Concurrency }}
+
+delete:
+  # "quarantine" moves directories to quarantineDir, "trash" to the desktop
+  # trash (Linux and macOS) and "rm" deletes them permanently.
+  mode: {{ q .Delete.Mode }}
+  # Where quarantined directories are moved to.
+  quarantineDir: {{ q .Delete.QuarantineDir }}
+  # Days to keep quarantined items before they are eligible for purging.
+  retentionDays: {{ .Delete.RetentionDays }}
+  # Largest total size in GB a single clean may delete, as a guard against a
+  # pattern matching far more than intended. A larger selection needs a typed
+  # confirmation, and is refused with --yes or JSON output (0 = no cap).
+  maxPerRunGB: {{ .Delete.MaxPerRunGB }}
+  # Store quarantined directories as .tar.gz archives to save space.
+  # Restoring extracts them again.
+  compress: {{ .Delete.Compress }}
+  # Record a SHA-256 of every quarantined item so verify --checksum can detect
+  # corruption. Hashing reads all of the item's data, which slows quarantining.
+  checksum: {{ .Delete.Checksum }}
+  # Append a JSON line for every quarantine, rm, restore and purge to this
+  # file, as a durable record of what was removed, by whom and when (empty =
+  # disabled). Show it with the log command.
+  auditLog: {{ q .Delete.AuditLog }}
+
+output:
+  # "table", "json", "csv", "tsv", "plain" or "plain0". TSV prints the CSV
+  # columns separated by tabs, plain one path per line (plain0:
+  # NUL-separated) for piping. CSV writes a file and can be combined with one
+  # of the others, e.g. "json,csv".
+  format: {{ q .Output.Format }}
+  # "size", "path" or "age".
+  sortBy: {{ q .Output.SortBy }}
+  # "iec" shows sizes in powers of 1024 (MiB, GiB) like du -h, "si" in
+  # powers of 1000 (MB, GB) and "bytes" as exact byte counts.
+  units: {{ q .Output.Units }}
+  # Show the size columns of table and CSV output as plain numbers in this
+  # unit, e.g. "MB" or "GiB" (empty = humanized).
+  sizeUnit: {{ q .Output.SizeUnit }}
+  # POST the JSON summary of every scan and clean, with the hostname and a
+  # timestamp, to this URL, e.g. for a dashboard of build agents (empty =
+  # disabled). A failed delivery is retried once, then only warned about.
+  webhookURL: {{ q .Output.WebhookURL }}
+  # Headers sent along, as "Name: value". $VAR and ${VAR} are expanded when
+  # the summary is sent, so secrets can stay in the environment.
+  {{ if .Output.WebhookHeaders }}webhookHeaders: {{ q .Output.WebhookHeaders }}{{ else }}# webhookHeaders: ["Authorization: Bearer ${DASHBOARD_TOKEN}"]{{ end }}
+  # Time limit for each delivery attempt, in seconds (0 = no limit).
+  webhookTimeoutSeconds: {{ .Output.WebhookTimeoutSeconds }}
+  # Don't verify the TLS certificate of webhookURL, for self-signed
+  # certificates on internal networks.
+  webhookInsecure: {{ .Output.WebhookInsecure }}
+
+size:
+  # Time limit for scanning and sizing, which overlap, in seconds (0 = no limit).
+  timeoutSeconds: {{ .Size.TimeoutSeconds }}
+  # Time limit per directory, in seconds. Slower directories keep a partial
+  # size that is reported as a lower bound (0 = no limit).
+  candidateTimeoutSeconds: {{ .Size.CandidateTimeoutSeconds }}
+
+behavior:
+  # Whether clean (and watch --auto-clean) only shows what it would delete
+  # unless --apply is given. When false, it deletes unless --dry-run is given.
+  dryRunByDefault: {{ .Behavior.DryRunByDefault }}
+  # Whether clean and verify --fix ask before deleting unless --yes is given.
+  # When false, they proceed without asking unless --yes=false is given.
+  requireConfirmation: {{ .Behavior.RequireConfirmation }}
+`))
+
+// RenderYAML renders cfg as a commented YAML config file
+func RenderYAML(cfg Config) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := configTemplate.Execute(&buf, cfg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
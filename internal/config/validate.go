@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+)
+
+// ValidDeleteModes lists the supported values for delete.mode.
+var ValidDeleteModes = []string{"quarantine", "rm", "trash"}
+
+// ValidOutputFormats lists the supported values for output.format, which
+// may combine several separated by commas.
+var ValidOutputFormats = []string{"table", "json", "csv", "tsv", "plain", "plain0"}
+
+// stdoutFormats are the output formats printed to stdout, of which a run can
+// only use one; the others write files.
+var stdoutFormats = []string{"table", "json", "tsv", "plain", "plain0"}
+
+// ValidSortOrders lists the supported values for output.sortBy.
+var ValidSortOrders = []string{"size", "path", "age"}
+
+// ValidUnits lists the supported values for output.units.
+var ValidUnits = []string{"iec", "si", "bytes"}
+
+// IsValidDeleteMode reports whether mode is a supported delete mode.
+func IsValidDeleteMode(mode string) bool {
+	return slices.Contains(ValidDeleteModes, mode)
+}
+
+// ValidationError lists every problem found in a configuration. Each problem
+// names the offending key and suggests a fix.
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+	lines := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		lines[i] = "  - " + problem.Error()
+	}
+	return fmt.Sprintf("%d problems found:\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// Unwrap exposes the individual problems to errors.Is and errors.As.
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks the settings so mistakes are reported before any work is done.
+// It returns a *ValidationError listing every problem, or nil.
+func (c Config) Validate() error {
+	var problems []error
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if _, err := c.MinSizeBytes(); err != nil {
+		add("invalid minSize %q: %v; 0 disables the size filter", c.MinSize, errors.Unwrap(err))
+	}
+	if c.MaxDepth < 0 {
+		add("invalid maxDepth %d: must be 0 or greater (0 means unlimited)", c.MaxDepth)
+	}
+	if c.MaxResults < 0 {
+		add("invalid maxResults %d: must be 0 or greater (0 means unlimited)", c.MaxResults)
+	}
+	if window, err := c.InUseWindow(); err != nil || window < 0 {
+		add("invalid skipIfModifiedWithin %q: must be a duration such as 10m or 1h, or 0 to disable it", c.SkipIfModifiedWithin)
+	}
+	for _, glob := range c.ExcludeGlobs {
+		if _, err := filepath.Match(glob, ""); err != nil || glob == "" || strings.ContainsAny(glob, `/\`) {
+			add("invalid excludeGlobs entry %q: must be a directory name pattern such as *.egg-info", glob)
+		}
+	}
+	for _, marker := range c.ProjectMarkers {
+		if _, err := filepath.Match(marker, ""); err != nil || marker == "" || strings.ContainsAny(marker, `/\`) {
+			add("invalid projectMarkers entry %q: must be a file name or a pattern such as *.csproj", marker)
+		}
+	}
+	if c.ReadRetries < 0 {
+		add("invalid readRetries %d: must be 0 or greater (0 never retries)", c.ReadRetries)
+	}
+	if c.Concurrency < 0 {
+		add("invalid concurrency %d: must be 0 or greater (0 tunes it to the storage type)", c.Concurrency)
+	}
+	if len(c.IncludeNames) == 0 && len(c.Profiles) == 0 {
+		add("invalid includeNames: the list is empty so nothing would ever be selected; add directory names, enable profiles or set replaceDefaults: false")
+	}
+	for _, profile := range c.Profiles {
+		if !slices.Contains(ProfileNames(), profile) {
+			add("invalid profiles entry %q: must be one of %s", profile, strings.Join(ProfileNames(), ", "))
+		}
+	}
+	for _, preset := range c.Presets {
+		if _, ok := LookupPreset(preset); !ok {
+			add("invalid presets entry %q: must be one of %s", preset, strings.Join(PresetNames(), ", "))
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Rules)) {
+		if len(c.Rules[name].ExceptSibling) == 0 {
+			add("invalid rules.%s: exceptSibling is empty; list the files that exempt %s, e.g. exceptSibling: [\"go.mod\"]", name, name)
+		}
+	}
+
+	if !IsValidDeleteMode(c.Delete.Mode) {
+		add("invalid delete.mode %q: must be one of %s", c.Delete.Mode, strings.Join(ValidDeleteModes, ", "))
+	}
+	if c.Delete.Mode == "quarantine" && c.Delete.QuarantineDir == "" {
+		add("invalid delete.quarantineDir: must be set when delete.mode is quarantine")
+	}
+	for _, scanPath := range c.ScanPaths {
+		if c.Delete.QuarantineDir != "" && samePath(scanPath, c.Delete.QuarantineDir) {
+			add("invalid delete.quarantineDir %q: it is also listed in scanPaths; move the quarantine outside the scanned directories",
+				c.Delete.QuarantineDir)
+			break
+		}
+	}
+	if c.Delete.RetentionDays < 0 {
+		add("invalid delete.retentionDays %d: must be 0 or greater", c.Delete.RetentionDays)
+	}
+	if c.Delete.MaxPerRunGB < 0 {
+		add("invalid delete.maxPerRunGB %g: must be 0 or greater (0 means no cap)", c.Delete.MaxPerRunGB)
+	}
+
+	var printed []string
+	for _, format := range c.OutputFormats() {
+		if !slices.Contains(ValidOutputFormats, format) {
+			add("invalid output.format %q: %q is not one of %s", c.Output.Format, format, strings.Join(ValidOutputFormats, ", "))
+		} else if slices.Contains(stdoutFormats, format) && !slices.Contains(printed, format) {
+			printed = append(printed, format)
+		}
+	}
+	if len(printed) > 1 {
+		all := "both"
+		if len(printed) > 2 {
+			all = "all"
+		}
+		add("invalid output.format %q: %s %s print to stdout, so only one of them can be used at a time",
+			c.Output.Format, strings.Join(printed, " and "), all)
+	}
+	if !slices.Contains(ValidSortOrders, c.Output.SortBy) {
+		add("invalid output.sortBy %q: must be one of %s", c.Output.SortBy, strings.Join(ValidSortOrders, ", "))
+	}
+	if !slices.Contains(ValidUnits, c.Output.Units) {
+		add("invalid output.units %q: must be one of %s", c.Output.Units, strings.Join(ValidUnits, ", "))
+	}
+	if _, err := c.FixedSizeUnit(); err != nil {
+		add("invalid output.sizeUnit: %v", err)
+	}
+	if _, _, err := c.Webhook(); err != nil {
+		add("invalid %v", err)
+	}
+	if c.Output.WebhookTimeoutSeconds < 0 {
+		add("invalid output.webhookTimeoutSeconds %d: must be 0 or greater (0 means no limit)", c.Output.WebhookTimeoutSeconds)
+	}
+
+	if c.Size.TimeoutSeconds < 0 {
+		add("invalid size.timeoutSeconds %d: must be 0 or greater (0 means no limit)", c.Size.TimeoutSeconds)
+	}
+	if c.Size.CandidateTimeoutSeconds < 0 {
+		add("invalid size.candidateTimeoutSeconds %d: must be 0 or greater (0 means no limit)", c.Size.CandidateTimeoutSeconds)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// Warnings returns problems that don't stop the config from loading but are
+// probably mistakes.
+func (c Config) Warnings() []string {
+	var warnings []string
+	seen := make(map[string]struct{})
+	for _, name := range c.IncludeNames {
+		if _, dup := seen[name]; dup || !slices.Contains(c.ExcludeNames, name) {
+			continue
+		}
+		seen[name] = struct{}{}
+		warnings = append(warnings, fmt.Sprintf(
+			"%q is in both includeNames and excludeNames; the exclusion wins, so remove one of them", name))
+	}
+	return append(warnings, c.PathWarnings()...)
+}
+
+// KeyWarnings reads the YAML file at path and returns a warning for every key
+// that is deprecated or isn't a known setting, suggesting the intended key for
+// likely typos such as "maxdepth".
+func KeyWarnings(path string) ([]string, error) {
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
+		return nil, err
+	}
+	// Deprecated keys are those the migrations rewrite
+	warnings, _, err := migrate(k)
+	if err != nil {
+		return nil, err
+	}
+
+	known := GetDefaults().Keys()
+	knownSections := make(map[string]struct{})
+	for _, key := range known {
+		section, _, _ := strings.Cut(key, ".")
+		knownSections[section] = struct{}{}
+	}
+
+	reported := make(map[string]struct{})
+	for _, key := range k.Keys() {
+		if slices.Contains(known, key) || strings.HasPrefix(key, "rules.") {
+			continue
+		}
+		// Report an unknown section once rather than once per key inside it
+		candidates := known
+		if section, _, _ := strings.Cut(key, "."); !hasKey(knownSections, section) {
+			key = section
+			candidates = keysOf(knownSections)
+		}
+		if _, ok := reported[key]; ok {
+			continue
+		}
+		reported[key] = struct{}{}
+
+		warning := fmt.Sprintf("unknown key %q", key)
+		if suggestion := suggestKey(key, candidates); suggestion != "" {
+			warning += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings, nil
+}
+
+// suggestKey returns the known key that matches key apart from case, if any
+func suggestKey(key string, known []string) string {
+	for _, candidate := range known {
+		if strings.EqualFold(candidate, key) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func hasKey(set map[string]struct{}, key string) bool {
+	_, ok := set[key]
+	return ok
+}
+
+func keysOf(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// samePath reports whether a and b refer to the same location once made absolute
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
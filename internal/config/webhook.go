@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Webhook returns the URL that run summaries are posted to and the headers
+// sent along, with the environment variables in both expanded, so a token can
+// stay out of the config file. The URL is empty when no webhook is set.
+func (c Config) Webhook() (string, http.Header, error) {
+	return c.webhook(runtime.GOOS, os.LookupEnv)
+}
+
+// webhook is Webhook for the platform goos and the environment lookupEnv
+func (c Config) webhook(goos string, lookupEnv func(string) (string, bool)) (string, http.Header, error) {
+	if c.Output.WebhookURL == "" {
+		return "", nil, nil
+	}
+	rawURL := expandEnv(c.Output.WebhookURL, goos, lookupEnv)
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", nil, fmt.Errorf("output.webhookURL %q: must be an http:// or https:// URL", c.Output.WebhookURL)
+	}
+
+	header := make(http.Header)
+	for _, line := range c.Output.WebhookHeaders {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return "", nil, fmt.Errorf("output.webhookHeaders entry %q: must be \"Name: value\"", line)
+		}
+		header.Add(name, strings.TrimSpace(expandEnv(value, goos, lookupEnv)))
+	}
+	return rawURL, header, nil
+}
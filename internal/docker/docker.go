@@ -0,0 +1,125 @@
+// Package docker reports the space Docker's build cache takes, which lives in
+// Docker's own storage rather than in any directory a scan could find, and
+// prunes it through the docker CLI.
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// BuildCachePath is the path of the candidate standing for Docker's build
+// cache. It is not a directory: cleaning it runs docker builder prune.
+const BuildCachePath = "docker://build-cache"
+
+// ErrNotInstalled is returned when the docker CLI is not on the PATH
+var ErrNotInstalled = errors.New("the docker CLI is not installed")
+
+// run runs the docker CLI with args and returns its standard output; tests
+// replace it to return canned output
+var run = func(ctx context.Context, args ...string) ([]byte, error) {
+	path, err := exec.LookPath("docker")
+	if err != nil {
+		return nil, ErrNotInstalled
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Usage is one line of docker system df: the space a type of Docker object
+// takes, and how much of it isn't used by any container
+type Usage struct {
+	Type             string
+	SizeBytes        int64
+	ReclaimableBytes int64
+}
+
+// dfLine is a line of docker system df --format '{{json .}}'
+type dfLine struct {
+	Type        string
+	Size        string
+	Reclaimable string
+}
+
+// ParseSystemDF parses the output of docker system df --format '{{json .}}',
+// one JSON object per line. Docker prints sizes such as "1.845GB" in powers
+// of 1000, with the reclaimable share of images in parentheses after it.
+func ParseSystemDF(out []byte) ([]Usage, error) {
+	var usages []Usage
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var parsed dfLine
+		if err := json.Unmarshal(line, &parsed); err != nil {
+			return nil, fmt.Errorf("unexpected docker system df output %q: %w", line, err)
+		}
+		size, err := bytesize.Parse(parsed.Size)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parsed.Type, err)
+		}
+		reclaimable, _, _ := strings.Cut(parsed.Reclaimable, "(")
+		reclaimableBytes, err := bytesize.Parse(reclaimable)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parsed.Type, err)
+		}
+		usages = append(usages, Usage{Type: parsed.Type, SizeBytes: size, ReclaimableBytes: reclaimableBytes})
+	}
+	return usages, scanner.Err()
+}
+
+// BuildCacheCandidate returns a candidate for the reclaimable part of
+// Docker's build cache, the layers BuildKit keeps to speed up later builds.
+// ok is false when nothing is reclaimable. ErrNotInstalled is returned when
+// there is no docker CLI.
+func BuildCacheCandidate(ctx context.Context) (candidate scan.Candidate, ok bool, err error) {
+	out, err := run(ctx, "system", "df", "--format", "{{json .}}")
+	if err != nil {
+		return scan.Candidate{}, false, err
+	}
+	usages, err := ParseSystemDF(out)
+	if err != nil {
+		return scan.Candidate{}, false, err
+	}
+	for _, usage := range usages {
+		if usage.Type != "Build Cache" || usage.ReclaimableBytes == 0 {
+			continue
+		}
+		return scan.Candidate{
+			Path:      BuildCachePath,
+			SizeBytes: usage.ReclaimableBytes,
+			Reason:    "preset docker: build cache",
+		}, true, nil
+	}
+	return scan.Candidate{}, false, nil
+}
+
+// IsCandidate reports whether candidate stands for Docker's build cache
+// rather than a directory
+func IsCandidate(candidate scan.Candidate) bool {
+	return candidate.Path == BuildCachePath
+}
+
+// PruneBuildCache deletes the build cache no image or running build uses,
+// with docker builder prune
+func PruneBuildCache(ctx context.Context) error {
+	_, err := run(ctx, "builder", "prune", "--force")
+	return err
+}
@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// systemDF is the output of docker system df --format '{{json .}}'
+const systemDF = `{"Active":"2","Reclaimable":"11.63GB (70%)","Size":"16.43GB","TotalCount":"5","Type":"Images"}
+{"Active":"0","Reclaimable":"0B","Size":"0B","TotalCount":"2","Type":"Containers"}
+{"Active":"1","Reclaimable":"512kB (50%)","Size":"1.024MB","TotalCount":"2","Type":"Local Volumes"}
+{"Active":"0","Reclaimable":"1.845GB","Size":"1.845GB","TotalCount":"14","Type":"Build Cache"}
+`
+
+func TestParseSystemDF(t *testing.T) {
+	usages, err := ParseSystemDF([]byte(systemDF))
+	require.NoError(t, err)
+	assert.Equal(t, []Usage{
+		{Type: "Images", SizeBytes: 16_430_000_000, ReclaimableBytes: 11_630_000_000},
+		{Type: "Containers"},
+		{Type: "Local Volumes", SizeBytes: 1_024_000, ReclaimableBytes: 512_000},
+		{Type: "Build Cache", SizeBytes: 1_845_000_000, ReclaimableBytes: 1_845_000_000},
+	}, usages)
+
+	_, err = ParseSystemDF([]byte("TYPE  TOTAL  ACTIVE  SIZE  RECLAIMABLE\n"))
+	assert.ErrorContains(t, err, "unexpected docker system df output")
+	_, err = ParseSystemDF([]byte(`{"Type":"Images","Size":"lots","Reclaimable":"0B"}`))
+	assert.ErrorContains(t, err, "Images")
+}
+
+func TestBuildCacheCandidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		err     error
+		wantOK  bool
+		wantErr error
+	}{
+		{"reclaimable", systemDF, nil, true, nil},
+		{"nothing reclaimable", `{"Reclaimable":"0B","Size":"2GB","Type":"Build Cache"}`, nil, false, nil},
+		{"not installed", "", ErrNotInstalled, false, ErrNotInstalled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalRun := run
+			t.Cleanup(func() { run = originalRun })
+			var gotArgs []string
+			run = func(ctx context.Context, args ...string) ([]byte, error) {
+				gotArgs = args
+				return []byte(tt.out), tt.err
+			}
+
+			candidate, ok, err := BuildCacheCandidate(context.Background())
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, []string{"system", "df", "--format", "{{json .}}"}, gotArgs)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, BuildCachePath, candidate.Path)
+				assert.Equal(t, int64(1_845_000_000), candidate.SizeBytes)
+				assert.True(t, IsCandidate(candidate))
+			}
+		})
+	}
+}
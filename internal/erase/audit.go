@@ -0,0 +1,145 @@
+package erase
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Audit log actions
+const (
+	ActionQuarantine = "quarantine"
+	ActionRemove     = "rm"
+	ActionTrash      = "trash"
+	ActionRestore    = "restore"
+	ActionPurge      = "purge"
+)
+
+// AuditEntry is one line of the audit log, recording a single action on an item.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Action is quarantine, rm, trash, restore or purge
+	Action string `json:"action"`
+	// Mode is the delete mode of quarantine, rm and trash entries
+	Mode         string `json:"mode,omitempty"`
+	OriginalPath string `json:"originalPath"`
+	// QuarantinePath is where the item was moved to, in the trash for trash
+	// entries; it is empty for items that were deleted permanently
+	QuarantinePath string `json:"quarantinePath,omitempty"`
+	SizeBytes      int64  `json:"sizeBytes"`
+	User           string `json:"user"`
+	Hostname       string `json:"hostname"`
+	// RunID is shared by every entry written by one invocation of the tool
+	RunID string `json:"runId"`
+}
+
+// RunID identifies this invocation of the tool in the audit log
+var RunID = sync.OnceValue(func() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+})
+
+// currentUser is the name of the user running the tool, for the audit log
+var currentUser = sync.OnceValue(func() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	for _, key := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(key); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+})
+
+// hostname is the name of this machine, for the audit log
+var hostname = sync.OnceValue(func() string {
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "unknown"
+})
+
+// appendAuditEntry appends entry as a single JSON line to the log at path,
+// creating the file and its directory if needed. The file is opened with
+// O_APPEND and each line written at once, so concurrent runs never interleave
+// or overwrite each other's lines.
+func appendAuditEntry(path string, entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// audit records an action on an item in the configured audit log, if any. A
+// failure is reported but doesn't stop the run, since the action already happened.
+func (e *Eraser) audit(action, originalPath, quarantinePath string, sizeBytes int64) {
+	if e.cfg.Delete.AuditLog == "" {
+		return
+	}
+	entry := AuditEntry{
+		Timestamp:      time.Now(),
+		Action:         action,
+		OriginalPath:   originalPath,
+		QuarantinePath: quarantinePath,
+		SizeBytes:      sizeBytes,
+		User:           currentUser(),
+		Hostname:       hostname(),
+		RunID:          RunID(),
+	}
+	if action == ActionQuarantine || action == ActionRemove || action == ActionTrash {
+		entry.Mode = e.cfg.Delete.Mode
+	}
+	if err := appendAuditEntry(e.cfg.Delete.AuditLog, entry); err != nil {
+		e.logger.Warn("failed to write audit log entry", "path", originalPath, "error", err)
+	}
+}
+
+// LoadAuditLog reads the entries of the audit log at path, oldest first.
+// Lines that can't be parsed, such as one cut short by a crash, are skipped.
+func LoadAuditLog(path string) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		// Logs written before actions were recorded only had the mode
+		if entry.Action == "" {
+			entry.Action = entry.Mode
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read audit log %s: %w", path, err)
+	}
+	return entries, nil
+}
@@ -3,10 +3,15 @@ package erase
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/vbauerster/mpb/v8"
 	"github.com/yehia2amer/BuildBloatBuster/internal/config"
 	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
 )
@@ -17,86 +22,414 @@ type Metadata struct {
 	QuarantinePath string    `json:"quarantinePath"`
 	Timestamp      time.Time `json:"timestamp"`
 	SizeBytes      int64     `json:"sizeBytes"`
+	// Compressed is set when the item was stored as a .tar.gz archive at ArchivePath
+	Compressed  bool   `json:"compressed,omitempty"`
+	ArchivePath string `json:"archivePath,omitempty"`
+	// Checksum is the Checksum of the item when it was quarantined, recorded
+	// only when delete.checksum is enabled
+	Checksum string `json:"checksum,omitempty"`
 }
 
 // Eraser handles the deletion of candidates.
 type Eraser struct {
-	cfg config.Config
+	cfg    config.Config
+	logger *slog.Logger
+	// removed lists the candidates the last EraseCandidates call actually removed
+	removed []scan.Candidate
+	// done, if set, is asked before each candidate whether to stop
+	done func() bool
+	// claimed holds the quarantine destinations handed out so far, so no
+	// two candidates are given the same one
+	claimed map[string]struct{}
+	// progress, if set, is where the quarantine progress bar is drawn
+	progress *mpb.Progress
 }
 
 // NewEraser creates a new Eraser.
 func NewEraser(cfg config.Config) *Eraser {
-	return &Eraser{cfg: cfg}
+	return &Eraser{cfg: cfg, logger: slog.Default(), claimed: make(map[string]struct{})}
+}
+
+// SetLogger sets where the eraser logs the items it skipped or could only
+// partly handle; it is slog's default logger unless set
+func (e *Eraser) SetLogger(logger *slog.Logger) {
+	e.logger = logger
+}
+
+// SetDone sets a check made before each candidate is erased. Once it returns
+// true, EraseCandidates stops and leaves the remaining candidates in place,
+// as clean --free does when enough space was freed.
+func (e *Eraser) SetDone(done func() bool) {
+	e.done = done
+}
+
+// finished reports whether the done check says to stop
+func (e *Eraser) finished() bool {
+	return e.done != nil && e.done()
 }
 
 // EraseCandidates deletes the given candidates based on the configured mode.
 func (e *Eraser) EraseCandidates(candidates []scan.Candidate) error {
+	e.removed = nil
 	switch e.cfg.Delete.Mode {
 	case "quarantine":
 		return e.quarantineCandidates(candidates)
 	case "rm":
-		// TODO: Implement permanent deletion
-		return fmt.Errorf("permanent deletion mode ('rm') is not yet implemented")
+		return e.removeCandidates(candidates)
+	case "trash":
+		return e.trashCandidates(candidates)
 	default:
 		return fmt.Errorf("unsupported delete mode: %s", e.cfg.Delete.Mode)
 	}
 }
 
+// touchesQuarantine reports whether path is the quarantine directory, lies
+// inside it or contains it. Such a path may have been scanned by mistake, and
+// removing it would destroy quarantined items, so it is never erased.
+func (e *Eraser) touchesQuarantine(path string) bool {
+	if e.cfg.Delete.QuarantineDir == "" {
+		return false
+	}
+	quarantineDir, err := filepath.Abs(e.cfg.Delete.QuarantineDir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return isWithin(absPath, quarantineDir) || isWithin(quarantineDir, absPath)
+}
+
+// isWithin reports whether path is dir or lies inside it
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// Removed returns the candidates removed by the last EraseCandidates call.
+// Candidates that were skipped or failed are not included.
+func (e *Eraser) Removed() []scan.Candidate {
+	return e.removed
+}
+
 // quarantineCandidates moves candidates to the quarantine directory.
 func (e *Eraser) quarantineCandidates(candidates []scan.Candidate) error {
 	quarantineDir := e.cfg.Delete.QuarantineDir
 	if err := os.MkdirAll(quarantineDir, 0755); err != nil {
 		return fmt.Errorf("could not create quarantine directory at %s: %w", quarantineDir, err)
 	}
+	lock, err := LockQuarantine(quarantineDir)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
 
-	fmt.Printf("Moving %d directories to quarantine (%s)...\n", len(candidates), quarantineDir)
+	out := e.output()
+	fmt.Fprintf(out, "Moving %d directories to quarantine (%s)...\n", len(candidates), quarantineDir)
 
+	var bar *mpb.Bar
+	var freed atomic.Int64
+	if e.progress != nil {
+		bar = newQuarantineProgress(e.progress, len(candidates), &freed)
+	}
+
+	var quarantined []Metadata
 	for _, candidate := range candidates {
-		// Create a unique name for the quarantined item
-		timestamp := time.Now().Format("20060102-150405")
-		baseName := filepath.Base(candidate.Path)
-		destName := fmt.Sprintf("%s-%s", timestamp, baseName)
-		destPath := filepath.Join(quarantineDir, destName)
+		if e.finished() {
+			break
+		}
+		meta, ok := e.quarantineCandidate(candidate, out)
+		if ok {
+			freed.Add(candidate.SizeBytes)
+		}
+		if bar != nil {
+			// Skipped and failed candidates count as done too
+			bar.Increment()
+		}
+		if !ok {
+			continue
+		}
+		quarantined = append(quarantined, meta)
+		e.removed = append(e.removed, candidate)
+		e.audit(ActionQuarantine, candidate.Path, meta.QuarantinePath, candidate.SizeBytes)
+	}
+	if bar != nil {
+		// A run stopped early, as by clean --free, leaves its bar short
+		bar.Abort(false)
+	}
 
-		fmt.Printf(" - Quarantining %s -> %s\n", candidate.Path, destPath)
+	// Record this run so it can be undone in one step
+	if len(quarantined) > 0 {
+		if err := writeSessionLog(quarantineDir, quarantined); err != nil {
+			e.logger.Warn("failed to record this run for undo", "error", err)
+		}
+	}
 
-		// Move the directory
-		if err := os.Rename(candidate.Path, destPath); err != nil {
-			// os.Rename might fail across different devices.
-			// A more robust implementation would copy and then delete.
-			// For now, we'll just log the error.
-			fmt.Fprintf(os.Stderr, "Warning: failed to move %s: %v. It might be on a different device.\n", candidate.Path, err)
-			continue // Continue with the next candidate
+	fmt.Fprintln(out, "\nQuarantine complete.")
+	return nil
+}
+
+// quarantineCandidate moves a single candidate to the quarantine, printing
+// where to on out. It reports false if the candidate was refused or could
+// not be moved, which is logged.
+func (e *Eraser) quarantineCandidate(candidate scan.Candidate, out io.Writer) (Metadata, bool) {
+	// Never move a protected path or the home directory itself, however
+	// the candidate list was produced
+	if config.IsProtectedPath(candidate.Path) || config.IsHomeDir(candidate.Path) {
+		e.logger.Warn("refusing to quarantine protected path", "path", candidate.Path)
+		return Metadata{}, false
+	}
+	if e.touchesQuarantine(candidate.Path) {
+		e.logger.Warn("refusing to quarantine the quarantine directory or an item in it", "path", candidate.Path)
+		return Metadata{}, false
+	}
+
+	destPath, compressed := e.QuarantineDestination(candidate, time.Now())
+
+	fmt.Fprintf(out, " - Quarantining %s -> %s\n", candidate.Path, destPath)
+
+	meta, err := e.quarantine(candidate, destPath, compressed)
+	if err != nil {
+		e.logger.Warn("failed to quarantine", "path", candidate.Path, "error", err)
+		return Metadata{}, false
+	}
+	return meta, true
+}
+
+// QuarantineName returns the name a directory at path gets in the quarantine
+// when it is moved there at now: the time, then the directory's own name,
+// e.g. "20240101-120000-node_modules"
+func QuarantineName(path string, now time.Time) string {
+	return fmt.Sprintf("%s-%s", now.Format("20060102-150405"), filepath.Base(path))
+}
+
+// QuarantineDestination returns where candidate is moved to when it is
+// quarantined at now, and whether it is compressed into a .tar.gz archive
+// there. Links are never compressed. Directories with the same name moved
+// within the same second would get the same QuarantineName, so a counter is
+// appended, as in "20240101-120000-node_modules-2", when an earlier call
+// returned the path or something already exists there.
+func (e *Eraser) QuarantineDestination(candidate scan.Candidate, now time.Time) (destPath string, compressed bool) {
+	base := filepath.Join(e.cfg.Delete.QuarantineDir, QuarantineName(candidate.Path, now))
+	compressed = e.cfg.Delete.Compress && !scan.IsLink(candidate.Path, nil)
+	var ext string
+	if compressed {
+		ext = ".tar.gz"
+	}
+	destPath = base + ext
+	for n := 2; e.destinationTaken(destPath); n++ {
+		destPath = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	e.claimed[destPath] = struct{}{}
+	return destPath, compressed
+}
+
+// destinationTaken reports whether destPath was handed out before, or an item
+// or a metadata file already exists there
+func (e *Eraser) destinationTaken(destPath string) bool {
+	if _, ok := e.claimed[destPath]; ok {
+		return true
+	}
+	for _, path := range []string{destPath, MetadataPath(destPath)} {
+		if _, err := os.Lstat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCandidates permanently deletes candidates.
+func (e *Eraser) removeCandidates(candidates []scan.Candidate) error {
+	fmt.Printf("Permanently deleting %d directories...\n", len(candidates))
+
+	for _, candidate := range candidates {
+		if e.finished() {
+			break
+		}
+		if config.IsProtectedPath(candidate.Path) || config.IsHomeDir(candidate.Path) {
+			e.logger.Warn("refusing to delete protected path", "path", candidate.Path)
+			continue
 		}
+		if e.touchesQuarantine(candidate.Path) {
+			e.logger.Warn("refusing to delete the quarantine directory or an item in it", "path", candidate.Path)
+			continue
+		}
+
+		fmt.Printf(" - Deleting %s\n", candidate.Path)
 
-		// Create metadata file for restoration
-		if err := e.writeMetadata(candidate, destPath); err != nil {
-			// If metadata fails, we should ideally try to move the directory back.
-			// For now, we will log a critical warning.
-			fmt.Fprintf(os.Stderr, "CRITICAL: failed to write metadata for %s. Manual restore may be required from %s. Error: %v\n", candidate.Path, destPath, err)
+		// RemoveAll removes a symlink or junction candidate itself, never its target
+		if err := os.RemoveAll(candidate.Path); err != nil {
+			e.logger.Warn("failed to delete", "path", candidate.Path, "error", err)
+			continue
 		}
+		e.removed = append(e.removed, candidate)
+		e.audit(ActionRemove, candidate.Path, "", candidate.SizeBytes)
 	}
 
-	fmt.Println("\nQuarantine complete.")
+	fmt.Println("\nDeletion complete.")
 	return nil
 }
 
-// writeMetadata creates a JSON file with details about the quarantined item.
-func (e *Eraser) writeMetadata(candidate scan.Candidate, quarantinePath string) error {
+// renameMetadata moves a staged metadata file into place; tests replace it
+// to make the last step of quarantining fail
+var renameMetadata = os.Rename
+
+// quarantine moves candidate to destPath, or archives it there if compressed,
+// along with its metadata. It either succeeds as a whole or leaves the
+// candidate where it was: the metadata is staged in a temporary file first
+// and only renamed into place once the item is in the quarantine, and if
+// that fails the item is moved back.
+func (e *Eraser) quarantine(candidate scan.Candidate, destPath string, compressed bool) (Metadata, error) {
+	if compressed {
+		if err := compressDirectory(candidate.Path, destPath); err != nil {
+			os.Remove(destPath)
+			return Metadata{}, fmt.Errorf("failed to compress: %w", err)
+		}
+	}
+
+	// A directory checksums the same before and after the move, so the
+	// metadata can be complete before anything is moved
+	checksumPath := candidate.Path
+	if compressed {
+		checksumPath = destPath
+	}
+	meta := e.newMetadata(candidate, destPath, checksumPath, compressed)
+	tmpPath, err := stageMetadata(meta)
+	if err != nil {
+		if compressed {
+			os.Remove(destPath)
+		}
+		return meta, err
+	}
+
+	// Rename operates on the path itself, so a symlink or junction candidate
+	// is moved as a link and its target is left untouched. It fails across
+	// devices; then the candidate stays where it is.
+	if !compressed {
+		if err := os.Rename(candidate.Path, destPath); err != nil {
+			os.Remove(tmpPath)
+			return meta, fmt.Errorf("failed to move to quarantine, it might be on a different device: %w", err)
+		}
+	}
+
+	if err := renameMetadata(tmpPath, MetadataPath(destPath)); err != nil {
+		os.Remove(tmpPath)
+		if compressed {
+			os.Remove(destPath)
+		} else if rollbackErr := os.Rename(destPath, candidate.Path); rollbackErr != nil {
+			e.logger.Error("failed to write metadata and to move the directory back, manual restore may be required",
+				"path", candidate.Path, "quarantinePath", destPath, "error", rollbackErr)
+		}
+		return meta, fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	// The archive is complete and restorable, so the original can go
+	if compressed {
+		if err := os.RemoveAll(candidate.Path); err != nil {
+			e.logger.Warn("archived but failed to remove", "path", candidate.Path, "error", err)
+		}
+	}
+	return meta, nil
+}
+
+// newMetadata returns the metadata of candidate quarantined at
+// quarantinePath, with the checksum of the item at checksumPath if
+// delete.checksum is set
+func (e *Eraser) newMetadata(candidate scan.Candidate, quarantinePath, checksumPath string, compressed bool) Metadata {
 	meta := Metadata{
 		OriginalPath:   candidate.Path,
 		QuarantinePath: quarantinePath,
 		Timestamp:      time.Now(),
 		SizeBytes:      candidate.SizeBytes,
 	}
+	if compressed {
+		meta.Compressed = true
+		meta.ArchivePath = quarantinePath
+	}
+	if e.cfg.Delete.Checksum {
+		// A checksum is only a bonus; the item can be restored without it
+		sum, err := Checksum(checksumPath)
+		if err != nil {
+			e.logger.Warn("failed to checksum", "path", candidate.Path, "error", err)
+		}
+		meta.Checksum = sum
+	}
+	return meta
+}
 
-	// Metadata file will have the same name as the quarantined dir, but with .json extension
-	metaPath := quarantinePath + ".meta.json"
-
+// stageMetadata writes meta to a temporary file next to where its metadata
+// file goes and returns the file's path. Listing the quarantine only reads
+// .json files, so a staged file that is left behind is never taken for
+// metadata.
+func stageMetadata(meta Metadata) (string, error) {
+	metaPath := MetadataPath(meta.QuarantinePath)
 	data, err := json.MarshalIndent(meta, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return "", fmt.Errorf("could not create metadata directory: %w", err)
+	}
+	tmpPath := metaPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return tmpPath, nil
+}
+
+// Restore moves a quarantined item back to its original location, extracting
+// it if it was compressed, and removes its metadata file. It refuses to
+// overwrite anything now at the original path.
+func Restore(meta Metadata) error {
+	if _, err := os.Lstat(meta.OriginalPath); err == nil {
+		return fmt.Errorf("cannot restore %s: path already exists", meta.OriginalPath)
+	}
+
+	if meta.Compressed {
+		if err := extractArchive(meta.ArchivePath, meta.OriginalPath); err != nil {
+			// Don't leave a half-extracted tree behind
+			os.RemoveAll(meta.OriginalPath)
+			return fmt.Errorf("failed to extract archive: %w", err)
+		}
+		if err := os.Remove(meta.ArchivePath); err != nil {
+			slog.Warn("failed to remove archive", "path", meta.ArchivePath, "error", err)
+		}
+	} else if err := os.Rename(meta.QuarantinePath, meta.OriginalPath); err != nil {
+		return fmt.Errorf("failed to move directory: %w", err)
+	}
+
+	// Clean up the metadata file
+	metaPath := MetadataPath(meta.QuarantinePath)
+	if err := os.Remove(metaPath); err != nil {
+		// Log a warning but don't fail the whole operation
+		slog.Warn("failed to remove metadata file", "path", metaPath, "error", err)
 	}
 
-	return os.WriteFile(metaPath, data, 0644)
+	return nil
+}
+
+// Restore restores a quarantined item like the package-level Restore and
+// records it in the audit log
+func (e *Eraser) Restore(meta Metadata) error {
+	if err := Restore(meta); err != nil {
+		return err
+	}
+	e.audit(ActionRestore, meta.OriginalPath, meta.QuarantinePath, meta.SizeBytes)
+	return nil
+}
+
+// Purge permanently deletes a quarantined item and its metadata file, and
+// records it in the audit log. The metadata is only removed once the item is
+// gone, so an item that failed to purge is still listed.
+func (e *Eraser) Purge(meta Metadata) error {
+	if err := os.RemoveAll(meta.QuarantinePath); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", meta.QuarantinePath, err)
+	}
+	metaPath := MetadataPath(meta.QuarantinePath)
+	if err := os.Remove(metaPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete metadata file %s: %w", metaPath, err)
+	}
+	e.audit(ActionPurge, meta.OriginalPath, meta.QuarantinePath, meta.SizeBytes)
+	return nil
 }
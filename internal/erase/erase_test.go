@@ -1,13 +1,17 @@
 package erase
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vbauerster/mpb/v8"
 	"github.com/yehia2amer/BuildBloatBuster/internal/config"
 	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
 )
@@ -39,6 +43,7 @@ func TestEraser_Quarantine(t *testing.T) {
 	defer cleanup()
 
 	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
 	cfg.Delete.QuarantineDir = quarantineDir
 	cfg.Delete.Mode = "quarantine"
 
@@ -56,23 +61,20 @@ func TestEraser_Quarantine(t *testing.T) {
 	assert.True(t, os.IsNotExist(err), "original directory should have been moved")
 
 	// 2. Check that something exists in quarantine
-	quarantineItems, err := os.ReadDir(quarantineDir)
-	require.NoError(t, err)
-	// Expecting one directory and one metadata file
-	assert.Len(t, quarantineItems, 2)
+	quarantineItems := quarantineEntries(t, quarantineDir)
+	// Expecting one directory, the metadata directory and the session log
+	assert.Len(t, quarantineItems, 3)
 
 	// 3. Find the metadata file and verify its content
-	var metaPath string
 	var quarantinedDir string
 	for _, item := range quarantineItems {
-		if filepath.Ext(item.Name()) == ".json" {
-			metaPath = filepath.Join(quarantineDir, item.Name())
-		} else {
+		if item.Name() != SessionLogName && item.Name() != MetadataDirName {
 			quarantinedDir = filepath.Join(quarantineDir, item.Name())
 		}
 	}
-	require.NotEmpty(t, metaPath, "metadata file should exist")
 	require.NotEmpty(t, quarantinedDir, "quarantined directory should exist")
+	metaPath := MetadataPath(quarantinedDir)
+	require.FileExists(t, metaPath, "metadata file should exist")
 
 	// 4. Verify metadata content
 	metaData, err := os.ReadFile(metaPath)
@@ -87,3 +89,459 @@ func TestEraser_Quarantine(t *testing.T) {
 	assert.NotZero(t, meta.Timestamp)
 	assert.Equal(t, int64(1024), meta.SizeBytes)
 }
+
+func TestQuarantineDestination(t *testing.T) {
+	now := time.Date(2024, 3, 9, 14, 5, 7, 0, time.Local)
+	assert.Equal(t, "20240309-140507-node_modules", QuarantineName(filepath.Join("app", "node_modules"), now))
+
+	quarantineDir := t.TempDir()
+	cfg := config.GetDefaults()
+	cfg.Delete.QuarantineDir = quarantineDir
+	candidate := scan.Candidate{Path: filepath.Join(t.TempDir(), "target")}
+
+	destPath, compressed := NewEraser(cfg).QuarantineDestination(candidate, now)
+	assert.Equal(t, filepath.Join(quarantineDir, "20240309-140507-target"), destPath)
+	assert.False(t, compressed)
+
+	cfg.Delete.Compress = true
+	eraser := NewEraser(cfg)
+	destPath, compressed = eraser.QuarantineDestination(candidate, now)
+	assert.Equal(t, filepath.Join(quarantineDir, "20240309-140507-target.tar.gz"), destPath)
+	assert.True(t, compressed)
+
+	// A name handed out before, or taken by an item or a metadata file, gets a counter
+	destPath, _ = eraser.QuarantineDestination(candidate, now)
+	assert.Equal(t, filepath.Join(quarantineDir, "20240309-140507-target-2.tar.gz"), destPath)
+	require.NoError(t, os.Mkdir(filepath.Join(quarantineDir, MetadataDirName), 0755))
+	require.NoError(t, os.WriteFile(MetadataPath(filepath.Join(quarantineDir, "20240309-140507-target-3.tar.gz")), nil, 0644))
+	destPath, _ = eraser.QuarantineDestination(candidate, now)
+	assert.Equal(t, filepath.Join(quarantineDir, "20240309-140507-target-4.tar.gz"), destPath)
+}
+
+func TestEraser_QuarantineSameName(t *testing.T) {
+	root := t.TempDir()
+	quarantineDir := filepath.Join(root, "quarantine")
+	var candidates []scan.Candidate
+	for _, project := range []string{"web", "api"} {
+		target := filepath.Join(root, project, "node_modules")
+		require.NoError(t, os.MkdirAll(target, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(target, project+".js"), nil, 0644))
+		candidates = append(candidates, scan.Candidate{Path: target})
+	}
+
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = ""
+	cfg.Delete.QuarantineDir = quarantineDir
+	cfg.Delete.Mode = "quarantine"
+	eraser := NewEraser(cfg)
+	require.NoError(t, eraser.EraseCandidates(candidates))
+	assert.Len(t, eraser.Removed(), 2)
+
+	// Both are quarantined, under distinct names, each with its own metadata
+	session, err := LoadLastSession(quarantineDir)
+	require.NoError(t, err)
+	require.Len(t, session.Items, 2)
+	assert.NotEqual(t, session.Items[0].QuarantinePath, session.Items[1].QuarantinePath)
+	for _, item := range session.Items {
+		project := filepath.Base(filepath.Dir(item.OriginalPath))
+		assert.FileExists(t, filepath.Join(item.QuarantinePath, project+".js"))
+
+		data, err := os.ReadFile(MetadataPath(item.QuarantinePath))
+		require.NoError(t, err)
+		var meta Metadata
+		require.NoError(t, json.Unmarshal(data, &meta))
+		assert.Equal(t, item.OriginalPath, meta.OriginalPath)
+	}
+}
+
+func TestEraser_QuarantineRollsBack(t *testing.T) {
+	originalRename := renameMetadata
+	t.Cleanup(func() { renameMetadata = originalRename })
+	renameMetadata = func(oldPath, newPath string) error {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrPermission}
+	}
+
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{"moved", false},
+		{"compressed", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dummyPath, quarantineDir, cleanup := setupEraseTest(t)
+			defer cleanup()
+
+			cfg := config.GetDefaults()
+			cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+			cfg.Delete.QuarantineDir = quarantineDir
+			cfg.Delete.Compress = tt.compress
+			cfg.Delete.Checksum = true
+			eraser := NewEraser(cfg)
+			require.NoError(t, eraser.EraseCandidates([]scan.Candidate{{Path: dummyPath, SizeBytes: 1024}}))
+
+			// The metadata couldn't be put in place, so the directory is back
+			// where it was and nothing is left in the quarantine
+			assert.FileExists(t, filepath.Join(dummyPath, "some-file.js"))
+			assert.Empty(t, eraser.Removed())
+			for _, entry := range quarantineEntries(t, quarantineDir) {
+				assert.Equal(t, MetadataDirName, entry.Name(), "only the metadata directory may remain")
+			}
+			assert.Empty(t, quarantineEntries(t, filepath.Join(quarantineDir, MetadataDirName)))
+			session, err := LoadLastSession(quarantineDir)
+			require.NoError(t, err)
+			assert.Nil(t, session, "a rolled back item can't be undone")
+		})
+	}
+}
+
+func TestEraser_QuarantineProgress(t *testing.T) {
+	root := t.TempDir()
+	quarantineDir := filepath.Join(root, "quarantine")
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = ""
+	cfg.Delete.QuarantineDir = quarantineDir
+
+	var candidates []scan.Candidate
+	for _, project := range []string{"web", "api", "docs"} {
+		path := filepath.Join(root, project, "node_modules")
+		require.NoError(t, os.MkdirAll(path, 0755))
+		candidates = append(candidates, scan.Candidate{Path: path, SizeBytes: 1536})
+	}
+	// A protected path is skipped, but still counted as done
+	candidates = append(candidates, scan.Candidate{Path: "/"})
+
+	var out bytes.Buffer
+	p := mpb.New(mpb.WithOutput(&out), mpb.WithWidth(100), mpb.WithAutoRefresh())
+	eraser := NewEraser(cfg)
+	eraser.SetProgress(p)
+	require.NoError(t, eraser.EraseCandidates(candidates))
+	p.Wait()
+
+	assert.Len(t, eraser.Removed(), 3)
+	for _, candidate := range candidates[:3] {
+		assert.NoDirExists(t, candidate.Path)
+	}
+	session, err := LoadLastSession(quarantineDir)
+	require.NoError(t, err)
+	assert.Len(t, session.Items, 3)
+
+	// The moves are listed above the bar, which ends complete
+	assert.Contains(t, out.String(), " - Quarantining "+candidates[0].Path)
+	assert.Contains(t, out.String(), "Quarantining 4 / 4")
+	assert.Contains(t, out.String(), "4.5 KiB freed")
+}
+
+func TestEraser_RefusesHomeDir(t *testing.T) {
+	_, quarantineDir, cleanup := setupEraseTest(t)
+	defer cleanup()
+
+	// Point the home directory at a throwaway location so a failure can't hurt
+	fakeHome := t.TempDir()
+	t.Setenv("HOME", fakeHome)
+	t.Setenv("USERPROFILE", fakeHome)
+
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Delete.QuarantineDir = quarantineDir
+	cfg.Delete.Mode = "quarantine"
+
+	err := NewEraser(cfg).EraseCandidates([]scan.Candidate{{Path: fakeHome}})
+	require.NoError(t, err)
+
+	_, err = os.Stat(fakeHome)
+	assert.NoError(t, err, "home directory must not be moved")
+	quarantineItems := quarantineEntries(t, quarantineDir)
+	assert.Empty(t, quarantineItems)
+}
+
+func TestEraser_RefusesQuarantineDir(t *testing.T) {
+	for _, mode := range []string{"quarantine", "rm"} {
+		t.Run(mode, func(t *testing.T) {
+			// The quarantine lies inside the workspace that was scanned
+			workspace := t.TempDir()
+			quarantineDir := filepath.Join(workspace, ".bbb-trash")
+			quarantined := filepath.Join(quarantineDir, "20240101-120000-node_modules")
+			require.NoError(t, os.MkdirAll(filepath.Join(quarantined, "pkg", "node_modules"), 0755))
+
+			cfg := config.GetDefaults()
+			cfg.Delete.AuditLog = ""
+			cfg.Delete.QuarantineDir = quarantineDir
+			cfg.Delete.Mode = mode
+
+			eraser := NewEraser(cfg)
+			require.NoError(t, eraser.EraseCandidates([]scan.Candidate{
+				{Path: filepath.Join(quarantined, "pkg", "node_modules")},
+				{Path: quarantined},
+				{Path: quarantineDir},
+				{Path: workspace},
+			}))
+			assert.Empty(t, eraser.Removed())
+			assert.DirExists(t, filepath.Join(quarantined, "pkg", "node_modules"))
+		})
+	}
+}
+
+func TestUndoLastSession(t *testing.T) {
+	dummyPath, quarantineDir, cleanup := setupEraseTest(t)
+	defer cleanup()
+
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Delete.QuarantineDir = quarantineDir
+	cfg.Delete.Mode = "quarantine"
+
+	err := NewEraser(cfg).EraseCandidates([]scan.Candidate{{Path: dummyPath, SizeBytes: 1024}})
+	require.NoError(t, err)
+
+	session, err := LoadLastSession(quarantineDir)
+	require.NoError(t, err)
+	require.NotNil(t, session)
+	require.Len(t, session.Items, 1)
+	assert.Equal(t, dummyPath, session.Items[0].OriginalPath)
+
+	restored, skipped, err := NewEraser(cfg).UndoLastSession()
+	require.NoError(t, err)
+	assert.Len(t, restored, 1)
+	assert.Empty(t, skipped)
+
+	// The directory is back and the quarantine is empty, including the
+	// session log; only the metadata directory is left, with nothing in it
+	_, err = os.Stat(filepath.Join(dummyPath, "some-file.js"))
+	assert.NoError(t, err)
+	quarantineItems := quarantineEntries(t, quarantineDir)
+	require.Len(t, quarantineItems, 1)
+	assert.Equal(t, MetadataDirName, quarantineItems[0].Name())
+	assert.Empty(t, quarantineEntries(t, filepath.Join(quarantineDir, MetadataDirName)))
+
+	session, err = LoadLastSession(quarantineDir)
+	require.NoError(t, err)
+	assert.Nil(t, session)
+}
+
+func TestUndoLastSession_SkipsItemsGoneSince(t *testing.T) {
+	tmpDir := t.TempDir()
+	quarantineDir := filepath.Join(tmpDir, "quarantine")
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = filepath.Join(tmpDir, "audit.log")
+	cfg.Delete.QuarantineDir = quarantineDir
+	cfg.Delete.Mode = "quarantine"
+
+	var candidates []scan.Candidate
+	for _, name := range []string{"node_modules", "target", "dist"} {
+		path := filepath.Join(tmpDir, "app", name)
+		require.NoError(t, os.MkdirAll(path, 0755))
+		candidates = append(candidates, scan.Candidate{Path: path, SizeBytes: 1024})
+	}
+	eraser := NewEraser(cfg)
+	require.NoError(t, eraser.EraseCandidates(candidates))
+	session, err := LoadLastSession(quarantineDir)
+	require.NoError(t, err)
+	require.Len(t, session.Items, 3)
+	items := make(map[string]Metadata)
+	for _, item := range session.Items {
+		items[filepath.Base(item.OriginalPath)] = item
+	}
+
+	// One item is restored and another purged before the undo
+	require.NoError(t, eraser.Restore(items["node_modules"]))
+	require.NoError(t, eraser.Purge(items["target"]))
+
+	restored, skipped, err := eraser.UndoLastSession()
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Equal(t, items["dist"].OriginalPath, restored[0].OriginalPath)
+	assert.Len(t, skipped, 2)
+	assert.DirExists(t, items["dist"].OriginalPath)
+
+	// The log is cleared, so a later undo has nothing left to do
+	session, err = LoadLastSession(quarantineDir)
+	require.NoError(t, err)
+	assert.Nil(t, session)
+}
+
+func TestEraser_CompressRoundTrip(t *testing.T) {
+	dummyPath, quarantineDir, cleanup := setupEraseTest(t)
+	defer cleanup()
+
+	// Build a multi-file tree with nested directories
+	files := map[string]string{
+		"some-file.js":             "",
+		"pkg/index.js":             "module.exports = 1\n",
+		"pkg/lib/deep/util.js":     "export const x = 2\n",
+		"pkg/README.md":            "# readme\n",
+		".bin/another-tool-script": "#!/bin/sh\necho hi\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dummyPath, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Delete.QuarantineDir = quarantineDir
+	cfg.Delete.Mode = "quarantine"
+	cfg.Delete.Compress = true
+
+	require.NoError(t, NewEraser(cfg).EraseCandidates([]scan.Candidate{{Path: dummyPath, SizeBytes: 1024}}))
+
+	_, err := os.Stat(dummyPath)
+	assert.True(t, os.IsNotExist(err), "original directory should have been removed")
+
+	session, err := LoadLastSession(quarantineDir)
+	require.NoError(t, err)
+	require.Len(t, session.Items, 1)
+	meta := session.Items[0]
+	assert.True(t, meta.Compressed)
+	assert.True(t, strings.HasSuffix(meta.ArchivePath, "-node_modules.tar.gz"))
+	_, err = os.Stat(meta.ArchivePath)
+	require.NoError(t, err, "archive should exist")
+	_, err = os.Stat(MetadataPath(meta.ArchivePath))
+	require.NoError(t, err, "metadata should exist")
+
+	require.NoError(t, Restore(meta))
+
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(dummyPath, filepath.FromSlash(name)))
+		require.NoError(t, err, name)
+		assert.Equal(t, content, string(data), name)
+	}
+	_, err = os.Stat(meta.ArchivePath)
+	assert.True(t, os.IsNotExist(err), "archive should be removed after restore")
+}
+
+func TestEraser_AuditLog(t *testing.T) {
+	for _, mode := range []string{"quarantine", "rm"} {
+		t.Run(mode, func(t *testing.T) {
+			_, quarantineDir, cleanup := setupEraseTest(t)
+			defer cleanup()
+
+			projects := t.TempDir()
+			var candidates []scan.Candidate
+			for i, name := range []string{"node_modules", "target", "dist"} {
+				path := filepath.Join(projects, name)
+				require.NoError(t, os.MkdirAll(path, 0755))
+				candidates = append(candidates, scan.Candidate{Path: path, SizeBytes: int64(i+1) * 1024})
+			}
+
+			cfg := config.GetDefaults()
+			cfg.Delete.QuarantineDir = quarantineDir
+			cfg.Delete.Mode = mode
+			cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+
+			require.NoError(t, NewEraser(cfg).EraseCandidates(candidates))
+
+			data, err := os.ReadFile(cfg.Delete.AuditLog)
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			require.Len(t, lines, len(candidates), "one line per deleted candidate")
+
+			for i, line := range lines {
+				var entry AuditEntry
+				require.NoError(t, json.Unmarshal([]byte(line), &entry), "line %d should be valid JSON", i)
+				assert.Equal(t, candidates[i].Path, entry.OriginalPath)
+				assert.Equal(t, candidates[i].SizeBytes, entry.SizeBytes)
+				assert.Equal(t, mode, entry.Action)
+				assert.Equal(t, mode, entry.Mode)
+				assert.NotZero(t, entry.Timestamp)
+				assert.NotEmpty(t, entry.User)
+				assert.NotEmpty(t, entry.Hostname)
+				assert.Equal(t, RunID(), entry.RunID)
+				assert.Equal(t, mode == "quarantine", entry.QuarantinePath != "")
+
+				_, err := os.Stat(candidates[i].Path)
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+func TestEraser_AuditRestoreAndPurge(t *testing.T) {
+	_, quarantineDir, cleanup := setupEraseTest(t)
+	defer cleanup()
+
+	projects := t.TempDir()
+	var candidates []scan.Candidate
+	for _, name := range []string{"node_modules", "target"} {
+		path := filepath.Join(projects, name)
+		require.NoError(t, os.MkdirAll(path, 0755))
+		candidates = append(candidates, scan.Candidate{Path: path, SizeBytes: 2048})
+	}
+
+	cfg := config.GetDefaults()
+	cfg.Delete.QuarantineDir = quarantineDir
+	cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	eraser := NewEraser(cfg)
+	require.NoError(t, eraser.EraseCandidates(candidates))
+
+	session, err := LoadLastSession(quarantineDir)
+	require.NoError(t, err)
+	require.Len(t, session.Items, 2)
+	require.NoError(t, eraser.Restore(session.Items[0]))
+	require.NoError(t, eraser.Purge(session.Items[1]))
+
+	// The purged item and its metadata are gone
+	_, err = os.Stat(session.Items[1].QuarantinePath)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(MetadataPath(session.Items[1].QuarantinePath))
+	assert.True(t, os.IsNotExist(err))
+
+	entries, err := LoadAuditLog(cfg.Delete.AuditLog)
+	require.NoError(t, err)
+	var actions, paths []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+		paths = append(paths, entry.OriginalPath)
+	}
+	assert.Equal(t, []string{ActionQuarantine, ActionQuarantine, ActionRestore, ActionPurge}, actions)
+	assert.Equal(t, []string{candidates[0].Path, candidates[1].Path, candidates[0].Path, candidates[1].Path}, paths)
+	assert.Empty(t, entries[2].Mode, "only quarantine and rm entries have a delete mode")
+}
+
+func TestChecksum(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "index.js"), []byte("module.exports = 1"), 0644))
+
+	original, err := Checksum(dir)
+	require.NoError(t, err)
+	again, err := Checksum(dir)
+	require.NoError(t, err)
+	assert.Equal(t, original, again, "checksum should be stable")
+
+	changes := map[string]func(t *testing.T){
+		"modified": func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "index.js"), []byte("module.exports = 2"), 0644))
+		},
+		"renamed": func(t *testing.T) {
+			require.NoError(t, os.Rename(filepath.Join(dir, "lib", "index.js"), filepath.Join(dir, "lib", "main.js")))
+		},
+		"added": func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "extra"), nil, 0644))
+		},
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			change(t)
+			sum, err := Checksum(dir)
+			require.NoError(t, err)
+			assert.NotEqual(t, original, sum)
+		})
+	}
+}
+
+// quarantineEntries lists quarantineDir, leaving out its lock file
+func quarantineEntries(t *testing.T, quarantineDir string) []os.DirEntry {
+	t.Helper()
+	entries, err := os.ReadDir(quarantineDir)
+	require.NoError(t, err)
+	var items []os.DirEntry
+	for _, entry := range entries {
+		if entry.Name() != QuarantineLockName {
+			items = append(items, entry)
+		}
+	}
+	return items
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package erase
+
+// FreeSpace has no portable statfs to query on this platform, so the free
+// space is always unknown
+func FreeSpace(path string) (uint64, bool) {
+	return 0, false
+}
+
+// DeviceID has no device number to report on this platform either
+func DeviceID(path string) (uint64, bool) {
+	return 0, false
+}
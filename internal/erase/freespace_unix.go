@@ -0,0 +1,25 @@
+//go:build linux || darwin
+
+package erase
+
+import "golang.org/x/sys/unix"
+
+// FreeSpace returns the bytes available to unprivileged users on the
+// filesystem holding path, or false if it can't be determined
+func FreeSpace(path string) (uint64, bool) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return 0, false
+	}
+	return fs.Bavail * uint64(fs.Bsize), true
+}
+
+// DeviceID returns the device of the filesystem holding path, or false if it
+// can't be determined. Paths with the same device share their free space.
+func DeviceID(path string) (uint64, bool) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
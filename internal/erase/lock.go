@@ -0,0 +1,41 @@
+package erase
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/filelock"
+)
+
+// QuarantineLockName is the lock file in the quarantine directory that keeps
+// two runs from moving items and writing metadata there at the same time
+const QuarantineLockName = ".lock"
+
+// quarantineLockWait is how long LockQuarantine waits for another run to finish
+var quarantineLockWait = 5 * time.Second
+
+// LockQuarantine takes the exclusive lock on quarantineDir, which commands
+// that add, restore or purge items hold until they are done. If another run
+// keeps holding it, it fails with an error naming that run's pid. Once locked,
+// metadata written by older versions is moved into place.
+func LockQuarantine(quarantineDir string) (*filelock.Lock, error) {
+	path := filepath.Join(quarantineDir, QuarantineLockName)
+	lock, err := filelock.TryAcquire(path, quarantineLockWait)
+	if errors.Is(err, filelock.ErrLocked) {
+		holder := "another instance"
+		if pid := filelock.HolderPID(path); pid > 0 {
+			holder = fmt.Sprintf("another instance (pid %d)", pid)
+		}
+		return nil, fmt.Errorf("%s is running and using the quarantine directory %s; try again once it has finished", holder, quarantineDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock the quarantine directory: %w", err)
+	}
+	if err := MigrateLegacyMetadata(quarantineDir); err != nil {
+		lock.Release()
+		return nil, err
+	}
+	return lock, nil
+}
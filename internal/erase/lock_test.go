@@ -0,0 +1,45 @@
+package erase
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockQuarantine(t *testing.T) {
+	quarantineDir := filepath.Join(t.TempDir(), "quarantine")
+	originalWait := quarantineLockWait
+	t.Cleanup(func() { quarantineLockWait = originalWait })
+	quarantineLockWait = 0
+
+	lock, err := LockQuarantine(quarantineDir)
+	require.NoError(t, err)
+
+	// A second run gives up, naming the run holding the lock
+	_, err = LockQuarantine(quarantineDir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("another instance (pid %d) is running", os.Getpid()))
+
+	require.NoError(t, lock.Release())
+	lock, err = LockQuarantine(quarantineDir)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+}
+
+func TestLockQuarantine_ReclaimsStaleLock(t *testing.T) {
+	quarantineDir := t.TempDir()
+	// A lock file left behind by a run that crashed holds no lock
+	lockPath := filepath.Join(quarantineDir, QuarantineLockName)
+	require.NoError(t, os.WriteFile(lockPath, []byte("999999\n"), 0644))
+
+	lock, err := LockQuarantine(quarantineDir)
+	require.NoError(t, err)
+	defer lock.Release()
+	data, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%d\n", os.Getpid()), string(data))
+}
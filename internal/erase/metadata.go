@@ -0,0 +1,92 @@
+package erase
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MetadataDirName is the directory in the quarantine directory that holds the
+// metadata files. Keeping them apart from the items means no item, whatever
+// its name, can be mistaken for metadata.
+const MetadataDirName = ".meta"
+
+// legacyMetadataSuffix ends the metadata files that older versions wrote next
+// to their item in the quarantine directory
+const legacyMetadataSuffix = ".meta.json"
+
+// MetadataPath returns the path of the metadata file of the item at
+// quarantinePath
+func MetadataPath(quarantinePath string) string {
+	return filepath.Join(filepath.Dir(quarantinePath), MetadataDirName, filepath.Base(quarantinePath)+".json")
+}
+
+// IsLegacyMetadata reports whether entry, in the quarantine directory, is a
+// metadata file an older version wrote next to its item. Quarantined items
+// are directories, links or archives, so only regular files count.
+func IsLegacyMetadata(entry fs.DirEntry) bool {
+	return strings.HasSuffix(entry.Name(), legacyMetadataSuffix) && entry.Type().IsRegular()
+}
+
+// MetadataFiles returns the paths of the metadata files of quarantineDir:
+// those in MetadataDirName and those older versions wrote next to their item,
+// unless the item also has one in MetadataDirName. It only reads, so it needs
+// no lock on the quarantine.
+func MetadataFiles(quarantineDir string) ([]string, error) {
+	var paths []string
+	metaDir := filepath.Join(quarantineDir, MetadataDirName)
+	files, err := os.ReadDir(metaDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.Type().IsRegular() && strings.HasSuffix(file.Name(), ".json") {
+			paths = append(paths, filepath.Join(metaDir, file.Name()))
+		}
+	}
+
+	entries, err := os.ReadDir(quarantineDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !IsLegacyMetadata(entry) {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), legacyMetadataSuffix)
+		if _, err := os.Lstat(MetadataPath(filepath.Join(quarantineDir, name))); err == nil {
+			continue
+		}
+		paths = append(paths, filepath.Join(quarantineDir, entry.Name()))
+	}
+	return paths, nil
+}
+
+// MigrateLegacyMetadata moves metadata files written next to their item by
+// older versions into MetadataDirName. It renames files, so callers hold the
+// lock on the quarantine; LockQuarantine runs it once the lock is taken.
+func MigrateLegacyMetadata(quarantineDir string) error {
+	entries, err := os.ReadDir(quarantineDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if !IsLegacyMetadata(entry) {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), legacyMetadataSuffix)
+		metaPath := MetadataPath(filepath.Join(quarantineDir, name))
+		if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+			return fmt.Errorf("could not create metadata directory: %w", err)
+		}
+		if err := os.Rename(filepath.Join(quarantineDir, entry.Name()), metaPath); err != nil {
+			return fmt.Errorf("could not move metadata file %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
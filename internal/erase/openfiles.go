@@ -0,0 +1,65 @@
+package erase
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// errOpenFilesUnsupported is returned by OpenFiles on platforms where open
+// files can't be detected
+var errOpenFilesUnsupported = errors.New("detecting open files is not supported on this platform")
+
+// Process is a running process holding files open
+type Process struct {
+	PID  int
+	Name string
+}
+
+func (p Process) String() string {
+	return fmt.Sprintf("%s (pid %d)", p.Name, p.PID)
+}
+
+// openFile is a file, or working directory, a process has open
+type openFile struct {
+	process Process
+	path    string
+}
+
+// OpenFiles returns the processes that have a file open, or their working
+// directory, inside each of dirs, keyed by dir. Moving such a directory
+// away leaves dev servers and watchers with half-moved trees. Processes that
+// can't be inspected, such as other users' without privileges, are left out.
+func OpenFiles(dirs []string) (map[string][]Process, error) {
+	files, err := listOpenFiles()
+	if err != nil {
+		return nil, err
+	}
+	return matchOpenFiles(dirs, files), nil
+}
+
+// IsOpenFilesUnsupported reports whether err means OpenFiles can't detect
+// open files on this platform
+func IsOpenFilesUnsupported(err error) bool {
+	return errors.Is(err, errOpenFilesUnsupported)
+}
+
+// matchOpenFiles returns, for each of dirs, the processes with one of files
+// inside it, each process listed once
+func matchOpenFiles(dirs []string, files []openFile) map[string][]Process {
+	holders := make(map[string][]Process)
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		prefix := strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+		seen := make(map[int]bool)
+		for _, file := range files {
+			if seen[file.process.PID] || (file.path != dir && !strings.HasPrefix(file.path, prefix)) {
+				continue
+			}
+			seen[file.process.PID] = true
+			holders[dir] = append(holders[dir], file.process)
+		}
+	}
+	return holders
+}
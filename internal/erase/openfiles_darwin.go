@@ -0,0 +1,48 @@
+package erase
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// listOpenFiles asks lsof for the open files of every process; reading them
+// directly needs libproc, which isn't reachable without cgo
+func listOpenFiles() ([]openFile, error) {
+	// lsof exits non-zero when it couldn't inspect some processes, yet
+	// still lists the others
+	out, err := exec.Command("lsof", "-n", "-P", "-w", "-F", "pcn").Output()
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("lsof failed: %w", err)
+	}
+	return parseLsof(out), nil
+}
+
+// parseLsof reads the field output of lsof -F pcn: a p line starts each
+// process, followed by its c line and an n line for each open file
+func parseLsof(out []byte) []openFile {
+	var files []openFile
+	var process Process
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		value := line[1:]
+		switch line[0] {
+		case 'p':
+			pid, _ := strconv.Atoi(value)
+			process = Process{PID: pid}
+		case 'c':
+			process.Name = value
+		case 'n':
+			if len(value) > 0 && value[0] == '/' {
+				files = append(files, openFile{process: process, path: value})
+			}
+		}
+	}
+	return files
+}
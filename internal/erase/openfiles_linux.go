@@ -0,0 +1,46 @@
+package erase
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// listOpenFiles reads the open file descriptors and working directory of
+// every process from /proc, as lsof does
+func listOpenFiles() ([]openFile, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []openFile
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		procDir := filepath.Join("/proc", entry.Name())
+		name, _ := os.ReadFile(filepath.Join(procDir, "comm"))
+		process := Process{PID: pid, Name: strings.TrimSpace(string(name))}
+
+		if cwd, err := os.Readlink(filepath.Join(procDir, "cwd")); err == nil {
+			files = append(files, openFile{process: process, path: cwd})
+		}
+		// Other users' processes can't be read without privileges
+		fds, err := os.ReadDir(filepath.Join(procDir, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(procDir, "fd", fd.Name()))
+			if err != nil || !filepath.IsAbs(target) {
+				// Sockets, pipes and the like
+				continue
+			}
+			files = append(files, openFile{process: process, path: strings.TrimSuffix(target, " (deleted)")})
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,64 @@
+package erase
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFiles(t *testing.T) {
+	root := t.TempDir()
+	held := filepath.Join(root, "held", "node_modules")
+	working := filepath.Join(root, "working", "node_modules")
+	idle := filepath.Join(root, "idle", "node_modules")
+	for _, dir := range []string{filepath.Join(held, ".cache"), working, idle} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+
+	// A file open deep inside, and a process working in a directory
+	file, err := os.Create(filepath.Join(held, ".cache", "lock"))
+	require.NoError(t, err)
+	defer file.Close()
+	sleep := exec.Command("sleep", "30")
+	sleep.Dir = working
+	require.NoError(t, sleep.Start())
+	t.Cleanup(func() {
+		sleep.Process.Kill()
+		sleep.Wait()
+	})
+
+	holders, err := OpenFiles([]string{held, working, idle})
+	require.NoError(t, err)
+	assert.Contains(t, holders[held], Process{PID: os.Getpid(), Name: readComm(t, os.Getpid())})
+	assert.Contains(t, holders[working], Process{PID: sleep.Process.Pid, Name: "sleep"})
+	assert.Empty(t, holders[idle])
+}
+
+func TestMatchOpenFiles(t *testing.T) {
+	node := Process{PID: 10, Name: "node"}
+	vite := Process{PID: 11, Name: "vite"}
+	files := []openFile{
+		{process: node, path: "/p/app/node_modules/a.js"},
+		{process: node, path: "/p/app/node_modules/b.js"},
+		{process: vite, path: "/p/app/node_modules"},
+		{process: vite, path: "/p/app/node_modules_old/c.js"},
+	}
+
+	holders := matchOpenFiles([]string{"/p/app/node_modules/", "/p/other"}, files)
+	// Each process is listed once, and a sibling sharing the prefix doesn't count
+	assert.Equal(t, map[string][]Process{"/p/app/node_modules": {node, vite}}, holders)
+}
+
+// readComm returns the process name the kernel reports for pid
+func readComm(t *testing.T, pid int) string {
+	t.Helper()
+	comm, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	require.NoError(t, err)
+	return strings.TrimSpace(string(comm))
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package erase
+
+// listOpenFiles has no way to find open files on this platform
+func listOpenFiles() ([]openFile, error) {
+	return nil, errOpenFilesUnsupported
+}
@@ -0,0 +1,49 @@
+package erase
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+)
+
+// SetProgress makes quarantining draw a progress bar in p, with the items
+// moved so far and the space they took. The lines naming each item are then
+// printed above the bar instead of to stdout. Without it no progress is
+// shown, so callers decide whether and where progress can be drawn. The
+// caller must wait for p.
+func (e *Eraser) SetProgress(p *mpb.Progress) {
+	e.progress = p
+}
+
+// output is where the eraser prints what it does: above the progress bar if
+// there is one, so the bar isn't torn by the lines, otherwise stdout
+func (e *Eraser) output() io.Writer {
+	if e.progress != nil {
+		return e.progress
+	}
+	return os.Stdout
+}
+
+// newQuarantineProgress starts the quarantine progress bar in p for total
+// items; freed is the size of the items moved so far
+func newQuarantineProgress(p *mpb.Progress, total int, freed *atomic.Int64) *mpb.Bar {
+	return p.New(int64(total),
+		mpb.BarStyle().Lbound("[").Filler("=").Tip(">").Padding("-").Rbound("]"),
+		mpb.PrependDecorators(
+			decor.Name("Quarantining "),
+			decor.CountersNoUnit("%d / %d"),
+		),
+		mpb.AppendDecorators(
+			decor.Any(func(decor.Statistics) string {
+				return bytesize.Format(freed.Load()) + " freed"
+			}),
+			decor.Name(" | "),
+			decor.Elapsed(decor.ET_STYLE_GO),
+		),
+		mpb.BarWidth(60),
+	)
+}
@@ -0,0 +1,108 @@
+package erase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SessionLogName is the file in the quarantine directory that records the
+// items quarantined by the most recent clean run.
+const SessionLogName = "last-run.json"
+
+// Session lists the items quarantined by a single clean run.
+type Session struct {
+	Timestamp time.Time  `json:"timestamp"`
+	Items     []Metadata `json:"items"`
+}
+
+// writeSessionLog records items as the most recent run, replacing any previous log.
+func writeSessionLog(quarantineDir string, items []Metadata) error {
+	session := Session{Timestamp: time.Now(), Items: items}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session log: %w", err)
+	}
+	return os.WriteFile(filepath.Join(quarantineDir, SessionLogName), data, 0644)
+}
+
+// LoadLastSession reads the session log of the most recent run.
+// It returns nil without error if there is nothing to undo.
+func LoadLastSession(quarantineDir string) (*Session, error) {
+	data, err := os.ReadFile(filepath.Join(quarantineDir, SessionLogName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("could not parse session log: %w", err)
+	}
+	return &session, nil
+}
+
+// UndoLastSession restores every item of the most recent run. Items that can't
+// be restored stay in the session log so the undo can be retried; once all are
+// back the log is removed. Items restored or purged on their own since the run
+// are left out of the log as skipped. It returns the restored and skipped items.
+func (e *Eraser) UndoLastSession() (restored, skipped []Metadata, err error) {
+	quarantineDir := e.cfg.Delete.QuarantineDir
+	session, err := LoadLastSession(quarantineDir)
+	if err != nil || session == nil {
+		return nil, nil, err
+	}
+
+	var remaining []Metadata
+	var errs []error
+	for _, item := range session.Items {
+		if leftQuarantine(item) {
+			skipped = append(skipped, item)
+			continue
+		}
+		if err := e.Restore(item); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", item.OriginalPath, err))
+			remaining = append(remaining, item)
+			continue
+		}
+		restored = append(restored, item)
+	}
+
+	logPath := filepath.Join(quarantineDir, SessionLogName)
+	if len(remaining) == 0 {
+		if err := os.Remove(logPath); err != nil {
+			errs = append(errs, fmt.Errorf("failed to clear session log: %w", err))
+		}
+	} else {
+		session.Items = remaining
+		data, err := json.MarshalIndent(session, "", "  ")
+		if err == nil {
+			err = os.WriteFile(logPath, data, 0644)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to update session log: %w", err))
+		}
+	}
+
+	return restored, skipped, errors.Join(errs...)
+}
+
+// leftQuarantine reports whether an item of a session was restored or purged
+// since: neither the item nor its metadata is in the quarantine any more
+func leftQuarantine(meta Metadata) bool {
+	paths := []string{meta.QuarantinePath, MetadataPath(meta.QuarantinePath)}
+	if meta.ArchivePath != "" {
+		paths = append(paths, meta.ArchivePath)
+	}
+	for _, path := range paths {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package erase
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// errTrashUnsupported is returned by moveToTrash on platforms without a trash
+// it knows how to use
+var errTrashUnsupported = errors.New("the trash is not supported on this platform; use delete.mode quarantine instead")
+
+// trashCandidates moves candidates to the desktop trash, where they can be
+// restored with the file manager.
+func (e *Eraser) trashCandidates(candidates []scan.Candidate) error {
+	if !trashSupported {
+		return errTrashUnsupported
+	}
+	fmt.Printf("Moving %d directories to the trash...\n", len(candidates))
+
+	for _, candidate := range candidates {
+		if e.finished() {
+			break
+		}
+		if config.IsProtectedPath(candidate.Path) || config.IsHomeDir(candidate.Path) {
+			e.logger.Warn("refusing to trash protected path", "path", candidate.Path)
+			continue
+		}
+		if e.touchesQuarantine(candidate.Path) {
+			e.logger.Warn("refusing to trash the quarantine directory or an item in it", "path", candidate.Path)
+			continue
+		}
+
+		fmt.Printf(" - Trashing %s\n", candidate.Path)
+
+		// Like quarantining, this moves the path itself, so a symlink or
+		// junction candidate is trashed as a link and its target is kept
+		trashPath, err := moveToTrash(candidate.Path)
+		if err != nil {
+			e.logger.Warn("failed to move to the trash, it might be on a different device", "path", candidate.Path, "error", err)
+			continue
+		}
+		e.removed = append(e.removed, candidate)
+		e.audit(ActionTrash, candidate.Path, trashPath, candidate.SizeBytes)
+	}
+
+	fmt.Println("\nMoved to the trash.")
+	return nil
+}
@@ -0,0 +1,38 @@
+package erase
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const trashSupported = true
+
+// moveToTrash moves path into ~/.Trash, naming it like Finder does if the
+// name is taken, and returns the path of the item in the trash
+func moveToTrash(path string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	trash := filepath.Join(homeDir, ".Trash")
+	if err := os.MkdirAll(trash, 0700); err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(path)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s %d", base, n)
+		}
+		dest := filepath.Join(trash, name)
+		if _, err := os.Lstat(dest); err == nil {
+			continue
+		}
+		if err := os.Rename(path, dest); err != nil {
+			return "", err
+		}
+		return dest, nil
+	}
+}
@@ -0,0 +1,84 @@
+package erase
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const trashSupported = true
+
+// trashDir returns the user's trash as in the freedesktop.org trash
+// specification: $XDG_DATA_HOME/Trash, or ~/.local/share/Trash
+func trashDir() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" || !filepath.IsAbs(dataHome) {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// moveToTrash moves path into the trash and records where it came from in a
+// .trashinfo file, so file managers can put it back. It returns the path of
+// the item in the trash.
+func moveToTrash(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	trash, err := trashDir()
+	if err != nil {
+		return "", err
+	}
+	filesDir, infoDir := filepath.Join(trash, "files"), filepath.Join(trash, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: absPath}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	base := filepath.Base(absPath)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s.%d", base, n)
+		}
+		if _, err := os.Lstat(filepath.Join(filesDir, name)); err == nil {
+			continue
+		}
+
+		// Creating the info file first reserves the name, as the spec asks
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		file, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		_, err = file.WriteString(info)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(infoPath)
+			return "", err
+		}
+
+		dest := filepath.Join(filesDir, name)
+		if err := os.Rename(absPath, dest); err != nil {
+			os.Remove(infoPath)
+			return "", err
+		}
+		return dest, nil
+	}
+}
@@ -0,0 +1,52 @@
+package erase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestEraser_Trash(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	trash := filepath.Join(dataHome, "Trash")
+
+	cfg := config.GetDefaults()
+	cfg.Delete.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Delete.Mode = "trash"
+	eraser := NewEraser(cfg)
+
+	// Two directories with the same name get distinct names in the trash
+	var candidates []scan.Candidate
+	for _, project := range []string{"a", "b dir"} {
+		path := filepath.Join(t.TempDir(), project, "node_modules")
+		require.NoError(t, os.MkdirAll(path, 0755))
+		candidates = append(candidates, scan.Candidate{Path: path, SizeBytes: 1024})
+	}
+	require.NoError(t, eraser.EraseCandidates(candidates))
+	assert.Len(t, eraser.Removed(), 2)
+
+	for i, name := range []string{"node_modules", "node_modules.2"} {
+		assert.NoDirExists(t, candidates[i].Path)
+		assert.DirExists(t, filepath.Join(trash, "files", name))
+
+		info, err := os.ReadFile(filepath.Join(trash, "info", name+".trashinfo"))
+		require.NoError(t, err)
+		assert.Contains(t, string(info), "[Trash Info]\nPath=")
+		assert.Contains(t, string(info), "\nDeletionDate=")
+	}
+	info, err := os.ReadFile(filepath.Join(trash, "info", "node_modules.2.trashinfo"))
+	require.NoError(t, err)
+	assert.Contains(t, string(info), "/b%20dir/node_modules\n", "the original path is URL-escaped")
+
+	entries, err := LoadAuditLog(cfg.Delete.AuditLog)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, ActionTrash, entries[0].Action)
+	assert.Equal(t, filepath.Join(trash, "files", "node_modules"), entries[0].QuarantinePath)
+}
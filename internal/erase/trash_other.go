@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package erase
+
+const trashSupported = false
+
+// moveToTrash has no trash to move to on this platform
+func moveToTrash(path string) (string, error) {
+	return "", errTrashUnsupported
+}
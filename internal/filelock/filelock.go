@@ -0,0 +1,104 @@
+// Package filelock provides exclusive advisory locks on files, used to
+// serialise access to state shared between concurrent runs.
+package filelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned by TryAcquire when another process kept holding the
+// lock for as long as it waited
+var ErrLocked = errors.New("lock is held by another process")
+
+// pollInterval is how often TryAcquire tries to take a held lock again
+const pollInterval = 100 * time.Millisecond
+
+// Lock is an exclusive lock held on a file
+type Lock struct {
+	file *os.File
+}
+
+// Acquire opens path, creating it and its directory if needed, and blocks
+// until it holds an exclusive lock on it.
+func Acquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Lock{file: file}, nil
+}
+
+// TryAcquire is like Acquire, but waits at most wait for the lock before
+// giving up with ErrLocked. Once held, the lock file records the pid of this
+// process, for HolderPID. The operating system releases the lock of a process
+// that exits, even by crashing, so a lock file it left behind is reclaimed.
+func TryAcquire(path string, wait time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrLocked
+		}
+		time.Sleep(pollInterval)
+	}
+
+	// The pid only helps to tell who holds the lock, so failing to record
+	// it is not an error
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{file: file}, nil
+}
+
+// HolderPID returns the pid TryAcquire recorded in the lock file at path, or
+// 0 if there is none or it can't be read, as on Windows while it is locked
+func HolderPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// Release unlocks and closes the lock file. The file itself is left in place
+// so other processes keep locking the same file.
+func (l *Lock) Release() error {
+	unlockErr := unlockFile(l.file)
+	closeErr := l.file.Close()
+	if unlockErr != nil {
+		return unlockErr
+	}
+	return closeErr
+}
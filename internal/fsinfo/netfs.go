@@ -0,0 +1,16 @@
+// Package fsinfo tells what kind of filesystem a path is on, for the scanner
+// and the size calculator alike.
+package fsinfo
+
+import "strings"
+
+// IsNetworkFS reports whether path is on a network filesystem such as NFS or
+// SMB. Walking those is slow, so the scanner skips them unless asked not to.
+func IsNetworkFS(path string) bool {
+	return isUNCPath(path) || statNetworkFS(path)
+}
+
+// isUNCPath recognises Windows network paths (\\server\share)
+func isUNCPath(path string) bool {
+	return strings.HasPrefix(path, `\\`) || strings.HasPrefix(path, "//")
+}
@@ -0,0 +1,24 @@
+//go:build darwin
+
+package fsinfo
+
+import "golang.org/x/sys/unix"
+
+// Filesystem type names of network filesystems as reported by statfs(2)
+var networkFilesystems = map[string]struct{}{
+	"nfs":    {},
+	"smbfs":  {},
+	"afpfs":  {},
+	"webdav": {},
+	"cifs":   {},
+}
+
+// statNetworkFS checks the filesystem type reported by statfs
+func statNetworkFS(path string) bool {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return false
+	}
+	_, ok := networkFilesystems[unix.ByteSliceToString(fs.Fstypename[:])]
+	return ok
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package fsinfo
+
+import "golang.org/x/sys/unix"
+
+// Filesystem magic numbers of network filesystems (see statfs(2))
+var networkFilesystems = map[uint32]struct{}{
+	0x6969:     {}, // NFS
+	0x517B:     {}, // SMB
+	0xFF534D42: {}, // CIFS
+	0xFE534D42: {}, // SMB2
+	0x5346414F: {}, // AFS
+	0x73757245: {}, // Coda
+}
+
+// statNetworkFS checks the filesystem type reported by statfs
+func statNetworkFS(path string) bool {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return false
+	}
+	_, ok := networkFilesystems[uint32(fs.Type)]
+	return ok
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package fsinfo
+
+// statNetworkFS has no filesystem type to check on this platform; network
+// shares are only recognised by their UNC path.
+func statNetworkFS(path string) bool {
+	return false
+}
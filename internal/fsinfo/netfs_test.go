@@ -0,0 +1,26 @@
+package fsinfo
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNetworkFS_LocalPath(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("filesystem type detection is only implemented on Linux and macOS")
+	}
+	dir := t.TempDir()
+	if IsNetworkFS(dir) {
+		t.Skipf("temp dir %s is on a network filesystem", dir)
+	}
+
+	// The working directory of the tests is a local checkout as well
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.False(t, IsNetworkFS(wd))
+	assert.True(t, IsNetworkFS(`\\server\share`))
+}
@@ -0,0 +1,40 @@
+// Package notify shows desktop notifications, so a long clean running in the
+// background can say when it is done.
+package notify
+
+import (
+	"fmt"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+)
+
+// Title is the title of every notification
+const Title = "BuildBloatBuster"
+
+// CleanSummary is the notification text for a finished clean, e.g.
+// "Freed 4.2 GiB across 37 directories"
+func CleanSummary(directories int, bytesFreed int64) string {
+	if directories == 0 {
+		return "Nothing was cleaned"
+	}
+	noun := "directories"
+	if directories == 1 {
+		noun = "directory"
+	}
+	return fmt.Sprintf("Freed %s across %d %s", bytesize.Format(bytesFreed), directories, noun)
+}
+
+// Send shows a desktop notification with the native tool of the platform:
+// notify-send on Linux, osascript on macOS and a PowerShell toast on
+// Windows. It returns an error if the tool is missing or fails, which
+// callers may ignore, as a notification is only a courtesy.
+func Send(title, message string) error {
+	cmd, err := command(title, message)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Path, err, out)
+	}
+	return nil
+}
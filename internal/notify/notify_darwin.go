@@ -0,0 +1,17 @@
+package notify
+
+import "os/exec"
+
+// command runs an AppleScript that displays the notification. The texts are
+// passed as arguments so quotes in them need no escaping.
+func command(title, message string) (*exec.Cmd, error) {
+	path, err := exec.LookPath("osascript")
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(path,
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		title, message), nil
+}
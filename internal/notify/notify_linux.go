@@ -0,0 +1,12 @@
+package notify
+
+import "os/exec"
+
+// command runs notify-send, which talks to the desktop's notification daemon
+func command(title, message string) (*exec.Cmd, error) {
+	path, err := exec.LookPath("notify-send")
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(path, "--app-name", Title, title, message), nil
+}
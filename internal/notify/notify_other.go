@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package notify
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// command has no notification tool to run on this platform
+func command(title, message string) (*exec.Cmd, error) {
+	return nil, errors.New("desktop notifications are not supported on this platform")
+}
@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanSummary(t *testing.T) {
+	tests := []struct {
+		name        string
+		directories int
+		bytesFreed  int64
+		want        string
+	}{
+		{"several", 37, 4_509_715_661, "Freed 4.2 GiB across 37 directories"},
+		{"one", 1, 1536, "Freed 1.5 KiB across 1 directory"},
+		{"none", 0, 0, "Nothing was cleaned"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CleanSummary(tt.directories, tt.bytesFreed))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"os"
+	"os/exec"
+)
+
+// toastScript shows a toast through the Windows Runtime notification API.
+// The texts come from environment variables so they need no escaping.
+const toastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:BBB_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:BBB_NOTIFY_MESSAGE)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('BuildBloatBuster').Show([Windows.UI.Notifications.ToastNotification]::new($template))`
+
+// command runs PowerShell to show a toast notification
+func command(title, message string) (*exec.Cmd, error) {
+	path, err := exec.LookPath("powershell")
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, "-NoProfile", "-NonInteractive", "-Command", toastScript)
+	cmd.Env = append(os.Environ(), "BBB_NOTIFY_TITLE="+title, "BBB_NOTIFY_MESSAGE="+message)
+	return cmd, nil
+}
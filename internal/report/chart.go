@@ -0,0 +1,70 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// chartMaxWidth is the widest a bar is drawn
+	chartMaxWidth = 40
+	// chartMinWidth is the narrowest bar worth drawing; with less room the
+	// bars are left out
+	chartMinWidth = 8
+	// defaultTerminalWidth is assumed when stdout is not a terminal and
+	// $COLUMNS is unset
+	defaultTerminalWidth = 80
+)
+
+// chartEighths draws the last, partial cell of a bar in eighths of a cell
+var chartEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// TerminalWidth returns how many columns the table may use: the width of the
+// terminal on stdout, $COLUMNS, or 80. Tests replace it.
+var TerminalWidth = func() int {
+	if width, ok := terminalWidth(); ok {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
+
+// sharePercent returns size as a percentage of total
+func sharePercent(size, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(size) * 100 / float64(total)
+}
+
+// formatShare formats a percentage for the SHARE column
+func formatShare(percent float64) string {
+	return fmt.Sprintf("%.1f%%", percent)
+}
+
+// chartBar draws a bar of up to width cells for size, scaled so that largest
+// fills the whole width. Non-empty sizes always get at least a sliver.
+func chartBar(size, largest int64, width int) string {
+	if largest <= 0 || size <= 0 || width <= 0 {
+		return ""
+	}
+	eighths := int(math.Round(float64(size) / float64(largest) * float64(width*8)))
+	eighths = max(1, min(eighths, width*8))
+	return strings.Repeat("█", eighths/8) + chartEighths[eighths%8]
+}
+
+// chartWidth returns how wide the bars may be next to a table whose other
+// columns take tableWidth columns, or 0 if the terminal is too narrow to fit
+// a useful bar
+func chartWidth(tableWidth int) int {
+	width := min(chartMaxWidth, TerminalWidth()-tableWidth)
+	if width < chartMinWidth {
+		return 0
+	}
+	return width
+}
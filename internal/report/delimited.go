@@ -0,0 +1,57 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// delimitedRecords returns the header and one record per candidate shared by
+// the delimited formats, CSV and TSV, so they always have the same columns
+func (r *Reporter) delimitedRecords(candidates []scan.Candidate) [][]string {
+	header := []string{"Path", "Size (Bytes)", "Size (Human)", "Reason", "Last Modified"}
+	if !r.sizeUnit.IsZero() {
+		header = append(header, fmt.Sprintf("Size (%s)", r.sizeUnit.Name))
+	}
+	// New columns go last so existing columns keep their positions
+	header = append(header, "ID", "Scan Root")
+
+	records := [][]string{header}
+	for _, candidate := range candidates {
+		record := []string{
+			candidate.Path,
+			fmt.Sprintf("%d", candidate.SizeBytes),
+			formatSize(candidate),
+			candidate.Reason,
+			candidate.NewestMTime.Format(time.RFC3339),
+		}
+		if !r.sizeUnit.IsZero() {
+			record = append(record, r.sizeUnit.Format(candidate.SizeBytes))
+		}
+		record = append(record, candidate.ID, candidate.ScanRoot)
+		records = append(records, record)
+	}
+	return records
+}
+
+// tsvEscaper escapes the characters that would break a TSV line, the way
+// tools such as PostgreSQL's COPY read them back
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// writeTSV writes records as tab-separated lines. Fields are never quoted;
+// backslashes, tabs and line breaks inside them are escaped instead.
+func writeTSV(w io.Writer, records [][]string) error {
+	for _, record := range records {
+		fields := make([]string, len(record))
+		for i, field := range record {
+			fields[i] = tsvEscaper.Replace(field)
+		}
+		if _, err := io.WriteString(w, strings.Join(fields, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
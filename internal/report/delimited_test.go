@@ -0,0 +1,85 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestDelimitedRecords(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	candidates := []scan.Candidate{
+		{ID: "a1b2c3d4", Path: "/p/app, v2/node_modules", ScanRoot: "/p", SizeBytes: 1536 * bytesize.MB, Reason: "node_modules", NewestMTime: mtime},
+	}
+
+	records := NewReporter([]string{"csv"}, "size", bytesize.Unit{}).delimitedRecords(candidates)
+	assert.Equal(t, [][]string{
+		{"Path", "Size (Bytes)", "Size (Human)", "Reason", "Last Modified", "ID", "Scan Root"},
+		{"/p/app, v2/node_modules", "1536000000", "1.4 GiB", "node_modules", "2024-05-01T12:00:00Z", "a1b2c3d4", "/p"},
+	}, records)
+
+	unit, err := bytesize.ParseUnit("MB")
+	require.NoError(t, err)
+	records = NewReporter([]string{"csv"}, "size", unit).delimitedRecords(candidates)
+	assert.Equal(t, []string{"Path", "Size (Bytes)", "Size (Human)", "Reason", "Last Modified", "Size (MB)", "ID", "Scan Root"}, records[0])
+	assert.Equal(t, "1536.0", records[1][5])
+}
+
+func TestWriteTSV(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeTSV(&out, [][]string{
+		{"Path", "Reason"},
+		{"/p/app, v2/node_modules", `matches "node_modules"`},
+		{"/p/odd\tname\nhere", `C:\build`},
+	}))
+	assert.Equal(t, "Path\tReason\n"+
+		"/p/app, v2/node_modules\tmatches \"node_modules\"\n"+
+		`/p/odd\tname\nhere`+"\t"+`C:\\build`+"\n", out.String())
+}
+
+func TestReporter_TSVAndCSVShareColumns(t *testing.T) {
+	candidates := []scan.Candidate{
+		{ID: "a1b2c3d4", Path: "/p/app, v2/node_modules", SizeBytes: 200000000, Reason: "node_modules", NewestMTime: time.Now()},
+	}
+	tmpDir := t.TempDir()
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	stdoutR, stdoutW, _ := os.Pipe()
+	stderrR, stderrW, _ := os.Pipe()
+	os.Stdout, os.Stderr = stdoutW, stderrW
+	err := NewReporter([]string{"tsv", "csv"}, "size", bytesize.Unit{}).Report(candidates, tmpDir)
+	stdoutW.Close()
+	stderrW.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	io.Copy(&stdout, stdoutR)
+	io.Copy(&stderr, stderrR)
+	assert.Contains(t, stderr.String(), "CSV report generated", "the TSV on stdout stays clean")
+
+	tsv := csv.NewReader(&stdout)
+	tsv.Comma = '\t'
+	tsvRecords, err := tsv.ReadAll()
+	require.NoError(t, err)
+
+	matches, err := filepath.Glob(filepath.Join(tmpDir, "BuildBloatBuster-report-*.csv"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	file, err := os.Open(matches[0])
+	require.NoError(t, err)
+	defer file.Close()
+	csvRecords, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+
+	assert.Equal(t, csvRecords, tsvRecords)
+}
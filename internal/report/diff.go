@@ -0,0 +1,164 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// Diff change kinds
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeGrown   = "grown"
+	ChangeShrunk  = "shrunk"
+)
+
+// DiffEntry describes how a single candidate changed between two snapshots.
+// OldSizeBytes is 0 for added candidates and NewSizeBytes is 0 for removed ones.
+type DiffEntry struct {
+	Path         string `json:"path"`
+	Change       string `json:"change"`
+	OldSizeBytes int64  `json:"oldSizeBytes"`
+	NewSizeBytes int64  `json:"newSizeBytes"`
+	DeltaBytes   int64  `json:"deltaBytes"`
+}
+
+// DiffCandidates compares two scan results by path. Candidates whose size is
+// unchanged are left out. Entries are ordered by the size of the change,
+// largest first.
+func DiffCandidates(oldCandidates, newCandidates []scan.Candidate) []DiffEntry {
+	oldSizes := make(map[string]int64, len(oldCandidates))
+	for _, c := range oldCandidates {
+		oldSizes[c.Path] = c.SizeBytes
+	}
+
+	var entries []DiffEntry
+	seen := make(map[string]struct{}, len(newCandidates))
+	for _, c := range newCandidates {
+		seen[c.Path] = struct{}{}
+		oldSize, existed := oldSizes[c.Path]
+		entry := DiffEntry{Path: c.Path, OldSizeBytes: oldSize, NewSizeBytes: c.SizeBytes, DeltaBytes: c.SizeBytes - oldSize}
+		switch {
+		case !existed:
+			entry.Change = ChangeAdded
+		case entry.DeltaBytes > 0:
+			entry.Change = ChangeGrown
+		case entry.DeltaBytes < 0:
+			entry.Change = ChangeShrunk
+		default:
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, c := range oldCandidates {
+		if _, ok := seen[c.Path]; ok {
+			continue
+		}
+		entries = append(entries, DiffEntry{Path: c.Path, Change: ChangeRemoved, OldSizeBytes: c.SizeBytes, DeltaBytes: -c.SizeBytes})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if abs(entries[i].DeltaBytes) != abs(entries[j].DeltaBytes) {
+			return abs(entries[i].DeltaBytes) > abs(entries[j].DeltaBytes)
+		}
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// DiffSummary is the comparison of two snapshots: the overall change in
+// reclaimable space and the changes of the individual candidates
+type DiffSummary struct {
+	OldTotalBytes int64 `json:"oldTotalBytes"`
+	NewTotalBytes int64 `json:"newTotalBytes"`
+	// DeltaBytes is the overall change, including changes below MinDeltaBytes
+	DeltaBytes int64 `json:"deltaBytes"`
+	// MinDeltaBytes is the smallest change listed in Changes; smaller ones
+	// are only counted in Hidden
+	MinDeltaBytes int64       `json:"minDeltaBytes"`
+	Count         int         `json:"count"`
+	Hidden        int         `json:"hidden"`
+	Changes       []DiffEntry `json:"changes"`
+}
+
+// DiffSnapshots compares two snapshots by path as DiffCandidates does, and
+// leaves out the changes smaller than minDelta in either direction
+func DiffSnapshots(oldSnapshot, newSnapshot Snapshot, minDelta int64) DiffSummary {
+	summary := DiffSummary{
+		OldTotalBytes: oldSnapshot.TotalSize,
+		NewTotalBytes: newSnapshot.TotalSize,
+		DeltaBytes:    newSnapshot.TotalSize - oldSnapshot.TotalSize,
+		MinDeltaBytes: minDelta,
+		Changes:       []DiffEntry{},
+	}
+	for _, entry := range DiffCandidates(oldSnapshot.Candidates, newSnapshot.Candidates) {
+		if abs(entry.DeltaBytes) < minDelta {
+			summary.Hidden++
+			continue
+		}
+		summary.Changes = append(summary.Changes, entry)
+	}
+	summary.Count = len(summary.Changes)
+	return summary
+}
+
+// ReportDiff displays a diff as a table or as JSON
+func ReportDiff(summary DiffSummary, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+
+	case "table":
+		hidden := ""
+		if summary.Hidden > 0 {
+			hidden = fmt.Sprintf(" (%d smaller than %s not shown)", summary.Hidden, bytesize.Format(summary.MinDeltaBytes))
+		}
+		if len(summary.Changes) == 0 {
+			fmt.Printf("No changes%s.\n", hidden)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer w.Flush()
+
+		fmt.Fprintln(w, "CHANGE\tDELTA\tOLD SIZE\tNEW SIZE\tPATH")
+		fmt.Fprintln(w, "------\t-----\t--------\t--------\t----")
+		for _, e := range summary.Changes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				e.Change, formatDelta(e.DeltaBytes), bytesize.Format(e.OldSizeBytes),
+				bytesize.Format(e.NewSizeBytes), truncatePath(e.Path, 60))
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "TOTAL:\t%s\t%s\t%s\t%d changes%s\n", formatDelta(summary.DeltaBytes),
+			bytesize.Format(summary.OldTotalBytes), bytesize.Format(summary.NewTotalBytes), len(summary.Changes), hidden)
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// formatDelta formats a size change with an explicit sign
+func formatDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + bytesize.Format(-delta)
+	}
+	return "+" + bytesize.Format(delta)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestDiffCandidates_Snapshots(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.json")
+	newPath := filepath.Join(dir, "new.json")
+
+	require.NoError(t, SaveSnapshot(oldPath, []scan.Candidate{
+		{Path: "/p/a/node_modules", SizeBytes: 100, Reason: "node_modules"},
+		{Path: "/p/b/target", SizeBytes: 500, Reason: "target"},
+		{Path: "/p/c/dist", SizeBytes: 300, Reason: "dist"},
+		{Path: "/p/d/.venv", SizeBytes: 50, Reason: ".venv"},
+	}))
+	require.NoError(t, SaveSnapshot(newPath, []scan.Candidate{
+		{Path: "/p/a/node_modules", SizeBytes: 400, Reason: "node_modules"},
+		{Path: "/p/c/dist", SizeBytes: 100, Reason: "dist"},
+		{Path: "/p/d/.venv", SizeBytes: 50, Reason: ".venv"},
+		{Path: "/p/e/build", SizeBytes: 20, Reason: "build"},
+	}))
+
+	oldSnapshot, err := LoadSnapshot(oldPath)
+	require.NoError(t, err)
+	newSnapshot, err := LoadSnapshot(newPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(950), oldSnapshot.TotalSize)
+
+	entries := DiffCandidates(oldSnapshot.Candidates, newSnapshot.Candidates)
+
+	// Unchanged candidates are omitted and the largest changes come first
+	assert.Equal(t, []DiffEntry{
+		{Path: "/p/b/target", Change: ChangeRemoved, OldSizeBytes: 500, NewSizeBytes: 0, DeltaBytes: -500},
+		{Path: "/p/a/node_modules", Change: ChangeGrown, OldSizeBytes: 100, NewSizeBytes: 400, DeltaBytes: 300},
+		{Path: "/p/c/dist", Change: ChangeShrunk, OldSizeBytes: 300, NewSizeBytes: 100, DeltaBytes: -200},
+		{Path: "/p/e/build", Change: ChangeAdded, OldSizeBytes: 0, NewSizeBytes: 20, DeltaBytes: 20},
+	}, entries)
+
+	// The threshold hides small changes but not from the overall change
+	summary := DiffSnapshots(oldSnapshot, newSnapshot, 250)
+	assert.Equal(t, int64(950), summary.OldTotalBytes)
+	assert.Equal(t, int64(570), summary.NewTotalBytes)
+	assert.Equal(t, int64(-380), summary.DeltaBytes)
+	assert.Equal(t, 2, summary.Count)
+	assert.Equal(t, 2, summary.Hidden)
+	assert.Equal(t, "/p/b/target", summary.Changes[0].Path)
+	assert.Equal(t, "/p/a/node_modules", summary.Changes[1].Path)
+}
+
+func TestLoadSnapshot_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	require.NoError(t, os.WriteFile(path, []byte("Found 3 directories"), 0644))
+
+	_, err := LoadSnapshot(path)
+	assert.ErrorContains(t, err, "not a valid snapshot")
+}
+
+func TestLoadSnapshot_Versions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("without version is version 1", func(t *testing.T) {
+		path := write("v1.json", `{"count": 9, "totalSizeBytes": 1, "candidates": [{"path": "/p/node_modules", "sizeBytes": 300, "future": true}]}`)
+		snapshot, err := LoadSnapshot(path)
+		require.NoError(t, err)
+		assert.Equal(t, 1, snapshot.Version)
+		assert.Equal(t, 1, snapshot.Count, "the count is derived from the candidates")
+		assert.Equal(t, int64(300), snapshot.TotalSize)
+		assert.Len(t, snapshot.Candidates[0].ID, 8, "older snapshots get IDs")
+	})
+
+	t.Run("bare list of candidates", func(t *testing.T) {
+		path := write("list.json", `[{"path": "/p/target", "sizeBytes": 70}]`)
+		snapshot, err := LoadSnapshot(path)
+		require.NoError(t, err)
+		require.Len(t, snapshot.Candidates, 1)
+		assert.Equal(t, int64(70), snapshot.TotalSize)
+	})
+
+	t.Run("text after the JSON", func(t *testing.T) {
+		path := write("redirected.json", "{\"version\": 2, \"candidates\": []}\n\nTotal time taken: 1.2s\n")
+		snapshot, err := LoadSnapshot(path)
+		require.NoError(t, err)
+		assert.Equal(t, 2, snapshot.Version)
+		assert.Empty(t, snapshot.Candidates)
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		path := write("v99.json", `{"version": 99, "candidates": []}`)
+		_, err := LoadSnapshot(path)
+		assert.ErrorContains(t, err, "version 99 snapshot, newer than")
+	})
+
+	t.Run("object without candidates", func(t *testing.T) {
+		path := write("config.json", `{"minSize": "100MB"}`)
+		_, err := LoadSnapshot(path)
+		assert.ErrorContains(t, err, "has no candidates")
+	})
+}
@@ -0,0 +1,178 @@
+package report
+
+import (
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// EcosystemOther is the ecosystem of candidates whose include name doesn't
+// identify one, such as a dist or .cache directory without a profile marker
+const EcosystemOther = "Other"
+
+// ecosystemByName maps include names to the ecosystem that produces them.
+// Generic names such as build, dist, out and target are left out; they are
+// resolved from the marker found next to them instead.
+var ecosystemByName = map[string]string{
+	"node_modules":     "JavaScript",
+	".next":            "JavaScript",
+	".nuxt":            "JavaScript",
+	".svelte-kit":      "JavaScript",
+	".turbo":           "JavaScript",
+	".parcel-cache":    "JavaScript",
+	".angular":         "JavaScript",
+	".vite":            "JavaScript",
+	"storybook-static": "JavaScript",
+	".venv":            "Python",
+	"venv":             "Python",
+	".tox":             "Python",
+	".nox":             "Python",
+	".pytest_cache":    "Python",
+	"__pycache__":      "Python",
+	".mypy_cache":      "Python",
+	".ruff_cache":      "Python",
+	".hypothesis":      "Python",
+	"htmlcov":          "Python",
+	".gradle":          "JVM",
+	"Pods":             "Apple",
+	"Carthage":         "Apple",
+	"Carthage/Build":   "Apple",
+	".build":           "Apple",
+	"DerivedData":      "Apple",
+	"vendor/bundle":    "Ruby",
+	".terraform":       "Terraform",
+	".serverless":      "Serverless",
+}
+
+// ecosystemByMarker maps the profile markers and project manifests found next
+// to generic names to the ecosystem they belong to
+var ecosystemByMarker = map[string]string{
+	"package.json":        "JavaScript",
+	"go.mod":              "Go",
+	"Gemfile":             "Ruby",
+	"composer.json":       "PHP",
+	"pyproject.toml":      "Python",
+	"setup.py":            "Python",
+	"setup.cfg":           "Python",
+	"Cargo.toml":          "Rust",
+	"pom.xml":             "JVM",
+	"build.sbt":           "JVM",
+	"build.gradle":        "JVM",
+	"build.gradle.kts":    "JVM",
+	"settings.gradle":     "JVM",
+	"settings.gradle.kts": "JVM",
+	"Podfile":             "Apple",
+	"Cartfile":            "Apple",
+	"Package.swift":       "Apple",
+}
+
+// ecosystemByGlobalCache maps the tools of --global caches to their ecosystem
+var ecosystemByGlobalCache = map[string]string{
+	"gradle": "JVM",
+	"maven":  "JVM",
+	"cargo":  "Rust",
+	"pip":    "Python",
+	"npm":    "JavaScript",
+	"yarn":   "JavaScript",
+	"pnpm":   "JavaScript",
+	"go":     "Go",
+	"xcode":  "Apple",
+	// simulator is the CoreSimulator caches and outdated simulator devices
+	"simulator": "Apple",
+}
+
+// EcosystemTotal is the reclaimable space of one ecosystem
+type EcosystemTotal struct {
+	Ecosystem  string `json:"ecosystem"`
+	Count      int    `json:"count"`
+	TotalSize  int64  `json:"totalSizeBytes"`
+	TotalSizeH string `json:"totalSizeHuman"`
+	// SizeIncomplete is set when any candidate's size is only a lower bound
+	SizeIncomplete bool `json:"sizeIncomplete,omitempty"`
+}
+
+// Ecosystem derives the ecosystem of a candidate from the include name or
+// global cache it matched. The marker next to a generic name such as target
+// decides between ecosystems that share it. Candidates that matched no include
+// name, such as those of custom detectors, fall back to the name of their
+// directory.
+func Ecosystem(candidate scan.Candidate) string {
+	if candidate.GlobalCache != "" {
+		return lookupEcosystem(ecosystemByGlobalCache, candidate.GlobalCache)
+	}
+	if ecosystem, ok := ecosystemByName[MatchedName(candidate)]; ok {
+		return ecosystem
+	}
+	return lookupEcosystem(ecosystemByMarker, candidate.Marker)
+}
+
+// MatchedName returns the include name a candidate matched, or the name of its
+// directory if the scanner recorded none. Global caches match no include name
+// and yield "".
+func MatchedName(candidate scan.Candidate) string {
+	if candidate.GlobalCache != "" {
+		return ""
+	}
+	if candidate.MatchedName != "" {
+		return candidate.MatchedName
+	}
+	return filepath.Base(candidate.Path)
+}
+
+// FilterByMatchedName keeps the candidates whose MatchedName is one of names
+func FilterByMatchedName(candidates []scan.Candidate, names []string) []scan.Candidate {
+	var filtered []scan.Candidate
+	for _, candidate := range candidates {
+		if slices.Contains(names, MatchedName(candidate)) {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered
+}
+
+func lookupEcosystem(table map[string]string, key string) string {
+	if ecosystem, ok := table[key]; ok {
+		return ecosystem
+	}
+	return EcosystemOther
+}
+
+// EcosystemTotals groups candidates by Ecosystem and sums their sizes,
+// leaving out informational candidates, which reclaim nothing. Ecosystems are
+// ordered by size, largest first, with Other always last.
+func EcosystemTotals(candidates []scan.Candidate) []EcosystemTotal {
+	index := map[string]int{}
+	var totals []EcosystemTotal
+	for _, candidate := range candidates {
+		if candidate.Informational {
+			continue
+		}
+		ecosystem := Ecosystem(candidate)
+		i, ok := index[ecosystem]
+		if !ok {
+			i = len(totals)
+			index[ecosystem] = i
+			totals = append(totals, EcosystemTotal{Ecosystem: ecosystem})
+		}
+		totals[i].Count++
+		totals[i].TotalSize += candidate.SizeBytes
+		totals[i].SizeIncomplete = totals[i].SizeIncomplete || candidate.SizeIncomplete
+	}
+
+	sort.SliceStable(totals, func(i, j int) bool {
+		if (totals[i].Ecosystem == EcosystemOther) != (totals[j].Ecosystem == EcosystemOther) {
+			return totals[j].Ecosystem == EcosystemOther
+		}
+		if totals[i].TotalSize != totals[j].TotalSize {
+			return totals[i].TotalSize > totals[j].TotalSize
+		}
+		return totals[i].Ecosystem < totals[j].Ecosystem
+	})
+	for i := range totals {
+		totals[i].TotalSizeH = bytesize.Format(totals[i].TotalSize)
+	}
+	return totals
+}
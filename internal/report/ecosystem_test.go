@@ -0,0 +1,92 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestEcosystem(t *testing.T) {
+	tests := []struct {
+		name      string
+		candidate scan.Candidate
+		want      string
+	}{
+		{"include name", scan.Candidate{Path: "/p/node_modules", MatchedName: "node_modules"}, "JavaScript"},
+		{"marker decides generic name", scan.Candidate{Path: "/p/target", MatchedName: "target", Marker: "Cargo.toml"}, "Rust"},
+		{"same name other marker", scan.Candidate{Path: "/p/target", MatchedName: "target", Marker: "pom.xml"}, "JVM"},
+		{"manifest next to generic name", scan.Candidate{Path: "/p/build", MatchedName: "build", Marker: "go.mod"}, "Go"},
+		{"include name wins over manifest", scan.Candidate{Path: "/p/node_modules", MatchedName: "node_modules", Marker: "Cargo.toml"}, "JavaScript"},
+		{"generic name without marker", scan.Candidate{Path: "/p/dist", MatchedName: "dist"}, EcosystemOther},
+		{"global cache", scan.Candidate{Path: "/home/u/.cargo/registry/cache", GlobalCache: "cargo"}, "Rust"},
+		{"xcode cache", scan.Candidate{Path: "/d/App-abc", GlobalCache: "xcode"}, "Apple"},
+		{"custom detector falls back to directory name", scan.Candidate{Path: "/p/.venv", Reason: "custom"}, "Python"},
+		{"unknown", scan.Candidate{Path: "/p/stuff", Reason: "custom"}, EcosystemOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Ecosystem(tt.candidate))
+		})
+	}
+}
+
+func TestEcosystem_DefaultConfig(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "crate", "target"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "crate", "Cargo.toml"), nil, 0644))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.ExcludePaths = []string{}
+	candidates, err := scan.NewScanner(cfg).ScanPaths()
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, "Rust", Ecosystem(candidates[0]), "a Cargo target is Rust without the rust profile")
+}
+
+func TestEcosystemTotals(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/a/node_modules", SizeBytes: 300, MatchedName: "node_modules"},
+		{Path: "/a/dist", SizeBytes: 5000, MatchedName: "dist"},
+		{Path: "/b/node_modules", SizeBytes: 200, MatchedName: "node_modules", SizeIncomplete: true},
+		{Path: "/c/target", SizeBytes: 1000, MatchedName: "target", Marker: "Cargo.toml"},
+		{Path: "/d/.venv", SizeBytes: 400, MatchedName: ".venv"},
+		{Path: "/d/__pycache__", SizeBytes: 100, MatchedName: "__pycache__"},
+		// A .git directory is reported, but reclaims nothing
+		{Path: "/d/.git", SizeBytes: 9000, Reason: scan.ReasonVCS, Informational: true},
+	}
+
+	totals := EcosystemTotals(candidates)
+
+	// Largest first, Other last regardless of size
+	assert.Equal(t, []EcosystemTotal{
+		{Ecosystem: "Rust", Count: 1, TotalSize: 1000, TotalSizeH: "1000 B"},
+		{Ecosystem: "JavaScript", Count: 2, TotalSize: 500, TotalSizeH: "500 B", SizeIncomplete: true},
+		{Ecosystem: "Python", Count: 2, TotalSize: 500, TotalSizeH: "500 B"},
+		{Ecosystem: EcosystemOther, Count: 1, TotalSize: 5000, TotalSizeH: "4.9 KiB"},
+	}, totals)
+	assert.Empty(t, EcosystemTotals(nil))
+}
+
+func TestFilterByMatchedName(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/a/node_modules", MatchedName: "node_modules"},
+		{Path: "/a/dist", MatchedName: "dist"},
+		{Path: "/b/target", MatchedName: "target", Marker: "Cargo.toml"},
+		{Path: "/b/node_modules", MatchedName: "node_modules"},
+		{Path: "/c/Carthage/Build", MatchedName: "Carthage/Build"},
+		{Path: "/home/u/.npm/_cacache", GlobalCache: "npm"},
+	}
+
+	var paths []string
+	for _, candidate := range FilterByMatchedName(candidates, []string{"node_modules", "target", "Carthage/Build"}) {
+		paths = append(paths, candidate.Path)
+	}
+	assert.Equal(t, []string{"/a/node_modules", "/b/target", "/b/node_modules", "/c/Carthage/Build"}, paths)
+	assert.Empty(t, FilterByMatchedName(candidates, []string{"venv"}))
+}
@@ -1,9 +1,11 @@
 package report
 
 import (
-	"encoding/json"
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"text/tabwriter"
@@ -12,48 +14,118 @@ import (
 	"encoding/csv"
 	"path/filepath"
 
-	"github.com/dustin/go-humanize"
+	"github.com/rivo/uniseg"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
 	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+	"github.com/yehia2amer/BuildBloatBuster/internal/size"
 )
 
+// tablePadding is the number of spaces between table columns
+const tablePadding = 2
+
 // Reporter handles formatting and displaying scan results
 type Reporter struct {
-	format string
-	sortBy string
+	formats []string
+	sortBy  string
+	// sizeUnit, unless zero, is the unit the size columns are shown in
+	sizeUnit bytesize.Unit
+	// chart draws a bar next to every row of the table
+	chart bool
+	// noTruncate shows paths and reasons in full, however wide the table gets
+	noTruncate bool
+	// refusal is added to JSON output when the candidates won't be deleted
+	refusal *Refusal
+	// readErrors are added to JSON output
+	readErrors []scan.ReadError
 }
 
-// NewReporter creates a new reporter with the given format and sort options
-func NewReporter(format, sortBy string) *Reporter {
-	if format == "" {
-		format = "table"
+// NewReporter creates a new reporter with the given formats and sort options.
+// Table, JSON, TSV and the plain lists of paths print to stdout and CSV
+// writes a file, so formats may list CSV together with one of the others. Unless sizeUnit is zero, the size
+// columns of table and CSV output show plain numbers in that unit instead of
+// humanized sizes.
+func NewReporter(formats []string, sortBy string, sizeUnit bytesize.Unit) *Reporter {
+	if len(formats) == 0 {
+		formats = []string{"table"}
 	}
 	if sortBy == "" {
 		sortBy = "size"
 	}
 	return &Reporter{
-		format: format,
-		sortBy: sortBy,
+		formats:  formats,
+		sortBy:   sortBy,
+		sizeUnit: sizeUnit,
 	}
 }
 
-// Report displays the candidates according to the configured format
+// SetChart makes the table draw a bar next to every candidate, scaled to the
+// largest one. The bars are left out when the terminal is too narrow and in
+// every other format.
+func (r *Reporter) SetChart(chart bool) {
+	r.chart = chart
+}
+
+// SetNoTruncate makes the table show every path and reason in full instead
+// of fitting them to the terminal width.
+func (r *Reporter) SetNoTruncate(noTruncate bool) {
+	r.noTruncate = noTruncate
+}
+
+// SetRefusal records why clean won't delete the candidates, which JSON output
+// then includes as "refused"
+func (r *Reporter) SetRefusal(refusal *Refusal) {
+	r.refusal = refusal
+}
+
+// SetReadErrors records the paths that couldn't be read while scanning or
+// sizing, which JSON output then includes as "errors"
+func (r *Reporter) SetReadErrors(readErrors []scan.ReadError) {
+	r.readErrors = readErrors
+}
+
+// Report displays the candidates in each of the configured formats
 func (r *Reporter) Report(candidates []scan.Candidate, outputDir ...string) error {
 	// Sort candidates
 	r.sortCandidates(candidates)
 
-	switch r.format {
-	case "json":
-		return r.reportJSON(candidates)
-	case "table":
-		return r.reportTable(candidates)
-	case "csv":
-		if len(outputDir) > 0 {
-			return r.reportCSV(candidates, outputDir[0])
+	for _, format := range r.formats {
+		var err error
+		switch format {
+		case "json":
+			err = r.reportJSON(candidates)
+		case "table":
+			err = r.reportTable(candidates)
+		case "tsv":
+			err = writeTSV(os.Stdout, r.delimitedRecords(candidates))
+		case "plain":
+			err = r.reportPlain(candidates, '\n')
+		case "plain0":
+			err = r.reportPlain(candidates, 0)
+		case "csv":
+			if len(outputDir) > 0 {
+				err = r.reportCSV(candidates, outputDir[0])
+			} else {
+				err = r.reportCSV(candidates, "")
+			}
+		default:
+			err = fmt.Errorf("unsupported format: %s", format)
 		}
-		return r.reportCSV(candidates, "")
-	default:
-		return fmt.Errorf("unsupported format: %s", r.format)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusOutput is where notes about written files go: stdout, unless JSON, TSV
+// or paths are printed there and must stay parseable
+func (r *Reporter) statusOutput() io.Writer {
+	if slices.ContainsFunc(r.formats, func(format string) bool {
+		return format == "json" || format == "tsv" || format == "plain" || format == "plain0"
+	}) {
+		return os.Stderr
 	}
+	return os.Stdout
 }
 
 func (r *Reporter) reportCSV(candidates []scan.Candidate, outputDir string) error {
@@ -71,29 +143,11 @@ func (r *Reporter) reportCSV(candidates []scan.Candidate, outputDir string) erro
 	defer file.Close()
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write header
-	header := []string{"Path", "Size (Bytes)", "Size (Human)", "Reason", "Last Modified"}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("failed to write CSV header: %w", err)
+	if err := writer.WriteAll(r.delimitedRecords(candidates)); err != nil {
+		return fmt.Errorf("failed to write CSV report: %w", err)
 	}
 
-	// Write data
-	for _, candidate := range candidates {
-		record := []string{
-			candidate.Path,
-			fmt.Sprintf("%d", candidate.SizeBytes),
-			humanize.Bytes(uint64(candidate.SizeBytes)),
-			candidate.Reason,
-			candidate.NewestMTime.Format(time.RFC3339),
-		}
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("failed to write CSV record: %w", err)
-		}
-	}
-
-	fmt.Printf("\nCSV report generated: %s\n", filePath)
+	fmt.Fprintf(r.statusOutput(), "\nCSV report generated: %s\n", filePath)
 	return nil
 }
 
@@ -117,21 +171,26 @@ func (r *Reporter) sortCandidates(candidates []scan.Candidate) {
 
 // reportJSON outputs candidates as JSON
 func (r *Reporter) reportJSON(candidates []scan.Candidate) error {
-	summary := struct {
-		Count      int              `json:"count"`
-		TotalSize  int64            `json:"totalSizeBytes"`
-		TotalSizeH string           `json:"totalSizeHuman"`
-		Candidates []scan.Candidate `json:"candidates"`
-	}{
-		Count:      len(candidates),
-		TotalSize:  calculateTotalSize(candidates),
-		Candidates: candidates,
-	}
-	summary.TotalSizeH = humanize.Bytes(uint64(summary.TotalSize))
+	snapshot := NewSnapshot(candidates)
+	snapshot.Refused = r.refusal
+	snapshot.Errors = r.readErrors
+	return writeSnapshot(os.Stdout, snapshot)
+}
 
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(summary)
+// reportPlain prints the absolute path of each candidate followed by sep and
+// nothing else, for piping into tools like fzf or xargs. A NUL sep keeps
+// paths containing newlines intact.
+func (r *Reporter) reportPlain(candidates []scan.Candidate, sep byte) error {
+	w := bufio.NewWriter(os.Stdout)
+	for _, candidate := range candidates {
+		path := candidate.Path
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+		w.WriteString(path)
+		w.WriteByte(sep)
+	}
+	return w.Flush()
 }
 
 // reportTable outputs candidates as a formatted table
@@ -143,48 +202,230 @@ func (r *Reporter) reportTable(candidates []scan.Candidate) error {
 
 	// Calculate totals
 	totalSize := calculateTotalSize(candidates)
-	totalCount := len(candidates)
+	totalCount := countReclaimable(candidates)
+
+	totalSizeStr := bytesize.Format(totalSize)
+	if hasIncompleteSize(candidates) {
+		totalSizeStr = "≥ " + totalSizeStr
+	}
 
 	// Print summary header
 	fmt.Printf("Found %d directories using %s\n\n",
-		totalCount, humanize.Bytes(uint64(totalSize)))
+		totalCount, totalSizeStr)
 
 	// Create table writer
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, tablePadding, ' ', 0)
 	defer w.Flush()
 
 	// Print table header
-	fmt.Fprintln(w, "SIZE\tPATH\tLAST MODIFIED\tREASON")
-	fmt.Fprintln(w, "----\t----\t-------------\t------")
+	sizeHeader := "SIZE"
+	if !r.sizeUnit.IsZero() {
+		sizeHeader = fmt.Sprintf("SIZE (%s)", r.sizeUnit.Name)
+	}
+	// Sizes and shares are right-aligned so they are easy to compare
+	sizes := make([]string, len(candidates))
+	shares := make([]string, len(candidates))
+	sizeWidth, shareWidth := len(sizeHeader), len("SHARE")
+	var largest int64
+	for i, candidate := range candidates {
+		sizes[i] = r.sizeColumn(candidate.SizeBytes, candidate.SizeIncomplete)
+		sizeWidth = max(sizeWidth, uniseg.StringWidth(sizes[i]))
+		shares[i] = "-"
+		if !candidate.Informational {
+			shares[i] = formatShare(sharePercent(candidate.SizeBytes, totalSize))
+		}
+		shareWidth = max(shareWidth, len(shares[i]))
+		largest = max(largest, candidate.SizeBytes)
+	}
 
-	// Print each candidate
-	for _, candidate := range candidates {
-		sizeStr := humanize.Bytes(uint64(candidate.SizeBytes))
-		timeStr := formatTime(candidate.NewestMTime)
-		pathStr := truncatePath(candidate.Path, 60)
-		reasonStr := truncateString(candidate.Reason, 30)
+	rows := [][]string{
+		{"ID", alignRight(sizeHeader, sizeWidth), alignRight("SHARE", shareWidth), "PATH", "LAST MODIFIED", "REASON"},
+		{"--", alignRight(strings.Repeat("-", len(sizeHeader)), sizeWidth), alignRight("-----", shareWidth), "----", "-------------", "------"},
+	}
+	for i, candidate := range candidates {
+		rows = append(rows, []string{
+			candidate.ID,
+			alignRight(sizes[i], sizeWidth),
+			alignRight(shares[i], shareWidth),
+			candidate.Path,
+			formatTime(candidate.NewestMTime),
+			candidate.Reason,
+		})
+	}
+	if !r.noTruncate {
+		fitTable(rows, 3, 5)
+	}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
-			sizeStr, pathStr, timeStr, reasonStr)
+	// The bars go after the shares, as wide as the terminal leaves room for
+	if r.chart {
+		if barWidth := chartWidth(tableWidth(rows)); barWidth > 0 {
+			rows[0] = slices.Insert(rows[0], 3, "")
+			rows[1] = slices.Insert(rows[1], 3, "")
+			for i, candidate := range candidates {
+				rows[i+2] = slices.Insert(rows[i+2], 3, chartBar(candidate.SizeBytes, largest, barWidth))
+			}
+		}
+	}
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
 	}
 
 	// Print summary footer
 	fmt.Fprintln(w)
 	fmt.Fprintf(w, "TOTAL:\t%s\t%d directories\t\n",
-		humanize.Bytes(uint64(totalSize)), totalCount)
+		r.sizeColumn(totalSize, hasIncompleteSize(candidates)), totalCount)
+
+	// Print the breakdown by ecosystem
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "BY ECOSYSTEM:")
+	totals := EcosystemTotals(candidates)
+	totalSizes := make([]string, len(totals))
+	sizeWidth = 0
+	for i, total := range totals {
+		totalSizes[i] = r.sizeColumn(total.TotalSize, total.SizeIncomplete)
+		sizeWidth = max(sizeWidth, uniseg.StringWidth(totalSizes[i]))
+	}
+	for i, total := range totals {
+		fmt.Fprintf(w, "%s\t%s\t%d directories\t\n",
+			total.Ecosystem, alignRight(totalSizes[i], sizeWidth), total.Count)
+	}
+
+	// With several scan paths, also break the total down by scan path
+	rootTotals := ScanRootTotals(candidates)
+	if len(rootTotals) < 2 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "BY SCAN ROOT:")
+	totalSizes = make([]string, len(rootTotals))
+	sizeWidth = 0
+	for i, total := range rootTotals {
+		totalSizes[i] = r.sizeColumn(total.TotalSize, total.SizeIncomplete)
+		sizeWidth = max(sizeWidth, uniseg.StringWidth(totalSizes[i]))
+	}
+	for i, total := range rootTotals {
+		root := total.ScanRoot
+		if root == "" {
+			root = ScanRootOther
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d directories\t\n", root, alignRight(totalSizes[i], sizeWidth), total.Count)
+	}
 
 	return nil
 }
 
-// calculateTotalSize sums up the size of all candidates
+const (
+	// minPathWidth is the narrowest the path column is squeezed to; a table
+	// that doesn't fit even then wraps
+	minPathWidth = 20
+	// maxReasonWidth and minReasonWidth bound the reason column, which gives
+	// up room before the path column does
+	maxReasonWidth = 30
+	minReasonWidth = 12
+)
+
+// fitTable truncates the path and reason columns of rows, given by their
+// index, so the table fits the terminal. The path column gets all the width
+// the other columns leave, keeping both ends of long paths.
+func fitTable(rows [][]string, pathCol, reasonCol int) {
+	var pathWant, reasonWant int
+	others := make([][]string, len(rows))
+	for i, row := range rows {
+		pathWant = max(pathWant, uniseg.StringWidth(row[pathCol]))
+		reasonWant = max(reasonWant, uniseg.StringWidth(row[reasonCol]))
+		others[i] = slices.Delete(slices.Clone(row), max(pathCol, reasonCol), max(pathCol, reasonCol)+1)
+		others[i] = slices.Delete(others[i], min(pathCol, reasonCol), min(pathCol, reasonCol)+1)
+	}
+	room := TerminalWidth() - tableWidth(others) - 2*tablePadding
+
+	reasonWidth := min(reasonWant, maxReasonWidth)
+	if room-reasonWidth < min(pathWant, minPathWidth) {
+		reasonWidth = max(min(reasonWidth, minReasonWidth), room-min(pathWant, minPathWidth))
+	}
+	pathWidth := max(min(pathWant, room-reasonWidth), min(pathWant, minPathWidth))
+
+	for _, row := range rows {
+		row[pathCol] = truncatePath(row[pathCol], pathWidth)
+		row[reasonCol] = truncateString(row[reasonCol], reasonWidth)
+	}
+}
+
+// tableWidth returns how many terminal columns rows take once the table
+// writer has aligned them
+func tableWidth(rows [][]string) int {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], uniseg.StringWidth(cell))
+		}
+	}
+	total := 0
+	for _, width := range widths {
+		total += width + tablePadding
+	}
+	return total
+}
+
+// calculateTotalSize sums up the size of all candidates that can be cleaned.
+// Informational ones, such as .git directories, reclaim nothing.
 func calculateTotalSize(candidates []scan.Candidate) int64 {
 	var total int64
 	for _, candidate := range candidates {
-		total += candidate.SizeBytes
+		if !candidate.Informational {
+			total += candidate.SizeBytes
+		}
 	}
 	return total
 }
 
+// countReclaimable counts the candidates that aren't informational
+func countReclaimable(candidates []scan.Candidate) int {
+	count := 0
+	for _, candidate := range candidates {
+		if !candidate.Informational {
+			count++
+		}
+	}
+	return count
+}
+
+// formatSize formats a candidate's size for display, marking sizes that are
+// only a lower bound because sizing timed out
+func formatSize(candidate scan.Candidate) string {
+	sizeStr := bytesize.Format(candidate.SizeBytes)
+	if candidate.SizeIncomplete {
+		return "≥ " + sizeStr
+	}
+	return sizeStr
+}
+
+// sizeColumn formats a size for a table column: in the fixed size unit if one
+// is set, humanized otherwise. Incomplete sizes are marked as a lower bound.
+func (r *Reporter) sizeColumn(bytes int64, incomplete bool) string {
+	sizeStr := bytesize.Format(bytes)
+	if !r.sizeUnit.IsZero() {
+		sizeStr = r.sizeUnit.Format(bytes)
+	}
+	if incomplete {
+		return "≥ " + sizeStr
+	}
+	return sizeStr
+}
+
+// hasIncompleteSize reports whether any candidate counted in the total has an
+// incomplete size
+func hasIncompleteSize(candidates []scan.Candidate) bool {
+	for _, candidate := range candidates {
+		if candidate.SizeIncomplete && !candidate.Informational {
+			return true
+		}
+	}
+	return false
+}
+
 // formatTime formats a time for display
 func formatTime(t time.Time) string {
 	if t.IsZero() {
@@ -205,45 +446,124 @@ func formatTime(t time.Time) string {
 	}
 }
 
-// truncatePath truncates a path to fit within maxLen characters
+// truncatePath truncates a path to fit within maxLen terminal columns. Whole
+// components are replaced by an ellipsis in the middle, so both the start of
+// the path and the directory itself stay visible, e.g.
+// /Users/me/…/packages/app/node_modules.
 func truncatePath(path string, maxLen int) string {
-	if len(path) <= maxLen {
+	if uniseg.StringWidth(path) <= maxLen {
 		return path
 	}
 
-	// Try to keep the end of the path (filename/dirname)
-	if maxLen > 10 {
-		return "..." + path[len(path)-(maxLen-3):]
+	sep := string(filepath.Separator)
+	parts := strings.Split(path, sep)
+	join := func(head, tail int) string {
+		middle := "…" + sep + strings.Join(parts[tail:], sep)
+		if head == 0 {
+			return middle
+		}
+		return strings.Join(parts[:head], sep) + sep + middle
 	}
 
-	return path[:maxLen-3] + "..."
+	fits := func(head, tail int) bool {
+		return uniseg.StringWidth(join(head, tail)) <= maxLen
+	}
+
+	// An absolute path keeps its leading separator. Components are then
+	// added from either end in turn, starting with the end, while they fit.
+	head, tail := 0, len(parts)-1
+	if parts[0] == "" {
+		head = 1
+	}
+	if tail <= head || !fits(head, tail) {
+		// Not even the last component fits: keep as much of its end as does
+		return "…" + fitWidth(path, maxLen-1, true)
+	}
+	for fromEnd := true; head < tail-1; fromEnd = !fromEnd {
+		switch {
+		case fromEnd && fits(head, tail-1):
+			tail--
+		case fits(head+1, tail):
+			head++
+		case fits(head, tail-1):
+			tail--
+		default:
+			return join(head, tail)
+		}
+	}
+	return join(head, tail)
 }
 
-// truncateString truncates a string to fit within maxLen characters
+// truncateString truncates a string to fit within maxLen terminal columns
 func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	if uniseg.StringWidth(s) <= maxLen {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+	return fitWidth(s, maxLen-3, false) + "..."
 }
 
-// PrintScanProgress prints scanning progress information
-func PrintScanProgress(scanned, found int) {
-	fmt.Printf("\rScanning... %d directories checked, %d candidates found", scanned, found)
+// alignRight pads s with spaces on the left to width columns
+func alignRight(s string, width int) string {
+	return strings.Repeat(" ", max(0, width-uniseg.StringWidth(s))) + s
 }
 
-// PrintSizeProgress prints size calculation progress
-func PrintSizeProgress(completed, total int) {
-	if total == 0 {
+// fitWidth returns the longest prefix of s, or suffix if tail is set, that
+// fits in width columns. It only cuts between grapheme clusters, so an emoji
+// or an accent stored as a combining mark (as macOS does in file names) is
+// never split, and wide characters count as two columns.
+func fitWidth(s string, width int, tail bool) string {
+	var starts, widths []int
+	graphemes := uniseg.NewGraphemes(s)
+	for graphemes.Next() {
+		from, _ := graphemes.Positions()
+		starts = append(starts, from)
+		widths = append(widths, graphemes.Width())
+	}
+
+	// Cluster i ends where the next one starts
+	starts = append(starts, len(s))
+	used := 0
+	if tail {
+		for i := len(widths) - 1; i >= 0; i-- {
+			if used += widths[i]; used > width {
+				return s[starts[i+1]:]
+			}
+		}
+		return s
+	}
+	for i := range widths {
+		if used += widths[i]; used > width {
+			return s[:starts[i]]
+		}
+	}
+	return s
+}
+
+// ProgressOutput is where PrintSizeProgress and ClearProgress draw. It is
+// stderr so progress never mixes with a report on stdout; nil turns progress
+// off, e.g. when stderr is not a terminal.
+var ProgressOutput io.Writer = os.Stderr
+
+// PrintSizeProgress prints size calculation progress, with the time left
+// extrapolated from the time elapsed since sizing started
+func PrintSizeProgress(completed, total int, elapsed time.Duration) {
+	if ProgressOutput == nil || total == 0 {
 		return
 	}
 
 	percent := (completed * 100) / total
 	bar := strings.Repeat("█", percent/5) + strings.Repeat("░", 20-percent/5)
-	fmt.Printf("\rCalculating sizes... [%s] %d%% (%d/%d)", bar, percent, completed, total)
+	eta := "ETA ?"
+	if remaining, ok := size.EstimateRemaining(elapsed, int64(completed), int64(total)); ok {
+		eta = "ETA " + remaining.Round(time.Second).String()
+	}
+	fmt.Fprintf(ProgressOutput, "\rCalculating sizes... [%s] %d%% (%d/%d) %s", bar, percent, completed, total, eta)
 }
 
 // ClearProgress clears the current progress line
 func ClearProgress() {
-	fmt.Print("\r" + strings.Repeat(" ", 80) + "\r")
+	if ProgressOutput == nil {
+		return
+	}
+	fmt.Fprint(ProgressOutput, "\r"+strings.Repeat(" ", 80)+"\r")
 }
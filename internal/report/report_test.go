@@ -2,15 +2,20 @@ package report
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/rivo/uniseg"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
 	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
 )
 
@@ -20,7 +25,7 @@ func TestReporter_JSON(t *testing.T) {
 		{Path: "/tmp/project/target", SizeBytes: 50000000, Reason: "target", NewestMTime: time.Now().Add(-24 * time.Hour)},
 	}
 
-	reporter := NewReporter("json", "size")
+	reporter := NewReporter([]string{"json"}, "size", bytesize.Unit{})
 
 	// Capture stdout
 	oldStdout := os.Stdout
@@ -42,6 +47,7 @@ func TestReporter_JSON(t *testing.T) {
 		Count      int              `json:"count"`
 		TotalSize  int64            `json:"totalSizeBytes"`
 		Candidates []scan.Candidate `json:"candidates"`
+		Ecosystems []EcosystemTotal `json:"ecosystems"`
 	}
 
 	err = json.Unmarshal(buf.Bytes(), &summary)
@@ -51,6 +57,57 @@ func TestReporter_JSON(t *testing.T) {
 	assert.Equal(t, int64(250000000), summary.TotalSize)
 	assert.Len(t, summary.Candidates, 2)
 	assert.Equal(t, "/tmp/project/node_modules", summary.Candidates[0].Path)
+	assert.Equal(t, 80.0, summary.Candidates[0].SharePercent)
+	assert.Equal(t, 20.0, summary.Candidates[1].SharePercent)
+	require.Len(t, summary.Ecosystems, 2)
+	assert.Equal(t, "JavaScript", summary.Ecosystems[0].Ecosystem)
+	assert.Equal(t, int64(200000000), summary.Ecosystems[0].TotalSize)
+}
+
+func TestReporter_JSONRefusal(t *testing.T) {
+	candidates := []scan.Candidate{{Path: "/tmp/project/node_modules", SizeBytes: 200000000, Reason: "node_modules"}}
+
+	for _, refusal := range []*Refusal{nil, {Code: "max-delete", Reason: "too much"}} {
+		reporter := NewReporter([]string{"json"}, "size", bytesize.Unit{})
+		reporter.SetRefusal(refusal)
+
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		require.NoError(t, reporter.Report(candidates))
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		var snapshot Snapshot
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &snapshot))
+		assert.Equal(t, refusal, snapshot.Refused)
+		assert.Equal(t, refusal != nil, strings.Contains(buf.String(), `"refused"`))
+	}
+}
+
+func TestReporter_JSONReadErrors(t *testing.T) {
+	candidates := []scan.Candidate{{Path: "/tmp/project/node_modules", SizeBytes: 200000000, Reason: "node_modules"}}
+
+	for _, readErrors := range [][]scan.ReadError{nil, {{Path: "/tmp/locked", Error: "open /tmp/locked: permission denied"}}} {
+		reporter := NewReporter([]string{"json"}, "size", bytesize.Unit{})
+		reporter.SetReadErrors(readErrors)
+
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		require.NoError(t, reporter.Report(candidates))
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		var snapshot Snapshot
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &snapshot))
+		assert.Equal(t, readErrors, snapshot.Errors)
+		assert.Equal(t, readErrors != nil, strings.Contains(buf.String(), `"errors"`))
+	}
 }
 
 func TestReporter_CSV(t *testing.T) {
@@ -59,7 +116,7 @@ func TestReporter_CSV(t *testing.T) {
 		{Path: "/tmp/project/target", SizeBytes: 50000000, Reason: "target", NewestMTime: time.Now().Add(-24 * time.Hour)},
 	}
 
-	reporter := NewReporter("csv", "size")
+	reporter := NewReporter([]string{"csv"}, "size", bytesize.Unit{})
 
 	// For this test, we'll just check that it runs without error
 	// and creates a file. A more robust test would parse the CSV.
@@ -75,3 +132,289 @@ func TestReporter_CSV(t *testing.T) {
 	require.NoError(t, err)
 	require.NotEmpty(t, matches, "CSV report file should have been created")
 }
+
+func TestReporter_JSONAndCSV(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/tmp/project/node_modules", SizeBytes: 200000000, Reason: "node_modules", NewestMTime: time.Now()},
+	}
+	tmpDir := t.TempDir()
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	stdoutR, stdoutW, _ := os.Pipe()
+	stderrR, stderrW, _ := os.Pipe()
+	os.Stdout, os.Stderr = stdoutW, stderrW
+	err := NewReporter([]string{"json", "csv"}, "size", bytesize.Unit{}).Report(candidates, tmpDir)
+	stdoutW.Close()
+	stderrW.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	io.Copy(&stdout, stdoutR)
+	io.Copy(&stderr, stderrR)
+
+	// The JSON on stdout stays parseable, the note about the CSV goes to stderr
+	var snapshot Snapshot
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &snapshot), "stdout should be only JSON")
+	assert.Equal(t, 1, snapshot.Count)
+	assert.Contains(t, stderr.String(), "CSV report generated")
+
+	matches, err := filepath.Glob(filepath.Join(tmpDir, "BuildBloatBuster-report-*.csv"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	data, err := os.ReadFile(matches[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "/tmp/project/node_modules,200000000")
+}
+
+func TestReporter_Plain(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/tmp/project/target", SizeBytes: 50000000, Reason: "target"},
+		{Path: "/tmp/my\nproject/node_modules", SizeBytes: 200000000, Reason: "node_modules"},
+	}
+
+	capture := func(format string) string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := NewReporter([]string{format}, "size", bytesize.Unit{}).Report(candidates)
+		w.Close()
+		os.Stdout = oldStdout
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		return buf.String()
+	}
+
+	// Only the paths, largest first, without headers or totals
+	assert.Equal(t, "/tmp/my\nproject/node_modules\n/tmp/project/target\n", capture("plain"))
+	assert.Equal(t, "/tmp/my\nproject/node_modules\x00/tmp/project/target\x00", capture("plain0"))
+}
+
+func TestReporter_CSVSizeUnit(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/tmp/project/node_modules", SizeBytes: 1536 * bytesize.MB, Reason: "node_modules", NewestMTime: time.Now()},
+		{Path: "/tmp/project/target", SizeBytes: 1234567, Reason: "target", NewestMTime: time.Now()},
+	}
+	unit, err := bytesize.ParseUnit("MB")
+	require.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	require.NoError(t, NewReporter([]string{"csv"}, "size", unit).Report(candidates, tmpDir))
+
+	matches, err := filepath.Glob(filepath.Join(tmpDir, "BuildBloatBuster-report-*.csv"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	file, err := os.Open(matches[0])
+	require.NoError(t, err)
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, records, 3)
+	assert.Equal(t, "Size (MB)", records[0][5])
+	assert.Equal(t, []string{"1536000000", "1.4 GiB", "1536.0"}, []string{records[1][1], records[1][2], records[1][5]})
+	assert.Equal(t, "1.2", records[2][5])
+}
+
+func TestReporter_TableAlignsSizesRight(t *testing.T) {
+	candidates := []scan.Candidate{
+		{ID: "a1b2c3d4", Path: "/tmp/project/node_modules", SizeBytes: 300 * bytesize.MiB, Reason: "node_modules", NewestMTime: time.Now()},
+		{ID: "deadbeef", Path: "/tmp/project/target", SizeBytes: 1536, Reason: "target", NewestMTime: time.Now()},
+	}
+
+	oldWidth := TerminalWidth
+	defer func() { TerminalWidth = oldWidth }()
+	TerminalWidth = func() int { return 120 }
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := NewReporter([]string{"table"}, "size", bytesize.Unit{}).Report(candidates)
+	w.Close()
+	os.Stdout = oldStdout
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	lines := strings.Split(buf.String(), "\n")
+	require.Greater(t, len(lines), 5)
+	assert.True(t, strings.HasPrefix(lines[2], "ID           SIZE   SHARE  PATH"), lines[2])
+	assert.True(t, strings.HasPrefix(lines[4], "a1b2c3d4  300 MiB  100.0%  /tmp/project/node_modules"), lines[4])
+	assert.True(t, strings.HasPrefix(lines[5], "deadbeef  1.5 KiB    0.0%  /tmp/project/target"), lines[5])
+}
+
+// renderTable prints candidates as a table on a terminal width columns wide
+// and returns its lines
+func renderTable(t *testing.T, candidates []scan.Candidate, width int, configure func(*Reporter)) []string {
+	t.Helper()
+	oldWidth := TerminalWidth
+	defer func() { TerminalWidth = oldWidth }()
+	TerminalWidth = func() int { return width }
+
+	reporter := NewReporter([]string{"table"}, "size", bytesize.Unit{})
+	configure(reporter)
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := reporter.Report(candidates)
+	w.Close()
+	os.Stdout = oldStdout
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return strings.Split(buf.String(), "\n")
+}
+
+func TestReporter_TableChart(t *testing.T) {
+	candidates := []scan.Candidate{
+		{ID: "a1b2c3d4", Path: "/tmp/project/node_modules", SizeBytes: 300 * bytesize.MiB, Reason: "node_modules", NewestMTime: time.Now()},
+		{ID: "deadbeef", Path: "/tmp/project/target", SizeBytes: 100 * bytesize.MiB, Reason: "target", NewestMTime: time.Now()},
+	}
+	chart := func(r *Reporter) { r.SetChart(true) }
+
+	// Wide terminal: the largest candidate gets a full bar, the rest scale
+	lines := renderTable(t, candidates, 200, chart)
+	assert.True(t, strings.HasPrefix(lines[4], "a1b2c3d4  300 MiB  75.0%  "+strings.Repeat("█", chartMaxWidth)+"  /tmp/project/node_modules"), lines[4])
+	assert.Contains(t, lines[5], " 25.0%  "+strings.Repeat("█", 13)+"▍ ")
+
+	// Too narrow for a useful bar: the table is shown without one
+	lines = renderTable(t, candidates, 60, chart)
+	assert.NotContains(t, strings.Join(lines, "\n"), "█")
+	assert.True(t, strings.HasPrefix(lines[4], "a1b2c3d4  300 MiB  75.0%  /tmp/"), lines[4])
+}
+
+func TestReporter_TableLeavesInformationalOutOfTotals(t *testing.T) {
+	candidates := []scan.Candidate{
+		{ID: "a1b2c3d4", Path: "/tmp/project/node_modules", SizeBytes: 300 * bytesize.MiB, Reason: "matches include pattern 'node_modules'", NewestMTime: time.Now()},
+		{ID: "deadbeef", Path: "/tmp/project/.git", SizeBytes: 900 * bytesize.MiB, Reason: scan.ReasonVCS, NewestMTime: time.Now(), Informational: true},
+	}
+
+	out := strings.Join(renderTable(t, candidates, 120, func(*Reporter) {}), "\n")
+	assert.Contains(t, out, "Found 1 directories using 300 MiB")
+	assert.Contains(t, out, "100.0%  /tmp/project/node_modules")
+	assert.Contains(t, out, "-  /tmp/project/.git")
+	assert.Regexp(t, `TOTAL:\s+300 MiB\s+1 directories`, out)
+	assert.NotContains(t, out, EcosystemOther)
+}
+
+func TestReporter_TableFitsTerminal(t *testing.T) {
+	candidates := []scan.Candidate{
+		{ID: "a1b2c3d4", Path: "/Users/me/code/work/packages/app/node_modules", SizeBytes: 300 * bytesize.MiB,
+			Reason: "matches include pattern 'node_modules'", NewestMTime: time.Now()},
+	}
+	fullPath := candidates[0].Path
+
+	// Wide terminal: the path is shown in full
+	lines := renderTable(t, candidates, 200, func(*Reporter) {})
+	assert.Contains(t, lines[4], fullPath+"  ")
+
+	// 80 columns: the table fits, keeping both ends of the path
+	lines = renderTable(t, candidates, 80, func(*Reporter) {})
+	for _, line := range lines[2:5] {
+		assert.LessOrEqual(t, uniseg.StringWidth(line), 80, line)
+	}
+	assert.Contains(t, lines[4], "  /…/app/node_modules  ")
+
+	// --no-truncate shows everything however narrow the terminal is
+	lines = renderTable(t, candidates, 40, func(r *Reporter) { r.SetNoTruncate(true) })
+	assert.Contains(t, lines[4], fullPath)
+	assert.True(t, strings.HasSuffix(lines[4], "matches include pattern 'node_modules'"), lines[4])
+}
+
+func TestChartBar(t *testing.T) {
+	tests := []struct {
+		size, largest int64
+		width         int
+		want          string
+	}{
+		{100, 100, 10, "██████████"},
+		{50, 100, 10, "█████"},
+		{55, 100, 10, "█████▌"},
+		{1, 1000000, 10, "▏"},
+		{0, 100, 10, ""},
+		{100, 100, 0, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, chartBar(tt.size, tt.largest, tt.width), "%d/%d in %d cells", tt.size, tt.largest, tt.width)
+	}
+}
+
+func TestFormatSize_Incomplete(t *testing.T) {
+	assert.Equal(t, "2.0 GiB", formatSize(scan.Candidate{SizeBytes: 2100000000}))
+	assert.Equal(t, "≥ 2.0 GiB", formatSize(scan.Candidate{SizeBytes: 2100000000, SizeIncomplete: true}))
+}
+
+func TestTruncate_Unicode(t *testing.T) {
+	// "é" written as e and a combining accent, as macOS stores file names
+	decomposed := "Résumés"
+
+	tests := []struct {
+		name     string
+		truncate func(string, int) string
+		input    string
+		maxLen   int
+		want     string
+	}{
+		{"short path is kept", truncatePath, "/Users/zoë/node_modules", 60, "/Users/zoë/node_modules"},
+		{"path keeps both ends", truncatePath, "/Users/me/code/work/packages/app/node_modules", 37, "/Users/me/…/packages/app/node_modules"},
+		{"path keeps its leaf", truncatePath, "/Users/zoë/Projets/café-app/node_modules", 20, "/…/node_modules"},
+		{"path keeps Cyrillic leaf", truncatePath, "/Users/zoë/Проекты/приложение", 15, "/…/приложение"},
+		{"path drops emoji component", truncatePath, "/home/me/🚀🚀🚀🚀🚀🚀🚀🚀/build", 16, "/home/me/…/build"},
+		{"path keeps combining accents", truncatePath, "/Users/me/" + decomposed + "/" + decomposed, 12, "/…/" + decomposed},
+		{"short components from both ends", truncatePath, "/ü/ü/ü/ü/ü/ü", 8, "/ü/…/ü/ü"},
+		{"relative path", truncatePath, "code/work/app/node_modules", 20, "…/app/node_modules"},
+		{"leaf too long keeps its end", truncatePath, "/tmp/" + strings.Repeat("🚀", 10), 8, "…🚀🚀🚀"},
+		{"reason keeps emoji", truncateString, "matches include pattern '🐍venv'", 30, "matches include pattern '🐍..."},
+		{"reason never splits wide rune", truncateString, "matches include pattern '🐍venv'", 29, "matches include pattern '..."},
+		{"reason with accents", truncateString, "matches include pattern 'données'", 30, "matches include pattern 'do..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.truncate(tt.input, tt.maxLen)
+			assert.True(t, utf8.ValidString(got), "invalid UTF-8: %q", got)
+			assert.Equal(t, tt.want, got)
+			assert.LessOrEqual(t, uniseg.StringWidth(got), tt.maxLen)
+		})
+	}
+}
+
+func TestSnapshot_Units(t *testing.T) {
+	t.Cleanup(func() { bytesize.DisplayUnits = bytesize.UnitsIEC })
+	candidates := []scan.Candidate{
+		{Path: "/tmp/project/node_modules", SizeBytes: 300 * bytesize.MiB, Reason: "matches include pattern 'node_modules'"},
+	}
+
+	bytesize.DisplayUnits = bytesize.UnitsSI
+	si := NewSnapshot(candidates)
+	assert.Equal(t, "315 MB", si.TotalSizeH)
+	assert.Equal(t, "315 MB", si.Ecosystems[0].TotalSizeH)
+
+	bytesize.DisplayUnits = bytesize.UnitsIEC
+	iec := NewSnapshot(candidates)
+	assert.Equal(t, "300 MiB", iec.TotalSizeH)
+	assert.Equal(t, "300 MiB", iec.Ecosystems[0].TotalSizeH)
+	assert.Equal(t, "300 MiB", formatSize(candidates[0]))
+	assert.Equal(t, si.TotalSize, iec.TotalSize, "only the human-readable sizes change")
+}
+
+func TestProgressOutput(t *testing.T) {
+	t.Cleanup(func() { ProgressOutput = os.Stderr })
+
+	var out bytes.Buffer
+	ProgressOutput = &out
+	PrintSizeProgress(1, 2, 10*time.Second)
+	assert.Equal(t, "\rCalculating sizes... [██████████░░░░░░░░░░] 50% (1/2) ETA 10s", out.String())
+
+	// Nothing sized yet gives nothing to extrapolate from
+	out.Reset()
+	PrintSizeProgress(0, 4, time.Second)
+	assert.Equal(t, "\rCalculating sizes... [░░░░░░░░░░░░░░░░░░░░] 0% (0/4) ETA ?", out.String())
+
+	out.Reset()
+	ProgressOutput = nil
+	PrintSizeProgress(1, 2, time.Second)
+	ClearProgress()
+	assert.Empty(t, out.String())
+}
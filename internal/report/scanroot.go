@@ -0,0 +1,59 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// ScanRootOther labels, in the table, the candidates found under no scan
+// path, such as preset directories and global caches
+const ScanRootOther = "Other"
+
+// ScanRootTotal is the reclaimable space found under one scan path
+type ScanRootTotal struct {
+	// ScanRoot is empty for the candidates found under no scan path
+	ScanRoot   string `json:"scanRoot"`
+	Count      int    `json:"count"`
+	TotalSize  int64  `json:"totalSizeBytes"`
+	TotalSizeH string `json:"totalSizeHuman"`
+	// SizeIncomplete is set when any candidate's size is only a lower bound
+	SizeIncomplete bool `json:"sizeIncomplete,omitempty"`
+}
+
+// ScanRootTotals groups candidates by the scan path they were found under and
+// sums their sizes, leaving out informational candidates. Scan paths are
+// ordered by size, largest first, with the candidates found under none last.
+func ScanRootTotals(candidates []scan.Candidate) []ScanRootTotal {
+	index := map[string]int{}
+	var totals []ScanRootTotal
+	for _, candidate := range candidates {
+		if candidate.Informational {
+			continue
+		}
+		i, ok := index[candidate.ScanRoot]
+		if !ok {
+			i = len(totals)
+			index[candidate.ScanRoot] = i
+			totals = append(totals, ScanRootTotal{ScanRoot: candidate.ScanRoot})
+		}
+		totals[i].Count++
+		totals[i].TotalSize += candidate.SizeBytes
+		totals[i].SizeIncomplete = totals[i].SizeIncomplete || candidate.SizeIncomplete
+	}
+
+	sort.SliceStable(totals, func(i, j int) bool {
+		if (totals[i].ScanRoot == "") != (totals[j].ScanRoot == "") {
+			return totals[j].ScanRoot == ""
+		}
+		if totals[i].TotalSize != totals[j].TotalSize {
+			return totals[i].TotalSize > totals[j].TotalSize
+		}
+		return totals[i].ScanRoot < totals[j].ScanRoot
+	})
+	for i := range totals {
+		totals[i].TotalSizeH = bytesize.Format(totals[i].TotalSize)
+	}
+	return totals
+}
@@ -0,0 +1,27 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestScanRootTotals(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/work/a/node_modules", ScanRoot: "/work", SizeBytes: 300},
+		{Path: "/oss/b/target", ScanRoot: "/oss", SizeBytes: 2000},
+		{Path: "/work/c/.venv", ScanRoot: "/work", SizeBytes: 200, SizeIncomplete: true},
+		{Path: "/home/u/Library/Developer/Xcode/DerivedData", SizeBytes: 9000},
+		{Path: "/mnt/builds/d/dist", ScanRoot: "/mnt/builds", SizeBytes: 500},
+	}
+
+	// Largest first, candidates under no scan path last regardless of size
+	assert.Equal(t, []ScanRootTotal{
+		{ScanRoot: "/oss", Count: 1, TotalSize: 2000, TotalSizeH: "2.0 KiB"},
+		{ScanRoot: "/mnt/builds", Count: 1, TotalSize: 500, TotalSizeH: "500 B"},
+		{ScanRoot: "/work", Count: 2, TotalSize: 500, TotalSizeH: "500 B", SizeIncomplete: true},
+		{ScanRoot: "", Count: 1, TotalSize: 9000, TotalSizeH: "8.8 KiB"},
+	}, ScanRootTotals(candidates))
+	assert.Empty(t, ScanRootTotals(nil))
+}
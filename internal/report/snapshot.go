@@ -0,0 +1,138 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// SnapshotVersion is the version of the snapshot format written by this
+// build. Snapshots without a version were written before it was recorded and
+// are version 1.
+const SnapshotVersion = 2
+
+// Snapshot is the JSON form of a scan result. It is what --format json prints
+// and what scan --save writes, so either can be compared later with diff.
+type Snapshot struct {
+	Version    int              `json:"version"`
+	Count      int              `json:"count"`
+	TotalSize  int64            `json:"totalSizeBytes"`
+	TotalSizeH string           `json:"totalSizeHuman"`
+	Candidates []scan.Candidate `json:"candidates"`
+	// Ecosystems breaks the total down by ecosystem, see EcosystemTotals
+	Ecosystems []EcosystemTotal `json:"ecosystems,omitempty"`
+	// ByScanRoot breaks the total down by scan path, see ScanRootTotals
+	ByScanRoot []ScanRootTotal `json:"byScanRoot,omitempty"`
+	// Refused is set when clean found candidates but deleted none of them
+	// because a safety check failed
+	Refused *Refusal `json:"refused,omitempty"`
+	// Errors lists the paths left out of the scan or the sizes because they
+	// couldn't be read
+	Errors []scan.ReadError `json:"errors,omitempty"`
+}
+
+// Refusal says why a clean deleted nothing, for automation reading the JSON
+type Refusal struct {
+	// Code names the check that failed, e.g. "max-delete"
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// NewSnapshot summarises candidates into a Snapshot, setting the share of
+// the total each of them takes
+func NewSnapshot(candidates []scan.Candidate) Snapshot {
+	total := calculateTotalSize(candidates)
+	candidates = slices.Clone(candidates)
+	for i := range candidates {
+		if candidates[i].Informational {
+			continue
+		}
+		// Two decimals are plenty and keep the JSON readable
+		candidates[i].SharePercent = math.Round(sharePercent(candidates[i].SizeBytes, total)*100) / 100
+	}
+	return Snapshot{
+		Version:    SnapshotVersion,
+		Count:      len(candidates),
+		TotalSize:  total,
+		TotalSizeH: bytesize.Format(total),
+		Candidates: candidates,
+		Ecosystems: EcosystemTotals(candidates),
+		ByScanRoot: ScanRootTotals(candidates),
+	}
+}
+
+// SaveSnapshot writes candidates to path as a JSON snapshot
+func SaveSnapshot(path string, candidates []scan.Candidate) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer file.Close()
+
+	if err := writeSnapshot(file, NewSnapshot(candidates)); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return file.Close()
+}
+
+// LoadSnapshot reads a JSON snapshot written by SaveSnapshot or --format json.
+// It accepts snapshots of every earlier version, a bare list of candidates,
+// and text after the JSON, such as the timing line of a redirected scan.
+// Fields it doesn't know are ignored, and the count and totals are derived
+// from the candidates again.
+func LoadSnapshot(path string) (Snapshot, error) {
+	var snapshot Snapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&raw); err != nil {
+		return snapshot, fmt.Errorf("%s is not a valid snapshot: %w", path, err)
+	}
+	if raw[0] == '[' {
+		var candidates []scan.Candidate
+		if err := json.Unmarshal(raw, &candidates); err != nil {
+			return snapshot, fmt.Errorf("%s is not a valid snapshot: %w", path, err)
+		}
+		scan.AssignIDs(candidates)
+		loaded := NewSnapshot(candidates)
+		loaded.Version = 1
+		return loaded, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return snapshot, fmt.Errorf("%s is not a valid snapshot: %w", path, err)
+	}
+	if _, ok := fields["candidates"]; !ok {
+		return snapshot, fmt.Errorf("%s is not a valid snapshot: it has no candidates", path)
+	}
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("%s is not a valid snapshot: %w", path, err)
+	}
+	if snapshot.Version > SnapshotVersion {
+		return snapshot, fmt.Errorf("%s is a version %d snapshot, newer than the version %d this build reads; upgrade BuildBloatBuster",
+			path, snapshot.Version, SnapshotVersion)
+	}
+
+	// Snapshots written before candidates had IDs get them here
+	scan.AssignIDs(snapshot.Candidates)
+	loaded := NewSnapshot(snapshot.Candidates)
+	loaded.Version = max(snapshot.Version, 1)
+	return loaded, nil
+}
+
+func writeSnapshot(w io.Writer, snapshot Snapshot) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snapshot)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package report
+
+// terminalWidth can't query the terminal on this platform, so the width
+// falls back to $COLUMNS
+func terminalWidth() (int, bool) {
+	return 0, false
+}
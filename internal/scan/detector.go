@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Detector decides whether a directory is deletable build output. Detectors
+// run for every directory that no exclusion skipped, in order, and the first
+// match makes the directory a candidate; the scan doesn't descend into it.
+// A Detector may be called from several goroutines at once.
+type Detector interface {
+	// Match reports whether dir, an absolute path whose contents are entries,
+	// is a candidate and why. When ok is false, a non-empty reason explains
+	// why a near match was rejected and is shown in the verbose trace.
+	Match(dir string, entries []fs.DirEntry) (reason string, ok bool)
+}
+
+// nameDetector is the built-in detector for includeNames, honouring the
+// profile markers and exceptSibling rules of the names. It only looks at the
+// directory's name and siblings, never its entries.
+type nameDetector struct {
+	rules ruleSet
+}
+
+// nameMatch is what nameDetector found for a selected directory
+type nameMatch struct {
+	// name is the include name the directory matched
+	name string
+	// marker is the project file next to the directory that tells which
+	// ecosystem produced it: the profile marker the name required, or else
+	// the first of manifestFiles found there
+	marker string
+}
+
+// manifestFiles are the project files that tell which ecosystem a directory
+// with a generic name such as target or build belongs to, when no profile
+// marker did. The more specific ones come first, as a package.json often sits
+// next to the manifest of another ecosystem.
+var manifestFiles = []string{
+	"Cargo.toml", "go.mod",
+	"pom.xml", "build.sbt", "build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts",
+	"pyproject.toml", "setup.py", "setup.cfg",
+	"Package.swift", "Podfile", "Cartfile",
+	"Gemfile", "composer.json",
+	"package.json",
+}
+
+func (n nameDetector) Match(dir string, _ []fs.DirEntry) (string, bool) {
+	_, reason, ok := n.match(dir)
+	return reason, ok
+}
+
+// match is Match, also returning the include name and marker it found
+func (n nameDetector) match(dir string) (nameMatch, string, bool) {
+	name := filepath.Base(dir)
+	if _, included := n.rules.includeMap[name]; !included {
+		return nameMatch{}, "", false
+	}
+
+	m := nameMatch{name: name}
+	reason := fmt.Sprintf("matches include pattern '%s'", name)
+	if markers, restricted := n.rules.markers[name]; restricted {
+		m.marker = findMarker(filepath.Dir(dir), markers)
+		if m.marker == "" {
+			// Not build output of a known project
+			return nameMatch{}, fmt.Sprintf("includeNames %q without a profile marker (%s)", name, strings.Join(markers, ", ")), false
+		}
+		reason += fmt.Sprintf(" next to %s", m.marker)
+	}
+	if except := findMarker(filepath.Dir(dir), n.rules.exceptions[name]); except != "" {
+		// Source rather than build output, e.g. a Go vendor directory
+		return nameMatch{}, fmt.Sprintf("rules.%s.exceptSibling %q", name, except), false
+	}
+	if m.marker == "" {
+		m.marker = findMarker(filepath.Dir(dir), manifestFiles)
+	}
+	return m, reason, true
+}
+
+// findMarker returns the first of markers that exists in dir, or ""
+func findMarker(dir string, markers []string) string {
+	for _, marker := range markers {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return marker
+		}
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The rules a Check can name, in the order the walk applies them
+const (
+	RuleLink           = "link"
+	RuleMaxDepth       = "maxDepth"
+	RuleProtected      = "protected path"
+	RuleExcludePaths   = "excludePaths"
+	RuleNetworkFS      = "network filesystem"
+	RuleVersionControl = "version control"
+	RuleExcludeNames   = "excludeNames"
+	RuleExcludeGlobs   = "excludeGlobs"
+	// RuleDetector is includeNames matching, followed by any custom detectors
+	RuleDetector = "detector"
+)
+
+// Outcome is what the scan does with a directory
+type Outcome int
+
+const (
+	// OutcomeDescend walks into the directory without selecting it
+	OutcomeDescend Outcome = iota
+	// OutcomeSkip neither selects the directory nor walks into it
+	OutcomeSkip
+	// OutcomeSelect makes the directory a candidate, which is not walked into
+	OutcomeSelect
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSkip:
+		return "skip"
+	case OutcomeSelect:
+		return "select"
+	default:
+		return "descend"
+	}
+}
+
+// Check is one rule applied to a directory
+type Check struct {
+	Rule string
+	// Applies is set when the rule decided the outcome: it skipped the
+	// directory or, for a detector, selected it
+	Applies bool
+	// Detail says what the rule found, e.g. `excludeNames "src"`. A detector
+	// that didn't apply has an empty detail unless it nearly matched.
+	Detail string
+	// Name and Marker are set when includeNames selected the directory: the
+	// include name it matched and the project file found next to it, if any
+	Name   string
+	Marker string
+}
+
+// Evaluation is every rule applied to a directory, in order, and their outcome
+type Evaluation struct {
+	Path    string
+	Outcome Outcome
+	Checks  []Check
+}
+
+// Decisive returns the check that decided the outcome: the last one, which
+// for a directory that is walked into is the last detector that didn't match
+func (e Evaluation) Decisive() Check {
+	if len(e.Checks) == 0 {
+		return Check{}
+	}
+	return e.Checks[len(e.Checks)-1]
+}
+
+// pass records a rule that let the directory through
+func (e *Evaluation) pass(rule, detail string) {
+	e.Checks = append(e.Checks, Check{Rule: rule, Detail: detail})
+}
+
+// skip records the rule that skipped the directory and returns the evaluation
+func (e *Evaluation) skip(rule, detail string) Evaluation {
+	e.Checks = append(e.Checks, Check{Rule: rule, Applies: true, Detail: detail})
+	e.Outcome = OutcomeSkip
+	return *e
+}
+
+// Explain traces why the scan does or doesn't select the directory at path.
+// It evaluates the rules for every directory from the scan root down to path,
+// stopping at the first one that is not walked into, so the last evaluation
+// is either path's own or the ancestor's that hides it. root is the scan
+// path to start from; if empty, the nearest configured scan path above path
+// is used.
+func (s *Scanner) Explain(path, root string) ([]Evaluation, error) {
+	target, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path for %s: %w", path, err)
+	}
+	if root == "" {
+		if root = s.nearestScanPath(target); root == "" {
+			return nil, fmt.Errorf("%s is not under any scan path (%s)", target, strings.Join(s.config.ScanPaths, ", "))
+		}
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path for %s: %w", root, err)
+	}
+	if absRoot != target && !strings.HasPrefix(target, strings.TrimSuffix(absRoot, string(filepath.Separator))+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is not under the scan path %s", target, absRoot)
+	}
+
+	rules, err := s.rulesFor(absRoot)
+	if err != nil {
+		return nil, err
+	}
+	w := &rootWalk{
+		Scanner:       s,
+		ctx:           context.Background(),
+		root:          absRoot,
+		rules:         rules,
+		detectors:     append([]Detector{nameDetector{rules: rules}}, s.detectors...),
+		skipNetworkFS: !s.config.IncludeNetworkFS && !isNetworkFS(absRoot),
+	}
+
+	var trace []Evaluation
+	for _, dir := range pathsBetween(absRoot, target) {
+		info, err := os.Lstat(dir)
+		if err != nil {
+			return trace, err
+		}
+		d := fs.FileInfoToDirEntry(info)
+
+		var evaluation Evaluation
+		switch {
+		case IsLink(dir, d):
+			evaluation = Evaluation{Path: dir}
+			evaluation.skip(RuleLink, "links are not walked into")
+		case !info.IsDir():
+			return trace, fmt.Errorf("%s is not a directory", dir)
+		default:
+			evaluation = w.evaluate(dir, d)
+		}
+		trace = append(trace, evaluation)
+		if evaluation.Outcome != OutcomeDescend {
+			break
+		}
+	}
+	return trace, nil
+}
+
+// nearestScanPath returns the deepest configured scan path that is or
+// contains path, or "" if there is none
+func (s *Scanner) nearestScanPath(path string) string {
+	var nearest string
+	for _, scanPath := range s.config.ScanPaths {
+		absPath, err := filepath.Abs(scanPath)
+		if err != nil {
+			continue
+		}
+		under := absPath == path || strings.HasPrefix(path, strings.TrimSuffix(absPath, string(filepath.Separator))+string(filepath.Separator))
+		if under && len(absPath) > len(nearest) {
+			nearest = absPath
+		}
+	}
+	return nearest
+}
+
+// pathsBetween returns root and every directory below it down to target
+func pathsBetween(root, target string) []string {
+	paths := []string{root}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." {
+		return paths
+	}
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		paths = append(paths, current)
+	}
+	return paths
+}
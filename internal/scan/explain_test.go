@@ -0,0 +1,101 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+)
+
+func TestScanner_Explain(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"app/node_modules/pkg",
+		"app/dist",
+		"gomod/vendor",
+		"deep/a/b/c/node_modules",
+		"excluded/node_modules",
+		"repo/.git/objects",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "gomod", "go.mod"), nil, 0644))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.MaxDepth = 3
+	cfg.ExcludePaths = []string{filepath.Join(root, "excluded")}
+	cfg.ExcludeNames = append(cfg.ExcludeNames, "dist")
+	cfg.Rules = map[string]config.Rule{"vendor": {ExceptSibling: []string{"go.mod"}}}
+	scanner := NewScanner(cfg)
+
+	tests := []struct {
+		name    string
+		path    string
+		outcome Outcome
+		// decidedAt is the directory whose rules ended the trace, relative to root
+		decidedAt string
+		rule      string
+		detail    string
+	}{
+		{"selected", "app/node_modules", OutcomeSelect, "app/node_modules", RuleDetector, "matches include pattern 'node_modules'"},
+		{"inside a candidate", "app/node_modules/pkg", OutcomeSelect, "app/node_modules", RuleDetector, "matches include pattern 'node_modules'"},
+		{"excludeNames", "app/dist", OutcomeSkip, "app/dist", RuleExcludeNames, `excludeNames "dist" overrides includeNames`},
+		{"exception", "gomod/vendor", OutcomeDescend, "gomod/vendor", RuleDetector, `rules.vendor.exceptSibling "go.mod"`},
+		{"ancestor too deep", "deep/a/b/c/node_modules", OutcomeSkip, "deep/a/b/c", RuleMaxDepth, "maxDepth 3"},
+		{"excludePaths", "excluded/node_modules", OutcomeSkip, "excluded", RuleExcludePaths, `excludePaths "` + filepath.Join(root, "excluded") + `"`},
+		{"version control", "repo/.git/objects", OutcomeSkip, "repo/.git", RuleVersionControl, `version control directory ".git"`},
+		{"walked into", "app", OutcomeDescend, "app", RuleDetector, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace, err := scanner.Explain(filepath.Join(root, tt.path), "")
+			require.NoError(t, err)
+			require.NotEmpty(t, trace)
+
+			// The trace starts at the scan path and goes down one level at a time
+			assert.Equal(t, root, trace[0].Path)
+			for i := 1; i < len(trace); i++ {
+				assert.Equal(t, trace[i-1].Path, filepath.Dir(trace[i].Path))
+				assert.Equal(t, OutcomeDescend, trace[i-1].Outcome)
+			}
+
+			last := trace[len(trace)-1]
+			assert.Equal(t, filepath.Join(root, tt.decidedAt), last.Path)
+			assert.Equal(t, tt.outcome, last.Outcome)
+			decisive := last.Decisive()
+			assert.Equal(t, tt.rule, decisive.Rule)
+			assert.Equal(t, tt.outcome != OutcomeDescend, decisive.Applies)
+			assert.Equal(t, tt.detail, decisive.Detail)
+		})
+	}
+}
+
+func TestScanner_ExplainScanPath(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "nested")
+	target := filepath.Join(nested, "node_modules")
+	require.NoError(t, os.MkdirAll(target, 0755))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root, nested}
+	cfg.ExcludePaths = []string{}
+	scanner := NewScanner(cfg)
+
+	// The nearest scan path is used, unless another one is given
+	trace, err := scanner.Explain(target, "")
+	require.NoError(t, err)
+	assert.Equal(t, nested, trace[0].Path)
+
+	trace, err = scanner.Explain(target, root)
+	require.NoError(t, err)
+	assert.Equal(t, root, trace[0].Path)
+
+	_, err = scanner.Explain(t.TempDir(), "")
+	assert.ErrorContains(t, err, "is not under any scan path")
+	_, err = scanner.Explain(root, nested)
+	assert.ErrorContains(t, err, "is not under the scan path")
+}
@@ -0,0 +1,113 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// GlobalCache is a well-known cache directory outside any project that its
+// tool recreates on demand, so deleting it only costs a re-download.
+type GlobalCache struct {
+	// Name is the tool the cache belongs to, e.g. "gradle"
+	Name string
+	Path string
+}
+
+// GlobalCaches returns the well-known global cache locations for this platform
+func GlobalCaches() []GlobalCache {
+	homeDir, _ := os.UserHomeDir()
+	return globalCaches(runtime.GOOS, os.Getenv, homeDir)
+}
+
+func globalCaches(goos string, getenv func(string) string, homeDir string) []GlobalCache {
+	if homeDir == "" {
+		return nil
+	}
+	home := func(elem ...string) string {
+		return filepath.Join(append([]string{homeDir}, elem...)...)
+	}
+
+	// userCache is where tools following the platform conventions keep caches
+	var userCache string
+	switch goos {
+	case "darwin":
+		userCache = home("Library", "Caches")
+	case "windows":
+		userCache = getenv("LOCALAPPDATA")
+		if userCache == "" {
+			userCache = home("AppData", "Local")
+		}
+	default:
+		// XDG_CACHE_HOME must be absolute to be honoured, as in the spec
+		userCache = getenv("XDG_CACHE_HOME")
+		if userCache == "" || !filepath.IsAbs(userCache) {
+			userCache = home(".cache")
+		}
+	}
+
+	caches := []GlobalCache{
+		{"gradle", home(".gradle", "caches")},
+		{"maven", home(".m2", "repository")},
+		{"cargo", home(".cargo", "registry", "cache")},
+	}
+	switch goos {
+	case "darwin":
+		caches = append(caches,
+			GlobalCache{"pip", filepath.Join(userCache, "pip")},
+			GlobalCache{"npm", home(".npm", "_cacache")},
+			GlobalCache{"yarn", filepath.Join(userCache, "Yarn")},
+			GlobalCache{"pnpm", home("Library", "pnpm", "store")},
+			GlobalCache{"go", filepath.Join(userCache, "go-build")},
+			GlobalCache{"simulator", home("Library", "Developer", "CoreSimulator", "Caches")},
+		)
+	case "windows":
+		caches = append(caches,
+			GlobalCache{"pip", filepath.Join(userCache, "pip", "Cache")},
+			GlobalCache{"npm", filepath.Join(userCache, "npm-cache", "_cacache")},
+			GlobalCache{"yarn", filepath.Join(userCache, "Yarn", "Cache")},
+			GlobalCache{"pnpm", filepath.Join(userCache, "pnpm", "store")},
+			GlobalCache{"go", filepath.Join(userCache, "go-build")},
+		)
+	default:
+		caches = append(caches,
+			GlobalCache{"pip", filepath.Join(userCache, "pip")},
+			GlobalCache{"npm", home(".npm", "_cacache")},
+			GlobalCache{"yarn", filepath.Join(userCache, "yarn")},
+			GlobalCache{"pnpm", home(".local", "share", "pnpm", "store")},
+			GlobalCache{"go", filepath.Join(userCache, "go-build")},
+		)
+	}
+	return caches
+}
+
+// FindGlobalCaches returns a candidate for every global cache that exists.
+// They are picked from a curated list rather than found by walking, so
+// excludePaths such as ~/.cache and ~/Library don't apply to them. On macOS
+// this includes every project in Xcode's DerivedData and the simulator
+// devices on outdated runtimes.
+func FindGlobalCaches() []Candidate {
+	candidates := findGlobalCaches(GlobalCaches())
+	if homeDir, err := os.UserHomeDir(); err == nil && runtime.GOOS == "darwin" {
+		candidates = append(candidates, findXcodeCaches(filepath.Join(homeDir, "Library", "Developer"))...)
+	}
+	return candidates
+}
+
+func findGlobalCaches(caches []GlobalCache) []Candidate {
+	var candidates []Candidate
+	for _, cache := range caches {
+		info, err := os.Lstat(cache.Path)
+		// A linked cache lives elsewhere, so it's left alone like any other link
+		if err != nil || !info.IsDir() || IsLink(cache.Path, nil) {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Path:        cache.Path,
+			Reason:      "global cache: " + cache.Name,
+			GlobalCache: cache.Name,
+			NewestMTime: info.ModTime(),
+		})
+	}
+	return candidates
+}
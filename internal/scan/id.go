@@ -0,0 +1,115 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// IDLength is the number of hex digits in a candidate ID, unless more are
+// needed to tell two candidates apart
+const IDLength = 8
+
+// pathHash returns the hex SHA-256 of the cleaned absolute form of path
+func pathHash(path string) string {
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(path)))
+	return hex.EncodeToString(sum[:])
+}
+
+// AssignIDs gives every candidate an ID derived from its path: the first
+// IDLength hex digits of the SHA-256 of the absolute path, so the same
+// directory keeps its ID across runs. Candidates whose IDs would collide get
+// as many more digits as it takes to tell them apart.
+func AssignIDs(candidates []Candidate) {
+	hashes := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		hashes[i] = pathHash(candidate.Path)
+	}
+
+	// Candidates with the same path share their ID
+	sorted := slices.Clone(hashes)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	for i, hash := range hashes {
+		length := IDLength
+		// The longest prefix shared with another hash is shared with a
+		// neighbour in sorted order
+		j, _ := slices.BinarySearch(sorted, hash)
+		for _, k := range []int{j - 1, j + 1} {
+			if k >= 0 && k < len(sorted) {
+				length = max(length, commonPrefixLen(hash, sorted[k])+1)
+			}
+		}
+		candidates[i].ID = hash[:min(length, len(hash))]
+	}
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// SelectByID returns the candidates with the given IDs, in the order of
+// candidates. An ID may be shortened as long as it still names one candidate.
+// Nothing is returned if any ID is unknown or ambiguous.
+func SelectByID(candidates []Candidate, ids []string) ([]Candidate, error) {
+	hashes := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		hashes[i] = pathHash(candidate.Path)
+	}
+
+	selected := make([]bool, len(candidates))
+	for _, id := range ids {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" || strings.Trim(id, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("invalid ID %q: IDs are hex digits such as a1b2c3d4", id)
+		}
+
+		var matches []int
+		for i, hash := range hashes {
+			if strings.HasPrefix(hash, id) {
+				matches = append(matches, i)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			return nil, fmt.Errorf("no candidate has ID %s", id)
+		case len(matches) > 1 && !samePath(candidates, matches):
+			var paths []string
+			for _, i := range matches {
+				paths = append(paths, candidates[i].Path)
+			}
+			return nil, fmt.Errorf("ID %s matches several candidates (%s), give more digits", id, strings.Join(paths, ", "))
+		}
+		for _, i := range matches {
+			selected[i] = true
+		}
+	}
+
+	var result []Candidate
+	for i, candidate := range candidates {
+		if selected[i] {
+			result = append(result, candidate)
+		}
+	}
+	return result, nil
+}
+
+// samePath reports whether the candidates at indexes all share one path
+func samePath(candidates []Candidate, indexes []int) bool {
+	for _, i := range indexes[1:] {
+		if filepath.Clean(candidates[i].Path) != filepath.Clean(candidates[indexes[0]].Path) {
+			return false
+		}
+	}
+	return true
+}
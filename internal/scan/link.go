@@ -0,0 +1,21 @@
+package scan
+
+import (
+	"io/fs"
+	"os"
+)
+
+// IsLink reports whether the entry at path is a symbolic link or, on Windows,
+// a directory junction or other reparse point. Links are only traversed when
+// FollowSymlinks is set, and are never recursed into when sizing.
+// If d is nil the path is stat'ed instead.
+func IsLink(path string, d fs.DirEntry) bool {
+	if d != nil {
+		if d.Type()&fs.ModeSymlink != 0 {
+			return true
+		}
+	} else if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return true
+	}
+	return isReparsePoint(path)
+}
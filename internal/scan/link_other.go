@@ -0,0 +1,8 @@
+//go:build !windows
+
+package scan
+
+// isReparsePoint is a no-op outside Windows; symlinks are detected via the mode bits.
+func isReparsePoint(path string) bool {
+	return false
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package scan
+
+import (
+	"os"
+	"syscall"
+)
+
+// isReparsePoint checks the Win32 attributes of path for a reparse point,
+// which covers junctions (e.g. pnpm's node_modules links) and directory symlinks.
+func isReparsePoint(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+	return attrs.FileAttributes&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0
+}
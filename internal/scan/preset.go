@@ -0,0 +1,70 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+)
+
+// presetCandidates returns a candidate for every directory of the named
+// presets that exists, for the platform goos and homeDir. Like the global
+// caches they are picked from a curated list, so excludePaths such as
+// ~/Library don't apply to them.
+func presetCandidates(presets []string, goos, homeDir string) []Candidate {
+	var candidates []Candidate
+	for _, name := range presets {
+		preset, ok := config.LookupPreset(name)
+		if !ok {
+			continue
+		}
+		var caches []GlobalCache
+		for _, dir := range preset.Dirs(goos, homeDir) {
+			caches = append(caches, GlobalCache{Name: dir.Label, Path: dir.Path})
+		}
+		for _, candidate := range findGlobalCaches(caches) {
+			candidate.Reason = "preset " + name + ": " + strings.TrimPrefix(candidate.Reason, "global cache: ")
+			// Preset directories are labelled rather than named after their tool
+			candidate.GlobalCache = ""
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// scanPresets passes the directories of the enabled presets to emit, after
+// the scan paths were walked. Those already found by a walk, or inside a
+// directory it found, are left out so nothing is reported twice.
+func (s *Scanner) scanPresets(ctx context.Context, found []string, emit func(Candidate) error) error {
+	homeDir, _ := os.UserHomeDir()
+	seen := make(map[string]bool)
+	for _, candidate := range presetCandidates(s.config.Presets, runtime.GOOS, homeDir) {
+		if seen[candidate.Path] || isWithinAny(candidate.Path, found) {
+			continue
+		}
+		seen[candidate.Path] = true
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !s.reserveResult() {
+			break
+		}
+		s.decide(candidate.Path, true, candidate.Reason)
+		if err := emit(candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isWithinAny reports whether path is one of dirs or lies inside one of them
+func isWithinAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(os.PathSeparator))+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
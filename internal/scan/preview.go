@@ -0,0 +1,80 @@
+package scan
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// PreviewEntry is an immediate child of a directory along with its total size
+type PreviewEntry struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"sizeBytes"`
+	IsDir     bool   `json:"isDir"`
+}
+
+// PreviewDirectory lists the immediate children of path with their sizes,
+// largest first, so a candidate can be sanity-checked before deletion.
+// At most limit entries are returned; a limit <= 0 returns all of them.
+func PreviewDirectory(path string, limit int) ([]PreviewEntry, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := make([]PreviewEntry, 0, len(entries))
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+		item := PreviewEntry{Name: entry.Name()}
+
+		switch {
+		case IsLink(childPath, entry):
+			// Links take no space of their own and are never followed
+		case entry.IsDir():
+			item.IsDir = true
+			item.SizeBytes = treeSize(childPath)
+		default:
+			if info, err := entry.Info(); err == nil {
+				item.SizeBytes = info.Size()
+			}
+		}
+		preview = append(preview, item)
+	}
+
+	sort.SliceStable(preview, func(i, j int) bool {
+		if preview[i].SizeBytes != preview[j].SizeBytes {
+			return preview[i].SizeBytes > preview[j].SizeBytes
+		}
+		return preview[i].Name < preview[j].Name
+	})
+
+	if limit > 0 && len(preview) > limit {
+		preview = preview[:limit]
+	}
+	return preview, nil
+}
+
+// treeSize sums the sizes of all files under root, skipping links and
+// anything that can't be read
+func treeSize(root string) int64 {
+	var total int64
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if IsLink(path, d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
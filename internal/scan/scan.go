@@ -1,184 +1,830 @@
 package scan
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+	"github.com/yehia2amer/BuildBloatBuster/internal/fsinfo"
+	"golang.org/x/sync/errgroup"
 )
 
 // Candidate represents a directory that can be deleted
 type Candidate struct {
-	Path        string    `json:"path"`
-	SizeBytes   int64     `json:"sizeBytes"`
+	// ID is a short hash of the path that stays the same across runs, see AssignIDs
+	ID        string `json:"id,omitempty"`
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"sizeBytes"`
+	// ScanRoot is the absolute scan path the candidate was found under. It
+	// is empty for preset directories and global caches.
+	ScanRoot string `json:"scanRoot,omitempty"`
+	// SharePercent is the percentage of the total size of the reported
+	// candidates that this one takes, set by report.NewSnapshot
+	SharePercent float64 `json:"sharePercent"`
+	// FileCount is the number of files inside, counted along with the size
+	FileCount   int64     `json:"fileCount"`
 	Reason      string    `json:"reason"`
 	NewestMTime time.Time `json:"newestMTime"`
+	// NewestATime is the newest access time of the files inside, zero where
+	// access times aren't recorded or the size came from the cache
+	NewestATime time.Time `json:"newestATime,omitzero"`
+	// SizeIncomplete is set when sizing timed out and SizeBytes is only a lower bound
+	SizeIncomplete bool `json:"sizeIncomplete,omitempty"`
+	// Warning is shown before the candidate is cleaned, for candidates that
+	// are safe to remove but may need manual follow-up
+	Warning string `json:"warning,omitempty"`
+	// MatchedName is the include name the candidate matched. It is empty for
+	// candidates selected by custom detectors, presets and global caches.
+	MatchedName string `json:"matchedName,omitempty"`
+	// Marker is the project file next to the candidate that tells which
+	// ecosystem produced it, if any: the profile marker its include name
+	// required, or else a known manifest such as Cargo.toml or go.mod
+	Marker string `json:"marker,omitempty"`
+	// GlobalCache is the tool a global cache candidate belongs to, e.g. "cargo"
+	GlobalCache string `json:"globalCache,omitempty"`
+	// Informational is set for directories that are only reported, such as
+	// version control directories with scan --report-vcs; clean never
+	// deletes them
+	Informational bool `json:"informational,omitempty"`
+}
+
+// ReadError is a path that was left out of a scan or size because it
+// couldn't be read, and why
+type ReadError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// NewReadError returns the ReadError for path failing with err
+func NewReadError(path string, err error) ReadError {
+	return ReadError{Path: path, Error: err.Error()}
+}
+
+// SortReadErrors sorts errs by path and drops repeated paths
+func SortReadErrors(errs []ReadError) []ReadError {
+	slices.SortFunc(errs, func(a, b ReadError) int { return strings.Compare(a.Path, b.Path) })
+	return slices.CompactFunc(errs, func(a, b ReadError) bool { return a.Path == b.Path })
+}
+
+// ScanProgress is a snapshot of scanning progress passed to the progress callback
+type ScanProgress struct {
+	DirsVisited     int
+	CandidatesFound int
+	CurrentPath     string
+}
+
+// ScanStats counts what the last scan did with the directories it visited.
+// Each visited directory was walked into, skipped or selected, so DirsVisited
+// is the sum of the other counts, unless the scan was capped by MaxResults.
+type ScanStats struct {
+	DirsVisited int
+	// DirsDescended counts the directories walked into
+	DirsDescended int
+	// SkippedDepth counts the directories deeper than maxDepth
+	SkippedDepth int
+	// SkippedExcluded counts the directories left out by excludePaths,
+	// excludeNames, excludeGlobs or as protected paths
+	SkippedExcluded int
+	// SkippedVCS counts version control directories such as .git
+	SkippedVCS int
+	// SkippedNetworkFS counts the network filesystems not entered
+	SkippedNetworkFS int
+	// CandidatesFound counts the candidates, including the directories of
+	// enabled presets, which are not visited
+	CandidatesFound int
+}
+
+// Skipped returns how many visited directories were not walked into
+func (st ScanStats) Skipped() int {
+	return st.SkippedDepth + st.SkippedExcluded + st.SkippedVCS + st.SkippedNetworkFS
+}
+
+// Decision records which rule selected or skipped a directory during a scan
+type Decision struct {
+	Path string
+	// Selected is true when the directory became a candidate
+	Selected bool
+	// Rule names the setting that decided, e.g. `excludeNames "src"`
+	Rule string
+}
+
+// ruleSet holds the name and path filters applied while walking a scan root.
+// Exclusions always beat includes: excludePaths are checked first, then
+// excludeNames and excludeGlobs, and only then includeNames with their markers
+// and exceptions.
+type ruleSet struct {
+	includeMap map[string]struct{}
+	excludeMap map[string]struct{}
+	// excludeGlobs are name patterns that exclude like excludeMap does
+	excludeGlobs map[string]struct{}
+	excludePaths map[string]struct{}
+	// markers restricts included names to directories next to one of the
+	// listed files, as required by the selected profiles
+	markers map[string][]string
+	// exceptions skips included names next to one of the listed files, as
+	// configured by rules.<name>.exceptSibling
+	exceptions map[string][]string
 }
 
 // Scanner handles directory scanning operations
 type Scanner struct {
-	config       config.Config
-	includeMap   map[string]struct{}
-	excludeMap   map[string]struct{}
-	excludePaths map[string]struct{}
+	config config.Config
+	// rules are the filters from the effective config. Scan roots covered by a
+	// project config file use these merged with the project's overrides.
+	rules ruleSet
+	// protectedPaths are critical system paths the walk must never enter,
+	// regardless of how the scan was invoked.
+	protectedPaths map[string]struct{}
+	logger         *slog.Logger
+
+	// mu guards the fields below, which are shared by the walks of scan roots
+	// running concurrently
+	mu sync.Mutex
+	// detectors are the custom detectors run after the built-in ones
+	detectors []Detector
+	// skippedNetworkFS lists the network filesystems skipped by the last scan
+	skippedNetworkFS []string
+	// unreadable lists the directories the last scan skipped because they
+	// couldn't be read
+	unreadable []ReadError
+
+	onProgress func(ScanProgress)
+	// progressThrottle limits how often onProgress is called
+	progressThrottle throttle
+	onDecision       func(Decision)
+	// stats counts the directories of the last scan
+	stats ScanStats
+	// capped is set once a candidate was dropped because
+	// stats.CandidatesFound reached MaxResults, which stops all walks
+	capped bool
+	// reportVCS reports version control directories as informational
+	// candidates instead of only skipping them
+	reportVCS bool
 }
 
-// NewScanner creates a new scanner with the given configuration
-func NewScanner(cfg config.Config) *Scanner {
+// NewScanner creates a new scanner with the given configuration. The extra
+// detectors select more directories, after the built-in includeNames matching.
+func NewScanner(cfg config.Config, detectors ...Detector) *Scanner {
 	s := &Scanner{
-		config:       cfg,
+		config:         cfg,
+		detectors:      detectors,
+		rules:          newRuleSet(),
+		protectedPaths: make(map[string]struct{}),
+		logger:         slog.Default(),
+	}
+
+	s.rules.add(cfg.IncludeNames, cfg.ExcludeNames, cfg.ExcludePaths)
+	// Quarantined items would otherwise be found again when the quarantine
+	// lies below a scan path
+	if cfg.Delete.QuarantineDir != "" {
+		s.rules.add(nil, nil, []string{cfg.Delete.QuarantineDir})
+	}
+	for _, glob := range cfg.ExcludeGlobs {
+		s.rules.excludeGlobs[glob] = struct{}{}
+	}
+	// Profiles are validated when the config is loaded, so unknown names can be ignored here
+	if profile, err := config.ResolveProfiles(cfg.Profiles); err == nil {
+		// A marker only restricts names the profiles bring in; names that
+		// includeNames already selects keep matching anywhere
+		for name, markers := range profile.Rules {
+			if _, included := s.rules.includeMap[name]; !included {
+				s.rules.markers[name] = markers
+			}
+		}
+		s.rules.add(profile.IncludeNames, nil, nil)
+	}
+	for name, rule := range cfg.Rules {
+		s.rules.exceptions[name] = rule.ExceptSibling
+	}
+	for _, path := range config.GetProtectedPaths() {
+		if absPath, err := filepath.Abs(path); err == nil {
+			s.protectedPaths[absPath] = struct{}{}
+		}
+	}
+
+	return s
+}
+
+func newRuleSet() ruleSet {
+	return ruleSet{
 		includeMap:   make(map[string]struct{}),
 		excludeMap:   make(map[string]struct{}),
+		excludeGlobs: make(map[string]struct{}),
 		excludePaths: make(map[string]struct{}),
+		markers:      make(map[string][]string),
+		exceptions:   make(map[string][]string),
 	}
+}
 
+// add extends the rule set with more names and paths
+func (r ruleSet) add(includeNames, excludeNames, excludePaths []string) {
 	// Build lookup maps for O(1) access
-	for _, name := range cfg.IncludeNames {
-		s.includeMap[name] = struct{}{}
+	for _, name := range includeNames {
+		r.includeMap[name] = struct{}{}
 	}
-	for _, name := range cfg.ExcludeNames {
-		s.excludeMap[name] = struct{}{}
+	for _, name := range excludeNames {
+		r.excludeMap[name] = struct{}{}
 	}
-	for _, path := range cfg.ExcludePaths {
+	for _, path := range excludePaths {
 		absPath, err := filepath.Abs(path)
 		if err == nil {
-			s.excludePaths[absPath] = struct{}{}
+			r.excludePaths[absPath] = struct{}{}
 		}
-		s.excludePaths[path] = struct{}{} // Also store original path
+		r.excludePaths[path] = struct{}{} // Also store original path
 	}
+}
 
-	return s
+// clone returns an independent copy of the rule set
+func (r ruleSet) clone() ruleSet {
+	c := newRuleSet()
+	for name := range r.includeMap {
+		c.includeMap[name] = struct{}{}
+	}
+	for name := range r.excludeMap {
+		c.excludeMap[name] = struct{}{}
+	}
+	for glob := range r.excludeGlobs {
+		c.excludeGlobs[glob] = struct{}{}
+	}
+	for path := range r.excludePaths {
+		c.excludePaths[path] = struct{}{}
+	}
+	for name, markers := range r.markers {
+		c.markers[name] = markers
+	}
+	for name, files := range r.exceptions {
+		c.exceptions[name] = files
+	}
+	return c
+}
+
+// excludedByGlob returns the excludeGlobs pattern that dirName matches, the
+// first in sorted order if several do, or "" if none does
+func (r ruleSet) excludedByGlob(dirName string) string {
+	var match string
+	for glob := range r.excludeGlobs {
+		if ok, _ := filepath.Match(glob, dirName); ok && (match == "" || glob < match) {
+			match = glob
+		}
+	}
+	return match
+}
+
+// rulesFor returns the rules used under a scan root: the effective config's
+// rules, merged with the overrides of the nearest project config file at or
+// above the root, if there is one.
+func (s *Scanner) rulesFor(root string) (ruleSet, error) {
+	path := config.FindProjectConfig(root)
+	if path == "" {
+		return s.rules, nil
+	}
+	overrides, err := config.LoadProjectOverrides(path)
+	if err != nil {
+		return ruleSet{}, fmt.Errorf("failed to load project config %s: %w", path, err)
+	}
+
+	rules := s.rules.clone()
+	rules.add(overrides.IncludeNames, overrides.ExcludeNames, overrides.ExcludePaths)
+	for _, name := range overrides.IncludeNames {
+		delete(rules.markers, name)
+	}
+	return rules, nil
+}
+
+// SetLogger sets where the scanner logs the directories it skips because they
+// can't be read; it is slog's default logger unless set
+func (s *Scanner) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// SetReportVCS makes the scan report version control directories, such as
+// .git, as informational candidates with ReasonVCS, so their sizes can be
+// seen. They are still not walked into, and never deleted.
+func (s *Scanner) SetReportVCS(reportVCS bool) {
+	s.reportVCS = reportVCS
 }
 
-// ScanPaths scans all configured paths and returns candidates
+// OnProgress registers a callback invoked as directories are visited during a
+// scan, at most once per progressInterval. It is called synchronously from the
+// walk, so it must be cheap. Calls are serialised even when several scan roots
+// are walked concurrently.
+func (s *Scanner) OnProgress(fn func(ScanProgress)) {
+	s.onProgress = fn
+}
+
+// OnDecision registers a callback invoked whenever a rule selects or skips a
+// directory, to explain conflicting rules. Directories that are merely walked
+// through are not reported. Calls are serialised like OnProgress.
+func (s *Scanner) OnDecision(fn func(Decision)) {
+	s.onDecision = fn
+}
+
+// ScanPaths scans all configured paths and returns candidates. Scan roots are
+// walked concurrently, at most Concurrency (or the number of CPUs) at a time,
+// and the candidates are returned in scan path order. The first error aborts
+// the whole scan.
 func (s *Scanner) ScanPaths() ([]Candidate, error) {
-	var allCandidates []Candidate
+	// The candidates of presets come after those of every scan path
+	results := make([][]Candidate, len(s.config.ScanPaths)+1)
+	err := s.scanRoots(context.Background(), func(_ context.Context, root int, candidate Candidate) error {
+		// Each root is walked by a single goroutine, so its slice needs no lock
+		results[root] = append(results[root], candidate)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for _, scanPath := range s.config.ScanPaths {
-		candidates, err := s.scanPath(scanPath)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning path %s: %w", scanPath, err)
-		}
+	var allCandidates []Candidate
+	for _, candidates := range results {
 		allCandidates = append(allCandidates, candidates...)
 	}
-
 	return allCandidates, nil
 }
 
-// scanPath scans a single path for candidates
-func (s *Scanner) scanPath(rootPath string) ([]Candidate, error) {
-	var candidates []Candidate
+// Stream scans all configured paths like ScanPaths, but sends each candidate
+// to out as soon as it is found, so the caller can start sizing it while the
+// scan goes on. Candidates of different scan paths may be interleaved. out is
+// closed when the scan ends; the error is the first scan error, or ctx's error
+// if the caller stopped receiving by cancelling ctx.
+func (s *Scanner) Stream(ctx context.Context, out chan<- Candidate) error {
+	defer close(out)
+	return s.scanRoots(ctx, func(ctx context.Context, _ int, candidate Candidate) error {
+		select {
+		case out <- candidate:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
 
+// scanRoots walks the scan paths concurrently and calls emit with the index of
+// the scan path and each candidate found under it. Calls for the same scan path
+// are sequential. The first error cancels the ctx passed to emit and the walks.
+// The directories of enabled presets follow, with the index after the last
+// scan path.
+func (s *Scanner) scanRoots(ctx context.Context, emit func(ctx context.Context, root int, candidate Candidate) error) error {
+	s.stats = ScanStats{}
+	s.progressThrottle = throttle{interval: progressInterval}
+	s.capped = false
+	s.skippedNetworkFS = nil
+	s.unreadable = nil
+
+	// found collects the candidates of the walks, which presets must not repeat
+	var foundMu sync.Mutex
+	var found []string
+
+	g, walkCtx := errgroup.WithContext(ctx)
+	g.SetLimit(s.workers())
+	for i, scanPath := range s.config.ScanPaths {
+		g.Go(func() error {
+			err := s.scanPath(walkCtx, scanPath, func(candidate Candidate) error {
+				if len(s.config.Presets) > 0 {
+					foundMu.Lock()
+					found = append(found, candidate.Path)
+					foundMu.Unlock()
+				}
+				return emit(walkCtx, i, candidate)
+			})
+			if err != nil {
+				return fmt.Errorf("error scanning path %s: %w", scanPath, err)
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+	sort.Strings(s.skippedNetworkFS)
+	s.unreadable = SortReadErrors(s.unreadable)
+	if err != nil || len(s.config.Presets) == 0 {
+		return err
+	}
+	return s.scanPresets(ctx, found, func(candidate Candidate) error {
+		return emit(ctx, len(s.config.ScanPaths), candidate)
+	})
+}
+
+// workers returns how many scan roots may be walked at once
+func (s *Scanner) workers() int {
+	if s.config.Concurrency > 0 {
+		return s.config.Concurrency
+	}
+	return runtime.NumCPU()
+}
+
+// scanPath scans a single path, passing each candidate to emit as it is found
+func (s *Scanner) scanPath(ctx context.Context, rootPath string, emit func(Candidate) error) error {
 	absRootPath, err := filepath.Abs(rootPath)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get absolute path for %s: %w", rootPath, err)
+		return fmt.Errorf("unable to get absolute path for %s: %w", rootPath, err)
 	}
 
-	// Check if root path itself is excluded
-	if s.isPathExcluded(absRootPath) {
-		return candidates, nil // Skip entirely
+	rules, err := s.rulesFor(absRootPath)
+	if err != nil {
+		return err
 	}
 
-	err = filepath.WalkDir(absRootPath, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			// Skip directories we can't read
-			if os.IsPermission(err) {
-				return filepath.SkipDir
-			}
-			return err
-		}
+	// A protected or excluded root is skipped by the first visit, like any
+	// other directory
+	w := &rootWalk{
+		Scanner:   s,
+		ctx:       ctx,
+		root:      absRootPath,
+		rules:     rules,
+		detectors: append([]Detector{nameDetector{rules: rules}}, s.detectors...),
+		emit:      emit,
+		// A scan path that is itself on a network filesystem was asked for
+		// explicitly, so only mounts below a local scan path are skipped
+		skipNetworkFS: !s.config.IncludeNetworkFS && !isNetworkFS(absRootPath),
+	}
+	return WalkDir(absRootPath, s.config.ReadRetries, w.visit)
+}
 
-		if !d.IsDir() {
-			return nil // Skip files
-		}
+// rootWalk is the state of walking a single scan root
+type rootWalk struct {
+	*Scanner
+	ctx  context.Context
+	root string
+	// rules shadow the Scanner's, adding the project overrides for this root
+	rules         ruleSet
+	detectors     []Detector
+	emit          func(Candidate) error
+	skipNetworkFS bool
+}
 
-		// Get relative depth from root
-		relPath, err := filepath.Rel(absRootPath, path)
-		if err != nil {
-			return nil
+// visit is the filepath.WalkDirFunc of a scan root
+func (w *rootWalk) visit(path string, d fs.DirEntry, err error) error {
+	// Stop early when another scan root failed
+	if ctxErr := w.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	// Stop once a scan root found more candidates than MaxResults allows
+	if w.Capped() {
+		return filepath.SkipAll
+	}
+	if err != nil {
+		// Skip directories we can't read
+		if os.IsPermission(err) {
+			w.logger.Debug("skipping unreadable directory", "path", path, "error", err)
+			w.recordUnreadable(path, err)
+			return filepath.SkipDir
 		}
-
-		depth := strings.Count(relPath, string(filepath.Separator))
-		if relPath == "." {
-			depth = 0
+		// A directory that kept failing to read is skipped rather than
+		// aborting the scan; only a scan root that can't be found is fatal
+		if d != nil {
+			w.logger.Warn("skipping directory that could not be read", "path", path, "error", err)
+			w.recordUnreadable(path, err)
+			w.decide(path, false, "read error")
+			return filepath.SkipDir
 		}
+		return err
+	}
 
-		// Check max depth
-		if s.config.MaxDepth > 0 && depth >= s.config.MaxDepth {
+	// Symlinks, junctions and other reparse points are not traversed
+	// unless the user asked to follow them
+	if !w.config.FollowSymlinks && IsLink(path, d) {
+		if d.IsDir() {
 			return filepath.SkipDir
 		}
+		return nil
+	}
 
-		// Check if path is excluded
-		if s.isPathExcluded(path) {
-			return filepath.SkipDir
+	if !d.IsDir() {
+		return nil // Skip files
+	}
+
+	w.visitDir(path)
+
+	evaluation := w.evaluate(path, d)
+	for _, check := range evaluation.Checks {
+		// Detectors that nearly matched explain themselves in the trace
+		if !check.Applies && check.Rule == RuleDetector && check.Detail != "" {
+			w.decide(path, false, check.Detail)
 		}
+	}
 
-		// Check if this is a symlink and we're not following them
-		if !s.config.FollowSymlinks {
-			if info, err := d.Info(); err == nil && info.Mode()&os.ModeSymlink != 0 {
-				return filepath.SkipDir
+	decisive := evaluation.Decisive()
+	w.countOutcome(evaluation.Outcome, decisive.Rule)
+	switch evaluation.Outcome {
+	case OutcomeSkip:
+		switch decisive.Rule {
+		case RuleVersionControl:
+			// Not worth a line in the trace
+			if w.reportVCS {
+				if err := w.emit(Candidate{Path: path, ScanRoot: w.root, Reason: ReasonVCS, Informational: true}); err != nil {
+					return err
+				}
 			}
+		case RuleNetworkFS:
+			w.mu.Lock()
+			w.skippedNetworkFS = append(w.skippedNetworkFS, path)
+			w.mu.Unlock()
+			fallthrough
+		default:
+			w.decide(path, false, decisive.Detail)
+		}
+		return filepath.SkipDir
+	case OutcomeSelect:
+		// Another scan root may have reached MaxResults since visit checked
+		if !w.reserveResult() {
+			return filepath.SkipAll
+		}
+		rule := decisive.Detail
+		if decisive.Name != "" {
+			rule = fmt.Sprintf("includeNames %q", decisive.Name)
+		}
+		w.decide(path, true, rule)
+
+		// This is a candidate, don't descend into it
+		candidate := Candidate{
+			Path:        path,
+			ScanRoot:    w.root,
+			Reason:      decisive.Detail,
+			MatchedName: decisive.Name,
+			Marker:      decisive.Marker,
+			SizeBytes:   0, // Will be calculated later
 		}
 
-		dirName := d.Name()
-
-		// Check if directory name is a VCS dir
-		if s.isVersionControlDir(dirName) {
-			return filepath.SkipDir
+		// Get modification time
+		if info, err := d.Info(); err == nil {
+			candidate.NewestMTime = info.ModTime()
 		}
 
-		// Check if directory name is excluded
-		if _, excluded := s.excludeMap[dirName]; excluded {
-			return filepath.SkipDir
+		if err := w.emit(candidate); err != nil {
+			return err
 		}
+		return filepath.SkipDir
+	}
 
-		// Check if directory name is included
-		if _, included := s.includeMap[dirName]; included {
-			// This is a candidate, don't descend into it
-			candidate := Candidate{
-				Path:      path,
-				Reason:    fmt.Sprintf("matches include pattern '%s'", dirName),
-				SizeBytes: 0, // Will be calculated later
-			}
+	// Continue traversing
+	return nil
+}
 
-			// Get modification time
-			if info, err := d.Info(); err == nil {
-				candidate.NewestMTime = info.ModTime()
-			}
+// evaluate applies the scan rules to the directory at path in order: the
+// filters that stop the walk from entering it (depth, protected and excluded
+// paths, network filesystems, VCS directories and excludeNames), then the
+// detectors. Exclusions are checked before any detector, so they always win.
+// It has no side effects, so it both drives the walk and explains it.
+func (w *rootWalk) evaluate(path string, d fs.DirEntry) Evaluation {
+	e := Evaluation{Path: path}
+	dirName := d.Name()
+
+	// Get relative depth from root
+	depth := 0
+	if relPath, err := filepath.Rel(w.root, path); err == nil && relPath != "." {
+		depth = strings.Count(relPath, string(filepath.Separator))
+	}
 
-			candidates = append(candidates, candidate)
-			return filepath.SkipDir
+	// Check max depth
+	if w.config.MaxDepth > 0 && depth >= w.config.MaxDepth {
+		return e.skip(RuleMaxDepth, fmt.Sprintf("maxDepth %d", w.config.MaxDepth))
+	}
+	if w.config.MaxDepth > 0 {
+		e.pass(RuleMaxDepth, fmt.Sprintf("depth %d is within maxDepth %d", depth, w.config.MaxDepth))
+	} else {
+		e.pass(RuleMaxDepth, fmt.Sprintf("depth %d, maxDepth is unlimited", depth))
+	}
+
+	// Never descend into protected system paths, even if the CLI let the
+	// scan path through (defense-in-depth)
+	if w.isProtectedPath(path) {
+		return e.skip(RuleProtected, "protected path")
+	}
+	e.pass(RuleProtected, "not a protected path")
+
+	// Check if path is excluded
+	if excludePath := w.rules.excludedBy(path); excludePath != "" {
+		return e.skip(RuleExcludePaths, fmt.Sprintf("excludePaths %q", excludePath))
+	}
+	e.pass(RuleExcludePaths, "not under any excludePaths entry")
+
+	if w.skipNetworkFS && isNetworkFS(path) {
+		return e.skip(RuleNetworkFS, "network filesystem")
+	}
+	if w.skipNetworkFS {
+		e.pass(RuleNetworkFS, "not a network filesystem")
+	}
+
+	// Check if directory name is a VCS dir
+	if w.isVersionControlDir(dirName) {
+		return e.skip(RuleVersionControl, fmt.Sprintf("version control directory %q", dirName))
+	}
+	e.pass(RuleVersionControl, "not a version control directory")
+
+	// Check if directory name is excluded; this wins over includeNames
+	if _, excluded := w.rules.excludeMap[dirName]; excluded {
+		rule := fmt.Sprintf("excludeNames %q", dirName)
+		if _, included := w.rules.includeMap[dirName]; included {
+			rule += " overrides includeNames"
 		}
+		return e.skip(RuleExcludeNames, rule)
+	}
+	e.pass(RuleExcludeNames, fmt.Sprintf("%q is not in excludeNames", dirName))
+	if glob := w.rules.excludedByGlob(dirName); glob != "" {
+		return e.skip(RuleExcludeGlobs, fmt.Sprintf("excludeGlobs %q", glob))
+	}
+	if len(w.rules.excludeGlobs) > 0 {
+		e.pass(RuleExcludeGlobs, fmt.Sprintf("%q matches no excludeGlobs pattern", dirName))
+	}
 
-		// Continue traversing
-		return nil
-	})
+	// Only custom detectors look at the contents, so only read them for those
+	var entries []fs.DirEntry
+	if len(w.detectors) > 1 {
+		entries, _ = os.ReadDir(path)
+	}
 
-	if err != nil {
-		return nil, err
+	// The first match makes it a candidate, which the walk doesn't descend into
+	for _, detector := range w.detectors {
+		var match nameMatch
+		var reason string
+		var ok bool
+		if names, builtin := detector.(nameDetector); builtin {
+			match, reason, ok = names.match(path)
+		} else {
+			reason, ok = detector.Match(path, entries)
+		}
+		if ok {
+			e.Checks = append(e.Checks, Check{Rule: RuleDetector, Applies: true, Detail: reason, Name: match.name, Marker: match.marker})
+			e.Outcome = OutcomeSelect
+			return e
+		}
+		e.pass(RuleDetector, reason)
 	}
+	return e
+}
+
+// reserveResult counts a candidate about to be emitted. It returns false when
+// MaxResults was already reached and the candidate must be dropped. Only then
+// is the scan capped: finding exactly MaxResults candidates leaves nothing out.
+func (s *Scanner) reserveResult() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.MaxResults > 0 && s.stats.CandidatesFound >= s.config.MaxResults {
+		s.capped = true
+		return false
+	}
+	s.stats.CandidatesFound++
+	return true
+}
 
-	return candidates, nil
+// Capped reports whether the last scan stopped early because it found more
+// than MaxResults candidates, so some were left out
+func (s *Scanner) Capped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.capped
+}
+
+// Stats returns the directory counts of the last scan
+func (s *Scanner) Stats() ScanStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// SkippedNetworkFS returns the network filesystems the last scan did not enter
+func (s *Scanner) SkippedNetworkFS() []string {
+	return s.skippedNetworkFS
+}
+
+// Unreadable returns the directories the last scan skipped because they
+// couldn't be read, such as those without read permission
+func (s *Scanner) Unreadable() []string {
+	paths := make([]string, len(s.unreadable))
+	for i, readErr := range s.unreadable {
+		paths[i] = readErr.Path
+	}
+	return paths
+}
+
+// ReadErrors returns the directories the last scan skipped because they
+// couldn't be read, with the errors reading them failed with
+func (s *Scanner) ReadErrors() []ReadError {
+	return s.unreadable
+}
+
+// recordUnreadable adds path, which failed to read with err, to the
+// directories that couldn't be read
+func (s *Scanner) recordUnreadable(path string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unreadable = append(s.unreadable, NewReadError(path, err))
+}
+
+// decide reports a rule decision to the decision callback, if any
+func (s *Scanner) decide(path string, selected bool, rule string) {
+	if s.onDecision == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onDecision(Decision{Path: path, Selected: selected, Rule: rule})
+}
+
+// visitDir counts a visited directory and reports progress
+func (s *Scanner) visitDir(currentPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.DirsVisited++
+	s.reportProgress(currentPath)
+}
+
+// countOutcome counts a visited directory as walked into or skipped by rule.
+// Candidates are counted by reserveResult.
+func (s *Scanner) countOutcome(outcome Outcome, rule string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if outcome == OutcomeDescend {
+		s.stats.DirsDescended++
+		return
+	}
+	if outcome != OutcomeSkip {
+		return
+	}
+	switch rule {
+	case RuleMaxDepth:
+		s.stats.SkippedDepth++
+	case RuleVersionControl:
+		s.stats.SkippedVCS++
+	case RuleNetworkFS:
+		s.stats.SkippedNetworkFS++
+	default:
+		s.stats.SkippedExcluded++
+	}
+}
+
+// reportProgress invokes the progress callback, if any and unless it was
+// called less than progressInterval ago. The caller must hold s.mu.
+func (s *Scanner) reportProgress(currentPath string) {
+	if s.onProgress == nil || !s.progressThrottle.allow(time.Now()) {
+		return
+	}
+	s.onProgress(ScanProgress{
+		DirsVisited:     s.stats.DirsVisited,
+		CandidatesFound: s.stats.CandidatesFound,
+		CurrentPath:     currentPath,
+	})
+}
+
+// isNetworkFS is the check used by the scanner; tests replace it to simulate mounts
+var isNetworkFS = fsinfo.IsNetworkFS
+
+// progressInterval is the least time between two calls of the progress
+// callback. A scan visits directories much faster than progress can be read.
+var progressInterval = 100 * time.Millisecond
+
+// throttle lets calls through at most once per interval
+type throttle struct {
+	interval time.Duration
+	last     time.Time
+}
+
+// allow reports whether a call at now may go through, recording it if so.
+// The first call always does.
+func (t *throttle) allow(now time.Time) bool {
+	if !t.last.IsZero() && now.Sub(t.last) < t.interval {
+		return false
+	}
+	t.last = now
+	return true
 }
 
 // isPathExcluded checks if a path should be excluded
-func (s *Scanner) isPathExcluded(path string) bool {
+func (r ruleSet) isPathExcluded(path string) bool {
+	return r.excludedBy(path) != ""
+}
+
+// excludedBy returns the excludePaths entry that path is or lies under, or ""
+func (r ruleSet) excludedBy(path string) string {
 	// Check direct path exclusion
-	if _, excluded := s.excludePaths[path]; excluded {
-		return true
+	if _, excluded := r.excludePaths[path]; excluded {
+		return path
 	}
 
 	// Check if path is under any excluded directory
-	for excludePath := range s.excludePaths {
+	for excludePath := range r.excludePaths {
 		if strings.HasPrefix(path, excludePath+string(filepath.Separator)) {
-			return true
+			return excludePath
 		}
 	}
 
-	return false
+	return ""
+}
+
+// isProtectedPath checks if a path is one of the critical system paths
+func (s *Scanner) isProtectedPath(path string) bool {
+	_, protected := s.protectedPaths[filepath.Clean(path)]
+	return protected
 }
 
 // isVersionControlDir checks if the directory name is a version control directory.
@@ -191,15 +837,19 @@ func (s *Scanner) isVersionControlDir(dirName string) bool {
 	}
 }
 
+// ReasonVCS is the reason of the version control directories reported with
+// SetReportVCS
+const ReasonVCS = "VCS — not deletable"
+
 // IsSafeToDelete performs additional safety checks on a candidate
 func (s *Scanner) IsSafeToDelete(candidate Candidate) bool {
-	// Don't delete if it's a version control directory
-	if s.isVersionControlDir(candidate.Path) {
+	// Don't delete if it's a version control directory, or only reported
+	if candidate.Informational || s.isVersionControlDir(filepath.Base(candidate.Path)) {
 		return false
 	}
 
 	// Don't delete if it's an excluded path
-	if s.isPathExcluded(candidate.Path) {
+	if s.rules.isPathExcluded(candidate.Path) {
 		return false
 	}
 
@@ -209,29 +859,47 @@ func (s *Scanner) IsSafeToDelete(candidate Candidate) bool {
 	if s.isProjectRoot(parentDir) {
 		// Only allow if the directory name is in our include list
 		dirName := filepath.Base(candidate.Path)
-		_, included := s.includeMap[dirName]
+		_, included := s.rules.includeMap[dirName]
 		return included
 	}
 
 	return true
 }
 
-// isProjectRoot checks if a directory appears to be a project root
+// projectFiles are the built-in markers of a project root; config
+// projectMarkers adds to them
+var projectFiles = []string{
+	".git", ".svn", ".hg",
+	"package.json", "package-lock.json", "yarn.lock",
+	"go.mod", "go.sum",
+	"Cargo.toml", "Cargo.lock",
+	"pom.xml", "build.gradle", "build.gradle.kts",
+	"requirements.txt", "setup.py", "pyproject.toml",
+	"Gemfile", "Gemfile.lock",
+	"composer.json", "composer.lock",
+}
+
+// isProjectRoot checks if a directory appears to be a project root: it holds
+// one of projectFiles or a file matching one of the configured projectMarkers
 func (s *Scanner) isProjectRoot(path string) bool {
-	projectFiles := []string{
-		".git", ".svn", ".hg",
-		"package.json", "package-lock.json", "yarn.lock",
-		"go.mod", "go.sum",
-		"Cargo.toml", "Cargo.lock",
-		"pom.xml", "build.gradle", "build.gradle.kts",
-		"requirements.txt", "setup.py", "pyproject.toml",
-		"Gemfile", "Gemfile.lock",
-		"composer.json", "composer.lock",
-	}
-
-	for _, file := range projectFiles {
-		if _, err := os.Stat(filepath.Join(path, file)); err == nil {
-			return true
+	var entries []os.DirEntry
+	listed := false
+	for _, file := range slices.Concat(projectFiles, s.config.ProjectMarkers) {
+		if !strings.ContainsAny(file, "*?[") {
+			if _, err := os.Stat(filepath.Join(path, file)); err == nil {
+				return true
+			}
+			continue
+		}
+		// Patterns are matched against the names in the directory, listed once
+		if !listed {
+			entries, _ = os.ReadDir(path)
+			listed = true
+		}
+		for _, entry := range entries {
+			if matched, _ := filepath.Match(file, entry.Name()); matched {
+				return true
+			}
 		}
 	}
 
@@ -1,9 +1,15 @@
 package scan
 
 import (
+	"context"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -145,3 +151,994 @@ func TestScanner_ScanPaths(t *testing.T) {
 		assert.False(t, foundGit, "should not find '.git' because it's a VCS folder")
 	})
 }
+
+func TestScanner_SkipsProtectedPaths(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// Simulate a walk that reaches /usr by protecting a directory inside the tree
+	fakeUsr := filepath.Join(tmpDir, "usr")
+	require.NoError(t, os.MkdirAll(filepath.Join(fakeUsr, "share", "node_modules"), 0755))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{tmpDir}
+	cfg.ExcludePaths = []string{}
+	scanner := NewScanner(cfg)
+	scanner.protectedPaths[fakeUsr] = struct{}{}
+
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+
+	for _, c := range candidates {
+		assert.NotContains(t, c.Path, fakeUsr, "should not descend into a protected path")
+	}
+	assert.Len(t, candidates, 3)
+
+	t.Run("protected scan root yields nothing", func(t *testing.T) {
+		cfg.ScanPaths = []string{fakeUsr}
+		scanner := NewScanner(cfg)
+		scanner.protectedPaths[fakeUsr] = struct{}{}
+
+		candidates, err := scanner.ScanPaths()
+		require.NoError(t, err)
+		assert.Empty(t, candidates)
+	})
+}
+
+func TestScanner_SkipsLinkedDirectories(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// A linked directory whose target contains a candidate
+	if err := os.Symlink(filepath.Join(tmpDir, "project2"), filepath.Join(tmpDir, "project3")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{tmpDir}
+	cfg.ExcludePaths = []string{}
+	scanner := NewScanner(cfg)
+
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+
+	assert.Len(t, candidates, 3)
+	for _, c := range candidates {
+		assert.NotContains(t, c.Path, "project3")
+	}
+}
+
+func TestScanner_ProjectConfigOverrides(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// A monorepo whose dist is real release output, plus an unrelated project
+	monorepo := filepath.Join(tmpDir, "monorepo")
+	require.NoError(t, os.MkdirAll(filepath.Join(monorepo, "dist"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(monorepo, "web", "node_modules"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(monorepo, "legacy", "node_modules"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(monorepo, "tools", ".angular"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "other", "dist"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(monorepo, config.LocalConfigName),
+		[]byte("excludeNames: [dist]\nexcludePaths: [legacy]\nincludeNames: [.angular]\n"), 0644))
+
+	cfg := config.GetDefaults()
+	cfg.ExcludePaths = []string{}
+	paths := func(candidates []Candidate) []string {
+		var result []string
+		for _, c := range candidates {
+			result = append(result, c.Path)
+		}
+		return result
+	}
+
+	t.Run("overrides apply under the project", func(t *testing.T) {
+		// Scanning a subdirectory still picks up the config above it
+		cfg.ScanPaths = []string{monorepo, filepath.Join(monorepo, "web"), filepath.Join(tmpDir, "other")}
+		candidates, err := NewScanner(cfg).ScanPaths()
+		require.NoError(t, err)
+
+		found := paths(candidates)
+		assert.Contains(t, found, filepath.Join(monorepo, "web", "node_modules"))
+		assert.Contains(t, found, filepath.Join(monorepo, "tools", ".angular"))
+		assert.Contains(t, found, filepath.Join(tmpDir, "other", "dist"), "other roots keep the global rules")
+		assert.NotContains(t, found, filepath.Join(monorepo, "dist"))
+		assert.NotContains(t, found, filepath.Join(monorepo, "legacy", "node_modules"))
+	})
+
+	t.Run("global rules are unchanged", func(t *testing.T) {
+		cfg.ScanPaths = []string{filepath.Join(tmpDir, "other")}
+		scanner := NewScanner(cfg)
+		_, err := scanner.rulesFor(monorepo)
+		require.NoError(t, err)
+
+		assert.Contains(t, scanner.rules.includeMap, "dist")
+		assert.NotContains(t, scanner.rules.excludeMap, "dist")
+		assert.NotContains(t, scanner.rules.includeMap, ".angular")
+	})
+}
+
+func TestScanner_ProfileRules(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A Rust crate, a Maven project and a folder that just happens to be called target
+	mkdir := func(parts ...string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(append([]string{tmpDir}, parts...)...), 0755))
+	}
+	touch := func(parts ...string) {
+		require.NoError(t, os.WriteFile(filepath.Join(append([]string{tmpDir}, parts...)...), nil, 0644))
+	}
+	mkdir("crate", "target")
+	touch("crate", "Cargo.toml")
+	mkdir("maven", "target")
+	touch("maven", "pom.xml")
+	mkdir("docs", "target", "node_modules")
+	mkdir("pods", "Pods")
+	touch("pods", "Podfile")
+
+	scanReasons := func(cfg config.Config) map[string]string {
+		candidates, err := NewScanner(cfg).ScanPaths()
+		require.NoError(t, err)
+		reasons := make(map[string]string)
+		for _, c := range candidates {
+			rel, err := filepath.Rel(tmpDir, c.Path)
+			require.NoError(t, err)
+			reasons[filepath.ToSlash(rel)] = c.Reason
+		}
+		return reasons
+	}
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{tmpDir}
+	cfg.ExcludePaths = []string{}
+	cfg.Profiles = []string{"rust", "ios"}
+
+	t.Run("names only a profile includes need its marker", func(t *testing.T) {
+		cfg := cfg
+		cfg.IncludeNames = []string{"node_modules"}
+		reasons := scanReasons(cfg)
+
+		assert.Contains(t, reasons["crate/target"], "next to Cargo.toml")
+		assert.Contains(t, reasons["pods/Pods"], "next to Podfile")
+		assert.NotContains(t, reasons, "maven/target", "only the rust profile restricts target")
+		assert.NotContains(t, reasons, "docs/target")
+		assert.Contains(t, reasons, "docs/target/node_modules", "unmatched directories are still walked")
+	})
+
+	t.Run("default include names still match anywhere", func(t *testing.T) {
+		reasons := scanReasons(cfg)
+
+		assert.Contains(t, reasons, "crate/target")
+		assert.Contains(t, reasons, "maven/target", "the rust profile doesn't take target away from the defaults")
+		assert.Contains(t, reasons, "docs/target")
+		assert.Contains(t, reasons, "pods/Pods")
+	})
+}
+
+func TestScanner_RecordsMatchedNameAndMarker(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"crate/target", "gomod/build", "docs/target", "web/node_modules"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "crate", "Cargo.toml"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "gomod", "go.mod"), nil, 0644))
+
+	// No profile is active, so the manifests next to generic names are recorded
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.ExcludePaths = []string{}
+	candidates, err := NewScanner(cfg).ScanPaths()
+	require.NoError(t, err)
+
+	found := make(map[string]Candidate)
+	for _, c := range candidates {
+		rel, err := filepath.Rel(root, c.Path)
+		require.NoError(t, err)
+		found[filepath.ToSlash(rel)] = c
+	}
+	require.Len(t, found, 4)
+	assert.Equal(t, "target", found["crate/target"].MatchedName)
+	assert.Equal(t, "Cargo.toml", found["crate/target"].Marker)
+	assert.Equal(t, "go.mod", found["gomod/build"].Marker)
+	assert.Equal(t, "target", found["docs/target"].MatchedName)
+	assert.Empty(t, found["docs/target"].Marker)
+	assert.Equal(t, "node_modules", found["web/node_modules"].MatchedName)
+	assert.Equal(t, "matches include pattern 'target'", found["crate/target"].Reason, "a manifest isn't a restriction")
+}
+
+func TestScanner_RulePrecedence(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"gomod/vendor/pkg", "rails/vendor", "web/dist", "web/node_modules"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "gomod", "go.mod"), nil, 0644))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.ExcludePaths = []string{}
+	cfg.ExcludeNames = append(cfg.ExcludeNames, "dist")
+	cfg.Rules = map[string]config.Rule{"vendor": {ExceptSibling: []string{"go.mod"}}}
+	scanner := NewScanner(cfg)
+
+	decisions := make(map[string]Decision)
+	scanner.OnDecision(func(d Decision) {
+		rel, err := filepath.Rel(root, d.Path)
+		require.NoError(t, err)
+		decisions[rel] = d
+	})
+
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+
+	var found []string
+	for _, c := range candidates {
+		rel, err := filepath.Rel(root, c.Path)
+		require.NoError(t, err)
+		found = append(found, rel)
+	}
+	assert.ElementsMatch(t, []string{filepath.Join("rails", "vendor"), filepath.Join("web", "node_modules")}, found)
+
+	// The trace names the rule that decided each directory
+	assert.Equal(t, Decision{
+		Path: filepath.Join(root, "gomod", "vendor"),
+		Rule: `rules.vendor.exceptSibling "go.mod"`,
+	}, decisions[filepath.Join("gomod", "vendor")])
+	assert.Equal(t, `excludeNames "dist" overrides includeNames`, decisions[filepath.Join("web", "dist")].Rule)
+	assert.True(t, decisions[filepath.Join("rails", "vendor")].Selected)
+	assert.Equal(t, `includeNames "vendor"`, decisions[filepath.Join("rails", "vendor")].Rule)
+}
+
+func TestScanner_ExcludeGlobs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"app/node_modules",
+		"tmp-build/node_modules",
+		"tmpbuild/node_modules",
+		"mypkg.egg-info/node_modules",
+		"egg-info/node_modules",
+		"legacy/node_modules",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.ExcludePaths = []string{}
+	cfg.ExcludeNames = append(cfg.ExcludeNames, "legacy")
+	cfg.ExcludeGlobs = []string{"*.egg-info", "tmp-*"}
+	scanner := NewScanner(cfg)
+
+	decisions := make(map[string]string)
+	scanner.OnDecision(func(d Decision) {
+		decisions[filepath.Base(d.Path)] = d.Rule
+	})
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+
+	var found []string
+	for _, c := range candidates {
+		rel, err := filepath.Rel(root, c.Path)
+		require.NoError(t, err)
+		found = append(found, filepath.Dir(rel))
+	}
+	// tmpbuild and egg-info look alike but match neither pattern
+	assert.ElementsMatch(t, []string{"app", "tmpbuild", "egg-info"}, found)
+	assert.Equal(t, `excludeGlobs "tmp-*"`, decisions["tmp-build"])
+	assert.Equal(t, `excludeGlobs "*.egg-info"`, decisions["mypkg.egg-info"])
+	assert.Equal(t, `excludeNames "legacy"`, decisions["legacy"])
+}
+
+func TestScanner_Stats(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"app/node_modules/pkg",
+		"app/src",
+		"repo/.git/objects",
+		"excluded/node_modules",
+		"deep/a/b/c/node_modules",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "app", "package.json"), nil, 0644))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.MaxDepth = 3
+	cfg.ExcludePaths = []string{filepath.Join(root, "excluded")}
+	scanner := NewScanner(cfg)
+
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+
+	// Directories inside candidates and skipped directories are not visited
+	stats := scanner.Stats()
+	assert.Equal(t, ScanStats{
+		DirsVisited:     11,
+		DirsDescended:   6, // root, app, repo, deep, deep/a and deep/a/b
+		SkippedDepth:    1, // deep/a/b/c
+		SkippedExcluded: 2, // excluded and app/src
+		SkippedVCS:      1, // repo/.git
+		CandidatesFound: 1,
+	}, stats)
+	assert.Equal(t, stats.DirsVisited, stats.DirsDescended+stats.Skipped()+stats.CandidatesFound)
+
+	// The counts start over with every scan
+	_, err = scanner.ScanPaths()
+	require.NoError(t, err)
+	assert.Equal(t, stats, scanner.Stats())
+}
+
+func TestScanner_ReportVCS(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+	gitDir := filepath.Join(tmpDir, "project1", ".git")
+	require.NoError(t, os.MkdirAll(filepath.Join(gitDir, "objects", "node_modules"), 0755))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{tmpDir}
+	cfg.ExcludePaths = []string{}
+	scanner := NewScanner(cfg)
+
+	// By default version control directories are only skipped
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+	for _, candidate := range candidates {
+		assert.NotEqual(t, gitDir, candidate.Path)
+	}
+
+	// With SetReportVCS they are reported, but still not walked into
+	scanner.SetReportVCS(true)
+	candidates, err = scanner.ScanPaths()
+	require.NoError(t, err)
+	var vcs []Candidate
+	for _, candidate := range candidates {
+		if candidate.Informational {
+			vcs = append(vcs, candidate)
+		}
+	}
+	require.Len(t, vcs, 1)
+	assert.Equal(t, gitDir, vcs[0].Path)
+	assert.Equal(t, ReasonVCS, vcs[0].Reason)
+	assert.Len(t, candidates, 4, "nothing inside .git is reported")
+
+	// Either way they are never safe to delete
+	assert.False(t, scanner.IsSafeToDelete(vcs[0]))
+	assert.False(t, scanner.IsSafeToDelete(Candidate{Path: gitDir}))
+}
+
+func TestScanner_ExcludesQuarantineDir(t *testing.T) {
+	workspace := t.TempDir()
+	quarantineDir := filepath.Join(workspace, ".bbb-trash")
+	for _, dir := range []string{
+		"app/node_modules",
+		// A quarantined node_modules still holds nested ones
+		".bbb-trash/20240101-120000-node_modules/pkg/node_modules",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(workspace, dir), 0755))
+	}
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{workspace}
+	cfg.ExcludePaths = []string{}
+	cfg.Delete.QuarantineDir = quarantineDir
+
+	candidates, err := NewScanner(cfg).ScanPaths()
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, filepath.Join(workspace, "app", "node_modules"), candidates[0].Path)
+}
+
+// buildCacheDetector is an example custom detector: it selects directories
+// that contain a .buildcache marker file.
+type buildCacheDetector struct{}
+
+func (buildCacheDetector) Match(dir string, entries []fs.DirEntry) (string, bool) {
+	for _, entry := range entries {
+		if entry.Name() == ".buildcache" && !entry.IsDir() {
+			return "contains a .buildcache marker", true
+		}
+	}
+	return "", false
+}
+
+func TestScanner_IsSafeToDelete_ProjectMarkers(t *testing.T) {
+	root := t.TempDir()
+	deno := filepath.Join(root, "deno-app")
+	dotnet := filepath.Join(root, "dotnet-svc")
+	for _, dir := range []string{filepath.Join(deno, ".output"), filepath.Join(dotnet, ".output")} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(deno, "deno.json"), []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dotnet, "Service.csproj"), nil, 0644))
+
+	// .output isn't an include name, so inside a project root it isn't safe
+	tests := []struct {
+		name    string
+		markers []string
+		path    string
+		want    bool
+	}{
+		{"unknown marker", nil, filepath.Join(deno, ".output"), true},
+		{"custom marker", []string{"deno.json"}, filepath.Join(deno, ".output"), false},
+		{"unknown pattern", []string{"deno.json"}, filepath.Join(dotnet, ".output"), true},
+		{"custom pattern", []string{"*.csproj"}, filepath.Join(dotnet, ".output"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaults()
+			cfg.ExcludePaths = []string{}
+			cfg.ProjectMarkers = tt.markers
+			assert.Equal(t, tt.want, NewScanner(cfg).IsSafeToDelete(Candidate{Path: tt.path}))
+		})
+	}
+}
+
+func TestScanner_CustomDetector(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cache := filepath.Join(tmpDir, "project2", "tool-output")
+	require.NoError(t, os.MkdirAll(filepath.Join(cache, "objects"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(cache, ".buildcache"), nil, 0644))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{tmpDir}
+	cfg.ExcludePaths = []string{}
+
+	candidates, err := NewScanner(cfg, buildCacheDetector{}).ScanPaths()
+	require.NoError(t, err)
+
+	reasons := make(map[string]string)
+	for _, c := range candidates {
+		reasons[c.Path] = c.Reason
+	}
+	assert.Len(t, candidates, 4, "the built-in detector still finds its 3 candidates")
+	assert.Equal(t, "contains a .buildcache marker", reasons[cache])
+
+	// Exclusions beat custom detectors too
+	cfg.ExcludeNames = append(cfg.ExcludeNames, "tool-output")
+	candidates, err = NewScanner(cfg, buildCacheDetector{}).ScanPaths()
+	require.NoError(t, err)
+	assert.Len(t, candidates, 3)
+}
+
+func TestGlobalCaches(t *testing.T) {
+	paths := func(caches []GlobalCache) map[string]string {
+		m := make(map[string]string)
+		for _, cache := range caches {
+			m[cache.Name] = cache.Path
+		}
+		return m
+	}
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	linux := paths(globalCaches("linux", env(map[string]string{"XDG_CACHE_HOME": "/xdg"}), "/home/me"))
+	assert.Equal(t, filepath.Join("/home/me", ".gradle", "caches"), linux["gradle"])
+	assert.Equal(t, filepath.Join("/home/me", ".m2", "repository"), linux["maven"])
+	assert.Equal(t, filepath.Join("/xdg", "pip"), linux["pip"])
+	assert.NotContains(t, linux, "xcode")
+
+	darwin := paths(globalCaches("darwin", env(nil), "/Users/me"))
+	assert.Equal(t, filepath.Join("/Users/me", "Library", "Caches", "pip"), darwin["pip"])
+	assert.Equal(t, filepath.Join("/Users/me", "Library", "Developer", "CoreSimulator", "Caches"), darwin["simulator"])
+	// DerivedData is listed per project by findXcodeCaches instead
+	assert.NotContains(t, darwin, "xcode")
+
+	windows := paths(globalCaches("windows", env(map[string]string{"LOCALAPPDATA": `C:\Users\me\AppData\Local`}), `C:\Users\me`))
+	assert.Equal(t, filepath.Join(`C:\Users\me\AppData\Local`, "pip", "Cache"), windows["pip"])
+
+	assert.Empty(t, globalCaches("linux", env(nil), ""))
+}
+
+func TestFindGlobalCaches(t *testing.T) {
+	home := t.TempDir()
+	caches := globalCaches("linux", func(string) string { return "" }, home)
+
+	gradle := filepath.Join(home, ".gradle", "caches")
+	require.NoError(t, os.MkdirAll(gradle, 0755))
+	// Only directories count, and linked caches are left alone
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".npm"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".npm", "_cacache"), nil, 0644))
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".m2"), 0755))
+		require.NoError(t, os.Symlink(t.TempDir(), filepath.Join(home, ".m2", "repository")))
+	}
+
+	candidates := findGlobalCaches(caches)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, gradle, candidates[0].Path)
+	assert.Equal(t, "global cache: gradle", candidates[0].Reason)
+}
+
+func TestPresetCandidates(t *testing.T) {
+	home := t.TempDir()
+	derivedData := filepath.Join(home, "Library", "Developer", "Xcode", "DerivedData")
+	gradle := filepath.Join(home, ".gradle", "caches")
+	for _, dir := range []string{derivedData, gradle} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+
+	reasons := func(candidates []Candidate) map[string]string {
+		m := make(map[string]string)
+		for _, candidate := range candidates {
+			m[candidate.Path] = candidate.Reason
+		}
+		return m
+	}
+
+	// Only the directories that exist are reported, and DerivedData only on macOS
+	assert.Equal(t, map[string]string{
+		gradle:      "preset mobile: gradle caches",
+		derivedData: "preset mobile: xcode DerivedData",
+	}, reasons(presetCandidates([]string{"mobile"}, "darwin", home)))
+	assert.Equal(t, map[string]string{
+		gradle: "preset mobile: gradle caches",
+	}, reasons(presetCandidates([]string{"mobile"}, "linux", home)))
+	assert.Empty(t, presetCandidates(nil, "darwin", home))
+}
+
+func TestScanner_Presets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	gradle := filepath.Join(home, ".gradle", "caches")
+	androidCache := filepath.Join(home, ".android", "build-cache")
+	for _, dir := range []string{gradle, androidCache} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	project := filepath.Join(t.TempDir(), "app")
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "node_modules"), 0755))
+
+	cfg := config.GetDefaults()
+	cfg.ExcludePaths = []string{}
+	cfg.ScanPaths = []string{project}
+
+	// Without the preset, nothing outside the scan path is reported
+	candidates, err := NewScanner(cfg).ScanPaths()
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+
+	// The preset's directories come after the scan path's, though no
+	// project file sits next to them
+	cfg.Presets = []string{"mobile"}
+	candidates, err = NewScanner(cfg).ScanPaths()
+	require.NoError(t, err)
+	require.Len(t, candidates, 3)
+	assert.Equal(t, filepath.Join(project, "node_modules"), candidates[0].Path)
+	assert.ElementsMatch(t, []string{gradle, androidCache}, []string{candidates[1].Path, candidates[2].Path})
+
+	// Directories a walk already found, or inside one, aren't repeated
+	cfg.ScanPaths = []string{project, filepath.Join(home, ".android")}
+	cfg.IncludeNames = append(cfg.IncludeNames, "build-cache")
+	candidates, err = NewScanner(cfg).ScanPaths()
+	require.NoError(t, err)
+	require.Len(t, candidates, 3)
+	assert.Equal(t, androidCache, candidates[1].Path)
+	assert.Equal(t, "matches include pattern 'build-cache'", candidates[1].Reason)
+}
+
+func TestFindXcodeCaches(t *testing.T) {
+	developer := t.TempDir()
+	project := t.TempDir()
+	workspace := filepath.Join(project, "App.xcodeproj")
+	require.NoError(t, os.MkdirAll(workspace, 0755))
+
+	writePlist := func(path string, values map[string]string) {
+		var dict strings.Builder
+		for key, value := range values {
+			fmt.Fprintf(&dict, "\t<key>%s</key>\n\t<string>%s</string>\n", key, value)
+		}
+		plist := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>state</key>
+	<integer>1</integer>
+	<key>nested</key>
+	<dict><key>name</key><string>ignored</string></dict>
+` + dict.String() + `</dict>
+</plist>
+`
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(plist), 0644))
+	}
+
+	derivedData := filepath.Join(developer, "Xcode", "DerivedData")
+	writePlist(filepath.Join(derivedData, "App-abcdef", "info.plist"), map[string]string{"WorkspacePath": workspace})
+	writePlist(filepath.Join(derivedData, "Old-123456", "info.plist"), map[string]string{"WorkspacePath": "/gone/Old.xcworkspace"})
+	require.NoError(t, os.MkdirAll(filepath.Join(derivedData, "Other-fedcba"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(derivedData, "ModuleCache.noindex"), 0755))
+
+	devices := filepath.Join(developer, "CoreSimulator", "Devices")
+	writePlist(filepath.Join(devices, "AAAA", "device.plist"), map[string]string{"name": "iPhone 15", "runtime": "com.apple.CoreSimulator.SimRuntime.iOS-17-2"})
+	writePlist(filepath.Join(devices, "BBBB", "device.plist"), map[string]string{"name": "iPhone 13", "runtime": "com.apple.CoreSimulator.SimRuntime.iOS-15-0"})
+	writePlist(filepath.Join(devices, "CCCC", "device.plist"), map[string]string{"name": "Apple Watch", "runtime": "com.apple.CoreSimulator.SimRuntime.watchOS-9-1"})
+	require.NoError(t, os.WriteFile(filepath.Join(devices, "device_set.plist"), nil, 0644))
+
+	reasons := map[string]string{}
+	warnings := map[string]string{}
+	for _, c := range findXcodeCaches(developer) {
+		reasons[c.Path] = c.Reason
+		warnings[c.Path] = c.Warning
+	}
+
+	assert.Equal(t, map[string]string{
+		filepath.Join(derivedData, "App-abcdef"):          "global cache: xcode DerivedData of App (" + workspace + ")",
+		filepath.Join(derivedData, "Old-123456"):          "global cache: xcode DerivedData of Old (/gone/Old.xcworkspace, project gone)",
+		filepath.Join(derivedData, "Other-fedcba"):        "global cache: xcode DerivedData of Other",
+		filepath.Join(derivedData, "ModuleCache.noindex"): "global cache: xcode ModuleCache.noindex",
+		// Only the device on an older runtime than another of its platform
+		filepath.Join(devices, "BBBB"): "global cache: simulator iPhone 13 (iOS 15.0, older runtime)",
+	}, reasons)
+	assert.Contains(t, warnings[filepath.Join(devices, "BBBB")], "xcrun simctl delete BBBB")
+	assert.Empty(t, warnings[filepath.Join(derivedData, "App-abcdef")])
+}
+
+func TestScanner_SkipsNetworkFS(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// Pretend project2 is an NFS mount
+	mount := filepath.Join(tmpDir, "project2")
+	original := isNetworkFS
+	isNetworkFS = func(path string) bool { return path == mount }
+	defer func() { isNetworkFS = original }()
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{tmpDir}
+	cfg.ExcludePaths = []string{}
+
+	scanner := NewScanner(cfg)
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+	assert.Len(t, candidates, 2, "vendor inside the mount should be skipped")
+	assert.Equal(t, []string{mount}, scanner.SkippedNetworkFS())
+
+	t.Run("included on request", func(t *testing.T) {
+		cfg.IncludeNetworkFS = true
+		candidates, err := NewScanner(cfg).ScanPaths()
+		require.NoError(t, err)
+		assert.Len(t, candidates, 3)
+	})
+
+	t.Run("scan path on the mount is scanned", func(t *testing.T) {
+		cfg.IncludeNetworkFS = false
+		cfg.ScanPaths = []string{mount}
+		candidates, err := NewScanner(cfg).ScanPaths()
+		require.NoError(t, err)
+		assert.Len(t, candidates, 1)
+	})
+}
+
+func TestScanner_MultipleRoots(t *testing.T) {
+	var roots, want []string
+	for i := 0; i < 5; i++ {
+		root := t.TempDir()
+		for _, dir := range []string{"app/node_modules", "app/src/lib", "svc/target"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+		}
+		roots = append(roots, root)
+		want = append(want, filepath.Join(root, "app", "node_modules"), filepath.Join(root, "svc", "target"))
+	}
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = roots
+	cfg.ExcludePaths = []string{}
+	cfg.Concurrency = 3
+	scanner := NewScanner(cfg)
+
+	noProgressThrottle(t)
+	var last ScanProgress
+	calls := 0
+	scanner.OnProgress(func(p ScanProgress) {
+		calls++
+		last = p
+	})
+
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+
+	// Every root is scanned and the candidates keep the scan path order
+	var got []string
+	for i, c := range candidates {
+		got = append(got, c.Path)
+		assert.Equal(t, roots[i/2], c.ScanRoot, "each candidate records its scan path")
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, calls, last.DirsVisited)
+
+	t.Run("first error aborts", func(t *testing.T) {
+		cfg.ScanPaths = append(roots, filepath.Join(roots[0], "missing"))
+		_, err := NewScanner(cfg).ScanPaths()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "error scanning path")
+	})
+}
+
+func TestScanner_MaxResults(t *testing.T) {
+	var roots []string
+	for i := 0; i < 3; i++ {
+		root := t.TempDir()
+		for j := 0; j < 10; j++ {
+			require.NoError(t, os.MkdirAll(filepath.Join(root, fmt.Sprintf("app%d", j), "node_modules", "pkg"), 0755))
+		}
+		roots = append(roots, root)
+	}
+
+	tests := []struct {
+		name       string
+		maxResults int
+		want       int
+		wantCapped bool
+	}{
+		{"unlimited", 0, 30, false},
+		{"cap", 4, 4, true},
+		{"cap equal to results", 30, 30, false},
+		{"cap above results", 50, 30, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaults()
+			cfg.ScanPaths = roots
+			cfg.ExcludePaths = []string{}
+			cfg.MaxResults = tt.maxResults
+			scanner := NewScanner(cfg)
+
+			candidates, err := scanner.ScanPaths()
+			require.NoError(t, err)
+			assert.Len(t, candidates, tt.want)
+			assert.Equal(t, tt.wantCapped, scanner.Capped())
+		})
+	}
+}
+
+func TestScanner_Stream(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{tmpDir}
+	cfg.ExcludePaths = []string{}
+
+	want, err := NewScanner(cfg).ScanPaths()
+	require.NoError(t, err)
+
+	out := make(chan Candidate)
+	errc := make(chan error, 1)
+	go func() { errc <- NewScanner(cfg).Stream(context.Background(), out) }()
+
+	var got []Candidate
+	for candidate := range out {
+		got = append(got, candidate)
+	}
+	require.NoError(t, <-errc)
+	assert.ElementsMatch(t, want, got)
+
+	t.Run("stops when the consumer cancels", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		out := make(chan Candidate)
+		err := NewScanner(cfg).Stream(ctx, out)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, open := <-out
+		assert.False(t, open, "out is closed when the scan ends")
+	})
+}
+
+// BenchmarkScanPaths_Roots compares walking several scan roots one at a time
+// with walking them concurrently.
+func BenchmarkScanPaths_Roots(b *testing.B) {
+	var roots []string
+	for i := 0; i < 8; i++ {
+		root := b.TempDir()
+		for p := 0; p < 50; p++ {
+			for _, dir := range []string{"node_modules", "src/components/ui", "docs/guide"} {
+				require.NoError(b, os.MkdirAll(filepath.Join(root, fmt.Sprintf("project%d", p), dir), 0755))
+			}
+		}
+		roots = append(roots, root)
+	}
+
+	for _, bm := range []struct {
+		name        string
+		concurrency int
+	}{
+		{"serial", 1},
+		{"parallel", 8},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			cfg := config.GetDefaults()
+			cfg.ScanPaths = roots
+			cfg.ExcludePaths = []string{}
+			cfg.Concurrency = bm.concurrency
+			scanner := NewScanner(cfg)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				candidates, err := scanner.ScanPaths()
+				require.NoError(b, err)
+				require.Len(b, candidates, len(roots)*50)
+			}
+		})
+	}
+}
+
+func TestScanner_OnProgress(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{tmpDir}
+	cfg.ExcludePaths = []string{}
+	scanner := NewScanner(cfg)
+
+	noProgressThrottle(t)
+	var last ScanProgress
+	calls := 0
+	scanner.OnProgress(func(p ScanProgress) {
+		calls++
+		last = p
+	})
+
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+
+	assert.Equal(t, calls, last.DirsVisited)
+	assert.Greater(t, last.DirsVisited, len(candidates))
+	assert.NotEmpty(t, last.CurrentPath)
+}
+
+// noProgressThrottle reports progress for every directory visited
+func noProgressThrottle(t *testing.T) {
+	t.Helper()
+	original := progressInterval
+	t.Cleanup(func() { progressInterval = original })
+	progressInterval = 0
+}
+
+func TestThrottle(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	th := throttle{interval: 100 * time.Millisecond}
+
+	assert.True(t, th.allow(start), "the first call goes through")
+	assert.False(t, th.allow(start.Add(10*time.Millisecond)))
+	assert.False(t, th.allow(start.Add(99*time.Millisecond)))
+	assert.True(t, th.allow(start.Add(100*time.Millisecond)))
+	// The interval counts from the last call let through
+	assert.False(t, th.allow(start.Add(150*time.Millisecond)))
+	assert.True(t, th.allow(start.Add(250*time.Millisecond)))
+}
+
+func TestScanner_OnProgressThrottled(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, fmt.Sprintf("app%d", i), "docs"), 0755))
+	}
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.ExcludePaths = []string{}
+	scanner := NewScanner(cfg)
+	original := progressInterval
+	t.Cleanup(func() { progressInterval = original })
+	progressInterval = time.Hour
+
+	calls := 0
+	scanner.OnProgress(func(ScanProgress) { calls++ })
+	_, err := scanner.ScanPaths()
+	require.NoError(t, err)
+
+	// Only the first directory is reported within the interval
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 41, scanner.Stats().DirsVisited)
+}
+
+func TestPreviewDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	// small.txt (10), medium/ (2 files, 300 total), large.bin (500), tiny/ (1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "small.txt"), make([]byte, 10), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "medium", "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "medium", "a"), make([]byte, 100), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "medium", "nested", "b"), make([]byte, 200), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "large.bin"), make([]byte, 500), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tiny"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tiny", "c"), make([]byte, 1), 0644))
+
+	preview, err := PreviewDirectory(dir, 0)
+	require.NoError(t, err)
+	require.Len(t, preview, 4)
+	assert.Equal(t, []PreviewEntry{
+		{Name: "large.bin", SizeBytes: 500},
+		{Name: "medium", SizeBytes: 300, IsDir: true},
+		{Name: "small.txt", SizeBytes: 10},
+		{Name: "tiny", SizeBytes: 1, IsDir: true},
+	}, preview)
+
+	limited, err := PreviewDirectory(dir, 2)
+	require.NoError(t, err)
+	assert.Equal(t, preview[:2], limited)
+}
+
+func TestScanner_PathsThatAreCandidates(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// Paths piped from scan --format plain are candidates themselves
+	cfg := config.GetDefaults()
+	cfg.ExcludePaths = []string{}
+	cfg.ScanPaths = []string{
+		filepath.Join(tmpDir, "project1", "node_modules"),
+		filepath.Join(tmpDir, "project1", "deep", "nested", "target"),
+		filepath.Join(tmpDir, "project1", ".git"),
+	}
+	candidates, err := NewScanner(cfg).ScanPaths()
+	require.NoError(t, err)
+
+	var paths []string
+	for _, candidate := range candidates {
+		paths = append(paths, candidate.Path)
+	}
+	assert.ElementsMatch(t, cfg.ScanPaths[:2], paths, "the safety rules still apply, so .git is not a candidate")
+}
+
+func TestAssignIDs(t *testing.T) {
+	// The SHA-256 of the first two paths starts with the same 8 hex digits
+	candidates := []Candidate{
+		{Path: "/p/app14006/node_modules"},
+		{Path: "/p/app71119/node_modules"},
+		{Path: "/p/app/node_modules"},
+		{Path: "/p/app/node_modules/"},
+	}
+	AssignIDs(candidates)
+
+	assert.Equal(t, "0d19ad7e9", candidates[0].ID)
+	assert.Equal(t, "0d19ad7e7", candidates[1].ID)
+	assert.Equal(t, "f18b733b", candidates[2].ID)
+	assert.Equal(t, "f18b733b", candidates[3].ID, "the same directory has the same ID")
+
+	again := []Candidate{{Path: "/p/app/node_modules"}}
+	AssignIDs(again)
+	assert.Equal(t, candidates[2].ID, again[0].ID, "IDs are stable across runs")
+}
+
+func TestSelectByID(t *testing.T) {
+	candidates := []Candidate{
+		{Path: "/p/app14006/node_modules"},
+		{Path: "/p/app71119/node_modules"},
+		{Path: "/p/app/node_modules"},
+	}
+	AssignIDs(candidates)
+
+	tests := []struct {
+		name    string
+		ids     []string
+		want    []string
+		wantErr string
+	}{
+		{name: "one ID", ids: []string{"f18b733b"}, want: []string{"/p/app/node_modules"}},
+		{name: "several IDs keep scan order", ids: []string{"f18b733b", "0d19ad7e7"}, want: []string{"/p/app71119/node_modules", "/p/app/node_modules"}},
+		{name: "shortened and upper case", ids: []string{"F18B"}, want: []string{"/p/app/node_modules"}},
+		{name: "ambiguous prefix", ids: []string{"0d19ad7e"}, wantErr: "matches several candidates"},
+		{name: "unknown ID", ids: []string{"deadbeef"}, wantErr: "no candidate has ID deadbeef"},
+		{name: "not hex", ids: []string{"node"}, wantErr: "invalid ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := SelectByID(candidates, tt.ids)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				assert.Nil(t, selected)
+				return
+			}
+			require.NoError(t, err)
+			var paths []string
+			for _, candidate := range selected {
+				paths = append(paths, candidate.Path)
+			}
+			assert.Equal(t, tt.want, paths)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package scan
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// readDir reads a directory for WalkDir; tests replace it to inject failures
+var readDir = os.ReadDir
+
+// retryBackoff is how long WalkDir waits before reading a directory again;
+// the wait doubles with every further attempt
+var retryBackoff = 100 * time.Millisecond
+
+// WalkDir walks the tree at root like filepath.WalkDir, except that reading a
+// directory is retried up to retries times, with backoff, when it fails with
+// an error other than a permission or not-exist error. Such errors are often
+// transient on network mounts. Only the final error is passed to fn.
+func WalkDir(root string, retries int, fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(root, fs.FileInfoToDirEntry(info), retries, fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDir walks the directory entry d at path, as filepath.WalkDir does
+func walkDir(path string, d fs.DirEntry, retries int, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			// Skipped this directory
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := readDirRetrying(path, retries)
+	if err != nil {
+		// Second call, to report the read error
+		if err := fn(path, d, err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := walkDir(filepath.Join(path, entry.Name()), entry, retries, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readDirRetrying reads the directory at path, retrying errors that may be
+// transient up to retries times
+func readDirRetrying(path string, retries int) ([]fs.DirEntry, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		entries, err := readDir(path)
+		if err == nil || attempt >= retries || os.IsPermission(err) || os.IsNotExist(err) {
+			return entries, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
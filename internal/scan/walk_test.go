@@ -0,0 +1,163 @@
+package scan
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/config"
+)
+
+// flakyReadDir makes reading dir fail failures times with an I/O error
+// before it succeeds, and counts the attempts
+func flakyReadDir(t *testing.T, dir string, failures int) *int {
+	t.Helper()
+	originalReadDir, originalBackoff := readDir, retryBackoff
+	t.Cleanup(func() { readDir, retryBackoff = originalReadDir, originalBackoff })
+
+	var mu sync.Mutex
+	attempts := 0
+	retryBackoff = 0
+	readDir = func(path string) ([]fs.DirEntry, error) {
+		if path == dir {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts++
+			if attempts <= failures {
+				return nil, &fs.PathError{Op: "readdirent", Path: path, Err: errors.New("input/output error")}
+			}
+		}
+		return os.ReadDir(path)
+	}
+	return &attempts
+}
+
+func TestWalkDir_RetriesTransientErrors(t *testing.T) {
+	root := t.TempDir()
+	flaky := filepath.Join(root, "mnt")
+	inner := filepath.Join(flaky, "inner")
+	require.NoError(t, os.MkdirAll(inner, 0755))
+
+	tests := []struct {
+		name         string
+		retries      int
+		wantAttempts int
+		wantVisited  []string
+		wantErrs     int
+	}{
+		{"fails once then succeeds", 1, 2, []string{root, flaky, inner}, 0},
+		{"no retries", 0, 1, []string{root, flaky}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := flakyReadDir(t, flaky, 1)
+
+			var visited []string
+			var walkErrs []error
+			err := WalkDir(root, tt.retries, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					walkErrs = append(walkErrs, err)
+					return nil
+				}
+				visited = append(visited, path)
+				return nil
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantAttempts, *attempts)
+			assert.Equal(t, tt.wantVisited, visited)
+			assert.Len(t, walkErrs, tt.wantErrs)
+		})
+	}
+}
+
+func TestWalkDir_DoesNotRetryPermissionErrors(t *testing.T) {
+	root := t.TempDir()
+	originalReadDir := readDir
+	t.Cleanup(func() { readDir = originalReadDir })
+
+	attempts := 0
+	readDir = func(path string) ([]fs.DirEntry, error) {
+		attempts++
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrPermission}
+	}
+
+	var walkErr error
+	require.NoError(t, WalkDir(root, 3, func(path string, d fs.DirEntry, err error) error {
+		walkErr = err
+		return nil
+	}))
+	assert.Equal(t, 1, attempts)
+	assert.ErrorIs(t, walkErr, fs.ErrPermission)
+}
+
+func TestScanner_ReadRetries(t *testing.T) {
+	root := t.TempDir()
+	flaky := filepath.Join(root, "nfs")
+	require.NoError(t, os.MkdirAll(filepath.Join(flaky, "app", "node_modules"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "local", "node_modules"), 0755))
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.ExcludePaths = []string{}
+
+	t.Run("retried", func(t *testing.T) {
+		flakyReadDir(t, flaky, 1)
+		cfg.ReadRetries = 2
+		candidates, err := NewScanner(cfg).ScanPaths()
+		require.NoError(t, err)
+		assert.Len(t, candidates, 2)
+	})
+
+	t.Run("skipped once retries run out", func(t *testing.T) {
+		flakyReadDir(t, flaky, 3)
+		cfg.ReadRetries = 2
+		scanner := NewScanner(cfg)
+		var skipped []Decision
+		scanner.OnDecision(func(d Decision) {
+			if !d.Selected {
+				skipped = append(skipped, d)
+			}
+		})
+
+		// The other directories are still scanned
+		candidates, err := scanner.ScanPaths()
+		require.NoError(t, err)
+		require.Len(t, candidates, 1)
+		assert.Equal(t, filepath.Join(root, "local", "node_modules"), candidates[0].Path)
+		assert.Contains(t, skipped, Decision{Path: flaky, Rule: "read error"})
+	})
+}
+
+func TestScanner_Unreadable(t *testing.T) {
+	root := t.TempDir()
+	locked := filepath.Join(root, "locked")
+	require.NoError(t, os.MkdirAll(filepath.Join(locked, "node_modules"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "app", "node_modules"), 0755))
+
+	originalReadDir := readDir
+	t.Cleanup(func() { readDir = originalReadDir })
+	readDir = func(path string) ([]fs.DirEntry, error) {
+		if path == locked {
+			return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrPermission}
+		}
+		return os.ReadDir(path)
+	}
+
+	cfg := config.GetDefaults()
+	cfg.ScanPaths = []string{root}
+	cfg.ExcludePaths = []string{}
+	scanner := NewScanner(cfg)
+
+	// The unreadable directory is skipped, and recorded
+	candidates, err := scanner.ScanPaths()
+	require.NoError(t, err)
+	assert.Len(t, candidates, 1)
+	assert.Equal(t, []string{locked}, scanner.Unreadable())
+	assert.Equal(t, []ReadError{{Path: locked, Error: "open " + locked + ": permission denied"}}, scanner.ReadErrors())
+}
@@ -0,0 +1,196 @@
+package scan
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// findXcodeCaches returns the per-project DerivedData directories and the
+// simulator devices on outdated runtimes below developerDir, which is
+// ~/Library/Developer on macOS
+func findXcodeCaches(developerDir string) []Candidate {
+	candidates := findDerivedData(filepath.Join(developerDir, "Xcode", "DerivedData"))
+	return append(candidates, findOldSimulators(filepath.Join(developerDir, "CoreSimulator", "Devices"))...)
+}
+
+// findDerivedData returns a candidate for every project in Xcode's DerivedData,
+// named after the workspace recorded in the info.plist Xcode keeps there
+func findDerivedData(derivedData string) []Candidate {
+	var candidates []Candidate
+	for _, dir := range subdirs(derivedData) {
+		name := filepath.Base(dir.Path)
+		var reason string
+		plist, err := readPlistStrings(filepath.Join(dir.Path, "info.plist"))
+		switch workspace := plist["WorkspacePath"]; {
+		case err == nil && workspace != "":
+			project := strings.TrimSuffix(filepath.Base(workspace), filepath.Ext(workspace))
+			reason = fmt.Sprintf("global cache: xcode DerivedData of %s (%s", project, workspace)
+			if _, err := os.Stat(workspace); err != nil {
+				reason += ", project gone"
+			}
+			reason += ")"
+		case strings.HasSuffix(name, ".noindex"):
+			// Shared caches such as ModuleCache.noindex
+			reason = "global cache: xcode " + name
+		default:
+			// Project folders are named <project>-<hash>
+			if i := strings.LastIndex(name, "-"); i > 0 {
+				name = name[:i]
+			}
+			reason = "global cache: xcode DerivedData of " + name
+		}
+		dir.Reason = reason
+		dir.GlobalCache = "xcode"
+		candidates = append(candidates, dir)
+	}
+	return candidates
+}
+
+// findOldSimulators returns the simulator devices whose runtime is older than
+// the newest runtime of the same platform that any device uses. Their data
+// is often left behind when Xcode is updated.
+func findOldSimulators(devicesDir string) []Candidate {
+	type device struct {
+		candidate Candidate
+		name      string
+		platform  string
+		version   []int
+	}
+
+	var devices []device
+	newest := map[string][]int{}
+	for _, dir := range subdirs(devicesDir) {
+		plist, err := readPlistStrings(filepath.Join(dir.Path, "device.plist"))
+		if err != nil {
+			continue
+		}
+		platform, version, ok := parseSimRuntime(plist["runtime"])
+		if !ok {
+			continue
+		}
+		devices = append(devices, device{candidate: dir, name: plist["name"], platform: platform, version: version})
+		if slices.Compare(version, newest[platform]) > 0 {
+			newest[platform] = version
+		}
+	}
+
+	var candidates []Candidate
+	for _, d := range devices {
+		if slices.Compare(d.version, newest[d.platform]) >= 0 {
+			continue
+		}
+		runtime := fmt.Sprintf("%s %s", d.platform, joinVersion(d.version))
+		d.candidate.Reason = fmt.Sprintf("global cache: simulator %s (%s, older runtime)", d.name, runtime)
+		d.candidate.GlobalCache = "simulator"
+		d.candidate.Warning = fmt.Sprintf("Xcode keeps listing simulator %q until it is removed with `xcrun simctl delete %s`; restore it from the quarantine if Xcode reports it as broken",
+			d.name, filepath.Base(d.candidate.Path))
+		candidates = append(candidates, d.candidate)
+	}
+	return candidates
+}
+
+// subdirs returns a candidate without a reason for every directory in dir,
+// leaving out links like the global caches themselves
+func subdirs(dir string) []Candidate {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var candidates []Candidate
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() || IsLink(path, entry) {
+			continue
+		}
+		candidate := Candidate{Path: path}
+		if info, err := entry.Info(); err == nil {
+			candidate.NewestMTime = info.ModTime()
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// parseSimRuntime splits a runtime identifier such as
+// com.apple.CoreSimulator.SimRuntime.iOS-17-2 into platform and version
+func parseSimRuntime(id string) (string, []int, bool) {
+	_, runtime, found := strings.Cut(id, "SimRuntime.")
+	parts := strings.Split(runtime, "-")
+	if !found || len(parts) < 2 {
+		return "", nil, false
+	}
+	version := make([]int, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return "", nil, false
+		}
+		version = append(version, n)
+	}
+	return parts[0], version, true
+}
+
+func joinVersion(version []int) string {
+	parts := make([]string, len(version))
+	for i, n := range version {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// readPlistStrings returns the string values of the top-level dictionary of
+// an XML property list. Binary property lists are reported as an error.
+func readPlistStrings(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	decoder := xml.NewDecoder(file)
+	var key string
+	isPlist := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF && isPlist {
+			return values, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an XML property list: %w", path, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case start.Name.Local == "plist":
+			isPlist = true
+		case start.Name.Local == "key":
+			if err := decoder.DecodeElement(&key, &start); err != nil {
+				return nil, err
+			}
+		case key == "":
+			// The top-level <dict>, entered before the first key
+		case start.Name.Local == "string":
+			var value string
+			if err := decoder.DecodeElement(&value, &start); err != nil {
+				return nil, err
+			}
+			values[key] = value
+			key = ""
+		default:
+			// Values other than strings, including nested dictionaries
+			if err := decoder.Skip(); err != nil {
+				return nil, err
+			}
+			key = ""
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build darwin
+
+package size
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// accessTime returns the last access time recorded for info
+func accessTime(info fs.FileInfo) (time.Time, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Atimespec.Unix()), true
+}
+
+// accessTimesTracked reports whether the filesystem holding path updates
+// access times, i.e. it is not mounted with noatime
+func accessTimesTracked(path string) bool {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return false
+	}
+	return fs.Flags&unix.MNT_NOATIME == 0
+}
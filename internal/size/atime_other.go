@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package size
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime has no access time to read on this platform
+func accessTime(info fs.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// accessTimesTracked reports false, since accessTime never reads one here
+func accessTimesTracked(path string) bool {
+	return false
+}
@@ -0,0 +1,37 @@
+//go:build linux || darwin
+
+package size
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestCalculator_NewestATime(t *testing.T) {
+	dir := t.TempDir()
+	if !accessTimesTracked(dir) {
+		t.Skip("the temp directory is on a noatime mount")
+	}
+
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	older := filepath.Join(dir, "old.bin")
+	newer := filepath.Join(dir, "sub", "new.bin")
+	require.NoError(t, os.MkdirAll(filepath.Dir(newer), 0755))
+	require.NoError(t, os.WriteFile(older, []byte("old"), 0644))
+	require.NoError(t, os.WriteFile(newer, []byte("new"), 0644))
+	require.NoError(t, os.Chtimes(older, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), mtime))
+	require.NoError(t, os.Chtimes(newer, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), mtime))
+
+	sized, err := NewCalculator(1).CalculateSizes(context.Background(), []scan.Candidate{{Path: dir}})
+	require.NoError(t, err)
+	require.Len(t, sized, 1)
+	assert.True(t, sized[0].NewestATime.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)), "got %v", sized[0].NewestATime)
+	assert.Equal(t, int64(6), sized[0].SizeBytes)
+}
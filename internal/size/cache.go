@@ -0,0 +1,94 @@
+package size
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheEntry is the cached size, file count and newest file mtime of a
+// directory as of its top-level mtime
+type cacheEntry struct {
+	MTime       time.Time `json:"mtime"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	FileCount   int64     `json:"fileCount"`
+	NewestMTime time.Time `json:"newestMTime,omitzero"`
+}
+
+// Cache is an on-disk cache of directory sizes keyed by path. An entry is only
+// valid while the directory's own mtime is unchanged, which catches entries
+// being added or removed at the top level but not changes deeper in the tree.
+type Cache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// DefaultCachePath returns the location of the size cache file
+func DefaultCachePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".cache", "BuildBloatBuster", "sizes.json")
+}
+
+// LoadCache reads the cache at path. A missing or unreadable cache file yields
+// an empty cache, since the cache is only an optimisation.
+func LoadCache(path string) *Cache {
+	c := &Cache{path: path, entries: make(map[string]cacheEntry)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			c.entries = make(map[string]cacheEntry)
+		}
+	}
+	return c
+}
+
+// Lookup returns the cached size, file count and newest file mtime of dirPath
+// if they were cached at the given mtime
+func (c *Cache) Lookup(dirPath string, mtime time.Time) (size, files int64, newestMTime time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[dirPath]
+	if !ok || !entry.MTime.Equal(mtime) {
+		return 0, 0, time.Time{}, false
+	}
+	// Entries written before file counts and mtimes were cached have none,
+	// but any directory with a size holds at least one file
+	if entry.SizeBytes > 0 && (entry.FileCount == 0 || entry.NewestMTime.IsZero()) {
+		return 0, 0, time.Time{}, false
+	}
+	return entry.SizeBytes, entry.FileCount, entry.NewestMTime, true
+}
+
+// Store records the size, file count and newest file mtime of dirPath at the
+// given mtime
+func (c *Cache) Store(dirPath string, mtime time.Time, size, files int64, newestMTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[dirPath] = cacheEntry{MTime: mtime, SizeBytes: size, FileCount: files, NewestMTime: newestMTime}
+	c.dirty = true
+}
+
+// Save writes the cache back to disk if it changed
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,130 @@
+package size
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// InspectEntry is a file or directory found by Inspect. Its path is relative
+// to the inspected directory, unless it was reached through a followed link.
+type InspectEntry struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"sizeBytes"`
+	FileCount int64  `json:"fileCount"`
+	// ModTime is the newest modification time of the files it holds
+	ModTime time.Time `json:"modTime"`
+}
+
+// Inspection is what a directory holds: its totals along with its largest
+// immediate subdirectories and its largest files at any depth
+type Inspection struct {
+	Path          string    `json:"path"`
+	SizeBytes     int64     `json:"sizeBytes"`
+	FileCount     int64     `json:"fileCount"`
+	NewestModTime time.Time `json:"newestModTime"`
+	// Unreadable counts the files and directories that could not be read;
+	// whatever they hold is missing from the totals
+	Unreadable     int64          `json:"unreadable"`
+	Subdirectories []InspectEntry `json:"subdirectories"`
+	Files          []InspectEntry `json:"files"`
+}
+
+// Inspect walks dirPath and returns its totals, its top largest immediate
+// subdirectories and its top largest files, largest first. Links are
+// followed as the calculator is set to. If ctx is done mid-walk, what was
+// found so far is returned along with ctx's error.
+func (c *Calculator) Inspect(ctx context.Context, dirPath string, top int) (Inspection, error) {
+	root := dirPath
+	if resolved, err := filepath.EvalSymlinks(dirPath); err == nil {
+		root = resolved
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return Inspection{}, err
+	}
+
+	result := Inspection{
+		Path:           dirPath,
+		Subdirectories: []InspectEntry{},
+		Files:          []InspectEntry{},
+	}
+	onFile := func(path string, info fs.FileInfo) {
+		result.Files = insertLargest(result.Files, InspectEntry{
+			Path:      relativeTo(root, path),
+			SizeBytes: info.Size(),
+			FileCount: 1,
+			ModTime:   info.ModTime(),
+		}, top)
+	}
+
+	for _, entry := range entries {
+		childPath := filepath.Join(root, entry.Name())
+		usage := InspectEntry{Path: entry.Name()}
+		// A file is walked like a directory holding just itself, and a link
+		// counts as nothing of its own
+		dir, err := c.walkDirectory(ctx, childPath, func(path string, info fs.FileInfo) {
+			onFile(path, info)
+			if info.ModTime().After(usage.ModTime) {
+				usage.ModTime = info.ModTime()
+			}
+		})
+		usage.SizeBytes = dir.size
+		usage.FileCount = dir.files
+		result.Unreadable += dir.unreadable
+		result.add(usage)
+		if entry.IsDir() && !scan.IsLink(childPath, entry) {
+			result.Subdirectories = insertLargest(result.Subdirectories, usage, top)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// add counts entry in the totals
+func (in *Inspection) add(entry InspectEntry) {
+	in.SizeBytes += entry.SizeBytes
+	in.FileCount += entry.FileCount
+	if entry.ModTime.After(in.NewestModTime) {
+		in.NewestModTime = entry.ModTime
+	}
+}
+
+// insertLargest adds entry to entries, which are sorted largest first, and
+// keeps the top largest of them; a top <= 0 keeps all of them
+func insertLargest(entries []InspectEntry, entry InspectEntry, top int) []InspectEntry {
+	i := sort.Search(len(entries), func(i int) bool {
+		if entries[i].SizeBytes != entry.SizeBytes {
+			return entries[i].SizeBytes < entry.SizeBytes
+		}
+		return entries[i].Path > entry.Path
+	})
+	if top > 0 && i >= top {
+		return entries
+	}
+	entries = append(entries, InspectEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	if top > 0 && len(entries) > top {
+		entries = entries[:top]
+	}
+	return entries
+}
+
+// relativeTo returns path relative to root, or path itself when it lies
+// outside root
+func relativeTo(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,96 @@
+package size
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculator_Inspect(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	newest := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	files := map[string]struct {
+		size    int
+		modTime time.Time
+	}{
+		"objects/a.o":          {4000, old},
+		"objects/nested/b.o":   {3000, newest},
+		"cache/index":          {500, old},
+		"empty/.keep":          {0, old},
+		"build.log":            {2000, old},
+		"objects/nested/c.tmp": {10, old},
+	}
+	for name, f := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, make([]byte, f.size), 0644))
+		require.NoError(t, os.Chtimes(path, f.modTime, f.modTime))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(root, "objects"), filepath.Join(root, "link")))
+
+	inspection, err := NewCalculator(1).Inspect(context.Background(), root, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, root, inspection.Path)
+	assert.Equal(t, int64(9510), inspection.SizeBytes)
+	assert.Equal(t, int64(6), inspection.FileCount)
+	assert.True(t, newest.Equal(inspection.NewestModTime), inspection.NewestModTime)
+	assert.Zero(t, inspection.Unreadable)
+
+	// Only directories are listed as subdirectories, and links aren't followed
+	require.Len(t, inspection.Subdirectories, 2)
+	assert.Equal(t, "objects", inspection.Subdirectories[0].Path)
+	assert.Equal(t, int64(7010), inspection.Subdirectories[0].SizeBytes)
+	assert.Equal(t, int64(3), inspection.Subdirectories[0].FileCount)
+	assert.True(t, newest.Equal(inspection.Subdirectories[0].ModTime))
+	assert.Equal(t, "cache", inspection.Subdirectories[1].Path)
+
+	// Files are found at any depth
+	require.Len(t, inspection.Files, 2)
+	assert.Equal(t, filepath.Join("objects", "a.o"), inspection.Files[0].Path)
+	assert.Equal(t, filepath.Join("objects", "nested", "b.o"), inspection.Files[1].Path)
+}
+
+func TestCalculator_InspectUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read every directory")
+	}
+	root := t.TempDir()
+	locked := filepath.Join(root, "locked")
+	require.NoError(t, os.MkdirAll(filepath.Join(locked, "inner"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(locked, "inner", "secret"), make([]byte, 100), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "visible"), make([]byte, 50), 0644))
+	require.NoError(t, os.Chmod(locked, 0))
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	inspection, err := NewCalculator(1).Inspect(context.Background(), root, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), inspection.SizeBytes)
+	assert.Equal(t, int64(1), inspection.Unreadable)
+}
+
+func TestInsertLargest(t *testing.T) {
+	var entries []InspectEntry
+	for _, entry := range []InspectEntry{
+		{Path: "b", SizeBytes: 10},
+		{Path: "c", SizeBytes: 30},
+		{Path: "a", SizeBytes: 10},
+		{Path: "d", SizeBytes: 5},
+		{Path: "e", SizeBytes: 20},
+	} {
+		entries = insertLargest(entries, entry, 3)
+	}
+
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = entry.Path
+	}
+	assert.Equal(t, []string{"c", "e", "a"}, paths)
+}
@@ -2,10 +2,15 @@ package size
 
 import (
 	"context"
+	"errors"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/vbauerster/mpb/v8"
@@ -17,35 +22,260 @@ import (
 
 // Calculator handles concurrent size calculation for directories
 type Calculator struct {
-	concurrency int
+	concurrency      int
+	candidateTimeout time.Duration
+	rootConcurrency  map[string]int
+	cache            *Cache
+	// progress, if set, is where the size bar is drawn; without it no
+	// progress is shown
+	progress *mpb.Progress
+	logger   *slog.Logger
+	// followSymlinks makes the size include the targets of links
+	followSymlinks bool
+	// readRetries is how often a directory read failing with an I/O error
+	// is retried
+	readRetries int
+
+	// mu guards unreadable, which the workers sizing candidates add to
+	mu sync.Mutex
+	// unreadable lists the paths that were left out of sizes because they
+	// couldn't be read, and the candidates that couldn't be sized
+	unreadable []scan.ReadError
 }
 
 // NewCalculator creates a new size calculator
 func NewCalculator(concurrency int) *Calculator {
 	if concurrency <= 0 {
-		concurrency = 8 // Default fallback
+		concurrency = DefaultConcurrency()
 	}
 	return &Calculator{
 		concurrency: concurrency,
+		logger:      slog.Default(),
+	}
+}
+
+// Concurrency returns the number of workers used for candidates outside
+// every root given to SetRootConcurrency
+func (c *Calculator) Concurrency() int {
+	return c.concurrency
+}
+
+// SetLogger sets where the calculator logs directories it could not size
+// completely; it is slog's default logger unless set
+func (c *Calculator) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+// SetRootConcurrency sets the number of workers used for candidates under each
+// scan root, e.g. fewer for spinning disks. Candidates outside every root use
+// the calculator's default concurrency.
+func (c *Calculator) SetRootConcurrency(roots map[string]int) {
+	c.rootConcurrency = make(map[string]int, len(roots))
+	for root, workers := range roots {
+		if absRoot, err := filepath.Abs(root); err == nil {
+			root = absRoot
+		}
+		c.rootConcurrency[root] = workers
 	}
 }
 
-// CalculateSizes calculates sizes for all candidates concurrently
+// SetCache makes the calculator reuse sizes of directories whose top-level
+// mtime is unchanged, and record newly computed ones. The cache is saved when
+// CalculateSizes finishes.
+func (c *Calculator) SetCache(cache *Cache) {
+	c.cache = cache
+}
+
+// SetFollowSymlinks makes the calculator count what links inside a candidate
+// point to. Each target is counted once, so links pointing back into the
+// candidate or at each other can't make the walk loop.
+func (c *Calculator) SetFollowSymlinks(follow bool) {
+	c.followSymlinks = follow
+}
+
+// SetReadRetries sets how often a directory read failing with an error
+// other than a permission or not-exist error is retried before the
+// directory is left out of the size
+func (c *Calculator) SetReadRetries(retries int) {
+	c.readRetries = retries
+}
+
+// Unreadable returns, sorted, the paths left out of the sizes calculated so
+// far because they couldn't be read, and the candidates that failed to size
+func (c *Calculator) Unreadable() []string {
+	readErrs := c.ReadErrors()
+	paths := make([]string, len(readErrs))
+	for i, readErr := range readErrs {
+		paths[i] = readErr.Path
+	}
+	return paths
+}
+
+// ReadErrors returns, sorted by path, the paths left out of the sizes
+// calculated so far because they couldn't be read, and the candidates that
+// failed to size, with the errors they failed with
+func (c *Calculator) ReadErrors() []scan.ReadError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return scan.SortReadErrors(slices.Clone(c.unreadable))
+}
+
+// recordUnreadable adds path, which failed to read with err, to the paths
+// that couldn't be read
+func (c *Calculator) recordUnreadable(path string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unreadable = append(c.unreadable, scan.NewReadError(path, err))
+}
+
+// SetProgress makes the calculator draw a progress bar in p, e.g. below a
+// scan spinner. Without it the calculator shows no progress, so callers
+// decide whether and where progress can be drawn. The caller must wait for p.
+func (c *Calculator) SetProgress(p *mpb.Progress) {
+	c.progress = p
+}
+
+// SetCandidateTimeout bounds the time spent sizing a single candidate. When it
+// expires, the candidate keeps the size accumulated so far and is marked
+// SizeIncomplete. Zero disables the limit.
+func (c *Calculator) SetCandidateTimeout(timeout time.Duration) {
+	c.candidateTimeout = timeout
+}
+
+// CalculateSizes calculates sizes for all candidates concurrently and returns
+// them in the same order. It is the batch form of CalculateSizesStream.
 func (c *Calculator) CalculateSizes(ctx context.Context, candidates []scan.Candidate) ([]scan.Candidate, error) {
 	if len(candidates) == 0 {
 		return candidates, nil
 	}
 
-	// Create channels for work distribution
-	jobs := make(chan int, len(candidates))
-	results := make([]scan.Candidate, len(candidates))
+	in := make(chan scan.Candidate, len(candidates))
+	for _, candidate := range candidates {
+		in <- candidate
+	}
+	close(in)
+	return c.CalculateSizesStream(ctx, in)
+}
+
+// CalculateSizesStream sizes candidates as they arrive on in, so sizing can
+// overlap with a scan that is still running. It returns once in is closed and
+// every candidate has been sized, in the order they arrived. Candidates under
+// each scan root share their own pool of workers, tuned for its storage.
+func (c *Calculator) CalculateSizesStream(ctx context.Context, in <-chan scan.Candidate) ([]scan.Candidate, error) {
+	var mu sync.Mutex
+	var results []scan.Candidate
 
 	// Use errgroup for proper error handling and cancellation
 	g, ctx := errgroup.WithContext(ctx)
 
-	// Initialize progress bar
-	p := mpb.New(mpb.WithWidth(60), mpb.WithRefreshRate(180*time.Millisecond))
-	bar := p.New(int64(len(candidates)),
+	// The progress bar, if any, is created with the first candidate and its
+	// total grows as more arrive
+	var bar *mpb.Bar
+	// allFound is set once in is closed, from then on the bar's total is final
+	// and the time remaining can be estimated
+	var allFound atomic.Bool
+
+	// Each scan root gets a pool of workers, bounded by a semaphore
+	pools := make(map[string]chan struct{})
+
+	g.Go(func() error {
+		for {
+			var candidate scan.Candidate
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case next, ok := <-in:
+				if !ok {
+					allFound.Store(true)
+					return nil // Scan finished, every candidate is dispatched
+				}
+				candidate = next
+			}
+
+			mu.Lock()
+			results = append(results, candidate)
+			idx := len(results) - 1
+			mu.Unlock()
+
+			if bar == nil && c.progress != nil {
+				bar = newSizeProgress(c.progress, &allFound)
+			}
+			if bar != nil {
+				bar.SetTotal(int64(idx+1), false)
+			}
+
+			root, workers := c.rootFor(candidate.Path)
+			pool, ok := pools[root]
+			if !ok {
+				pool = make(chan struct{}, workers)
+				pools[root] = pool
+			}
+
+			g.Go(func() error {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case pool <- struct{}{}:
+				}
+				defer func() { <-pool }()
+
+				// Errors only leave the size incomplete; they don't fail the
+				// whole operation
+				usage, incomplete, err := c.calculateCandidateSize(ctx, candidate.Path)
+				if err != nil && ctx.Err() == nil {
+					c.logger.Warn("failed to calculate size", "path", candidate.Path, "error", err)
+					c.recordUnreadable(candidate.Path, err)
+				}
+
+				mu.Lock()
+				results[idx].SizeBytes = usage.size
+				results[idx].FileCount = usage.files
+				results[idx].NewestATime = usage.newestATime
+				// Writes deep inside don't change the directory's own mtime
+				if usage.newestMTime.After(results[idx].NewestMTime) {
+					results[idx].NewestMTime = usage.newestMTime
+				}
+				results[idx].SizeIncomplete = incomplete
+				mu.Unlock()
+
+				if bar != nil {
+					bar.Increment()
+				}
+				return nil
+			})
+		}
+	})
+
+	// Wait for all workers to complete
+	err := g.Wait()
+
+	// Complete the progress bar; the caller waits for its container
+	if bar != nil {
+		if err != nil {
+			bar.Abort(false)
+		} else {
+			bar.SetTotal(-1, true)
+		}
+	}
+
+	// The cache is only an optimisation, so failing to save it is not an error
+	if c.cache != nil {
+		_ = c.cache.Save()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// newSizeProgress starts the size calculation progress bar in p. Its total
+// starts at zero and is raised as candidates are found. The time remaining is
+// shown once allFound is set, since before that the total is still growing.
+func newSizeProgress(p *mpb.Progress, allFound *atomic.Bool) *mpb.Bar {
+	start := time.Now()
+	bar := p.New(0,
 		mpb.BarStyle().Lbound("[").Filler("=").Tip(">").Padding("-").Rbound("]"),
 		mpb.PrependDecorators(
 			decor.Name("Calculating sizes "),
@@ -55,109 +285,344 @@ func (c *Calculator) CalculateSizes(ctx context.Context, candidates []scan.Candi
 			decor.Percentage(),
 			decor.Name(" | "),
 			decor.Elapsed(decor.ET_STYLE_GO),
+			decor.Name(" | "),
+			decor.Any(func(st decor.Statistics) string {
+				if !allFound.Load() {
+					return "ETA ?"
+				}
+				remaining, ok := EstimateRemaining(time.Since(start), st.Current, st.Total)
+				if !ok {
+					return "ETA ?"
+				}
+				return "ETA " + remaining.Round(time.Second).String()
+			}),
 		),
+		mpb.BarWidth(60),
 	)
+	return bar
+}
 
-	// Start workers
-	for i := 0; i < c.concurrency; i++ {
-		g.Go(func() error {
-			for {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case idx, ok := <-jobs:
-					if !ok {
-						return nil // Channel closed, worker done
-					}
-
-					// Calculate size for this candidate
-					size, err := c.calculateDirectorySize(candidates[idx].Path)
-					if err != nil {
-						// Log error but don't fail the whole operation
-						// Note: In a real app, this should go to a proper logger
-						// and not interfere with the progress bar rendering.
-					}
-
-					// Update result
-					results[idx] = candidates[idx]
-					results[idx].SizeBytes = size
-
-					// Increment progress bar
-					bar.Increment()
-				}
-			}
-		})
+// EstimateRemaining extrapolates the time left from the time elapsed and the
+// share of work done so far. It reports false while nothing is done yet.
+func EstimateRemaining(elapsed time.Duration, done, total int64) (time.Duration, bool) {
+	if done <= 0 || total <= 0 {
+		return 0, false
 	}
+	if done >= total {
+		return 0, true
+	}
+	return time.Duration(float64(elapsed) * float64(total-done) / float64(done)), true
+}
 
-	// Send jobs to workers
-	go func() {
-		defer close(jobs)
-		for i := range candidates {
-			select {
-			case <-ctx.Done():
-				return
-			case jobs <- i:
+// rootFor returns the most specific scan root that path lives under ("" if it
+// is outside every root) and the number of workers that size it, so each root
+// is sized with the worker count tuned for its storage.
+func (c *Calculator) rootFor(path string) (string, int) {
+	root := ""
+	for r := range c.rootConcurrency {
+		if isUnder(path, r) && len(r) > len(root) {
+			root = r
+		}
+	}
+
+	workers := c.concurrency
+	if root != "" && c.rootConcurrency[root] > 0 {
+		workers = c.rootConcurrency[root]
+	}
+	return root, workers
+}
+
+// isUnder reports whether path is root or lies inside it
+func isUnder(path, root string) bool {
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// dirUsage is what a walk of a directory found
+type dirUsage struct {
+	size  int64
+	files int64
+	// newestMTime is the newest modification time of the files
+	newestMTime time.Time
+	// newestATime is the newest access time of the files, zero where it isn't
+	// recorded, e.g. on filesystems mounted with noatime
+	newestATime time.Time
+	// unreadable counts the files and directories that could not be read;
+	// whatever they hold is missing from size and files
+	unreadable int64
+}
+
+// calculateCandidateSize sizes a single candidate within the per-candidate
+// timeout. The newest access time is unknown (zero) for cached sizes. It
+// reports incomplete when the walk was cut short by the timeout or cancellation.
+func (c *Calculator) calculateCandidateSize(ctx context.Context, dirPath string) (dirUsage, bool, error) {
+	var mtime time.Time
+	if c.cache != nil {
+		if info, err := os.Lstat(dirPath); err == nil {
+			mtime = info.ModTime()
+			if size, files, newestMTime, ok := c.cache.Lookup(dirPath, mtime); ok {
+				return dirUsage{size: size, files: files, newestMTime: newestMTime}, false, nil
 			}
 		}
-	}()
+	}
 
-	// Wait for all workers to complete
-	err := g.Wait()
+	if c.candidateTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.candidateTimeout)
+		defer cancel()
+	}
 
-	// Wait for the progress bar to finish
-	p.Wait()
+	usage, err := c.calculateDirectorySize(ctx, dirPath)
+	if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+		return usage, true, nil
+	}
 
-	if err != nil {
-		return nil, err
+	// Only complete sizes are worth remembering
+	if c.cache != nil && err == nil && !mtime.IsZero() {
+		c.cache.Store(dirPath, mtime, usage.size, usage.files, usage.newestMTime)
 	}
+	return usage, false, err
+}
 
-	return results, nil
+// calculateDirectorySize calculates the total size and number of files of a
+// directory. If ctx is done mid-walk, what was found so far is returned along
+// with ctx's error.
+func (c *Calculator) calculateDirectorySize(ctx context.Context, dirPath string) (dirUsage, error) {
+	return c.walkDirectory(ctx, dirPath, nil)
+}
+
+// walkDirectory is calculateDirectorySize, calling onFile, if set, for every
+// file counted
+func (c *Calculator) walkDirectory(ctx context.Context, dirPath string, onFile func(path string, info fs.FileInfo)) (dirUsage, error) {
+	// A candidate that is itself a link occupies no space of its own
+	if scan.IsLink(dirPath, nil) {
+		return dirUsage{}, nil
+	}
+
+	w := &sizeWalk{
+		Calculator: c,
+		ctx:        ctx,
+		onFile:     onFile,
+		// Access times that are never updated would make everything look unused
+		trackATime: accessTimesTracked(dirPath),
+	}
+	if !w.trackATime {
+		c.logger.Debug("access times are not tracked", "path", dirPath)
+	}
+
+	root := dirPath
+	if c.followSymlinks {
+		// Followed links are compared by their resolved targets, so the root
+		// must be resolved too
+		if resolved, err := filepath.EvalSymlinks(dirPath); err == nil {
+			root = resolved
+		}
+	}
+	err := w.walk(root)
+	return w.usage, err
 }
 
-// calculateDirectorySize calculates the total size of a directory
-func (c *Calculator) calculateDirectorySize(dirPath string) (int64, error) {
-	var totalSize int64
-	var mutex sync.Mutex
+// sizeWalk is the state of sizing one candidate
+type sizeWalk struct {
+	*Calculator
+	ctx        context.Context
+	trackATime bool
+	onFile     func(path string, info fs.FileInfo)
+	usage      dirUsage
+	// walked are the resolved directories walked so far: the candidate and
+	// the targets of followed links. A link into any of them is not followed
+	// again, which also breaks cycles.
+	walked []string
+	// followedFiles are the resolved files counted through links
+	followedFiles map[string]struct{}
+}
+
+// walk adds the files below root to the usage. Directories walked from
+// another root are skipped, so nothing is counted twice.
+func (w *sizeWalk) walk(root string) error {
+	w.walked = append(w.walked, root)
+	return scan.WalkDir(root, w.readRetries, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := w.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 
-	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			// Skip files/directories we can't access
 			if os.IsPermission(err) || os.IsNotExist(err) {
+				w.logger.Debug("skipping unreadable path while sizing", "path", path, "error", err)
+				if os.IsPermission(err) {
+					w.usage.unreadable++
+					w.recordUnreadable(path, err)
+				}
+				return nil
+			}
+			// Directories that kept failing to read are left out of the size,
+			// but a candidate that can't be found at all fails
+			if d != nil {
+				w.logger.Warn("skipping directory that could not be read while sizing", "path", path, "error", err)
+				w.usage.unreadable++
+				w.recordUnreadable(path, err)
 				return nil
 			}
 			return err
 		}
 
-		if !d.IsDir() {
-			info, err := d.Info()
-			if err != nil {
-				return nil // Skip files we can't stat
+		// Links are only followed if asked to. Otherwise their targets are
+		// counted where they actually live (or not at all), and the link
+		// itself counts as nothing.
+		if scan.IsLink(path, d) {
+			if w.followSymlinks {
+				if err := w.follow(path); err != nil {
+					return err
+				}
+			}
+			if d.IsDir() {
+				return filepath.SkipDir
 			}
+			return nil
+		}
 
-			mutex.Lock()
-			totalSize += info.Size()
-			mutex.Unlock()
+		if d.IsDir() {
+			if path != root && slices.Contains(w.walked, path) {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
+		info, err := d.Info()
+		if err != nil {
+			// A file removed since its directory was read is simply gone;
+			// any other failure leaves it out of the size
+			if !os.IsNotExist(err) {
+				w.logger.Debug("skipping file that could not be stat'ed while sizing", "path", path, "error", err)
+				w.usage.unreadable++
+				w.recordUnreadable(path, err)
+			}
+			return nil
+		}
+		w.add(path, info)
 		return nil
 	})
+}
+
+// follow counts the target of the link at path, unless it was counted
+// already or lies inside a directory that is walked anyway
+func (w *sizeWalk) follow(path string) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Broken links and links that resolve to themselves
+		w.logger.Debug("skipping unresolvable link while sizing", "path", path, "error", err)
+		return nil
+	}
+	for _, walked := range w.walked {
+		if isUnder(target, walked) {
+			return nil
+		}
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil
+	}
+	if info.IsDir() {
+		return w.walk(target)
+	}
+	if _, ok := w.followedFiles[target]; ok {
+		return nil
+	}
+	if w.followedFiles == nil {
+		w.followedFiles = make(map[string]struct{})
+	}
+	w.followedFiles[target] = struct{}{}
+	w.add(target, info)
+	return nil
+}
 
-	return totalSize, err
+// add counts the file at path
+func (w *sizeWalk) add(path string, info fs.FileInfo) {
+	w.usage.size += info.Size()
+	w.usage.files++
+	if info.ModTime().After(w.usage.newestMTime) {
+		w.usage.newestMTime = info.ModTime()
+	}
+	if w.trackATime {
+		if atime, ok := accessTime(info); ok && atime.After(w.usage.newestATime) {
+			w.usage.newestATime = atime
+		}
+	}
+	if w.onFile != nil {
+		w.onFile(path, info)
+	}
 }
 
 // CalculateDirectorySize is a convenience function for calculating a single directory size
 func CalculateDirectorySize(dirPath string) (int64, error) {
 	calc := NewCalculator(1)
-	return calc.calculateDirectorySize(dirPath)
+	usage, err := calc.calculateDirectorySize(context.Background(), dirPath)
+	return usage.size, err
+}
+
+// LastUsed returns when candidate was last used: the newer of its newest
+// access and modification times. Writes don't always update the access time,
+// and where it isn't recorded this falls back to the modification time.
+func LastUsed(candidate scan.Candidate) time.Time {
+	if candidate.NewestATime.After(candidate.NewestMTime) {
+		return candidate.NewestATime
+	}
+	return candidate.NewestMTime
+}
+
+// FilterNotUsedSince keeps the candidates last used before cutoff. Candidates
+// with no known access or modification time are dropped, since nothing says
+// they are unused.
+func FilterNotUsedSince(candidates []scan.Candidate, cutoff time.Time) []scan.Candidate {
+	var filtered []scan.Candidate
+	for _, candidate := range candidates {
+		lastUsed := LastUsed(candidate)
+		if !lastUsed.IsZero() && lastUsed.Before(cutoff) {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered
+}
+
+// SplitRecentlyModified separates the candidates modified after cutoff,
+// which a running build may still be writing to, from the older ones.
+// Candidates with no known modification time count as older.
+func SplitRecentlyModified(candidates []scan.Candidate, cutoff time.Time) (older, recent []scan.Candidate) {
+	for _, candidate := range candidates {
+		if candidate.NewestMTime.After(cutoff) {
+			recent = append(recent, candidate)
+		} else {
+			older = append(older, candidate)
+		}
+	}
+	return older, recent
+}
+
+// FilterByMinFileCount keeps the candidates holding at least minFiles files
+func FilterByMinFileCount(candidates []scan.Candidate, minFiles int64) []scan.Candidate {
+	if minFiles <= 0 {
+		return candidates
+	}
+
+	var filtered []scan.Candidate
+	for _, candidate := range candidates {
+		if candidate.FileCount >= minFiles {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered
 }
 
-// FilterByMinSize filters candidates by minimum size threshold
-func FilterByMinSize(candidates []scan.Candidate, minSizeMB int) []scan.Candidate {
-	if minSizeMB <= 0 {
+// FilterByMinSize keeps the candidates of at least minSizeBytes
+func FilterByMinSize(candidates []scan.Candidate, minSizeBytes int64) []scan.Candidate {
+	if minSizeBytes <= 0 {
 		return candidates
 	}
 
-	minSizeBytes := int64(minSizeMB) * 1024 * 1024
 	var filtered []scan.Candidate
 
 	for _, candidate := range candidates {
@@ -1,13 +1,18 @@
 package size
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vbauerster/mpb/v8"
 	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
 )
 
@@ -52,6 +57,42 @@ func TestCalculator_CalculateSizes(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, results, 1)
 	assert.Equal(t, expectedSize, results[0].SizeBytes)
+	assert.Equal(t, int64(2), results[0].FileCount)
+}
+
+func TestCalculator_Progress(t *testing.T) {
+	tmpDir, _, cleanup := setupSizeTest(t)
+	defer cleanup()
+
+	var out bytes.Buffer
+	p := mpb.New(mpb.WithOutput(&out), mpb.WithWidth(60), mpb.WithAutoRefresh())
+	calculator := NewCalculator(4)
+	calculator.SetProgress(p)
+
+	_, err := calculator.CalculateSizes(context.Background(), []scan.Candidate{{Path: tmpDir}})
+	require.NoError(t, err)
+	p.Wait()
+	assert.Contains(t, out.String(), "Calculating sizes")
+}
+
+func TestCalculator_LogsSizeErrors(t *testing.T) {
+	var out bytes.Buffer
+	calculator := NewCalculator(1)
+	calculator.SetLogger(slog.New(slog.NewTextHandler(&out, nil)))
+
+	// A path with a NUL byte can't even be looked up
+	results, err := calculator.CalculateSizes(context.Background(), []scan.Candidate{{Path: "bad\x00path"}})
+	require.NoError(t, err, "a directory that can't be sized doesn't fail the run")
+	require.Len(t, results, 1)
+	assert.Contains(t, out.String(), "level=WARN")
+	assert.Contains(t, out.String(), `msg="failed to calculate size"`)
+
+	// The candidate is recorded with the error it failed with
+	readErrs := calculator.ReadErrors()
+	require.Len(t, readErrs, 1)
+	assert.Equal(t, "bad\x00path", readErrs[0].Path)
+	assert.Contains(t, readErrs[0].Error, "invalid argument")
+	assert.Equal(t, []string{"bad\x00path"}, calculator.Unreadable())
 }
 
 func TestFilterByMinSize(t *testing.T) {
@@ -62,16 +103,345 @@ func TestFilterByMinSize(t *testing.T) {
 	}
 
 	// Test with a threshold of 10 MB
-	filtered := FilterByMinSize(candidates, 10)
+	filtered := FilterByMinSize(candidates, 10*1024*1024)
 	assert.Len(t, filtered, 2)
 	assert.Equal(t, int64(15*1024*1024), filtered[0].SizeBytes)
 	assert.Equal(t, int64(25*1024*1024), filtered[1].SizeBytes)
 
 	// Test with a threshold of 30 MB
-	filtered = FilterByMinSize(candidates, 30)
+	filtered = FilterByMinSize(candidates, 30*1024*1024)
 	assert.Len(t, filtered, 0)
 
 	// Test with no threshold
 	filtered = FilterByMinSize(candidates, 0)
 	assert.Len(t, filtered, 3)
 }
+
+func TestFilterByMinFileCount(t *testing.T) {
+	root := t.TempDir()
+	var candidates []scan.Candidate
+	for _, files := range []int{1, 50, 200} {
+		dir := filepath.Join(root, fmt.Sprintf("files-%d", files))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0755))
+		for i := range files {
+			// Spread the files over two levels, both are counted
+			name := filepath.Join(dir, fmt.Sprintf("f%d", i))
+			if i%2 == 1 {
+				name = filepath.Join(dir, "nested", fmt.Sprintf("f%d", i))
+			}
+			require.NoError(t, os.WriteFile(name, []byte("x"), 0644))
+		}
+		candidates = append(candidates, scan.Candidate{Path: dir})
+	}
+
+	sized, err := NewCalculator(2).CalculateSizes(context.Background(), candidates)
+	require.NoError(t, err)
+	require.Len(t, sized, 3)
+	assert.Equal(t, []int64{1, 50, 200}, []int64{sized[0].FileCount, sized[1].FileCount, sized[2].FileCount})
+
+	filtered := FilterByMinFileCount(sized, 50)
+	require.Len(t, filtered, 2)
+	assert.Equal(t, sized[1].Path, filtered[0].Path)
+	assert.Equal(t, sized[2].Path, filtered[1].Path)
+
+	// Combined with a size filter both must hold: 200 one-byte files are 200 bytes
+	assert.Len(t, FilterByMinSize(FilterByMinFileCount(sized, 50), 100), 1)
+	assert.Len(t, FilterByMinFileCount(sized, 0), 3, "no threshold keeps everything")
+}
+
+func TestFilterNotUsedSince(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []scan.Candidate{
+		{Path: "/p/unused", NewestMTime: now.AddDate(0, 0, -90), NewestATime: now.AddDate(0, 0, -40)},
+		{Path: "/p/read", NewestMTime: now.AddDate(0, 0, -90), NewestATime: now.AddDate(0, 0, -2)},
+		{Path: "/p/written", NewestMTime: now.AddDate(0, 0, -1), NewestATime: now.AddDate(0, 0, -40)},
+		{Path: "/p/noatime", NewestMTime: now.AddDate(0, 0, -50)},
+		{Path: "/p/unknown"},
+	}
+
+	filtered := FilterNotUsedSince(candidates, now.AddDate(0, 0, -30))
+	require.Len(t, filtered, 2)
+	assert.Equal(t, "/p/unused", filtered[0].Path)
+	assert.Equal(t, "/p/noatime", filtered[1].Path, "without access times the modification time counts")
+}
+
+func TestCalculateDirectorySize_DoesNotFollowLinks(t *testing.T) {
+	tmpDir, expectedSize, cleanup := setupSizeTest(t)
+	defer cleanup()
+
+	// A directory outside the candidate that a link points into
+	outside, err := os.MkdirTemp("", "size-link-target-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(outside)
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "big.bin"), make([]byte, 8192), 0644))
+
+	if err := os.Symlink(outside, filepath.Join(tmpDir, "linked")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	size, err := CalculateDirectorySize(tmpDir)
+	require.NoError(t, err)
+	assert.Equal(t, expectedSize, size, "link target should not be counted")
+
+	// A candidate that is itself a link has no size of its own
+	size, err = CalculateDirectorySize(filepath.Join(tmpDir, "linked"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+}
+
+func TestCalculateDirectorySize_SymlinkLoops(t *testing.T) {
+	tmpDir, expectedSize, cleanup := setupSizeTest(t)
+	defer cleanup()
+
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "big.bin"), make([]byte, 8192), 0644))
+
+	links := map[string]string{
+		"subdir/up":       tmpDir,  // back to the candidate
+		"self":            "self",  // resolves to itself
+		"linked":          outside, // counted once...
+		"linked-again":    outside, // ...however many links lead there
+		"outside-file":    filepath.Join(outside, "big.bin"),
+		"subdir/file-dup": filepath.Join(tmpDir, "file1.txt"), // counted where it lives
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(tmpDir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	// The target links back to the candidate too
+	require.NoError(t, os.Symlink(tmpDir, filepath.Join(outside, "back")))
+
+	for _, follow := range []bool{false, true} {
+		t.Run(fmt.Sprintf("follow=%v", follow), func(t *testing.T) {
+			calculator := NewCalculator(1)
+			calculator.SetFollowSymlinks(follow)
+
+			done := make(chan dirUsage)
+			go func() {
+				usage, err := calculator.calculateDirectorySize(context.Background(), tmpDir)
+				assert.NoError(t, err)
+				done <- usage
+			}()
+
+			var usage dirUsage
+			select {
+			case usage = <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("sizing a directory with a symlink loop did not finish")
+			}
+			if follow {
+				assert.Equal(t, expectedSize+8192, usage.size, "every file is counted once")
+				assert.Equal(t, int64(3), usage.files)
+			} else {
+				assert.Equal(t, expectedSize, usage.size, "links count as nothing")
+				assert.Equal(t, int64(2), usage.files)
+			}
+		})
+	}
+}
+
+func TestCalculator_CandidateTimeout(t *testing.T) {
+	tmpDir, _, cleanup := setupSizeTest(t)
+	defer cleanup()
+
+	calculator := NewCalculator(2)
+	calculator.SetCandidateTimeout(time.Nanosecond)
+
+	results, err := calculator.CalculateSizes(context.Background(), []scan.Candidate{{Path: tmpDir}})
+	require.NoError(t, err, "a per-candidate timeout should not fail the run")
+	require.Len(t, results, 1)
+	assert.True(t, results[0].SizeIncomplete)
+
+	// Without a timeout the size is complete
+	calculator.SetCandidateTimeout(0)
+	results, err = calculator.CalculateSizes(context.Background(), []scan.Candidate{{Path: tmpDir}})
+	require.NoError(t, err)
+	assert.False(t, results[0].SizeIncomplete)
+}
+
+func TestCalculator_RootFor(t *testing.T) {
+	calculator := NewCalculator(16)
+	calculator.SetRootConcurrency(map[string]int{
+		"/mnt/hdd":         2,
+		"/mnt/hdd/fast":    8,
+		"/net/share":       4,
+		"/mnt/hdd-sibling": 3,
+	})
+
+	tests := []struct {
+		path    string
+		root    string
+		workers int
+	}{
+		{"/mnt/hdd/a/node_modules", "/mnt/hdd", 2},
+		{"/net/share/b/target", "/net/share", 4},
+		{"/mnt/hdd/fast/c/target", "/mnt/hdd/fast", 8},
+		{"/home/me/d/node_modules", "", 16},
+		{"/mnt/hdd/e/.venv", "/mnt/hdd", 2},
+	}
+	for _, tt := range tests {
+		root, workers := calculator.rootFor(tt.path)
+		assert.Equal(t, tt.root, root, tt.path)
+		assert.Equal(t, tt.workers, workers, tt.path)
+	}
+}
+
+func TestEstimateRemaining(t *testing.T) {
+	tests := []struct {
+		name        string
+		elapsed     time.Duration
+		done, total int64
+		want        time.Duration
+		ok          bool
+	}{
+		{"nothing done yet", 5 * time.Second, 0, 10, 0, false},
+		{"unknown total", 5 * time.Second, 3, 0, 0, false},
+		{"quarter done", 10 * time.Second, 25, 100, 30 * time.Second, true},
+		{"half done", 4 * time.Second, 5, 10, 4 * time.Second, true},
+		{"finished", 7 * time.Second, 10, 10, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := EstimateRemaining(tt.elapsed, tt.done, tt.total)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCalculator_CalculateSizesStream(t *testing.T) {
+	tmpDir, expectedSize, cleanup := setupSizeTest(t)
+	defer cleanup()
+
+	empty := t.TempDir()
+	in := make(chan scan.Candidate)
+	go func() {
+		defer close(in)
+		// Candidates trickle in like they do from a running scan
+		for _, path := range []string{tmpDir, empty} {
+			in <- scan.Candidate{Path: path}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	results, err := NewCalculator(2).CalculateSizesStream(context.Background(), in)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, tmpDir, results[0].Path)
+	assert.Equal(t, expectedSize, results[0].SizeBytes)
+	assert.Equal(t, empty, results[1].Path)
+	assert.Zero(t, results[1].SizeBytes)
+
+	t.Run("no candidates", func(t *testing.T) {
+		in := make(chan scan.Candidate)
+		close(in)
+		results, err := NewCalculator(2).CalculateSizesStream(context.Background(), in)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+}
+
+func BenchmarkCalculateSizes_PerRoot(b *testing.B) {
+	rootA := b.TempDir()
+	rootB := b.TempDir()
+	var candidates []scan.Candidate
+	for i, root := range []string{rootA, rootB, rootA, rootB} {
+		dir := filepath.Join(root, fmt.Sprintf("project%d", i), "node_modules")
+		require.NoError(b, os.MkdirAll(dir, 0755))
+		require.NoError(b, os.WriteFile(filepath.Join(dir, "index.js"), make([]byte, 4096), 0644))
+		candidates = append(candidates, scan.Candidate{Path: dir})
+	}
+
+	calculator := NewCalculator(8)
+	calculator.SetRootConcurrency(map[string]int{rootA: 2, rootB: 4})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results, err := calculator.CalculateSizes(context.Background(), candidates)
+		require.NoError(b, err)
+		for _, r := range results {
+			require.Equal(b, int64(4096), r.SizeBytes)
+		}
+	}
+}
+
+func TestCalculator_Cache(t *testing.T) {
+	tmpDir, expectedSize, cleanup := setupSizeTest(t)
+	defer cleanup()
+
+	cachePath := filepath.Join(t.TempDir(), "sizes.json")
+	info, err := os.Stat(tmpDir)
+	require.NoError(t, err)
+
+	// Seed the cache with a bogus size at the current mtime; a hit must return
+	// it unchanged, proving the directory wasn't walked
+	cache := LoadCache(cachePath)
+	cache.Store(tmpDir, info.ModTime(), 42, 7, info.ModTime())
+	require.NoError(t, cache.Save())
+
+	calculator := NewCalculator(2)
+	calculator.SetCache(LoadCache(cachePath))
+	results, err := calculator.CalculateSizes(context.Background(), []scan.Candidate{{Path: tmpDir}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), results[0].SizeBytes, "cache hit should skip the walk")
+	assert.Equal(t, int64(7), results[0].FileCount)
+
+	// Changing the top-level mtime invalidates the entry
+	newMTime := info.ModTime().Add(time.Minute)
+	require.NoError(t, os.Chtimes(tmpDir, newMTime, newMTime))
+
+	calculator.SetCache(LoadCache(cachePath))
+	results, err = calculator.CalculateSizes(context.Background(), []scan.Candidate{{Path: tmpDir}})
+	require.NoError(t, err)
+	assert.Equal(t, expectedSize, results[0].SizeBytes, "changed mtime should trigger recomputation")
+
+	// The recomputed size was saved for next time
+	size, files, newestMTime, ok := LoadCache(cachePath).Lookup(tmpDir, newMTime)
+	assert.True(t, ok)
+	assert.Equal(t, expectedSize, size)
+	assert.Equal(t, int64(2), files)
+	assert.True(t, newestMTime.Equal(results[0].NewestMTime))
+
+	// Entries cached without a file count or newest mtime are recomputed
+	for _, entry := range []string{
+		`{"mtime": "` + newMTime.Format(time.RFC3339Nano) + `", "sizeBytes": 42}`,
+		`{"mtime": "` + newMTime.Format(time.RFC3339Nano) + `", "sizeBytes": 42, "fileCount": 7}`,
+	} {
+		require.NoError(t, os.WriteFile(cachePath, []byte(`{"`+tmpDir+`": `+entry+`}`), 0644))
+		_, _, _, ok = LoadCache(cachePath).Lookup(tmpDir, newMTime)
+		assert.False(t, ok, entry)
+	}
+}
+
+func TestCalculator_NewestMTimeFromContents(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "a", "b")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+	fresh := filepath.Join(nested, "fresh.o")
+	require.NoError(t, os.WriteFile(fresh, []byte("x"), 0644))
+
+	old := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	for _, path := range []string{nested, filepath.Dir(nested), dir} {
+		require.NoError(t, os.Chtimes(path, old, old))
+	}
+	info, err := os.Stat(fresh)
+	require.NoError(t, err)
+
+	// The scanner only knows the directory's own, old mtime
+	results, err := NewCalculator(1).CalculateSizes(context.Background(), []scan.Candidate{{Path: dir, NewestMTime: old}})
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(results[0].NewestMTime), "newest mtime should come from the file deep inside")
+}
+
+func TestSplitRecentlyModified(t *testing.T) {
+	now := time.Now()
+	candidates := []scan.Candidate{
+		{Path: "old", NewestMTime: now.Add(-time.Hour)},
+		{Path: "recent", NewestMTime: now.Add(-time.Minute)},
+		{Path: "unknown"},
+	}
+	older, recent := SplitRecentlyModified(candidates, now.Add(-10*time.Minute))
+	assert.Equal(t, []scan.Candidate{candidates[0], candidates[2]}, older)
+	assert.Equal(t, []scan.Candidate{candidates[1]}, recent)
+}
@@ -0,0 +1,51 @@
+package size
+
+import "runtime"
+
+// StorageKind describes the kind of device a path is stored on
+type StorageKind int
+
+const (
+	StorageUnknown StorageKind = iota
+	StorageSSD
+	StorageRotational
+	StorageNetwork
+)
+
+// String returns a human-readable name for the storage kind
+func (k StorageKind) String() string {
+	switch k {
+	case StorageSSD:
+		return "ssd"
+	case StorageRotational:
+		return "rotational"
+	case StorageNetwork:
+		return "network"
+	default:
+		return "unknown"
+	}
+}
+
+// DefaultConcurrency is the worker count used for fast local storage
+func DefaultConcurrency() int {
+	return runtime.NumCPU() * 2
+}
+
+// DetectStorage makes a best-effort guess at the storage backing path
+func DetectStorage(path string) StorageKind {
+	return detectStorage(path)
+}
+
+// WorkersFor returns a sensible number of size workers for the storage kind.
+// Spinning disks and network shares are dominated by seek and round-trip
+// latency, so a handful of walkers outperforms one per core.
+func WorkersFor(kind StorageKind) int {
+	switch kind {
+	case StorageRotational:
+		return 2
+	case StorageNetwork:
+		return 4
+	default:
+		return DefaultConcurrency()
+	}
+}
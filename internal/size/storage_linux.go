@@ -0,0 +1,48 @@
+//go:build linux
+
+package size
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/fsinfo"
+)
+
+// detectStorage checks the filesystem type for network mounts, then the
+// block device's rotational flag in sysfs.
+func detectStorage(path string) StorageKind {
+	if fsinfo.IsNetworkFS(path) {
+		return StorageNetwork
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return StorageUnknown
+	}
+
+	devPath, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(uint64(st.Dev)), unix.Minor(uint64(st.Dev))))
+	if err != nil {
+		return StorageUnknown
+	}
+
+	// Partitions don't have a queue directory; their parent disk does
+	for _, dir := range []string{devPath, filepath.Dir(devPath)} {
+		data, err := os.ReadFile(filepath.Join(dir, "queue", "rotational"))
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(string(data)) {
+		case "1":
+			return StorageRotational
+		case "0":
+			return StorageSSD
+		}
+	}
+
+	return StorageUnknown
+}
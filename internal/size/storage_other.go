@@ -0,0 +1,14 @@
+//go:build !linux
+
+package size
+
+import "github.com/yehia2amer/BuildBloatBuster/internal/fsinfo"
+
+// detectStorage has no portable way to query the device outside Linux, so it
+// only recognises network storage.
+func detectStorage(path string) StorageKind {
+	if fsinfo.IsNetworkFS(path) {
+		return StorageNetwork
+	}
+	return StorageUnknown
+}
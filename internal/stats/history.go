@@ -0,0 +1,155 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/filelock"
+)
+
+// Run is one completed clean in the history file
+type Run struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Mode       string    `json:"mode"`
+	Deleted    int       `json:"deleted"`
+	BytesFreed int64     `json:"bytesFreed"`
+	// Paths lists every directory the run removed
+	Paths []PathTotal `json:"paths"`
+}
+
+// PathTotal is the space reclaimed from one path, possibly over several runs
+type PathTotal struct {
+	Path       string `json:"path"`
+	BytesFreed int64  `json:"bytesFreed"`
+	// Cleans is how often the path was removed; it is 1 within a single Run
+	Cleans int `json:"cleans"`
+}
+
+// MonthTotal is what the runs of one calendar month reclaimed
+type MonthTotal struct {
+	// Month is formatted as 2006-01, in local time
+	Month      string `json:"month"`
+	Runs       int    `json:"runs"`
+	Deleted    int    `json:"deleted"`
+	BytesFreed int64  `json:"bytesFreed"`
+}
+
+// HistoryPath returns the history file kept next to the quarantine directory,
+// so it follows the quarantine when that is moved to another disk
+func HistoryPath(quarantineDir string) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(quarantineDir)), "history.jsonl")
+}
+
+// AppendRun adds run as a single JSON line to the history file at path,
+// creating the file and its directory if needed. Concurrent runs are
+// serialised with a lock file next to the history file.
+func AppendRun(path string, run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	lock, err := filelock.Acquire(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("could not lock history file: %w", err)
+	}
+	defer lock.Release()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadHistory reads the runs in the history file at path, oldest first. A
+// missing file yields no runs. Lines that can't be parsed, such as one cut
+// short by a crash, are skipped.
+func LoadHistory(path string) ([]Run, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(file)
+	// A run lists every removed path, so lines can be long
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read history file %s: %w", path, err)
+	}
+	return runs, nil
+}
+
+// ByMonth groups runs by calendar month, oldest first
+func ByMonth(runs []Run) []MonthTotal {
+	index := map[string]int{}
+	var months []MonthTotal
+	for _, run := range runs {
+		month := run.Timestamp.Local().Format("2006-01")
+		i, ok := index[month]
+		if !ok {
+			i = len(months)
+			index[month] = i
+			months = append(months, MonthTotal{Month: month})
+		}
+		months[i].Runs++
+		months[i].Deleted += run.Deleted
+		months[i].BytesFreed += run.BytesFreed
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Month < months[j].Month })
+	return months
+}
+
+// TopPaths returns the n paths that reclaimed the most space across runs,
+// largest first. Paths that keep coming back are summed over every clean.
+func TopPaths(runs []Run, n int) []PathTotal {
+	index := map[string]int{}
+	var paths []PathTotal
+	for _, run := range runs {
+		for _, p := range run.Paths {
+			i, ok := index[p.Path]
+			if !ok {
+				i = len(paths)
+				index[p.Path] = i
+				paths = append(paths, PathTotal{Path: p.Path})
+			}
+			paths[i].BytesFreed += p.BytesFreed
+			paths[i].Cleans++
+		}
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		if paths[i].BytesFreed != paths[j].BytesFreed {
+			return paths[i].BytesFreed > paths[j].BytesFreed
+		}
+		return paths[i].Path < paths[j].Path
+	})
+	if len(paths) > n {
+		paths = paths[:n]
+	}
+	return paths
+}
@@ -0,0 +1,97 @@
+// Package stats keeps running totals of the space reclaimed by clean runs.
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/filelock"
+)
+
+// Stats accumulates what clean runs have removed over the tool's lifetime
+type Stats struct {
+	Runs            int       `json:"runs"`
+	TotalDeleted    int       `json:"totalDeleted"`
+	TotalBytesFreed int64     `json:"totalBytesFreed"`
+	FirstRun        time.Time `json:"firstRun"`
+	LastRun         time.Time `json:"lastRun"`
+	// LastRunDeleted and LastRunBytesFreed describe the most recent run only
+	LastRunDeleted    int   `json:"lastRunDeleted"`
+	LastRunBytesFreed int64 `json:"lastRunBytesFreed"`
+}
+
+// DefaultPath returns the location of the stats file
+func DefaultPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".cache", "BuildBloatBuster", "stats.json")
+}
+
+// Load reads the stats file at path. A missing file yields zero stats.
+func Load(path string) (Stats, error) {
+	var s Stats
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("could not parse stats file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Record adds a completed run that removed deleted directories totalling
+// bytesFreed to the stats file at path, and returns the updated totals.
+// Concurrent runs are serialised with a lock file next to the stats file.
+func Record(path string, deleted int, bytesFreed int64) (Stats, error) {
+	lock, err := filelock.Acquire(path + ".lock")
+	if err != nil {
+		return Stats{}, fmt.Errorf("could not lock stats file: %w", err)
+	}
+	defer lock.Release()
+
+	s, err := Load(path)
+	if err != nil {
+		return s, err
+	}
+
+	now := time.Now()
+	if s.FirstRun.IsZero() {
+		s.FirstRun = now
+	}
+	s.Runs++
+	s.TotalDeleted += deleted
+	s.TotalBytesFreed += bytesFreed
+	s.LastRun = now
+	s.LastRunDeleted = deleted
+	s.LastRunBytesFreed = bytesFreed
+
+	return s, write(path, s)
+}
+
+// write replaces the stats file atomically so a crash never leaves it truncated
+func write(path string, s Stats) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".stats-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,105 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_Accumulates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "stats.json")
+
+	s, err := Load(path)
+	require.NoError(t, err)
+	assert.Zero(t, s)
+
+	first, err := Record(path, 2, 3000)
+	require.NoError(t, err)
+	second, err := Record(path, 1, 500)
+	require.NoError(t, err)
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, second.TotalBytesFreed, loaded.TotalBytesFreed)
+
+	assert.Equal(t, 2, loaded.Runs)
+	assert.Equal(t, 3, loaded.TotalDeleted)
+	assert.Equal(t, int64(3500), loaded.TotalBytesFreed)
+	assert.Equal(t, 1, loaded.LastRunDeleted)
+	assert.Equal(t, int64(500), loaded.LastRunBytesFreed)
+	assert.True(t, first.FirstRun.Equal(loaded.FirstRun), "first run time should be kept")
+}
+
+func TestRecord_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+
+	const runs = 20
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Record(path, 1, 100)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	s, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, runs, s.Runs, "no update should be lost")
+	assert.Equal(t, int64(runs*100), s.TotalBytesFreed)
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.jsonl")
+
+	runs, err := LoadHistory(path)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 12, 0, 0, 0, time.Local)
+	}
+	run := func(ts time.Time, paths ...PathTotal) Run {
+		r := Run{Timestamp: ts, Mode: "quarantine", Deleted: len(paths), Paths: paths}
+		for _, p := range paths {
+			r.BytesFreed += p.BytesFreed
+		}
+		return r
+	}
+	require.NoError(t, AppendRun(path, run(at(8, 3), PathTotal{"/p/a/node_modules", 500, 1}, PathTotal{"/p/b/target", 300, 1})))
+	require.NoError(t, AppendRun(path, run(at(9, 1), PathTotal{"/p/a/node_modules", 400, 1})))
+	require.NoError(t, AppendRun(path, run(at(9, 20), PathTotal{"/p/c/.venv", 200, 1})))
+
+	// A line cut short by a crash doesn't hide the others
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"timestamp":"2026-`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	runs, err = LoadHistory(path)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+
+	assert.Equal(t, []MonthTotal{
+		{Month: "2026-08", Runs: 1, Deleted: 2, BytesFreed: 800},
+		{Month: "2026-09", Runs: 2, Deleted: 2, BytesFreed: 600},
+	}, ByMonth(runs))
+
+	assert.Equal(t, []PathTotal{
+		{Path: "/p/a/node_modules", BytesFreed: 900, Cleans: 2},
+		{Path: "/p/b/target", BytesFreed: 300, Cleans: 1},
+	}, TopPaths(runs, 2))
+}
+
+func TestHistoryPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("/home/me/.cache/BuildBloatBuster", "history.jsonl"),
+		HistoryPath("/home/me/.cache/BuildBloatBuster/trash/"))
+}
@@ -0,0 +1,228 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/yehia2amer/BuildBloatBuster/internal/bytesize"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// keyMap defines the key bindings of the selection list
+type keyMap struct {
+	Up        key.Binding
+	Down      key.Binding
+	Toggle    key.Binding
+	ToggleAll key.Binding
+	Confirm   key.Binding
+	Quit      key.Binding
+}
+
+var keys = keyMap{
+	Up:        key.NewBinding(key.WithKeys("up", "k")),
+	Down:      key.NewBinding(key.WithKeys("down", "j")),
+	Toggle:    key.NewBinding(key.WithKeys(" ", "x")),
+	ToggleAll: key.NewBinding(key.WithKeys("a")),
+	Confirm:   key.NewBinding(key.WithKeys("enter")),
+	Quit:      key.NewBinding(key.WithKeys("q", "esc", "ctrl+c")),
+}
+
+// model is the bubbletea model for selecting candidates
+type model struct {
+	// title and sizeNote describe the action in the header, e.g.
+	// "Select directories to clean" and "to free"
+	title      string
+	sizeNote   string
+	candidates []scan.Candidate
+	selected   []bool
+	cursor     int
+	offset     int
+	height     int
+	confirmed  bool
+	done       bool
+}
+
+// newModel creates a selection model with every candidate selected
+func newModel(candidates []scan.Candidate) model {
+	selected := make([]bool, len(candidates))
+	for i := range selected {
+		selected[i] = true
+	}
+	return model{
+		title:      "Select directories to clean",
+		sizeNote:   "to free",
+		candidates: candidates,
+		selected:   selected,
+		height:     20,
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the header and footer lines
+		m.height = max(msg.Height-6, 1)
+		m.scrollToCursor()
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Quit):
+			m.done = true
+			return m, tea.Quit
+		case key.Matches(msg, keys.Confirm):
+			m.confirmed = true
+			m.done = true
+			return m, tea.Quit
+		case key.Matches(msg, keys.Up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case key.Matches(msg, keys.Down):
+			if m.cursor < len(m.candidates)-1 {
+				m.cursor++
+			}
+		case key.Matches(msg, keys.Toggle):
+			m.selected[m.cursor] = !m.selected[m.cursor]
+		case key.Matches(msg, keys.ToggleAll):
+			all := m.selectedCount() == len(m.candidates)
+			for i := range m.selected {
+				m.selected[i] = !all
+			}
+		}
+		m.scrollToCursor()
+	}
+
+	return m, nil
+}
+
+// scrollToCursor keeps the cursor inside the visible window
+func (m *model) scrollToCursor() {
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.height {
+		m.offset = m.cursor - m.height + 1
+	}
+}
+
+func (m model) View() string {
+	if m.done {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%d of %d selected, %s %s)\n\n",
+		m.title, m.selectedCount(), len(m.candidates), bytesize.Format(m.selectedSize()), m.sizeNote)
+
+	end := min(m.offset+m.height, len(m.candidates))
+	for i := m.offset; i < end; i++ {
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		check := "[ ]"
+		if m.selected[i] {
+			check = "[x]"
+		}
+		fmt.Fprintf(&b, "%s%s %8s  %s\n", cursor, check,
+			bytesize.Format(m.candidates[i].SizeBytes), m.candidates[i].Path)
+	}
+
+	b.WriteString("\nspace: toggle • a: toggle all • enter: confirm • q: cancel\n")
+	return b.String()
+}
+
+// selectedCount returns the number of selected candidates
+func (m model) selectedCount() int {
+	count := 0
+	for _, s := range m.selected {
+		if s {
+			count++
+		}
+	}
+	return count
+}
+
+// selectedSize returns the total size of the selected candidates
+func (m model) selectedSize() int64 {
+	var total int64
+	for i, s := range m.selected {
+		if s {
+			total += m.candidates[i].SizeBytes
+		}
+	}
+	return total
+}
+
+// selection returns the selected candidates in list order
+func (m model) selection() []scan.Candidate {
+	var selected []scan.Candidate
+	for _, i := range m.selectedIndexes() {
+		selected = append(selected, m.candidates[i])
+	}
+	return selected
+}
+
+// selectedIndexes returns the indexes of the selected candidates in list order
+func (m model) selectedIndexes() []int {
+	var indexes []int
+	for i, s := range m.selected {
+		if s {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// SelectCandidates opens an interactive list where the user can toggle individual
+// candidates. It returns the selected subset, and false if the user cancelled.
+func SelectCandidates(candidates []scan.Candidate) ([]scan.Candidate, bool, error) {
+	if len(candidates) == 0 {
+		return nil, true, nil
+	}
+
+	m, err := run(newModel(candidates))
+	if err != nil || !m.confirmed {
+		return nil, false, err
+	}
+	return m.selection(), true, nil
+}
+
+// SelectToRestore opens the same list for quarantined directories, each shown
+// by the path it would be restored to, with nothing selected initially. It
+// returns the indexes of the chosen candidates, and false if the user cancelled.
+func SelectToRestore(candidates []scan.Candidate) ([]int, bool, error) {
+	if len(candidates) == 0 {
+		return nil, true, nil
+	}
+
+	m, err := run(newRestoreModel(candidates))
+	if err != nil || !m.confirmed {
+		return nil, false, err
+	}
+	return m.selectedIndexes(), true, nil
+}
+
+// newRestoreModel creates a selection model for restoring, with nothing selected
+func newRestoreModel(candidates []scan.Candidate) model {
+	m := newModel(candidates)
+	m.title = "Select directories to restore"
+	m.sizeNote = "to restore"
+	clear(m.selected)
+	return m
+}
+
+// run shows the list until the user confirms or cancels
+func run(m model) (model, error) {
+	final, err := tea.NewProgram(m).Run()
+	if err != nil {
+		return model{}, fmt.Errorf("interactive selection failed: %w", err)
+	}
+	return final.(model), nil
+}
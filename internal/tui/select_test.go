@@ -0,0 +1,87 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+// press sends a key to the model and returns the updated model
+func press(t *testing.T, m model, k string) model {
+	t.Helper()
+	var msg tea.KeyMsg
+	switch k {
+	case "down":
+		msg = tea.KeyMsg{Type: tea.KeyDown}
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		msg = tea.KeyMsg{Type: tea.KeyEsc}
+	case " ":
+		msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+	default:
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	}
+	updated, _ := m.Update(msg)
+	return updated.(model)
+}
+
+func TestModel_Selection(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/p/a/node_modules", SizeBytes: 100},
+		{Path: "/p/b/target", SizeBytes: 200},
+		{Path: "/p/c/.venv", SizeBytes: 300},
+	}
+
+	m := newModel(candidates)
+	assert.Equal(t, 3, m.selectedCount(), "everything is selected initially")
+	assert.Equal(t, int64(600), m.selectedSize())
+
+	// Deselect the second candidate
+	m = press(t, m, "down")
+	m = press(t, m, " ")
+	assert.Equal(t, int64(400), m.selectedSize())
+
+	m = press(t, m, "enter")
+	assert.True(t, m.confirmed)
+	assert.Equal(t, []scan.Candidate{candidates[0], candidates[2]}, m.selection())
+}
+
+func TestModel_ToggleAllAndCancel(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/p/a/node_modules", SizeBytes: 100},
+		{Path: "/p/b/target", SizeBytes: 200},
+	}
+
+	m := newModel(candidates)
+	m = press(t, m, "a")
+	assert.Equal(t, 0, m.selectedCount())
+	m = press(t, m, "a")
+	assert.Equal(t, 2, m.selectedCount())
+
+	m = press(t, m, "esc")
+	assert.True(t, m.done)
+	assert.False(t, m.confirmed)
+}
+
+func TestRestoreModel_Selection(t *testing.T) {
+	candidates := []scan.Candidate{
+		{Path: "/p/a/node_modules", SizeBytes: 100},
+		{Path: "/p/b/target", SizeBytes: 200},
+		{Path: "/p/c/.venv", SizeBytes: 300},
+	}
+
+	m := newRestoreModel(candidates)
+	assert.Equal(t, 0, m.selectedCount(), "nothing is selected initially")
+	assert.Contains(t, m.View(), "Select directories to restore")
+
+	m = press(t, m, "down")
+	m = press(t, m, " ")
+	m = press(t, m, "down")
+	m = press(t, m, " ")
+	m = press(t, m, "enter")
+	assert.True(t, m.confirmed)
+	assert.Equal(t, []int{1, 2}, m.selectedIndexes())
+}
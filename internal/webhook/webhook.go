@@ -0,0 +1,115 @@
+// Package webhook posts the summary of a run to an HTTP endpoint, such as a
+// dashboard collecting the results of scheduled runs on a fleet of build
+// agents.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yehia2amer/BuildBloatBuster/internal/report"
+)
+
+// Report is the JSON body posted: the summary that --format json prints,
+// with the command that produced it, the host it ran on and when
+type Report struct {
+	report.Snapshot
+	Command   string    `json:"command"`
+	Hostname  string    `json:"hostname"`
+	Timestamp time.Time `json:"timestamp"`
+	// DryRun is set for a clean that only showed what it would delete
+	DryRun bool `json:"dryRun,omitempty"`
+	// DeleteMode is how a clean removed its candidates
+	DeleteMode string `json:"deleteMode,omitempty"`
+}
+
+// Options says where and how a Report is posted
+type Options struct {
+	URL    string
+	Header http.Header
+	// Timeout bounds each attempt (0 = no limit)
+	Timeout time.Duration
+	// Insecure skips verifying the server's TLS certificate
+	Insecure bool
+}
+
+// retryDelay is how long Post waits before its retry; tests shorten it
+var retryDelay = 2 * time.Second
+
+// statusError is returned for a response with a status other than 2xx
+type statusError struct {
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return "server answered " + e.status
+}
+
+// Post sends report as JSON to opts.URL. An attempt that fails to connect,
+// times out or gets a 5xx or 429 response is retried once, after a short
+// wait.
+func Post(ctx context.Context, opts Options, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	client := &http.Client{Timeout: opts.Timeout}
+	if opts.Insecure {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client.Transport = transport
+	}
+
+	err = post(ctx, client, opts, body)
+	if err == nil || !retryable(err) {
+		return err
+	}
+	select {
+	case <-time.After(retryDelay):
+	case <-ctx.Done():
+		return err
+	}
+	return post(ctx, client, opts, body)
+}
+
+// post makes a single attempt at posting body
+func post(ctx context.Context, client *http.Client, opts Options, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range opts.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused for the retry
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{status: resp.Status, code: resp.StatusCode}
+	}
+	return nil
+}
+
+// retryable reports whether an attempt that failed with err may succeed when
+// made again: the server was unreachable, slow or briefly overloaded
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= 500 || statusErr.code == http.StatusTooManyRequests
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yehia2amer/BuildBloatBuster/internal/report"
+	"github.com/yehia2amer/BuildBloatBuster/internal/scan"
+)
+
+func TestPost(t *testing.T) {
+	var got Report
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC)
+	sent := Report{
+		Snapshot:  report.NewSnapshot([]scan.Candidate{{Path: "/app/node_modules", SizeBytes: 2048}}),
+		Command:   "scan",
+		Hostname:  "agent-7",
+		Timestamp: now,
+	}
+	require.NoError(t, Post(context.Background(), Options{
+		URL:    server.URL,
+		Header: http.Header{"Authorization": {"Bearer secret"}},
+	}, sent))
+
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", header.Get("Authorization"))
+	assert.Equal(t, "agent-7", got.Hostname)
+	assert.Equal(t, "scan", got.Command)
+	assert.True(t, now.Equal(got.Timestamp))
+	assert.Equal(t, int64(2048), got.TotalSize)
+	require.Len(t, got.Candidates, 1)
+	assert.Equal(t, "/app/node_modules", got.Candidates[0].Path)
+}
+
+func TestPost_Retry(t *testing.T) {
+	originalDelay := retryDelay
+	t.Cleanup(func() { retryDelay = originalDelay })
+	retryDelay = 0
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int32
+		wantErr      string
+	}{
+		{"succeeds", []int{http.StatusOK}, 1, ""},
+		{"server error, then success", []int{http.StatusBadGateway, http.StatusNoContent}, 2, ""},
+		{"retried once only", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}, 2, "503"},
+		{"rate limited", []int{http.StatusTooManyRequests, http.StatusOK}, 2, ""},
+		{"client errors are not retried", []int{http.StatusUnauthorized}, 1, "401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer server.Close()
+
+			err := Post(context.Background(), Options{URL: server.URL}, Report{Command: "clean"})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantAttempts, attempts.Load())
+		})
+	}
+}
+
+func TestPost_Timeout(t *testing.T) {
+	originalDelay := retryDelay
+	t.Cleanup(func() { retryDelay = originalDelay })
+	retryDelay = 0
+
+	var attempts atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	err := Post(context.Background(), Options{URL: server.URL, Timeout: 50 * time.Millisecond}, Report{})
+	assert.ErrorContains(t, err, "Timeout")
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestPost_Insecure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The test server's certificate is self-signed
+	originalDelay := retryDelay
+	t.Cleanup(func() { retryDelay = originalDelay })
+	retryDelay = 0
+	assert.ErrorContains(t, Post(context.Background(), Options{URL: server.URL}, Report{}), "certificate")
+	assert.NoError(t, Post(context.Background(), Options{URL: server.URL, Insecure: true}, Report{}))
+}